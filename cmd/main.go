@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/mrsubudei/adv-store-service/internal/app"
 	"github.com/mrsubudei/adv-store-service/internal/config"
@@ -12,5 +13,12 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := app.Migrate(cfg, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	app.Run(cfg)
 }
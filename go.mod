@@ -2,4 +2,8 @@ module github.com/mrsubudei/adv-store-service
 
 go 1.18
 
-require github.com/mattn/go-sqlite3 v1.14.16
+require (
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.16
+	golang.org/x/crypto v0.17.0
+)
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -8,42 +9,52 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/mrsubudei/adv-store-service/internal/config"
 	v1 "github.com/mrsubudei/adv-store-service/internal/controller/http/v1"
-	"github.com/mrsubudei/adv-store-service/internal/repository/sqlite"
 	"github.com/mrsubudei/adv-store-service/internal/service"
 
 	"github.com/mrsubudei/adv-store-service/pkg/httpserver"
 	"github.com/mrsubudei/adv-store-service/pkg/logger"
-	"github.com/mrsubudei/adv-store-service/pkg/sqlite3"
 )
 
+const dbPath = "database/adverts.db"
+
 func Run(cfg config.Config) {
 	// Logger
 	l := logger.New()
 
-	// Sqlite
-	sq, err := sqlite3.New("database/adverts.db")
+	// Database
+	db, err := openDatabase(cfg)
 	if err != nil {
-		l.WriteLog(fmt.Errorf("app - Run - sqlite3.New: %w", err))
+		l.WriteLog(fmt.Errorf("app - Run - %w", err))
 		return
 	}
-	defer sq.Close()
+	defer db.close()
 
-	// Repository
-	repo := sqlite.NewAdvertsRepo(sq)
-	err = sqlite.CreateDB(sq)
+	// Schema is only checked, migrations are applied by 'migrate' subcommand
+	err = db.check(context.Background())
 	if err != nil {
-		l.WriteLog(fmt.Errorf("app - Run - NewRepositories: %w", err))
+		l.WriteLog(fmt.Errorf("app - Run - check: %w", err))
+		log.Printf("database schema is not up to date, run 'migrate up': %s\n", err.Error())
 		return
 	}
 
 	// Service
-	service := service.NewAdvertService(repo)
+	service := service.NewAdvertService(db.repo, db.categories, db.users, db.keys,
+		db.roles, db.audit, db.idempotency)
+
+	// Trash purge
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go purgeTrash(ctx, service, cfg, l)
+	go purgeIdempotencyKeys(ctx, service, l)
 
 	// Http
 	handler := v1.NewHandler(service, cfg, l)
+	go handler.Limiter.Run(ctx,
+		time.Duration(cfg.RateLimit.EvictIntervalMinutes)*time.Minute)
 	server := httpserver.NewServer(handler)
 
 	go func() {
@@ -63,3 +74,45 @@ func Run(cfg config.Config) {
 		l.WriteLog(fmt.Errorf("app - Run - httpServer.Shutdown: %w", err))
 	}
 }
+
+// purgeTrash periodically removes adverts which are in trash longer than retention period
+func purgeTrash(ctx context.Context, s service.Service, cfg config.Config, l *logger.Logger) {
+	retention := time.Duration(cfg.Trash.RetentionDays) * 24 * time.Hour
+	interval := time.Duration(cfg.Trash.PurgeIntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, err := s.PurgeTrash(ctx, retention)
+		if err != nil {
+			l.WriteLog(fmt.Errorf("app - purgeTrash - PurgeTrash: %w", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeIdempotencyKeys periodically removes responses to idempotent requests which expired
+func purgeIdempotencyKeys(ctx context.Context, s service.Service, l *logger.Logger) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		_, err := s.PurgeIdempotencyKeys(ctx)
+		if err != nil {
+			l.WriteLog(fmt.Errorf("app - purgeIdempotencyKeys - PurgeIdempotencyKeys: %w", err))
+		}
+	}
+}
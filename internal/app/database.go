@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/mrsubudei/adv-store-service/internal/config"
+	"github.com/mrsubudei/adv-store-service/internal/repository"
+	"github.com/mrsubudei/adv-store-service/internal/repository/memory"
+	"github.com/mrsubudei/adv-store-service/internal/repository/postgres"
+	"github.com/mrsubudei/adv-store-service/internal/repository/sqlite"
+	"github.com/mrsubudei/adv-store-service/pkg/migrate"
+	pg "github.com/mrsubudei/adv-store-service/pkg/postgres"
+	"github.com/mrsubudei/adv-store-service/pkg/sqlite3"
+)
+
+const (
+	DriverSqlite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
+)
+
+var errNoMigrations = errors.New("database driver has no migrations")
+
+// database is repository chosen by config along with its schema migrations,
+// migrator is nil for databases without schema
+type database struct {
+	repo        repository.Advert
+	categories  repository.Category
+	users       repository.User
+	keys        repository.ApiKey
+	roles       repository.Role
+	audit       repository.Audit
+	idempotency repository.Idempotency
+	migrator    *migrate.Migrator
+	// migrate brings schema up to date
+	migrate func(ctx context.Context) error
+	// check fails if schema is not up to date, it is done on start
+	check func(ctx context.Context) error
+	close func()
+}
+
+func openDatabase(cfg config.Config) (database, error) {
+	switch cfg.Database.Driver {
+	case DriverSqlite, "":
+		path := cfg.Database.Path
+		if path == "" {
+			path = dbPath
+		}
+		sq, err := sqlite3.New(path)
+		if err != nil {
+			return database{}, fmt.Errorf("openDatabase - sqlite3.New: %w", err)
+		}
+		m, err := sqlite.NewMigrator(sq)
+		if err != nil {
+			sq.Close()
+			return database{}, fmt.Errorf("openDatabase - %w", err)
+		}
+		return database{
+			repo:        sqlite.NewAdvertsRepo(sq),
+			categories:  sqlite.NewCategoriesRepo(sq),
+			users:       sqlite.NewUsersRepo(sq),
+			keys:        sqlite.NewApiKeysRepo(sq),
+			roles:       sqlite.NewRolesRepo(sq),
+			audit:       sqlite.NewAuditRepo(sq),
+			idempotency: sqlite.NewIdempotencyRepo(sq),
+			migrator:    m,
+			migrate: func(ctx context.Context) error {
+				return sqlite.Migrate(ctx, sq)
+			},
+			check: func(ctx context.Context) error {
+				if err := m.Check(ctx); err != nil {
+					return err
+				}
+				indexed, err := sqlite.SyncSearch(ctx, sq)
+				if err != nil {
+					return err
+				}
+				if !indexed {
+					log.Println("sqlite is built without FTS5, adverts are searched without index")
+				}
+				return nil
+			},
+			close: sq.Close,
+		}, nil
+	case DriverPostgres:
+		p, err := pg.New(cfg.Database.Dsn)
+		if err != nil {
+			return database{}, fmt.Errorf("openDatabase - postgres.New: %w", err)
+		}
+		m, err := postgres.NewMigrator(p)
+		if err != nil {
+			p.Close()
+			return database{}, fmt.Errorf("openDatabase - %w", err)
+		}
+		return database{
+			repo:        postgres.NewAdvertsRepo(p),
+			categories:  postgres.NewCategoriesRepo(p),
+			users:       postgres.NewUsersRepo(p),
+			keys:        postgres.NewApiKeysRepo(p),
+			roles:       postgres.NewRolesRepo(p),
+			audit:       postgres.NewAuditRepo(p),
+			idempotency: postgres.NewIdempotencyRepo(p),
+			migrator:    m,
+			migrate: func(ctx context.Context) error {
+				return postgres.Migrate(ctx, p)
+			},
+			check: m.Check,
+			close: p.Close,
+		}, nil
+	case DriverMemory:
+		repo := memory.NewAdvertsRepo()
+		return database{
+			repo:        repo,
+			categories:  memory.NewCategoriesRepo(repo),
+			users:       memory.NewUsersRepo(),
+			keys:        memory.NewApiKeysRepo(),
+			roles:       memory.NewRolesRepo(),
+			audit:       memory.NewAuditRepo(),
+			idempotency: memory.NewIdempotencyRepo(),
+			migrate: func(ctx context.Context) error {
+				return nil
+			},
+			check: func(ctx context.Context) error {
+				return nil
+			},
+			close: func() {},
+		}, nil
+	}
+
+	return database{}, fmt.Errorf("openDatabase - unknown driver: %v", cfg.Database.Driver)
+}
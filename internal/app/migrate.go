@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/mrsubudei/adv-store-service/internal/config"
+	"github.com/mrsubudei/adv-store-service/pkg/migrate"
+)
+
+var errMigrateUsage = errors.New("usage: migrate up|down|status|to N")
+
+// Migrate runs migrate subcommand with given arguments
+func Migrate(cfg config.Config, args []string) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return fmt.Errorf("app - Migrate - %w", err)
+	}
+	defer db.close()
+
+	m := db.migrator
+	if m == nil {
+		return fmt.Errorf("app - Migrate - %v: %w", cfg.Database.Driver, errNoMigrations)
+	}
+	ctx := context.Background()
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		err = db.migrate(ctx)
+	case args[0] == "down" && len(args) == 1:
+		err = m.Down(ctx)
+	case args[0] == "to" && len(args) == 2:
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			return errMigrateUsage
+		}
+		err = m.To(ctx, version)
+	case args[0] == "status" && len(args) == 1:
+		return printStatus(ctx, m)
+	default:
+		return errMigrateUsage
+	}
+	if err != nil {
+		return fmt.Errorf("app - Migrate - %w", err)
+	}
+
+	return printStatus(ctx, m)
+}
+
+func printStatus(ctx context.Context, m *migrate.Migrator) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("app - printStatus - %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if !status.AppliedAt.IsZero() {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%04d\t%v\t%v\n", status.Version, status.Name, appliedAt)
+	}
+	return w.Flush()
+}
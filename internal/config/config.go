@@ -14,6 +14,39 @@ type Config struct {
 		WriteTimeout    int    `json:"write_timeout"`
 		ShutDownTimeout int    `json:"shutdown_timeout"`
 	} `json:"server"`
+	Database struct {
+		Driver string `json:"driver"`
+		Path   string `json:"path"`
+		Dsn    string `json:"dsn"`
+	} `json:"database"`
+	Trash struct {
+		RetentionDays        int `json:"retention_days"`
+		PurgeIntervalMinutes int `json:"purge_interval_minutes"`
+	} `json:"trash"`
+	Auth struct {
+		SessionTtlHours int `json:"session_ttl_hours"`
+	} `json:"auth"`
+	Idempotency struct {
+		TtlHours int `json:"ttl_hours"`
+	} `json:"idempotency"`
+	RateLimit struct {
+		// Routes holds quotas by route written as 'METHOD /pattern' or
+		// just '/pattern' for all methods, routes without quota are not limited
+		Routes               map[string]RateLimitRule `json:"routes"`
+		EvictIntervalMinutes int                      `json:"evict_interval_minutes"`
+	} `json:"rate_limit"`
+	Timeout struct {
+		// Routes holds deadlines in milliseconds by route written like
+		// routes of rate limit, other routes get default deadline if it is set
+		Routes              map[string]int `json:"routes"`
+		DefaultMilliseconds int            `json:"default_milliseconds"`
+	} `json:"timeout"`
+}
+
+// RateLimitRule is token bucket of limit requests refilled in full during window
+type RateLimitRule struct {
+	Limit         int `json:"limit"`
+	WindowSeconds int `json:"window_seconds"`
 }
 
 func LoadConfig(filename string) (Config, error) {
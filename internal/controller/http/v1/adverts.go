@@ -1,37 +1,50 @@
 package v1
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/mrsubudei/adv-store-service/internal/entity"
 )
 
 func (h *Handler) CreateAdvert(w http.ResponseWriter, r *http.Request) {
 	var adv entity.Advert
-	err := h.parseJson(w, r, &adv)
+	fields, err := h.parseJson(w, r, &adv)
 	if err != nil {
 		h.l.WriteLog(fmt.Errorf("v1 - CreateAdvert - parseJson: %w", err))
 		return
 	}
 
-	if errAns := h.checkData(adv); errAns.Error != "" {
+	if errAns := h.checkData(adv, fields); errAns.Error != "" {
 		h.writeResponse(w, errAns)
 		return
 	}
 
 	id, err := h.Service.Create(r.Context(), adv)
 	if err != nil {
+		var attrErr *entity.AttributesError
 		if errors.Is(err, entity.ErrNameAlreadyExist) {
 			h.l.WriteLog(fmt.Errorf("v1 - CreateAdvert - h.Service.Create: %w", err))
 			h.writeResponse(w, ErrMessage{code: http.StatusConflict,
 				Error: fmt.Sprintf(ItemNameExists, adv.Name)})
 			return
+		} else if errors.Is(err, entity.ErrWrongCategory) {
+			h.l.WriteLog(fmt.Errorf("v1 - CreateAdvert - h.Service.Create: %w", err))
+			h.writeResponse(w, ErrMessage{code: http.StatusBadRequest,
+				Error: fmt.Sprintf(WrongCategory, adv.CategoryId)})
+			return
+		} else if errors.As(err, &attrErr) {
+			h.l.WriteLog(fmt.Errorf("v1 - CreateAdvert - h.Service.Create: %w", err))
+			h.writeResponse(w, ErrMessage{code: http.StatusBadRequest,
+				Error: WrongAttributes, Detail: strings.Join(attrErr.Violations, "; ")})
+			return
 		}
-		h.l.WriteLog(fmt.Errorf("v1 - CreateAdvert - h.Service.Create: %w", err))
-		h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+		h.writeAccessError(w, fmt.Errorf("v1 - CreateAdvert - h.Service.Create: %w", err))
 		return
 	}
 
@@ -43,32 +56,125 @@ func (h *Handler) CreateAdvert(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) GetAllAdverts(w http.ResponseWriter, r *http.Request) {
-	advs, err := h.Service.GetAll(r.Context())
+	page, err := h.Service.GetAll(r.Context())
 	if err != nil {
 		if errors.Is(err, entity.ErrNoItems) {
 			h.l.WriteLog(fmt.Errorf("v1 - GetAllAdverts - h.Service.GetAll: %w", err))
-			h.writeResponse(w, Response{code: http.StatusOK, Data: []entity.Advert{}})
+			h.writePage(w, r, page)
 			return
 		}
-		h.l.WriteLog(fmt.Errorf("v1 - CreateAdvert - h.Service.Create: %w", err))
+		h.l.WriteLog(fmt.Errorf("v1 - GetAllAdverts - h.Service.GetAll: %w", err))
 		h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
 		return
 	}
 
-	maxPage := advs[0].MaxCount
-	meta := &MetaData{MaxPage: maxPage}
-	ans := Response{
-		Data: advs,
+	h.writePage(w, r, page)
+}
+
+func (h *Handler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	page, err := h.Service.GetTrash(r.Context())
+	if err != nil {
+		if errors.Is(err, entity.ErrNoItems) {
+			h.l.WriteLog(fmt.Errorf("v1 - GetTrash - h.Service.GetTrash: %w", err))
+			h.writePage(w, r, page)
+			return
+		}
+		h.writeAccessError(w, fmt.Errorf("v1 - GetTrash - h.Service.GetTrash: %w", err))
+		return
+	}
+
+	h.writePage(w, r, page)
+}
+
+// writePage responds with page of adverts and its metadata,
+// empty page has the same shape with empty data
+func (h *Handler) writePage(w http.ResponseWriter, r *http.Request, page entity.AdvertsPage) {
+	meta := &MetaData{
+		TotalCount: page.TotalCount,
+		Page:       page.Page,
+		PageSize:   page.PageSize,
+		HasNext:    page.HasNext,
+	}
+	if page.PageSize != 0 {
+		meta.MaxPage = (page.TotalCount + int64(page.PageSize) - 1) / int64(page.PageSize)
+	}
+
+	// results ranked by relevance can not be continued by cursor
+	order, sorted := r.Context().Value(entity.KeySort).(entity.Sort)
+	_, search := r.Context().Value(entity.KeyQuery).(string)
+	if page.HasNext && (!search || sorted) {
+		cursor, err := encodeCursor(page.Adverts[len(page.Adverts)-1], order)
+		if err != nil {
+			h.l.WriteLog(fmt.Errorf("v1 - writePage - encodeCursor: %w", err))
+			h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+			return
+		}
+		meta.NextCursor = cursor
+	}
+	meta.Links = pageLinks(r, page, meta.NextCursor)
+
+	if fields, ok := r.Context().Value(entity.KeyFields).(entity.Fields); ok {
+		ans := FieldsResponse{Data: []map[string]interface{}{}, code: http.StatusOK, Meta: meta}
+		for _, adv := range page.Adverts {
+			ans.Data = append(ans.Data, fields.Select(adv))
+		}
+		h.writeResponse(w, ans)
+		return
+	}
+
+	ans := PageResponse{
+		Data: page.Adverts,
 		code: http.StatusOK,
 		Meta: meta,
 	}
+	if ans.Data == nil {
+		ans.Data = []entity.Advert{}
+	}
 	h.writeResponse(w, ans)
 }
 
+// pageLinks builds links to current, next and previous pages from
+// request's query, pages taken by cursor can only be continued forward
+func pageLinks(r *http.Request, page entity.AdvertsPage, nextCursor string) *Links {
+	links := &Links{Self: r.URL.RequestURI()}
+	query := r.URL.Query()
+
+	if query.Get(QueryCursor) != "" {
+		if nextCursor != "" {
+			query.Set(QueryCursor, nextCursor)
+			links.Next = r.URL.Path + "?" + query.Encode()
+		}
+		return links
+	}
+
+	offset, _ := r.Context().Value(entity.KeyOffset).(int)
+	if page.HasNext {
+		query.Set(QueryOffset, strconv.Itoa(offset+page.PageSize))
+		links.Next = r.URL.Path + "?" + query.Encode()
+	}
+	if offset > 0 {
+		if prev := offset - page.PageSize; prev > 0 {
+			query.Set(QueryOffset, strconv.Itoa(prev))
+		} else {
+			query.Del(QueryOffset)
+		}
+		links.Prev = r.URL.Path + "?" + query.Encode()
+	}
+
+	return links
+}
+
 func (h *Handler) GetAdvert(w http.ResponseWriter, r *http.Request) {
 	id := r.Context().Value(entity.KeyId).(int64)
 
-	found, err := h.Service.GetById(r.Context(), id)
+	// only fields which are given are loaded
+	ctx := r.Context()
+	fields, selected := ctx.Value(entity.KeyFields).(entity.Fields)
+	if !selected && r.URL.Query().Get(QueryFields) != QueryValueTrue {
+		ctx = context.WithValue(ctx, entity.KeyFields, entity.DefaultFields)
+	}
+
+	found, err := h.Service.GetById(ctx, id)
 	if err != nil {
 		if errors.Is(err, entity.ErrItemNotExists) {
 			h.l.WriteLog(fmt.Errorf("v1 - GetAdvert - h.Service.GetById: %w", err))
@@ -76,14 +182,28 @@ func (h *Handler) GetAdvert(w http.ResponseWriter, r *http.Request) {
 				Error: NoContentFound + strconv.Itoa(int(id))})
 			return
 		}
-		h.l.WriteLog(fmt.Errorf("v1 - GetAdvert - h.Service.Create: %w", err))
+		h.l.WriteLog(fmt.Errorf("v1 - GetAdvert - h.Service.GetById: %w", err))
 		h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
 		return
 	}
 
+	etag := formatETag(found.Version)
+	w.Header().Set("ETag", etag)
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" &&
+		matchETag(ifNoneMatch, found.Version, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if selected {
+		h.writeResponse(w, FieldsResponse{code: http.StatusOK,
+			Data: []map[string]interface{}{fields.Select(found)}})
+		return
+	}
+
 	ans := Response{code: http.StatusOK}
 
-	if queryFields, ok := r.Context().Value(entity.KeyFields).(string); ok && queryFields != "" {
+	if r.URL.Query().Get(QueryFields) == QueryValueTrue {
 		ans.Data = []entity.Advert{found}
 	} else {
 		partialAdv := entity.Advert{Name: found.Name, Price: found.Price,
@@ -94,31 +214,170 @@ func (h *Handler) GetAdvert(w http.ResponseWriter, r *http.Request) {
 	h.writeResponse(w, ans)
 }
 
+// GetAdvertsByIds responds with result for every id of 'ids=' query in
+// order of ids, adverts which can not be given are reported by their items
+func (h *Handler) GetAdvertsByIds(w http.ResponseWriter, r *http.Request) {
+	ids := r.Context().Value(entity.KeyIds).([]int64)
+
+	// only fields which are given are loaded
+	ctx := r.Context()
+	fields, selected := ctx.Value(entity.KeyFields).(entity.Fields)
+	all := r.URL.Query().Get(QueryFields) == QueryValueTrue
+	if !selected && !all {
+		ctx = context.WithValue(ctx, entity.KeyFields, entity.DefaultFields)
+	}
+
+	results, err := h.Service.GetByIds(ctx, ids)
+	if err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - GetAdvertsByIds - h.Service.GetByIds: %w", err))
+		h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+		return
+	}
+
+	ans := BatchResponse{Data: []BatchItem{}, code: http.StatusOK}
+	for _, res := range results {
+		item := BatchItem{Id: res.Id}
+		switch {
+		case res.Err != nil:
+			item.Error = NoContentFound + strconv.FormatInt(res.Id, 10)
+		case selected:
+			item.Advert = fields.Select(res.Advert)
+		case all:
+			item.Advert = res.Advert
+		default:
+			item.Advert = entity.Advert{Name: res.Advert.Name, Price: res.Advert.Price,
+				MainPhotoUrl: res.Advert.MainPhotoUrl}
+		}
+		ans.Data = append(ans.Data, item)
+	}
+
+	h.writeResponse(w, ans)
+}
+
 func (h *Handler) UpdateAdvert(w http.ResponseWriter, r *http.Request) {
 	var adv entity.Advert
-	err := h.parseJson(w, r, &adv)
+	fields, err := h.parseJson(w, r, &adv)
 	if err != nil {
 		h.l.WriteLog(fmt.Errorf("v1 - UpdateAdvert - parseJson: %w", err))
 		return
 	}
 
+	if errAns := h.checkData(adv, fields); errAns.Error != "" {
+		h.writeResponse(w, errAns)
+		return
+	}
+
 	id := r.Context().Value(entity.KeyId).(int64)
 	adv.Id = id
 
-	err = h.Service.Update(r.Context(), adv)
+	adv.Version, err = h.expectedVersion(r.Context(), r.Header.Get("If-Match"), id)
+	if err != nil {
+		h.writeVersionError(w, fmt.Errorf("v1 - UpdateAdvert - %w", err), id)
+		return
+	}
+
+	h.updateAdvert(w, r, adv)
+}
+
+func (h *Handler) PatchAdvert(w http.ResponseWriter, r *http.Request) {
+	id := r.Context().Value(entity.KeyId).(int64)
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - PatchAdvert - ReadAll: %w", err))
+		h.writeResponse(w, ErrMessage{code: http.StatusBadRequest, Error: PatchNotCorrect})
+		return
+	}
+
+	found, err := h.Service.GetById(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, entity.ErrItemNotExists) {
-			h.l.WriteLog(fmt.Errorf("v1 - UpdateAdvert - h.Service.Update #1: %w", err))
+			h.l.WriteLog(fmt.Errorf("v1 - PatchAdvert - h.Service.GetById: %w", err))
 			h.writeResponse(w, ErrMessage{code: http.StatusNotFound,
 				Error: NoContentFound + strconv.Itoa(int(id))})
 			return
+		}
+		h.l.WriteLog(fmt.Errorf("v1 - PatchAdvert - h.Service.GetById: %w", err))
+		h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+		return
+	}
+
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" &&
+		!matchETag(ifMatch, found.Version, false) {
+		h.writeVersionError(w, fmt.Errorf("v1 - PatchAdvert - %v: %w",
+			ifMatch, entity.ErrVersionMismatch), id)
+		return
+	}
+
+	// advert is updated only if it was not changed since it was patched
+	adv, fields, err := applyPatch(found, r.Header.Get("Content-Type"), patch)
+	if err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - PatchAdvert - applyPatch: %w", err))
+		switch {
+		case errors.Is(err, errPatchType):
+			h.writeResponse(w, ErrMessage{code: http.StatusUnsupportedMediaType,
+				Error: PatchTypeWrong})
+		case errors.Is(err, errPatchTestFailed):
+			h.writeResponse(w, ErrMessage{code: http.StatusConflict,
+				Error: PatchTestFailed})
+		case errors.Is(err, errPatchNotCorrect):
+			h.writeResponse(w, ErrMessage{code: http.StatusBadRequest,
+				Error: PatchNotCorrect})
+		default:
+			h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+		}
+		return
+	}
+
+	if errAns := h.checkData(adv, fields); errAns.Error != "" {
+		h.writeResponse(w, errAns)
+		return
+	}
+
+	adv.Id = id
+
+	h.updateAdvert(w, r, adv)
+}
+
+// updateAdvert replaces advert with validated one
+func (h *Handler) updateAdvert(w http.ResponseWriter, r *http.Request, adv entity.Advert) {
+	err := h.Service.Update(r.Context(), adv)
+	if err != nil {
+		var attrErr *entity.AttributesError
+		if errors.Is(err, entity.ErrVersionMismatch) && r.Header.Get("If-Match") == "" {
+			h.l.WriteLog(fmt.Errorf("v1 - updateAdvert - h.Service.Update: %w", err))
+			h.writeResponse(w, ErrMessage{code: http.StatusConflict,
+				Error: ConcurrentUpdate})
+			return
+		} else if errors.Is(err, entity.ErrVersionMismatch) {
+			h.writeVersionError(w, fmt.Errorf("v1 - updateAdvert - h.Service.Update: %w",
+				err), adv.Id)
+			return
+		} else if errors.Is(err, entity.ErrItemNotExists) {
+			h.l.WriteLog(fmt.Errorf("v1 - updateAdvert - h.Service.Update #1: %w", err))
+			h.writeResponse(w, ErrMessage{code: http.StatusNotFound,
+				Error: NoContentFound + strconv.Itoa(int(adv.Id))})
+			return
 		} else if errors.Is(err, entity.ErrNameAlreadyExist) {
-			h.l.WriteLog(fmt.Errorf("v1 - UpdateAdvert - h.Service.Update #2: %w", err))
+			h.l.WriteLog(fmt.Errorf("v1 - updateAdvert - h.Service.Update #2: %w", err))
 			h.writeResponse(w, ErrMessage{code: http.StatusConflict,
 				Error: fmt.Sprintf(ItemNameExists, adv.Name)})
 			return
+		} else if errors.Is(err, entity.ErrWrongCategory) {
+			h.l.WriteLog(fmt.Errorf("v1 - updateAdvert - h.Service.Update #3: %w", err))
+			h.writeResponse(w, ErrMessage{code: http.StatusBadRequest,
+				Error: fmt.Sprintf(WrongCategory, adv.CategoryId)})
+			return
+		} else if errors.As(err, &attrErr) {
+			h.l.WriteLog(fmt.Errorf("v1 - updateAdvert - h.Service.Update #4: %w", err))
+			h.writeResponse(w, ErrMessage{code: http.StatusBadRequest,
+				Error: WrongAttributes, Detail: strings.Join(attrErr.Violations, "; ")})
+			return
+		} else if errors.Is(err, entity.ErrUnauthenticated) || errors.Is(err, entity.ErrForbidden) {
+			h.writeAccessError(w, fmt.Errorf("v1 - updateAdvert - h.Service.Update: %w", err))
+			return
 		}
-		h.l.WriteLog(fmt.Errorf("v1 - UpdateAdvert - h.Service.Create: %w", err))
+		h.l.WriteLog(fmt.Errorf("v1 - updateAdvert - h.Service.Update: %w", err))
 		h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
 		return
 	}
@@ -132,15 +391,28 @@ func (h *Handler) UpdateAdvert(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) DeleteAdvert(w http.ResponseWriter, r *http.Request) {
 	id := r.Context().Value(entity.KeyId).(int64)
 
-	err := h.Service.Delete(r.Context(), id)
+	version, err := h.expectedVersion(r.Context(), r.Header.Get("If-Match"), id)
 	if err != nil {
-		if errors.Is(err, entity.ErrItemNotExists) {
+		h.writeVersionError(w, fmt.Errorf("v1 - DeleteAdvert - %w", err), id)
+		return
+	}
+
+	err = h.Service.Delete(r.Context(), id, version)
+	if err != nil {
+		if errors.Is(err, entity.ErrVersionMismatch) {
+			h.writeVersionError(w, fmt.Errorf("v1 - DeleteAdvert - h.Service.Delete: %w",
+				err), id)
+			return
+		} else if errors.Is(err, entity.ErrItemNotExists) {
 			h.l.WriteLog(fmt.Errorf("v1 - DeleteAdvert - h.Service.Delete: %w", err))
 			h.writeResponse(w, ErrMessage{code: http.StatusNotFound,
 				Error: NoContentFound + strconv.Itoa(int(id))})
 			return
+		} else if errors.Is(err, entity.ErrUnauthenticated) || errors.Is(err, entity.ErrForbidden) {
+			h.writeAccessError(w, fmt.Errorf("v1 - DeleteAdvert - h.Service.Delete: %w", err))
+			return
 		}
-		h.l.WriteLog(fmt.Errorf("v1 - DeleteAdvert - h.Service.Create: %w", err))
+		h.l.WriteLog(fmt.Errorf("v1 - DeleteAdvert - h.Service.Delete: %w", err))
 		h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
 		return
 	}
@@ -150,3 +422,53 @@ func (h *Handler) DeleteAdvert(w http.ResponseWriter, r *http.Request) {
 	}
 	h.writeResponse(w, ans)
 }
+
+func (h *Handler) RestoreAdvert(w http.ResponseWriter, r *http.Request) {
+	id := r.Context().Value(entity.KeyId).(int64)
+
+	err := h.Service.Restore(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, entity.ErrItemNotExists) {
+			h.l.WriteLog(fmt.Errorf("v1 - RestoreAdvert - h.Service.Restore: %w", err))
+			h.writeResponse(w, ErrMessage{code: http.StatusNotFound,
+				Error: NoContentFound + strconv.Itoa(int(id))})
+			return
+		}
+		h.writeAccessError(w, fmt.Errorf("v1 - RestoreAdvert - h.Service.Restore: %w", err))
+		return
+	}
+
+	ans := Response{
+		Data: []entity.Advert{{Id: id}},
+		code: http.StatusOK,
+	}
+	h.writeResponse(w, ans)
+}
+
+// writeVersionError responds to failed If-Match precondition
+func (h *Handler) writeVersionError(w http.ResponseWriter, err error, id int64) {
+	h.l.WriteLog(err)
+	switch {
+	case errors.Is(err, entity.ErrVersionMismatch):
+		h.writeResponse(w, ErrMessage{code: http.StatusPreconditionFailed,
+			Error: VersionMismatch})
+	case errors.Is(err, entity.ErrItemNotExists):
+		h.writeResponse(w, ErrMessage{code: http.StatusNotFound,
+			Error: NoContentFound + strconv.Itoa(int(id))})
+	default:
+		h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+	}
+}
+
+// writeAccessError responds to action denied by service's policy
+func (h *Handler) writeAccessError(w http.ResponseWriter, err error) {
+	h.l.WriteLog(err)
+	switch {
+	case errors.Is(err, entity.ErrUnauthenticated):
+		h.writeUnauthenticated(w)
+	case errors.Is(err, entity.ErrForbidden):
+		h.writeResponse(w, ErrMessage{code: http.StatusForbidden, Error: AdvertForbidden})
+	default:
+		h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+	}
+}
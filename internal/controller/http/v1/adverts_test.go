@@ -5,11 +5,14 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 
 	"encoding/json"
 
+	v1 "github.com/mrsubudei/adv-store-service/internal/controller/http/v1"
 	"github.com/mrsubudei/adv-store-service/internal/entity"
+	mock "github.com/mrsubudei/adv-store-service/internal/service/mock"
 )
 
 var (
@@ -137,18 +140,35 @@ func TestCreateAdvert(t *testing.T) {
 			}
 		})
 	}
+
+	// policy of service denies create to API key without write scope
+	t.Run("Error create denied", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), entity.KeyIdentity,
+			entity.Identity{UserId: 1, ApiKeyId: 1, Scopes: []string{entity.ScopeAdvertsRead}})
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/adverts", bytes.NewReader([]byte(
+			`{"name":"bike","description":"asd","price":40,"photo_urls":["http://files.com/12"]}`)))
+		handler.CreateAdvert(rec, req.WithContext(ctx))
+
+		wantResult := `{"error":"only owner of advert or admin can change it"}`
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("want: %v, got: %v", http.StatusForbidden, rec.Code)
+		} else if rec.Body.String() != wantResult {
+			t.Fatalf("want: %v, got: %v", wantResult, rec.Body.String())
+		}
+	})
 }
 
 func TestGetAllAdverts(t *testing.T) {
 	handler := setup()
 	ctx := context.Background()
 
-	t.Run("OK status no content", func(t *testing.T) {
+	t.Run("OK empty page", func(t *testing.T) {
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodGet, "/v1/adverts", nil)
 		handler.Mux.ServeHTTP(rec, req)
 
-		wantResult := `{}`
+		wantResult := `{"meta_data":{"total_count":0,"page":1,"page_size":10,"has_next":false,"links":{"self":"/v1/adverts"}},"data":[]}`
 
 		if rec.Code != http.StatusOK {
 			t.Fatalf("want: %v, got: %v", http.StatusOK, rec.Code)
@@ -173,6 +193,91 @@ func TestGetAllAdverts(t *testing.T) {
 			t.Fatalf("want: %v, got: %v", http.StatusAccepted, rec.Code)
 		}
 	})
+	t.Run("OK with listed fields", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v1/adverts?fields=name,price", nil)
+		handler.Mux.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("want: %v, got: %v", http.StatusOK, rec.Code)
+		}
+		var ans struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &ans); err != nil {
+			t.Fatal(err)
+		}
+		if len(ans.Data) != 2 {
+			t.Fatalf("want 2 adverts, got: %v", rec.Body.String())
+		}
+		for _, adv := range ans.Data {
+			if len(adv) != 3 || adv["id"] == nil || adv["name"] == nil || adv["price"] == nil {
+				t.Fatalf("want only id, name and price, got: %v", adv)
+			}
+		}
+	})
+	t.Run("OK with next cursor", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v1/adverts?limit=1&sort_by=price", nil)
+		handler.Mux.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("want: %v, got: %v", http.StatusOK, rec.Code)
+		}
+
+		var ans struct {
+			Meta struct {
+				NextCursor string `json:"next_cursor"`
+			} `json:"meta_data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &ans); err != nil {
+			t.Fatal(err)
+		} else if ans.Meta.NextCursor == "" {
+			t.Fatalf("next_cursor expected: %v", rec.Body.String())
+		}
+
+		rec = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, "/v1/adverts?limit=1&cursor="+
+			ans.Meta.NextCursor, nil)
+		handler.Mux.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("want: %v, got: %v, body: %v", http.StatusOK, rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("OK pagination metadata", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v1/adverts?limit=1&offset=1", nil)
+		handler.Mux.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("want: %v, got: %v", http.StatusOK, rec.Code)
+		}
+
+		var ans struct {
+			Meta v1.MetaData `json:"meta_data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &ans); err != nil {
+			t.Fatal(err)
+		}
+		ans.Meta.NextCursor = ""
+		wantMeta := v1.MetaData{
+			MaxPage:    2,
+			TotalCount: 2,
+			Page:       1,
+			PageSize:   1,
+			HasNext:    true,
+			Links: &v1.Links{
+				Self: "/v1/adverts?limit=1&offset=1",
+				Next: "/v1/adverts?limit=1&offset=2",
+				Prev: "/v1/adverts?limit=1",
+			},
+		}
+		if !reflect.DeepEqual(wantMeta, ans.Meta) {
+			t.Fatalf("want: %+v, got: %+v", wantMeta, ans.Meta)
+		}
+	})
 }
 
 func TestGetAdvert(t *testing.T) {
@@ -203,6 +308,18 @@ func TestGetAdvert(t *testing.T) {
 			url:        "/v1/adverts/2?fields=true",
 			wantResult: `{"data":[{"name":"second item","description":"dgdrg","price":50,"main_photo_url":"http://files.com/14","photo_urls":["http://files.com/14","http://files.com/16"]}]}`,
 		},
+		{
+			name:       "OK with listed fields",
+			wantStatus: http.StatusOK,
+			url:        "/v1/adverts/2?fields=name,photo_urls,description",
+			wantResult: `{"data":[{"description":"dgdrg","id":2,"name":"second item","photo_urls":["http://files.com/14","http://files.com/16"]}]}`,
+		},
+		{
+			name:       "Error unknown field",
+			wantStatus: http.StatusBadRequest,
+			url:        "/v1/adverts/2?fields=name,color",
+			wantResult: `{"error":"queries have wrong value","detail":"'fields=' query value is not valid: unknown field 'color', allowed fields are 'attributes', 'category_id', 'created_at', 'description', 'id', 'main_photo_url', 'name', 'owner_id', 'photo_urls', 'price'"}`,
+		},
 		{
 			name:       "Error does not exist",
 			url:        "/v1/adverts/5",
@@ -226,11 +343,127 @@ func TestGetAdvert(t *testing.T) {
 	}
 }
 
+func TestGetAdvertsByIds(t *testing.T) {
+	handler := setup()
+	ctx := context.Background()
+	if _, err := handler.Service.Create(ctx, advert1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := handler.Service.Create(ctx, advert2); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+		wantResult string
+	}{
+		{
+			name:       "OK in order of ids",
+			url:        "/v1/adverts?ids=2,7,1",
+			wantStatus: http.StatusOK,
+			wantResult: `{"data":[{"id":2,"advert":{"name":"second item","price":50,"main_photo_url":"http://files.com/14"}},{"id":7,"error":"no content found with id: 7"},{"id":1,"advert":{"name":"first item","price":40,"main_photo_url":"http://files.com/12"}}]}`,
+		},
+		{
+			name:       "OK with listed fields",
+			url:        "/v1/adverts?ids=1&fields=name,photo_urls",
+			wantStatus: http.StatusOK,
+			wantResult: `{"data":[{"id":1,"advert":{"id":1,"name":"first item","photo_urls":["http://files.com/12","http://files.com/13"]}}]}`,
+		},
+		{
+			name:       "Error with paging",
+			url:        "/v1/adverts?ids=1,2&limit=1",
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'ids=' query can not be used with paging and sorting queries"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			handler.Mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want: %v, got: %v", tt.wantStatus, rec.Code)
+			} else if rec.Body.String() != tt.wantResult {
+				t.Fatalf("want: %v, got: %v", tt.wantResult, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestBatchAdverts(t *testing.T) {
+	handler := setup()
+	valid := `{"name":"first item","description":"asd","price":40,"photo_urls":["http://files.com/12"]}`
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantResult string
+	}{
+		{
+			name:       "OK best effort",
+			method:     http.MethodPost,
+			body:       `{"operations":[{"op":"create","advert":` + valid + `},{"op":"create","advert":{"name":"x"}},{"op":"update","id":7,"advert":` + valid + `},{"op":"delete","id":1,"version":1},{"op":"move","id":1}]}`,
+			wantStatus: http.StatusOK,
+			wantResult: `{"data":[{"status":201,"id":1},{"status":400,"error":"request has empty fields","detail":"'description:' field is required"},{"status":404,"error":"no content found with id: 7"},{"status":204,"id":1},{"status":400,"error":"wrong data format","detail":"'op:' field should be one of 'create', 'update' and 'delete'"}]}`,
+		},
+		{
+			name:       "OK atomic undone",
+			method:     http.MethodPost,
+			body:       `{"atomic":true,"operations":[{"op":"create","advert":{"name":"second item","description":"dgdrg","price":50,"photo_urls":["http://files.com/14"]}},{"op":"delete","id":1}]}`,
+			wantStatus: http.StatusOK,
+			wantResult: `{"data":[{"status":424,"error":"operation is not applied as other operation of atomic batch failed"},{"status":404,"error":"no content found with id: 1"}]}`,
+		},
+		{
+			name:       "Error no operations",
+			method:     http.MethodPost,
+			body:       `{"atomic":true,"operations":[]}`,
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"request has empty fields","detail":"'operations:' field should have at least 1 operation"}`,
+		},
+		{
+			name:       "Error wrong method",
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantResult: `{}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, "/v1/adverts:batch",
+				bytes.NewReader([]byte(tt.body)))
+			handler.Mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want: %v, got: %v", tt.wantStatus, rec.Code)
+			} else if rec.Body.String() != tt.wantResult {
+				t.Fatalf("want: %v, got: %v", tt.wantResult, rec.Body.String())
+			}
+		})
+	}
+
+	mockService := handler.Service.(*mock.MockService)
+	if len(mockService.Adverts) != 0 || len(mockService.Trash) != 1 {
+		t.Fatalf("want only created advert in trash, got: %+v, %+v", mockService.Adverts,
+			mockService.Trash)
+	}
+}
+
 func TestUpdateAdvert(t *testing.T) {
 	handler := setup()
 	if _, err := handler.Service.Create(context.Background(), advert1); err != nil {
 		t.Fatal(err)
 	}
+	if _, err := handler.Service.Create(context.Background(), advert2); err != nil {
+		t.Fatal(err)
+	}
 
 	tests := []struct {
 		name       string
@@ -243,22 +476,29 @@ func TestUpdateAdvert(t *testing.T) {
 			name:       "OK",
 			wantStatus: http.StatusOK,
 			url:        "/v1/adverts/1",
-			reqData:    `{"name":"new name","photo_urls":["http://files.com/12","http://files.com/13"]}`,
+			reqData:    `{"name":"new name","description":"","price":0,"photo_urls":["http://files.com/12","http://files.com/13"]}`,
 			wantResult: `{}`,
 		},
 		{
 			name:       "Error item does not exist",
 			wantStatus: http.StatusNotFound,
 			url:        "/v1/adverts/5",
-			reqData:    `{"name":"new name","photo_urls":["http://files.com/12","http://files.com/13"]}`,
+			reqData:    `{"name":"new name","description":"asd","price":40,"photo_urls":["http://files.com/12","http://files.com/13"]}`,
 			wantResult: `{"error":"no content found with id: 5"}`,
 		},
 		{
 			name:       "Error item with that name already exists",
 			wantStatus: http.StatusConflict,
 			url:        "/v1/adverts/1",
+			reqData:    `{"name":"second item","description":"asd","price":40,"photo_urls":["http://files.com/12","http://files.com/13"]}`,
+			wantResult: `{"error":"item with name 'second item' already exists"}`,
+		},
+		{
+			name:       "Error partial advert",
+			wantStatus: http.StatusBadRequest,
+			url:        "/v1/adverts/1",
 			reqData:    `{"name":"new name","photo_urls":["http://files.com/12","http://files.com/13"]}`,
-			wantResult: `{"error":"item with name 'new name' already exists"}`,
+			wantResult: `{"error":"request has empty fields","detail":"'description:' field is required"}`,
 		},
 	}
 
@@ -277,6 +517,138 @@ func TestUpdateAdvert(t *testing.T) {
 	}
 }
 
+func TestPatchAdvert(t *testing.T) {
+	handler := setup()
+	if _, err := handler.Service.Create(context.Background(), advert1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := handler.Service.Create(context.Background(), advert2); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		reqData     string
+		url         string
+		wantStatus  int
+		wantResult  string
+		wantAdvert  string
+	}{
+		{
+			name:        "OK merge patch",
+			contentType: "application/merge-patch+json",
+			url:         "/v1/adverts/1",
+			reqData:     `{"description":"","price":0}`,
+			wantStatus:  http.StatusOK,
+			wantResult:  `{}`,
+			wantAdvert:  `{"data":[{"name":"first item","main_photo_url":"http://files.com/12","photo_urls":["http://files.com/12","http://files.com/13"]}]}`,
+		},
+		{
+			name:        "OK json patch",
+			contentType: "application/json-patch+json",
+			url:         "/v1/adverts/1",
+			reqData: `[{"op":"test","path":"/price","value":0},
+			{"op":"replace","path":"/price","value":70},
+			{"op":"add","path":"/photo_urls/0","value":"http://files.com/11"},
+			{"op":"remove","path":"/photo_urls/2"}]`,
+			wantStatus: http.StatusOK,
+			wantResult: `{}`,
+			wantAdvert: `{"data":[{"name":"first item","price":70,"main_photo_url":"http://files.com/11","photo_urls":["http://files.com/11","http://files.com/12"]}]}`,
+		},
+		{
+			name:        "Error json patch test failed",
+			contentType: "application/json-patch+json",
+			url:         "/v1/adverts/1",
+			reqData:     `[{"op":"test","path":"/price","value":1},{"op":"replace","path":"/price","value":2}]`,
+			wantStatus:  http.StatusConflict,
+			wantResult:  `{"error":"patch test operation failed"}`,
+		},
+		{
+			name:        "Error removing required field",
+			contentType: "application/merge-patch+json",
+			url:         "/v1/adverts/1",
+			reqData:     `{"description":null}`,
+			wantStatus:  http.StatusBadRequest,
+			wantResult:  `{"error":"request has empty fields","detail":"'description:' field is required"}`,
+		},
+		{
+			name:        "Error unknown field",
+			contentType: "application/merge-patch+json",
+			url:         "/v1/adverts/1",
+			reqData:     `{"id":7}`,
+			wantStatus:  http.StatusBadRequest,
+			wantResult:  `{"error":"patch is not correct"}`,
+		},
+		{
+			name:        "Error wrong path",
+			contentType: "application/json-patch+json",
+			url:         "/v1/adverts/1",
+			reqData:     `[{"op":"remove","path":"/photo_urls/5"}]`,
+			wantStatus:  http.StatusBadRequest,
+			wantResult:  `{"error":"patch is not correct"}`,
+		},
+		{
+			name:        "Error too many urls",
+			contentType: "application/json-patch+json",
+			url:         "/v1/adverts/1",
+			reqData: `[{"op":"add","path":"/photo_urls/-","value":"http://files.com/1"},
+			{"op":"add","path":"/photo_urls/-","value":"http://files.com/2"}]`,
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"Request Entity Too Large","detail":"'photo_urls:' field's quantity exceeded"}`,
+		},
+		{
+			name:        "Error name already exists",
+			contentType: "application/merge-patch+json",
+			url:         "/v1/adverts/1",
+			reqData:     `{"name":"second item"}`,
+			wantStatus:  http.StatusConflict,
+			wantResult:  `{"error":"item with name 'second item' already exists"}`,
+		},
+		{
+			name:        "Error unsupported content type",
+			contentType: "application/json",
+			url:         "/v1/adverts/1",
+			reqData:     `{"price":5}`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantResult:  `{"error":"patch content type should be either 'application/merge-patch+json' or 'application/json-patch+json'"}`,
+		},
+		{
+			name:        "Error item does not exist",
+			contentType: "application/merge-patch+json",
+			url:         "/v1/adverts/5",
+			reqData:     `{"price":5}`,
+			wantStatus:  http.StatusNotFound,
+			wantResult:  `{"error":"no content found with id: 5"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPatch, tt.url, bytes.NewReader([]byte(tt.reqData)))
+			req.Header.Set("Content-Type", tt.contentType)
+			handler.Mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want: %v, got: %v", tt.wantStatus, rec.Code)
+			} else if rec.Body.String() != tt.wantResult {
+				t.Fatalf("want: %v, got: %v", tt.wantResult, rec.Body.String())
+			}
+
+			if tt.wantAdvert == "" {
+				return
+			}
+			rec = httptest.NewRecorder()
+			req = httptest.NewRequest(http.MethodGet, tt.url+"?fields=true", nil)
+			handler.Mux.ServeHTTP(rec, req)
+			if rec.Body.String() != tt.wantAdvert {
+				t.Fatalf("want: %v, got: %v", tt.wantAdvert, rec.Body.String())
+			}
+		})
+	}
+}
+
 func TestDeleteAdvert(t *testing.T) {
 	handler := setup()
 	if _, err := handler.Service.Create(context.Background(), advert1); err != nil {
@@ -317,3 +689,1140 @@ func TestDeleteAdvert(t *testing.T) {
 		})
 	}
 }
+
+func TestConditionalRequests(t *testing.T) {
+	handler := setup()
+	if _, err := handler.Service.Create(context.Background(), advert1); err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"name":"new name","description":"asd","price":40,"photo_urls":["http://files.com/12"]}`
+
+	tests := []struct {
+		name       string
+		method     string
+		header     string
+		value      string
+		reqData    string
+		wantStatus int
+		wantETag   string
+	}{
+		{
+			name:       "OK etag",
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			wantETag:   `"1"`,
+		},
+		{
+			name:       "OK not modified",
+			method:     http.MethodGet,
+			header:     "If-None-Match",
+			value:      `"3", W/"1"`,
+			wantStatus: http.StatusNotModified,
+			wantETag:   `"1"`,
+		},
+		{
+			name:       "Error update precondition failed",
+			method:     http.MethodPut,
+			header:     "If-Match",
+			value:      `"2"`,
+			reqData:    body,
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "OK update matched",
+			method:     http.MethodPut,
+			header:     "If-Match",
+			value:      `"2", "1"`,
+			reqData:    body,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "OK modified",
+			method:     http.MethodGet,
+			header:     "If-None-Match",
+			value:      `"1"`,
+			wantStatus: http.StatusOK,
+			wantETag:   `"2"`,
+		},
+		{
+			name:       "Error patch precondition failed",
+			method:     http.MethodPatch,
+			header:     "If-Match",
+			value:      `"1"`,
+			reqData:    `{"price":5}`,
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "Error delete with weak tag",
+			method:     http.MethodDelete,
+			header:     "If-Match",
+			value:      `W/"2"`,
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "OK delete matched",
+			method:     http.MethodDelete,
+			header:     "If-Match",
+			value:      `"2"`,
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, "/v1/adverts/1", bytes.NewReader([]byte(tt.reqData)))
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			handler.Mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want: %v, got: %v", tt.wantStatus, rec.Code)
+			} else if etag := rec.Header().Get("ETag"); etag != tt.wantETag {
+				t.Fatalf("want: %v, got: %v", tt.wantETag, etag)
+			}
+		})
+	}
+}
+
+func TestTrashAndRestore(t *testing.T) {
+	handler := setup()
+	owner := sessionToken(t, handler, "owner@example.com")
+	other := sessionToken(t, handler, "other@example.com")
+	admin := sessionToken(t, handler, "admin@example.com", entity.RoleAdmin)
+	ownerCtx := context.WithValue(context.Background(), entity.KeyIdentity,
+		entity.Identity{UserId: 1})
+	if _, err := handler.Service.Create(ownerCtx, advert1); err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.Service.Delete(ownerCtx, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	emptyTrash := `{"meta_data":{"total_count":0,"page":1,"page_size":10,"has_next":false,` +
+		`"links":{"self":"/v1/adverts/trash"}},"data":[]}`
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		token      string
+		wantStatus int
+		wantResult string
+	}{
+		{
+			name:       "Trash",
+			method:     http.MethodGet,
+			url:        "/v1/adverts/trash",
+			token:      owner,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Trash of admin",
+			method:     http.MethodGet,
+			url:        "/v1/adverts/trash",
+			token:      admin,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Empty trash of other user",
+			method:     http.MethodGet,
+			url:        "/v1/adverts/trash",
+			token:      other,
+			wantStatus: http.StatusOK,
+			wantResult: emptyTrash,
+		},
+		{
+			name:       "Error trash unauthenticated",
+			method:     http.MethodGet,
+			url:        "/v1/adverts/trash",
+			wantStatus: http.StatusUnauthorized,
+			wantResult: `{"error":"valid session token is required"}`,
+		},
+		{
+			name:       "Error trash method",
+			method:     http.MethodPost,
+			url:        "/v1/adverts/trash",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantResult: `{}`,
+		},
+		{
+			name:       "Error restore method",
+			method:     http.MethodGet,
+			url:        "/v1/adverts/1/restore",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantResult: `{}`,
+		},
+		{
+			name:       "Error wrong action",
+			method:     http.MethodPost,
+			url:        "/v1/adverts/1/revive",
+			wantStatus: http.StatusNotFound,
+			wantResult: `{}`,
+		},
+		{
+			name:       "Error restore unauthenticated",
+			method:     http.MethodPost,
+			url:        "/v1/adverts/1/restore",
+			wantStatus: http.StatusUnauthorized,
+			wantResult: `{"error":"valid session token is required"}`,
+		},
+		{
+			name:       "Error restore by other user",
+			method:     http.MethodPost,
+			url:        "/v1/adverts/1/restore",
+			token:      other,
+			wantStatus: http.StatusForbidden,
+			wantResult: `{"error":"only owner of advert or admin can change it"}`,
+		},
+		{
+			name:       "Restore",
+			method:     http.MethodPost,
+			url:        "/v1/adverts/1/restore",
+			token:      owner,
+			wantStatus: http.StatusOK,
+			wantResult: `{"data":[{"id":1}]}`,
+		},
+		{
+			name:       "Error already restored",
+			method:     http.MethodPost,
+			url:        "/v1/adverts/1/restore",
+			token:      admin,
+			wantStatus: http.StatusNotFound,
+			wantResult: `{"error":"no content found with id: 1"}`,
+		},
+		{
+			name:       "Empty trash",
+			method:     http.MethodGet,
+			url:        "/v1/adverts/trash",
+			token:      admin,
+			wantStatus: http.StatusOK,
+			wantResult: emptyTrash,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			handler.Mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want: %v, got: %v", tt.wantStatus, rec.Code)
+			}
+			if tt.wantResult == "" {
+				resp := v1.Response{}
+				if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+					t.Fatal(err)
+				}
+				if len(resp.Data) != 1 || resp.Data[0].DeletedAt == "" {
+					t.Fatalf("want deleted advert, got: %v", rec.Body.String())
+				}
+			} else if rec.Body.String() != tt.wantResult {
+				t.Fatalf("want: %v, got: %v", tt.wantResult, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRevisions(t *testing.T) {
+	handler := setup()
+	owner := sessionToken(t, handler, "owner@example.com")
+	other := sessionToken(t, handler, "other@example.com")
+	admin := sessionToken(t, handler, "admin@example.com", entity.RoleAdmin)
+	ownerCtx := context.WithValue(context.Background(), entity.KeyIdentity,
+		entity.Identity{UserId: 1})
+	if _, err := handler.Service.Create(ownerCtx, advert1); err != nil {
+		t.Fatal(err)
+	}
+	updated := advert1
+	updated.Id = 1
+	updated.Price = 70
+	if err := handler.Service.Update(ownerCtx, updated); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		token      string
+		wantStatus int
+		wantResult string
+	}{
+		{
+			name:       "OK diff",
+			method:     http.MethodGet,
+			url:        "/v1/adverts/1/revisions/diff?from=1&to=2",
+			token:      owner,
+			wantStatus: http.StatusOK,
+			wantResult: `{"data":{"from":1,"to":2,"changes":[{"field":"price","from":40,"to":70}]}}`,
+		},
+		{
+			name:       "OK diff of admin",
+			method:     http.MethodGet,
+			url:        "/v1/adverts/1/revisions/diff?from=1&to=2",
+			token:      admin,
+			wantStatus: http.StatusOK,
+			wantResult: `{"data":{"from":1,"to":2,"changes":[{"field":"price","from":40,"to":70}]}}`,
+		},
+		{
+			name:       "Error diff queries",
+			method:     http.MethodGet,
+			url:        "/v1/adverts/1/revisions/diff?from=1",
+			token:      owner,
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'from=' and 'to=' query values should be revision numbers"}`,
+		},
+		{
+			name:       "Error revision not found",
+			method:     http.MethodGet,
+			url:        "/v1/adverts/1/revisions/3",
+			token:      owner,
+			wantStatus: http.StatusNotFound,
+			wantResult: `{"error":"no revision found with number: 3"}`,
+		},
+		{
+			name:       "Error advert has no revisions",
+			method:     http.MethodGet,
+			url:        "/v1/adverts/2/revisions",
+			token:      owner,
+			wantStatus: http.StatusNotFound,
+			wantResult: `{"error":"no content found with id: 2"}`,
+		},
+		{
+			name:       "Error revisions unauthenticated",
+			method:     http.MethodGet,
+			url:        "/v1/adverts/1/revisions",
+			wantStatus: http.StatusUnauthorized,
+			wantResult: `{"error":"valid session token is required"}`,
+		},
+		{
+			name:       "Error revision of other user",
+			method:     http.MethodGet,
+			url:        "/v1/adverts/1/revisions/1",
+			token:      other,
+			wantStatus: http.StatusForbidden,
+			wantResult: `{"error":"only owner of advert or admin can change it"}`,
+		},
+		{
+			name:       "Error wrong path",
+			method:     http.MethodGet,
+			url:        "/v1/adverts/1/revisions/1/2",
+			wantStatus: http.StatusNotFound,
+			wantResult: `{}`,
+		},
+		{
+			name:       "Error method",
+			method:     http.MethodPost,
+			url:        "/v1/adverts/1/revisions",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantResult: `{}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			handler.Mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want: %v, got: %v", tt.wantStatus, rec.Code)
+			} else if rec.Body.String() != tt.wantResult {
+				t.Fatalf("want: %v, got: %v", tt.wantResult, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("OK revisions", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v1/adverts/1/revisions", nil)
+		req.Header.Set("Authorization", "Bearer "+owner)
+		handler.Mux.ServeHTTP(rec, req)
+
+		resp := struct {
+			Data []entity.Revision `json:"data"`
+		}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusOK || len(resp.Data) != 2 ||
+			resp.Data[1].Action != entity.ActionUpdate || resp.Data[1].Advert.Price != 70 ||
+			resp.Data[1].UserId != 1 {
+			t.Fatalf("unexpected response: %v %v", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+func TestCategories(t *testing.T) {
+	handler := setup()
+	token := sessionToken(t, handler, "admin@example.com", entity.RoleAdmin)
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		wantStatus int
+		wantResult string
+	}{
+		{
+			name:       "OK create",
+			method:     http.MethodPost,
+			url:        "/v1/categories",
+			body:       `{"name":"transport"}`,
+			wantStatus: http.StatusCreated,
+			wantResult: `{"data":[{"id":1,"adverts_count":0}]}`,
+		},
+		{
+			name:       "OK create child",
+			method:     http.MethodPost,
+			url:        "/v1/categories",
+			body:       `{"name":"cars","parent_id":1}`,
+			wantStatus: http.StatusCreated,
+			wantResult: `{"data":[{"id":2,"adverts_count":0}]}`,
+		},
+		{
+			name:       "Error empty name",
+			method:     http.MethodPost,
+			url:        "/v1/categories",
+			body:       `{"parent_id":1}`,
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"request has empty fields","detail":"'name:' field is required"}`,
+		},
+		{
+			name:       "Error wrong parent",
+			method:     http.MethodPost,
+			url:        "/v1/categories",
+			body:       `{"name":"boats","parent_id":7}`,
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"parent category does not exist or is inside category"}`,
+		},
+		{
+			name:       "OK tree",
+			method:     http.MethodGet,
+			url:        "/v1/categories",
+			wantStatus: http.StatusOK,
+			wantResult: `{"data":[{"id":1,"name":"transport","adverts_count":0,` +
+				`"children":[{"id":2,"name":"cars","parent_id":1,"adverts_count":0}]}]}`,
+		},
+		{
+			name:       "Error move inside itself",
+			method:     http.MethodPut,
+			url:        "/v1/categories/2",
+			body:       `{"name":"cars","parent_id":2}`,
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"parent category does not exist or is inside category"}`,
+		},
+		{
+			name:       "Error delete not empty",
+			method:     http.MethodDelete,
+			url:        "/v1/categories/1",
+			wantStatus: http.StatusConflict,
+			wantResult: `{"error":"category has subcategories or adverts"}`,
+		},
+		{
+			name:       "OK empty adverts",
+			method:     http.MethodGet,
+			url:        "/v1/categories/2/adverts",
+			wantStatus: http.StatusOK,
+			wantResult: `{"meta_data":{"total_count":0,"page":1,"page_size":10,"has_next":false,"links":{"self":"/v1/categories/2/adverts"}},"data":[]}`,
+		},
+		{
+			name:       "Error adverts method",
+			method:     http.MethodPost,
+			url:        "/v1/categories/2/adverts",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantResult: `{}`,
+		},
+		{
+			name:       "OK delete",
+			method:     http.MethodDelete,
+			url:        "/v1/categories/2",
+			wantStatus: http.StatusNoContent,
+			wantResult: `{}`,
+		},
+		{
+			name:       "Error not found",
+			method:     http.MethodGet,
+			url:        "/v1/categories/2",
+			wantStatus: http.StatusNotFound,
+			wantResult: `{"error":"no content found with id: 2"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.url, bytes.NewBufferString(tt.body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			handler.Mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want: %v, got: %v", tt.wantStatus, rec.Code)
+			} else if rec.Body.String() != tt.wantResult {
+				t.Fatalf("want: %v, got: %v", tt.wantResult, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("Error change without admin", func(t *testing.T) {
+		userToken := sessionToken(t, handler, "user@example.com")
+		for _, tt := range []struct {
+			method     string
+			url        string
+			token      string
+			wantStatus int
+			wantResult string
+		}{
+			{http.MethodPost, "/v1/categories", "", http.StatusUnauthorized,
+				`{"error":"valid session token is required"}`},
+			{http.MethodPost, "/v1/categories", userToken, http.StatusForbidden,
+				`{"error":"only admin can change categories"}`},
+			{http.MethodPut, "/v1/categories/1", userToken, http.StatusForbidden,
+				`{"error":"only admin can change categories"}`},
+			{http.MethodDelete, "/v1/categories/1", userToken, http.StatusForbidden,
+				`{"error":"only admin can change categories"}`},
+		} {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.url,
+				bytes.NewBufferString(`{"name":"boats"}`))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			handler.Mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want: %v, got: %v", tt.wantStatus, rec.Code)
+			} else if rec.Body.String() != tt.wantResult {
+				t.Fatalf("want: %v, got: %v", tt.wantResult, rec.Body.String())
+			}
+		}
+	})
+}
+
+func TestAttributes(t *testing.T) {
+	handler := setup()
+	token := sessionToken(t, handler, "admin@example.com", entity.RoleAdmin)
+	schema := `{"type":"object","properties":{"year":{"type":"integer","minimum":1900}},"required":["year"]}`
+	advert := `{"name":"car","description":"asd","price":40,"photo_urls":["http://a.com/1"],"category_id":1,`
+
+	tests := []struct {
+		name       string
+		url        string
+		body       string
+		wantStatus int
+		wantResult string
+	}{
+		{
+			name:       "OK category with schema",
+			url:        "/v1/categories",
+			body:       `{"name":"cars","schema":` + schema + `}`,
+			wantStatus: http.StatusCreated,
+			wantResult: `{"data":[{"id":1,"adverts_count":0}]}`,
+		},
+		{
+			name:       "Error schema is not valid",
+			url:        "/v1/categories",
+			body:       `{"name":"flats","schema":{"type":"room"}}`,
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"'schema:' field is not valid JSON Schema"}`,
+		},
+		{
+			name:       "Error attributes do not match schema",
+			url:        "/v1/adverts",
+			body:       advert + `"attributes":{"year":1850}}`,
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"'attributes:' field does not match category schema","detail":"/year: should be at least 1900"}`,
+		},
+		{
+			name:       "Error attributes are not object",
+			url:        "/v1/adverts",
+			body:       advert + `"attributes":[2015]}`,
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"wrong data format","detail":"'attributes:' field should be object"}`,
+		},
+		{
+			name:       "OK advert with attributes",
+			url:        "/v1/adverts",
+			body:       advert + `"attributes":{"year":2015}}`,
+			wantStatus: http.StatusCreated,
+			wantResult: `{"data":[{"id":1}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, tt.url, bytes.NewBufferString(tt.body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			handler.Mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want: %v, got: %v", tt.wantStatus, rec.Code)
+			} else if rec.Body.String() != tt.wantResult {
+				t.Fatalf("want: %v, got: %v", tt.wantResult, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestUsers(t *testing.T) {
+	handler := setup()
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		token      string
+		wantStatus int
+		wantResult string
+	}{
+		{
+			name:       "OK register",
+			method:     http.MethodPost,
+			url:        "/v1/users",
+			body:       `{"email":"User@example.com","password":"password"}`,
+			wantStatus: http.StatusCreated,
+			wantResult: `{"data":[{"id":1}]}`,
+		},
+		{
+			name:       "Error email exists",
+			method:     http.MethodPost,
+			url:        "/v1/users",
+			body:       `{"email":"user@example.com","password":"password"}`,
+			wantStatus: http.StatusConflict,
+			wantResult: `{"error":"user with email 'user@example.com' already exists"}`,
+		},
+		{
+			name:       "Error wrong email",
+			method:     http.MethodPost,
+			url:        "/v1/users",
+			body:       `{"email":"User <user@example.com>","password":"password"}`,
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"wrong data format","detail":"'email:' field should be valid email address"}`,
+		},
+		{
+			name:       "Error short password",
+			method:     http.MethodPost,
+			url:        "/v1/users",
+			body:       `{"email":"other@example.com","password":"pass"}`,
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"wrong data format","detail":"'password:' field should have from 8 to 72 bytes"}`,
+		},
+		{
+			name:       "Error wrong password",
+			method:     http.MethodPost,
+			url:        "/v1/sessions",
+			body:       `{"email":"user@example.com","password":"wrong"}`,
+			wantStatus: http.StatusUnauthorized,
+			wantResult: `{"error":"email or password is wrong"}`,
+		},
+		{
+			name:       "Error anonymous",
+			method:     http.MethodGet,
+			url:        "/v1/users/me",
+			wantStatus: http.StatusUnauthorized,
+			wantResult: `{"error":"valid session token is required"}`,
+		},
+		{
+			name:       "Error wrong token",
+			method:     http.MethodGet,
+			url:        "/v1/adverts",
+			token:      "token-7-7",
+			wantStatus: http.StatusUnauthorized,
+			wantResult: `{"error":"valid session token is required"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.url, bytes.NewBufferString(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			handler.Mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want: %v, got: %v", tt.wantStatus, rec.Code)
+			} else if rec.Body.String() != tt.wantResult {
+				t.Fatalf("want: %v, got: %v", tt.wantResult, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("OK session", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/sessions",
+			bytes.NewBufferString(`{"email":"user@example.com","password":"password"}`))
+		handler.Mux.ServeHTTP(rec, req)
+		var session struct {
+			Data entity.Session `json:"data"`
+		}
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("want: %v, got: %v", http.StatusCreated, rec.Code)
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &session); err != nil || session.Data.Token == "" {
+			t.Fatalf("want session with token, got: %v", rec.Body.String())
+		}
+
+		for _, want := range []struct {
+			method, url string
+			status      int
+		}{
+			{http.MethodGet, "/v1/users/me", http.StatusOK},
+			{http.MethodDelete, "/v1/sessions", http.StatusNoContent},
+			{http.MethodGet, "/v1/users/me", http.StatusUnauthorized},
+		} {
+			rec = httptest.NewRecorder()
+			req = httptest.NewRequest(want.method, want.url, nil)
+			req.Header.Set("Authorization", "Bearer "+session.Data.Token)
+			handler.Mux.ServeHTTP(rec, req)
+			if rec.Code != want.status {
+				t.Fatalf("%v %v: want: %v, got: %v", want.method, want.url, want.status, rec.Code)
+			}
+		}
+	})
+}
+
+func TestOwnership(t *testing.T) {
+	handler := setup()
+	serve := func(method, url, body, token string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		handler.Mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tokens := []string{}
+	for _, email := range []string{"owner@example.com", "other@example.com"} {
+		cred := `{"email":"` + email + `","password":"password"}`
+		serve(http.MethodPost, "/v1/users", cred, "")
+		var session struct {
+			Data entity.Session `json:"data"`
+		}
+		rec := serve(http.MethodPost, "/v1/sessions", cred, "")
+		if err := json.Unmarshal(rec.Body.Bytes(), &session); err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, session.Data.Token)
+	}
+
+	advert := `{"name":"car","description":"asd","price":40,"photo_urls":["http://a.com/1"]}`
+	if rec := serve(http.MethodPost, "/v1/adverts", advert, tokens[0]); rec.Code != http.StatusCreated {
+		t.Fatalf("want: %v, got: %v", http.StatusCreated, rec.Code)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		token      string
+		wantStatus int
+		wantResult string
+	}{
+		{
+			name:       "Error anonymous update",
+			method:     http.MethodPut,
+			wantStatus: http.StatusUnauthorized,
+			wantResult: `{"error":"valid session token is required"}`,
+		},
+		{
+			name:       "Error other user update",
+			method:     http.MethodPut,
+			token:      tokens[1],
+			wantStatus: http.StatusForbidden,
+			wantResult: `{"error":"only owner of advert or admin can change it"}`,
+		},
+		{
+			name:       "Error other user delete",
+			method:     http.MethodDelete,
+			token:      tokens[1],
+			wantStatus: http.StatusForbidden,
+			wantResult: `{"error":"only owner of advert or admin can change it"}`,
+		},
+		{
+			name:       "OK owner update",
+			method:     http.MethodPut,
+			token:      tokens[0],
+			wantStatus: http.StatusOK,
+			wantResult: `{}`,
+		},
+		{
+			name:       "OK owner delete",
+			method:     http.MethodDelete,
+			token:      tokens[0],
+			wantStatus: http.StatusNoContent,
+			wantResult: `{}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.method, "/v1/adverts/1", advert, tt.token)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want: %v, got: %v", tt.wantStatus, rec.Code)
+			} else if rec.Body.String() != tt.wantResult {
+				t.Fatalf("want: %v, got: %v", tt.wantResult, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestApiKeys(t *testing.T) {
+	handler := setup()
+	serve := func(method, url, body, token string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		handler.Mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	cred := `{"email":"user@example.com","password":"password"}`
+	serve(http.MethodPost, "/v1/users", cred, "")
+	var session struct {
+		Data entity.Session `json:"data"`
+	}
+	if err := json.Unmarshal(serve(http.MethodPost, "/v1/sessions", cred, "").Body.Bytes(),
+		&session); err != nil {
+		t.Fatal(err)
+	}
+	token := session.Data.Token
+
+	var keys struct {
+		Data []entity.ApiKey `json:"data"`
+	}
+	rec := serve(http.MethodPost, "/v1/api-keys", `{"name":"bot","scopes":["adverts:read"]}`, token)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("want: %v, got: %v", http.StatusCreated, rec.Code)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &keys); err != nil || len(keys.Data) != 1 {
+		t.Fatalf("key is not given: %v, %v", rec.Body.String(), err)
+	}
+	key := keys.Data[0].Key
+
+	advert := `{"name":"car","description":"asd","price":40,"photo_urls":["http://a.com/1"]}`
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		token      string
+		wantStatus int
+		wantResult string
+	}{
+		{
+			name:       "Error anonymous",
+			method:     http.MethodGet,
+			url:        "/v1/api-keys",
+			wantStatus: http.StatusUnauthorized,
+			wantResult: `{"error":"valid session token is required"}`,
+		},
+		{
+			name:       "Error wrong scope",
+			method:     http.MethodPost,
+			url:        "/v1/api-keys",
+			body:       `{"name":"bot","scopes":["adverts:all"]}`,
+			token:      token,
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"'scopes:' field should hold only 'adverts:read' and 'adverts:write'"}`,
+		},
+		{
+			name:       "Error no scopes",
+			method:     http.MethodPost,
+			url:        "/v1/api-keys",
+			body:       `{"name":"bot"}`,
+			token:      token,
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"request has empty fields",` +
+				`"detail":"'scopes:' field should have at least 1 scope"}`,
+		},
+		{
+			name:       "Error managing keys with key",
+			method:     http.MethodGet,
+			url:        "/v1/api-keys",
+			token:      key,
+			wantStatus: http.StatusForbidden,
+			wantResult: `{"error":"API keys are managed only with session token"}`,
+		},
+		{
+			name:       "OK read with key",
+			method:     http.MethodGet,
+			url:        "/v1/adverts",
+			token:      key,
+			wantStatus: http.StatusOK,
+			wantResult: `{"meta_data":{"total_count":0,"page":1,"page_size":10,"has_next":false,"links":{"self":"/v1/adverts"}},"data":[]}`,
+		},
+		{
+			name:       "Error write with read key",
+			method:     http.MethodPost,
+			url:        "/v1/adverts",
+			body:       advert,
+			token:      key,
+			wantStatus: http.StatusForbidden,
+			wantResult: `{"error":"API key has no 'adverts:write' scope"}`,
+		},
+		{
+			name:       "Error revoke not existing key",
+			method:     http.MethodDelete,
+			url:        "/v1/api-keys/2",
+			token:      token,
+			wantStatus: http.StatusNotFound,
+			wantResult: `{"error":"no content found with id: 2"}`,
+		},
+		{
+			name:       "OK revoke",
+			method:     http.MethodDelete,
+			url:        "/v1/api-keys/1",
+			token:      token,
+			wantStatus: http.StatusNoContent,
+			wantResult: `{}`,
+		},
+		{
+			name:       "Error revoked key",
+			method:     http.MethodGet,
+			url:        "/v1/adverts",
+			token:      key,
+			wantStatus: http.StatusUnauthorized,
+			wantResult: `{"error":"valid session token is required"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.method, tt.url, tt.body, tt.token)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want: %v, got: %v", tt.wantStatus, rec.Code)
+			} else if rec.Body.String() != tt.wantResult {
+				t.Fatalf("want: %v, got: %v", tt.wantResult, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("OK scope denial audited", func(t *testing.T) {
+		denied := false
+		for _, entry := range handler.Service.(*mock.MockService).Audit {
+			if entry.Action == entity.ScopeAdvertsWrite && entry.Resource == "adverts" &&
+				entry.ApiKeyId == 1 && !entry.Allowed {
+				denied = true
+			}
+		}
+		if !denied {
+			t.Fatal("scope denial is not audited")
+		}
+	})
+}
+
+func TestRoles(t *testing.T) {
+	handler := setup()
+	serve := func(method, url, body, token string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		handler.Mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	mockService := handler.Service.(*mock.MockService)
+	mockService.UserRoles[2] = []string{entity.RoleModerator}
+	mockService.UserRoles[3] = []string{entity.RoleAdmin}
+	tokens := []string{}
+	for _, email := range []string{"owner@example.com", "moderator@example.com",
+		"admin@example.com"} {
+		cred := `{"email":"` + email + `","password":"password"}`
+		serve(http.MethodPost, "/v1/users", cred, "")
+		var session struct {
+			Data entity.Session `json:"data"`
+		}
+		rec := serve(http.MethodPost, "/v1/sessions", cred, "")
+		if err := json.Unmarshal(rec.Body.Bytes(), &session); err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, session.Data.Token)
+	}
+
+	advert := `{"name":"car","description":"asd","price":40,"photo_urls":["http://a.com/1"]}`
+	if rec := serve(http.MethodPost, "/v1/adverts", advert, tokens[0]); rec.Code != http.StatusCreated {
+		t.Fatalf("want: %v, got: %v", http.StatusCreated, rec.Code)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		token      string
+		wantStatus int
+		wantResult string
+	}{
+		{
+			name:       "Error owner hides advert",
+			method:     http.MethodPost,
+			url:        "/v1/adverts/1/hide",
+			token:      tokens[0],
+			wantStatus: http.StatusForbidden,
+			wantResult: `{"error":"only moderator or admin can hide advert"}`,
+		},
+		{
+			name:       "OK moderator hides advert",
+			method:     http.MethodPost,
+			url:        "/v1/adverts/1/hide",
+			token:      tokens[1],
+			wantStatus: http.StatusNoContent,
+			wantResult: `{}`,
+		},
+		{
+			name:       "Error hidden advert",
+			method:     http.MethodGet,
+			url:        "/v1/adverts/1",
+			wantStatus: http.StatusNotFound,
+			wantResult: `{"error":"no content found with id: 1"}`,
+		},
+		{
+			name:       "Error moderator assigns role",
+			method:     http.MethodPut,
+			url:        "/v1/users/1/roles/moderator",
+			token:      tokens[1],
+			wantStatus: http.StatusForbidden,
+			wantResult: `{"error":"action needs session token of user with permission for it"}`,
+		},
+		{
+			name:       "Error not existing role",
+			method:     http.MethodPut,
+			url:        "/v1/users/1/roles/superuser",
+			token:      tokens[2],
+			wantStatus: http.StatusNotFound,
+			wantResult: `{"error":"no role found with name: superuser"}`,
+		},
+		{
+			name:       "OK admin assigns role",
+			method:     http.MethodPut,
+			url:        "/v1/users/1/roles/moderator",
+			token:      tokens[2],
+			wantStatus: http.StatusNoContent,
+			wantResult: `{}`,
+		},
+		{
+			name:       "OK user with roles",
+			method:     http.MethodGet,
+			url:        "/v1/users/1",
+			token:      tokens[2],
+			wantStatus: http.StatusOK,
+			wantResult: `{"data":[{"id":1,"email":"owner@example.com","roles":["moderator"]}]}`,
+		},
+		{
+			name:       "Error wrong method",
+			method:     http.MethodPost,
+			url:        "/v1/users/1/roles/moderator",
+			token:      tokens[2],
+			wantStatus: http.StatusMethodNotAllowed,
+			wantResult: `{}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.method, tt.url, "", tt.token)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want: %v, got: %v", tt.wantStatus, rec.Code)
+			} else if rec.Body.String() != tt.wantResult {
+				t.Fatalf("want: %v, got: %v", tt.wantResult, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("OK audit trail", func(t *testing.T) {
+		rec := serve(http.MethodGet, "/v1/audit", "", tokens[2])
+		var audit struct {
+			Data []entity.AuditEntry `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &audit); err != nil || len(audit.Data) < 2 {
+			t.Fatalf("want audit trail, got: %v, %v", rec.Body.String(), err)
+		}
+		// oldest entry is creation of advert
+		if entry := audit.Data[len(audit.Data)-2]; entry.Action != "adverts:hide" ||
+			entry.Allowed {
+			t.Fatalf("want denied hide first, got: %+v", entry)
+		}
+	})
+}
+
+func TestIdempotency(t *testing.T) {
+	handler := setup()
+	serve := func(body, key string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/adverts", bytes.NewBufferString(body))
+		req.Header.Set(v1.IdempotencyKeyHeader, key)
+		handler.Mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	advert := `{"name":"car","description":"asd","price":40,"photo_urls":["http://a.com/1"]}`
+	first := serve(advert, "retry-1")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("want: %v, got: %v", http.StatusCreated, first.Code)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		key        string
+		wantStatus int
+		wantResult string
+		replayed   bool
+	}{
+		{
+			name:       "OK replayed response",
+			body:       advert,
+			key:        "retry-1",
+			wantStatus: http.StatusCreated,
+			wantResult: first.Body.String(),
+			replayed:   true,
+		},
+		{
+			name:       "Error key reused with other body",
+			body:       `{"name":"bike","description":"asd","price":40,"photo_urls":["http://a.com/1"]}`,
+			key:        "retry-1",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantResult: `{"error":"'Idempotency-Key' header is already used for request with other body"}`,
+		},
+		{
+			name:       "Error without key",
+			body:       advert,
+			wantStatus: http.StatusConflict,
+			wantResult: `{"error":"item with name 'car' already exists"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(tt.body, tt.key)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want: %v, got: %v", tt.wantStatus, rec.Code)
+			} else if rec.Body.String() != tt.wantResult {
+				t.Fatalf("want: %v, got: %v", tt.wantResult, rec.Body.String())
+			}
+			if replayed := rec.Header().Get(v1.IdempotentReplayedHeader) != ""; replayed !=
+				tt.replayed {
+				t.Fatalf("want replayed: %v, got: %v", tt.replayed, replayed)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// apiKeyRequest is body of request creating API key
+type apiKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// CreateApiKey creates key of caller, key is shown only in this response
+func (h *Handler) CreateApiKey(w http.ResponseWriter, r *http.Request) {
+	req, errAns, err := parseApiKey(r)
+	if err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - CreateApiKey - %w", err))
+		h.writeResponse(w, errAns)
+		return
+	}
+
+	key, err := h.Service.CreateApiKey(r.Context(), req.Name, req.Scopes)
+	if err != nil {
+		h.writeApiKeyError(w, fmt.Errorf("v1 - CreateApiKey - h.Service.CreateApiKey: %w",
+			err), 0)
+		return
+	}
+
+	h.writeResponse(w, ApiKeysResponse{Data: []entity.ApiKey{key}, code: http.StatusCreated})
+}
+
+func (h *Handler) GetApiKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.Service.GetApiKeys(r.Context())
+	if err != nil {
+		h.writeApiKeyError(w, fmt.Errorf("v1 - GetApiKeys - h.Service.GetApiKeys: %w", err), 0)
+		return
+	}
+
+	h.writeResponse(w, ApiKeysResponse{Data: keys, code: http.StatusOK})
+}
+
+// RotateApiKey responds with new key which replaces old one
+func (h *Handler) RotateApiKey(w http.ResponseWriter, r *http.Request) {
+	id := r.Context().Value(entity.KeyId).(int64)
+
+	key, err := h.Service.RotateApiKey(r.Context(), id)
+	if err != nil {
+		h.writeApiKeyError(w, fmt.Errorf("v1 - RotateApiKey - h.Service.RotateApiKey: %w",
+			err), id)
+		return
+	}
+
+	h.writeResponse(w, ApiKeysResponse{Data: []entity.ApiKey{key}, code: http.StatusOK})
+}
+
+func (h *Handler) RevokeApiKey(w http.ResponseWriter, r *http.Request) {
+	id := r.Context().Value(entity.KeyId).(int64)
+
+	err := h.Service.RevokeApiKey(r.Context(), id)
+	if err != nil {
+		h.writeApiKeyError(w, fmt.Errorf("v1 - RevokeApiKey - h.Service.RevokeApiKey: %w",
+			err), id)
+		return
+	}
+
+	h.writeResponse(w, Response{code: http.StatusNoContent})
+}
+
+// writeApiKeyError responds to failed request to API keys
+func (h *Handler) writeApiKeyError(w http.ResponseWriter, err error, id int64) {
+	h.l.WriteLog(err)
+	switch {
+	case errors.Is(err, entity.ErrUnauthenticated):
+		h.writeUnauthenticated(w)
+	case errors.Is(err, entity.ErrForbidden):
+		h.writeResponse(w, ErrMessage{code: http.StatusForbidden, Error: ApiKeysForbidden})
+	case errors.Is(err, entity.ErrItemNotExists):
+		h.writeResponse(w, ErrMessage{code: http.StatusNotFound,
+			Error: NoContentFound + strconv.Itoa(int(id))})
+	case errors.Is(err, entity.ErrWrongScope):
+		h.writeResponse(w, ErrMessage{code: http.StatusBadRequest, Error: WrongScope})
+	default:
+		h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+	}
+}
+
+// parseApiKey decodes and validates key's name and scopes given in request's body
+func parseApiKey(r *http.Request) (apiKeyRequest, ErrMessage, error) {
+	errMsg := ErrMessage{code: http.StatusBadRequest}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		errMsg.Error = JsonNotCorrect
+		return apiKeyRequest{}, errMsg, fmt.Errorf("parseApiKey - ReadAll: %w", err)
+	}
+
+	var req apiKeyRequest
+	if err = json.Unmarshal(body, &req); err != nil {
+		errMsg.Error = JsonNotCorrect
+		return apiKeyRequest{}, errMsg, fmt.Errorf("parseApiKey - Unmarshal: %w", err)
+	}
+
+	switch {
+	case req.Name == "":
+		errMsg.Error = EmptyFiledRequest
+		errMsg.Detail = `'name:' field is required`
+	case utf8.RuneCountInString(req.Name) > MaxNameLength:
+		errMsg.Error = http.StatusText(http.StatusRequestEntityTooLarge)
+		errMsg.Detail = NameLengthExceeded
+	case len(req.Scopes) == 0:
+		errMsg.Error = EmptyFiledRequest
+		errMsg.Detail = `'scopes:' field should have at least 1 scope`
+	default:
+		return req, errMsg, nil
+	}
+	return apiKeyRequest{}, errMsg, fmt.Errorf("parseApiKey - %v", errMsg.Error)
+}
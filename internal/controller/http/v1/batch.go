@@ -0,0 +1,160 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// errWrongOperation marks operation rejected before it reached service
+var errWrongOperation = errors.New("operation is not valid")
+
+// batchRequest is body of request changing adverts at once
+type batchRequest struct {
+	Atomic     bool             `json:"atomic"`
+	Operations []batchOperation `json:"operations"`
+}
+
+// batchOperation is one of changes of batch, advert is given to create and
+// update, id to update and delete, version 0 means version is not checked
+type batchOperation struct {
+	Op      string          `json:"op"`
+	Id      int64           `json:"id"`
+	Version int64           `json:"version"`
+	Advert  json.RawMessage `json:"advert"`
+}
+
+// BatchAdverts applies create, update and delete operations in given order,
+// every operation is checked as single request would be and has its own status.
+// Atomic batch is applied only if all of its operations succeed
+func (h *Handler) BatchAdverts(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - BatchAdverts - ReadAll: %w", err))
+		h.writeResponse(w, ErrMessage{code: http.StatusBadRequest, Error: JsonNotCorrect})
+		return
+	}
+
+	var req batchRequest
+	if err = json.Unmarshal(body, &req); err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - BatchAdverts - Unmarshal: %w", err))
+		h.writeResponse(w, ErrMessage{code: http.StatusBadRequest, Error: JsonNotCorrect})
+		return
+	}
+	switch {
+	case len(req.Operations) == 0:
+		h.writeResponse(w, ErrMessage{code: http.StatusBadRequest, Error: EmptyFiledRequest,
+			Detail: `'operations:' field should have at least 1 operation`})
+		return
+	case len(req.Operations) > MaxBatchOps:
+		h.writeResponse(w, ErrMessage{code: http.StatusBadRequest,
+			Error: http.StatusText(http.StatusRequestEntityTooLarge), Detail: OpsExceeded})
+		return
+	}
+
+	ops := make([]entity.BatchOperation, len(req.Operations))
+	results := make([]OperationResult, len(req.Operations))
+	for i, op := range req.Operations {
+		ops[i], results[i] = h.parseOperation(op)
+	}
+
+	err = h.Service.Batch(r.Context(), ops, req.Atomic)
+	if err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - BatchAdverts - h.Service.Batch: %w", err))
+		h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+		return
+	}
+
+	for i, op := range ops {
+		if !errors.Is(op.Err, errWrongOperation) {
+			results[i] = h.operationResult(op)
+		}
+	}
+
+	h.writeResponse(w, OperationsResponse{Data: results, code: http.StatusOK})
+}
+
+// parseOperation validates operation of batch, rejected operation
+// is marked with errWrongOperation and has its result
+func (h *Handler) parseOperation(op batchOperation) (entity.BatchOperation, OperationResult) {
+	parsed := entity.BatchOperation{Action: op.Op, Advert: entity.Advert{Id: op.Id,
+		Version: op.Version}}
+	reject := func(errMsg ErrMessage) (entity.BatchOperation, OperationResult) {
+		parsed.Err = errWrongOperation
+		return parsed, OperationResult{Status: errMsg.code, Error: errMsg.Error,
+			Detail: errMsg.Detail}
+	}
+
+	switch {
+	case op.Op != entity.ActionCreate && op.Op != entity.ActionUpdate &&
+		op.Op != entity.ActionDelete:
+		return reject(ErrMessage{code: http.StatusBadRequest, Error: WrongDataFormat,
+			Detail: `'op:' field should be one of 'create', 'update' and 'delete'`})
+	case op.Op != entity.ActionCreate && op.Id <= 0:
+		return reject(ErrMessage{code: http.StatusBadRequest, Error: EmptyFiledRequest,
+			Detail: `'id:' field should be positive number`})
+	case op.Op == entity.ActionDelete:
+		return parsed, OperationResult{}
+	case len(op.Advert) == 0 || string(op.Advert) == "null":
+		return reject(ErrMessage{code: http.StatusBadRequest, Error: EmptyFiledRequest,
+			Detail: `'advert:' field is required`})
+	}
+
+	var adv entity.Advert
+	fields, err := decodeAdvert(op.Advert, &adv)
+	if err != nil {
+		return reject(ErrMessage{code: http.StatusBadRequest, Error: JsonNotCorrect})
+	}
+	if errAns := h.checkData(adv, fields); errAns.Error != "" {
+		return reject(errAns)
+	}
+
+	adv.Id = parsed.Advert.Id
+	adv.Version = parsed.Advert.Version
+	parsed.Advert = adv
+	return parsed, OperationResult{}
+}
+
+// operationResult gives status of operation the way single request is answered
+func (h *Handler) operationResult(op entity.BatchOperation) OperationResult {
+	var attrErr *entity.AttributesError
+	id := op.Advert.Id
+	switch {
+	case op.Err == nil && op.Action == entity.ActionCreate:
+		return OperationResult{Status: http.StatusCreated, Id: id}
+	case op.Err == nil && op.Action == entity.ActionDelete:
+		return OperationResult{Status: http.StatusNoContent, Id: id}
+	case op.Err == nil:
+		return OperationResult{Status: http.StatusOK, Id: id}
+	case errors.Is(op.Err, entity.ErrBatchAborted):
+		return OperationResult{Status: http.StatusFailedDependency, Error: BatchAborted}
+	case errors.Is(op.Err, entity.ErrItemNotExists):
+		return OperationResult{Status: http.StatusNotFound,
+			Error: NoContentFound + strconv.Itoa(int(id))}
+	case errors.Is(op.Err, entity.ErrVersionMismatch):
+		return OperationResult{Status: http.StatusConflict, Error: WrongVersion}
+	case errors.Is(op.Err, entity.ErrNameAlreadyExist):
+		return OperationResult{Status: http.StatusConflict,
+			Error: fmt.Sprintf(ItemNameExists, op.Advert.Name)}
+	case errors.Is(op.Err, entity.ErrWrongCategory):
+		return OperationResult{Status: http.StatusBadRequest,
+			Error: fmt.Sprintf(WrongCategory, op.Advert.CategoryId)}
+	case errors.As(op.Err, &attrErr):
+		return OperationResult{Status: http.StatusBadRequest, Error: WrongAttributes,
+			Detail: strings.Join(attrErr.Violations, "; ")}
+	case errors.Is(op.Err, entity.ErrUnauthenticated):
+		return OperationResult{Status: http.StatusUnauthorized, Error: Unauthenticated}
+	case errors.Is(op.Err, entity.ErrForbidden):
+		return OperationResult{Status: http.StatusForbidden, Error: AdvertForbidden}
+	}
+
+	h.l.WriteLog(fmt.Errorf("v1 - operationResult: %w", op.Err))
+	return OperationResult{Status: http.StatusInternalServerError,
+		Error: http.StatusText(http.StatusInternalServerError)}
+}
@@ -0,0 +1,185 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	cat, errAns, err := parseCategory(r)
+	if err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - CreateCategory - %w", err))
+		h.writeResponse(w, errAns)
+		return
+	}
+
+	id, err := h.Service.CreateCategory(r.Context(), cat)
+	if err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - CreateCategory - h.Service.CreateCategory: %w", err))
+		switch {
+		case errors.Is(err, entity.ErrUnauthenticated):
+			h.writeUnauthenticated(w)
+		case errors.Is(err, entity.ErrForbidden):
+			h.writeResponse(w, ErrMessage{code: http.StatusForbidden, Error: CategoryDenied})
+		case errors.Is(err, entity.ErrWrongParent):
+			h.writeResponse(w, ErrMessage{code: http.StatusBadRequest, Error: WrongParent})
+		case errors.Is(err, entity.ErrWrongSchema):
+			h.writeResponse(w, ErrMessage{code: http.StatusBadRequest, Error: WrongSchema})
+		default:
+			h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+		}
+		return
+	}
+
+	ans := CategoriesResponse{
+		Data: []entity.Category{{Id: id}},
+		code: http.StatusCreated,
+	}
+	h.writeResponse(w, ans)
+}
+
+func (h *Handler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.Service.GetCategories(r.Context())
+	if err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - GetCategories - h.Service.GetCategories: %w", err))
+		h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+		return
+	}
+
+	h.writeResponse(w, CategoriesResponse{Data: categories, code: http.StatusOK})
+}
+
+func (h *Handler) GetCategory(w http.ResponseWriter, r *http.Request) {
+	id := r.Context().Value(entity.KeyId).(int64)
+
+	cat, err := h.Service.GetCategory(r.Context(), id)
+	if err != nil {
+		h.writeCategoryError(w, fmt.Errorf("v1 - GetCategory - h.Service.GetCategory: %w",
+			err), id)
+		return
+	}
+
+	h.writeResponse(w, CategoriesResponse{Data: []entity.Category{cat}, code: http.StatusOK})
+}
+
+// UpdateCategory renames category and moves it under parent given in request
+func (h *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	cat, errAns, err := parseCategory(r)
+	if err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - UpdateCategory - %w", err))
+		h.writeResponse(w, errAns)
+		return
+	}
+	cat.Id = r.Context().Value(entity.KeyId).(int64)
+
+	err = h.Service.UpdateCategory(r.Context(), cat)
+	if err != nil {
+		h.writeCategoryError(w, fmt.Errorf("v1 - UpdateCategory - h.Service.UpdateCategory: %w",
+			err), cat.Id)
+		return
+	}
+
+	h.writeResponse(w, CategoriesResponse{Data: []entity.Category{}, code: http.StatusOK})
+}
+
+func (h *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id := r.Context().Value(entity.KeyId).(int64)
+
+	err := h.Service.DeleteCategory(r.Context(), id)
+	if err != nil {
+		h.writeCategoryError(w, fmt.Errorf("v1 - DeleteCategory - h.Service.DeleteCategory: %w",
+			err), id)
+		return
+	}
+
+	h.writeResponse(w, Response{code: http.StatusNoContent})
+}
+
+// GetCategoryAdverts responds with page of adverts of category and its descendants
+func (h *Handler) GetCategoryAdverts(w http.ResponseWriter, r *http.Request) {
+	id := r.Context().Value(entity.KeyId).(int64)
+
+	page, err := h.Service.GetCategoryAdverts(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, entity.ErrNoItems) {
+			h.l.WriteLog(fmt.Errorf("v1 - GetCategoryAdverts - h.Service.GetCategoryAdverts: %w",
+				err))
+			h.writePage(w, r, page)
+			return
+		}
+		h.writeCategoryError(w, fmt.Errorf(
+			"v1 - GetCategoryAdverts - h.Service.GetCategoryAdverts: %w", err), id)
+		return
+	}
+
+	h.writePage(w, r, page)
+}
+
+// writeCategoryError responds to failed request to category
+func (h *Handler) writeCategoryError(w http.ResponseWriter, err error, id int64) {
+	h.l.WriteLog(err)
+	switch {
+	case errors.Is(err, entity.ErrUnauthenticated):
+		h.writeUnauthenticated(w)
+	case errors.Is(err, entity.ErrForbidden):
+		h.writeResponse(w, ErrMessage{code: http.StatusForbidden, Error: CategoryDenied})
+	case errors.Is(err, entity.ErrItemNotExists):
+		h.writeResponse(w, ErrMessage{code: http.StatusNotFound,
+			Error: NoContentFound + strconv.Itoa(int(id))})
+	case errors.Is(err, entity.ErrWrongParent):
+		h.writeResponse(w, ErrMessage{code: http.StatusBadRequest, Error: WrongParent})
+	case errors.Is(err, entity.ErrWrongSchema):
+		h.writeResponse(w, ErrMessage{code: http.StatusBadRequest, Error: WrongSchema})
+	case errors.Is(err, entity.ErrCategoryNotEmpty):
+		h.writeResponse(w, ErrMessage{code: http.StatusConflict, Error: CategoryNotEmpty})
+	default:
+		h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+	}
+}
+
+// parseCategory decodes and validates category given in request's body,
+// only name, parent and schema are taken from it
+func parseCategory(r *http.Request) (entity.Category, ErrMessage, error) {
+	errMsg := ErrMessage{code: http.StatusBadRequest}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		errMsg.Error = JsonNotCorrect
+		return entity.Category{}, errMsg, fmt.Errorf("parseCategory - ReadAll: %w", err)
+	}
+
+	var cat entity.Category
+	if err = json.Unmarshal(body, &cat); err != nil {
+		errMsg.Error = JsonNotCorrect
+		return entity.Category{}, errMsg, fmt.Errorf("parseCategory - Unmarshal: %w", err)
+	}
+
+	switch {
+	case cat.Name == "":
+		errMsg.Error = EmptyFiledRequest
+		errMsg.Detail = `'name:' field is required`
+	case utf8.RuneCountInString(cat.Name) > MaxNameLength:
+		errMsg.Error = http.StatusText(http.StatusRequestEntityTooLarge)
+		errMsg.Detail = NameLengthExceeded
+	case cat.ParentId < 0:
+		errMsg.Error = WrongParent
+	case cat.Schema != nil && string(cat.Schema) != "null" &&
+		!bytes.HasPrefix(bytes.TrimSpace(cat.Schema), []byte("{")):
+		errMsg.Error = WrongSchema
+		errMsg.Detail = `'schema:' field should be object`
+	default:
+		if string(cat.Schema) == "null" {
+			cat.Schema = nil
+		}
+		return entity.Category{Name: cat.Name, ParentId: cat.ParentId, Schema: cat.Schema},
+			errMsg, nil
+	}
+	return entity.Category{}, errMsg, fmt.Errorf("parseCategory - %v", errMsg.Error)
+}
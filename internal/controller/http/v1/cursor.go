@@ -0,0 +1,46 @@
+package v1
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// encodeCursor makes opaque string of cursor pointing to advert
+func encodeCursor(adv entity.Advert, order entity.Sort) (string, error) {
+	data, err := json.Marshal(entity.NewCursor(adv, order))
+	if err != nil {
+		return "", fmt.Errorf("encodeCursor - Marshal: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor parses cursor given by encodeCursor and checks that it
+// holds known sort order and values of its keys, sort is nil for default order
+func decodeCursor(value string) (entity.Cursor, entity.Sort, error) {
+	cursor := entity.Cursor{}
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, nil, fmt.Errorf("decodeCursor - DecodeString: %w", err)
+	}
+	err = json.Unmarshal(data, &cursor)
+	if err != nil {
+		return cursor, nil, fmt.Errorf("decodeCursor - Unmarshal: %w", err)
+	}
+
+	var order entity.Sort
+	if cursor.Sort != "" {
+		order, err = entity.ParseSort(cursor.Sort)
+		if err != nil {
+			return cursor, nil, fmt.Errorf("decodeCursor - ParseSort: %w", err)
+		}
+	}
+	if _, err := cursor.Advert(order); err != nil {
+		return cursor, nil, fmt.Errorf("decodeCursor - Advert: %w", err)
+	}
+
+	return cursor, order, nil
+}
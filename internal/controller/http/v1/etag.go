@@ -0,0 +1,49 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// formatETag makes strong entity tag of advert's version
+func formatETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// matchETag reports whether header holding list of entity tags matches
+// version, weak tags match only if weak comparison is allowed
+func matchETag(header string, version int64, weak bool) bool {
+	etag := formatETag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedVersion returns version advert should have to satisfy If-Match
+// header, 0 means that any version is allowed
+func (h *Handler) expectedVersion(ctx context.Context, ifMatch string, id int64) (int64, error) {
+	if strings.TrimSpace(ifMatch) == "" || strings.TrimSpace(ifMatch) == "*" {
+		return 0, nil
+	}
+
+	found, err := h.Service.GetById(ctx, id)
+	if err != nil {
+		return 0, fmt.Errorf("expectedVersion - h.Service.GetById: %w", err)
+	}
+	if !matchETag(ifMatch, found.Version, false) {
+		return 0, fmt.Errorf("expectedVersion - %v: %w", ifMatch, entity.ErrVersionMismatch)
+	}
+
+	return found.Version, nil
+}
@@ -1,8 +1,11 @@
 package v1
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -21,6 +24,7 @@ type Handler struct {
 	Cfg     config.Config
 	l       *logger.Logger
 	Mux     *http.ServeMux
+	Limiter *RateLimiter
 }
 
 func NewHandler(advService service.Service, cfg config.Config,
@@ -31,15 +35,33 @@ func NewHandler(advService service.Service, cfg config.Config,
 		Cfg:     cfg,
 		l:       logger,
 		Mux:     mux,
+		Limiter: NewRateLimiter(),
 	}
 }
 
 func (h *Handler) NewRouteGroups() {
-	h.Mux.Handle("/v1/adverts", h.ParseQuery(http.HandlerFunc(h.CommonGroup)))
-	h.Mux.Handle("/v1/adverts/", h.ParseQuery(http.HandlerFunc(h.ParticularGroup)))
+	h.handle("/v1/adverts", h.RequireScope(h.ParseQuery(http.HandlerFunc(h.CommonGroup))))
+	h.handle("/v1/adverts/", h.RequireScope(h.ParseQuery(http.HandlerFunc(h.ParticularGroup))))
+	h.handle("/v1/adverts:batch", h.RequireScope(http.HandlerFunc(h.BatchGroup)))
+	h.handle("/v1/adverts/trash", h.RequireScope(h.ParseQuery(http.HandlerFunc(h.TrashGroup))))
+	h.handle("/v1/categories", h.RequireScope(http.HandlerFunc(h.CategoriesGroup)))
+	h.handle("/v1/categories/", h.RequireScope(h.ParseQuery(http.HandlerFunc(h.CategoryGroup))))
+	h.handle("/v1/users", http.HandlerFunc(h.UsersGroup))
+	h.handle("/v1/users/", http.HandlerFunc(h.UserGroup))
+	h.handle("/v1/users/me", http.HandlerFunc(h.MeGroup))
+	h.handle("/v1/roles", http.HandlerFunc(h.RolesGroup))
+	h.handle("/v1/audit", h.ParseQuery(http.HandlerFunc(h.AuditGroup)))
+	h.handle("/v1/sessions", http.HandlerFunc(h.SessionsGroup))
+	h.handle("/v1/api-keys", http.HandlerFunc(h.ApiKeysGroup))
+	h.handle("/v1/api-keys/", http.HandlerFunc(h.ApiKeyGroup))
 	h.Mux.HandleFunc("/", h.WrongRoute)
 }
 
+// handle registers route group behind middleware common to all groups
+func (h *Handler) handle(pattern string, handler http.Handler) {
+	h.Mux.Handle(pattern, h.Timeout(h.Authenticate(h.RateLimit(handler))))
+}
+
 func (h *Handler) WrongRoute(w http.ResponseWriter, r *http.Request) {
 	h.writeResponse(w, ErrMessage{code: http.StatusNotFound})
 }
@@ -47,33 +69,71 @@ func (h *Handler) WrongRoute(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) CommonGroup(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
+		if _, ok := r.Context().Value(entity.KeyIds).([]int64); ok {
+			h.GetAdvertsByIds(w, r)
+			return
+		}
 		h.GetAllAdverts(w, r)
 	case http.MethodPost:
-		h.CreateAdvert(w, r)
+		h.Idempotent(http.HandlerFunc(h.CreateAdvert)).ServeHTTP(w, r)
 	default:
 		h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
 	}
 }
 
+func (h *Handler) BatchGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
+		return
+	}
+	h.Idempotent(http.HandlerFunc(h.BatchAdverts)).ServeHTTP(w, r)
+}
+
 func (h *Handler) ParticularGroup(w http.ResponseWriter, r *http.Request) {
-	path := strings.Split(r.URL.Path, "/")
-	id, err := strconv.Atoi(path[len(path)-1])
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/adverts/"), "/")
+	id, err := parseId(path[0])
 	if err != nil {
-		h.l.WriteLog(fmt.Errorf("v1 - NewPluralRoutes - Atoi: %w", err))
+		h.l.WriteLog(fmt.Errorf("v1 - ParticularGroup - %w", err))
+		h.writeResponse(w, ErrMessage{code: http.StatusNotFound})
+		return
 	}
 
-	if id <= 0 || err != nil || r.URL.Path != "/v1/adverts/"+strconv.Itoa(id) {
+	ctx := context.WithValue(r.Context(), entity.KeyId, id)
+
+	switch {
+	case len(path) == 1:
+	case len(path) == 2 && path[1] == "restore":
+		if r.Method != http.MethodPost {
+			h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
+			return
+		}
+		h.RestoreAdvert(w, r.WithContext(ctx))
+		return
+	case len(path) == 2 && path[1] == "hide":
+		switch r.Method {
+		case http.MethodPost:
+			h.HideAdvert(w, r.WithContext(ctx), true)
+		case http.MethodDelete:
+			h.HideAdvert(w, r.WithContext(ctx), false)
+		default:
+			h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
+		}
+		return
+	case path[1] == "revisions":
+		h.RevisionsGroup(w, r.WithContext(ctx), path[2:])
+		return
+	default:
 		h.writeResponse(w, ErrMessage{code: http.StatusNotFound})
 		return
 	}
 
-	ctx := context.WithValue(r.Context(), entity.KeyId, int64(id))
-
 	switch r.Method {
 	case http.MethodGet:
 		h.GetAdvert(w, r.WithContext(ctx))
 	case http.MethodPut:
 		h.UpdateAdvert(w, r.WithContext(ctx))
+	case http.MethodPatch:
+		h.PatchAdvert(w, r.WithContext(ctx))
 	case http.MethodDelete:
 		h.DeleteAdvert(w, r.WithContext(ctx))
 	default:
@@ -81,15 +141,276 @@ func (h *Handler) ParticularGroup(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *Handler) parseJson(w http.ResponseWriter, r *http.Request, adv *entity.Advert) error {
-	err := json.NewDecoder(r.Body).Decode(adv)
+// RevisionsGroup routes requests to advert's revisions by rest of path
+// after /v1/adverts/{id}/revisions
+func (h *Handler) RevisionsGroup(w http.ResponseWriter, r *http.Request, path []string) {
+	if len(path) > 1 {
+		h.writeResponse(w, ErrMessage{code: http.StatusNotFound})
+		return
+	}
+	if r.Method != http.MethodGet {
+		h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
+		return
+	}
+
+	switch {
+	case len(path) == 0:
+		h.GetRevisions(w, r)
+	case path[0] == "diff":
+		h.DiffRevisions(w, r)
+	default:
+		rev, err := parseId(path[0])
+		if err != nil {
+			h.l.WriteLog(fmt.Errorf("v1 - RevisionsGroup - %w", err))
+			h.writeResponse(w, ErrMessage{code: http.StatusNotFound})
+			return
+		}
+		h.GetRevision(w, r.WithContext(context.WithValue(r.Context(), entity.KeyRevision, rev)))
+	}
+}
+
+func (h *Handler) TrashGroup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetTrash(w, r)
+	default:
+		h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
+	}
+}
+
+func (h *Handler) CategoriesGroup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetCategories(w, r)
+	case http.MethodPost:
+		h.CreateCategory(w, r)
+	default:
+		h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
+	}
+}
+
+func (h *Handler) UsersGroup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.Register(w, r)
+	default:
+		h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
+	}
+}
+
+func (h *Handler) MeGroup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetMe(w, r)
+	default:
+		h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
+	}
+}
+
+func (h *Handler) SessionsGroup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.Login(w, r)
+	case http.MethodDelete:
+		h.Logout(w, r)
+	default:
+		h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
+	}
+}
+
+func (h *Handler) ApiKeysGroup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetApiKeys(w, r)
+	case http.MethodPost:
+		h.CreateApiKey(w, r)
+	default:
+		h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
+	}
+}
+
+// ApiKeyGroup routes requests to /v1/api-keys/{id} and its rotation
+func (h *Handler) ApiKeyGroup(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/api-keys/"), "/")
+	id, err := parseId(path[0])
+	if err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - ApiKeyGroup - %w", err))
+		h.writeResponse(w, ErrMessage{code: http.StatusNotFound})
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), entity.KeyId, id)
+
+	switch {
+	case len(path) == 1 && r.Method == http.MethodDelete:
+		h.RevokeApiKey(w, r.WithContext(ctx))
+	case len(path) == 2 && path[1] == "rotate" && r.Method == http.MethodPost:
+		h.RotateApiKey(w, r.WithContext(ctx))
+	case len(path) == 1, len(path) == 2 && path[1] == "rotate":
+		h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
+	default:
+		h.writeResponse(w, ErrMessage{code: http.StatusNotFound})
+	}
+}
+
+// UserGroup routes requests to /v1/users/{id}, its roles and API keys
+func (h *Handler) UserGroup(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/users/"), "/")
+	id, err := parseId(path[0])
+	if err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - UserGroup - %w", err))
+		h.writeResponse(w, ErrMessage{code: http.StatusNotFound})
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), entity.KeyId, id)
+
+	switch {
+	case len(path) == 1 && r.Method == http.MethodGet:
+		h.GetUser(w, r.WithContext(ctx))
+	case len(path) == 3 && path[1] == "roles" && path[2] != "":
+		ctx = context.WithValue(ctx, entity.KeyRole, path[2])
+		switch r.Method {
+		case http.MethodPut:
+			h.AssignRole(w, r.WithContext(ctx))
+		case http.MethodDelete:
+			h.UnassignRole(w, r.WithContext(ctx))
+		default:
+			h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
+		}
+	case len(path) == 2 && path[1] == "api-keys" && r.Method == http.MethodGet:
+		h.GetUserApiKeys(w, r.WithContext(ctx))
+	case len(path) == 3 && path[1] == "api-keys":
+		keyId, err := parseId(path[2])
+		if err != nil {
+			h.l.WriteLog(fmt.Errorf("v1 - UserGroup - %w", err))
+			h.writeResponse(w, ErrMessage{code: http.StatusNotFound})
+			return
+		}
+		if r.Method != http.MethodDelete {
+			h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
+			return
+		}
+		h.RevokeUserApiKey(w, r.WithContext(context.WithValue(ctx, entity.KeyApiKey, keyId)))
+	case len(path) == 1, len(path) == 2 && path[1] == "api-keys":
+		h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
+	default:
+		h.writeResponse(w, ErrMessage{code: http.StatusNotFound})
+	}
+}
+
+func (h *Handler) RolesGroup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetRoles(w, r)
+	default:
+		h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
+	}
+}
+
+func (h *Handler) AuditGroup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetAudit(w, r)
+	default:
+		h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
+	}
+}
+
+// CategoryGroup routes requests to /v1/categories/{id} and its adverts
+func (h *Handler) CategoryGroup(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/categories/"), "/")
+	id, err := parseId(path[0])
+	if err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - CategoryGroup - %w", err))
+		h.writeResponse(w, ErrMessage{code: http.StatusNotFound})
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), entity.KeyId, id)
+
+	switch {
+	case len(path) == 1:
+	case len(path) == 2 && path[1] == "adverts":
+		if r.Method != http.MethodGet {
+			h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
+			return
+		}
+		h.GetCategoryAdverts(w, r.WithContext(ctx))
+		return
+	default:
+		h.writeResponse(w, ErrMessage{code: http.StatusNotFound})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.GetCategory(w, r.WithContext(ctx))
+	case http.MethodPut:
+		h.UpdateCategory(w, r.WithContext(ctx))
+	case http.MethodDelete:
+		h.DeleteCategory(w, r.WithContext(ctx))
+	default:
+		h.writeResponse(w, ErrMessage{code: http.StatusMethodNotAllowed})
+	}
+}
+
+// parseId parses positive number written without leading zeros or sign
+func parseId(value string) (int64, error) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parseId - ParseInt: %w", err)
+	}
+	if id <= 0 || strconv.FormatInt(id, 10) != value {
+		return 0, fmt.Errorf("parseId - %v is not valid id", value)
+	}
+	return id, nil
+}
+
+func (h *Handler) parseJson(w http.ResponseWriter, r *http.Request,
+	adv *entity.Advert) (map[string]bool, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.writeResponse(w, ErrMessage{code: http.StatusBadRequest,
+			Error: JsonNotCorrect})
+		return nil, fmt.Errorf("parseJson - ReadAll: %w", err)
+	}
+
+	fields, err := decodeAdvert(body, adv)
 	if err != nil {
 		h.writeResponse(w, ErrMessage{code: http.StatusBadRequest,
 			Error: JsonNotCorrect})
-		return fmt.Errorf(WrongDataFormat)
+		return nil, fmt.Errorf(WrongDataFormat)
 	}
 
-	return nil
+	return fields, nil
+}
+
+// decodeAdvert decodes json object into advert and returns names of its
+// fields that were given, fields with null value are treated as not given
+func decodeAdvert(data []byte, adv *entity.Advert) (map[string]bool, error) {
+	err := json.Unmarshal(data, adv)
+	if err != nil {
+		return nil, fmt.Errorf("decodeAdvert - Unmarshal: %w", err)
+	}
+
+	raw := map[string]json.RawMessage{}
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("decodeAdvert - Unmarshal: %w", err)
+	}
+
+	fields := map[string]bool{}
+	for name, value := range raw {
+		if string(value) != "null" {
+			fields[name] = true
+		}
+	}
+	if !fields[FieldAttributes] {
+		adv.Attributes = nil
+	}
+
+	return fields, nil
 }
 
 func (h *Handler) writeResponse(w http.ResponseWriter, ans Answer) {
@@ -101,12 +422,14 @@ func (h *Handler) writeResponse(w http.ResponseWriter, ans Answer) {
 		return
 	}
 	w.WriteHeader(ans.getCode())
-	if _, err = w.Write(jsonResp); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if _, err = w.Write(jsonResp); err != nil && !errors.Is(err, http.ErrBodyNotAllowed) {
+		h.l.WriteLog(fmt.Errorf("v1 - writeResponse - Write: %w", err))
 	}
 }
 
-func (h *Handler) checkData(adv entity.Advert) ErrMessage {
+// checkData validates advert given as a whole, required fields
+// should be present in request, but may hold zero values except name
+func (h *Handler) checkData(adv entity.Advert, fields map[string]bool) ErrMessage {
 	errMsg := ErrMessage{code: http.StatusBadRequest}
 	switch {
 	case utf8.RuneCountInString(adv.Description) > 1000:
@@ -121,15 +444,18 @@ func (h *Handler) checkData(adv entity.Advert) ErrMessage {
 	case adv.Name == "":
 		errMsg.Error = EmptyFiledRequest
 		errMsg.Detail = `'name:' field is required`
-	case adv.Description == "":
+	case !fields[FieldDescription]:
 		errMsg.Error = EmptyFiledRequest
 		errMsg.Detail = `'description:' field is required`
-	case adv.Price == 0:
+	case !fields[FieldPrice]:
 		errMsg.Error = EmptyFiledRequest
 		errMsg.Detail = `'price:' field is required`
 	case len(adv.PhotosUrls) == 0:
 		errMsg.Error = EmptyFiledRequest
 		errMsg.Detail = `'photo_urls:' field should have at least 1 url`
+	case fields[FieldAttributes] && !bytes.HasPrefix(bytes.TrimSpace(adv.Attributes), []byte("{")):
+		errMsg.Error = WrongDataFormat
+		errMsg.Detail = `'attributes:' field should be object`
 	}
 
 	return errMsg
@@ -0,0 +1,115 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// responseRecorder keeps copy of response written through it
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(code int) {
+	rr.status = code
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
+
+// Idempotent replays response to request with the same 'Idempotency-Key'
+// header and body, key is kept per client. Request with used key and other
+// body is rejected, failed request frees its key to be retried.
+func (h *Handler) Idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > MaxIdempotencyKeyLength {
+			h.writeResponse(w, ErrMessage{code: http.StatusBadRequest, Error: KeyTooLong})
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			h.l.WriteLog(fmt.Errorf("v1 - Idempotent - ReadAll: %w", err))
+			h.writeResponse(w, ErrMessage{code: http.StatusBadRequest, Error: JsonNotCorrect})
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+
+		ttl := time.Duration(h.Cfg.Idempotency.TtlHours) * time.Hour
+		if ttl <= 0 {
+			ttl = DefaultIdempotencyTtl
+		}
+		key = client(r) + " " + key
+		resp, err := h.Service.ReserveIdempotencyKey(r.Context(), key,
+			hex.EncodeToString(sum[:]), ttl)
+		if err != nil {
+			h.l.WriteLog(fmt.Errorf("v1 - Idempotent - h.Service.ReserveIdempotencyKey: %w", err))
+			switch {
+			case errors.Is(err, entity.ErrKeyReused):
+				h.writeResponse(w, ErrMessage{code: http.StatusUnprocessableEntity,
+					Error: KeyReused})
+			case errors.Is(err, entity.ErrKeyInProgress):
+				h.writeResponse(w, ErrMessage{code: http.StatusConflict,
+					Error: KeyInProgress})
+			default:
+				h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+			}
+			return
+		}
+
+		if resp.Status != 0 {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.WriteHeader(resp.Status)
+			if _, err = w.Write(resp.Body); err != nil &&
+				!errors.Is(err, http.ErrBodyNotAllowed) {
+				h.l.WriteLog(fmt.Errorf("v1 - Idempotent - Write: %w", err))
+			}
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		// request's context may be already canceled or timed out,
+		// key is still to be completed or released
+		ctx, cancel := context.WithTimeout(context.Background(), IdempotencySaveTimeout)
+		defer cancel()
+
+		if rec.status >= http.StatusInternalServerError {
+			err = h.Service.ReleaseIdempotencyKey(ctx, key)
+			if err != nil {
+				h.l.WriteLog(fmt.Errorf("v1 - Idempotent - h.Service.ReleaseIdempotencyKey: %w",
+					err))
+			}
+			return
+		}
+		err = h.Service.CompleteIdempotencyKey(ctx, key, rec.status, rec.body.Bytes())
+		if err != nil {
+			h.l.WriteLog(fmt.Errorf("v1 - Idempotent - h.Service.CompleteIdempotencyKey: %w", err))
+		}
+	})
+}
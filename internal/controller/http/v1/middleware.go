@@ -2,29 +2,60 @@ package v1
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/mrsubudei/adv-store-service/internal/entity"
 )
 
+var attributeName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
 func (h *Handler) ParseQuery(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		//checking queries
 		errMsg := ErrMessage{code: http.StatusBadRequest, Error: WrongQueryRequest}
 		getQuery := r.URL.Query().Get
 
+		// 'fields=true' gives all fields, the way it did before fields could be listed
+		var fields entity.Fields
 		if val := getQuery(QueryFields); val != "" && val != QueryValueTrue {
-			errMsg.Detail = `'fields=' query value should be 'true'`
+			var err error
+			if fields, err = entity.ParseFields(val); err != nil {
+				errMsg.Detail = `'fields=' query value is not valid: ` + err.Error()
+			}
 		}
-		if val := getQuery(QuerySortBy); val != "" && val != QueryValueCreatedAt &&
-			val != QueryValuePrice {
-			errMsg.Detail = `'sort_by=' query value should be either 'created_at' or 'price'`
+		if val := getQuery(QuerySortBy); val != "" {
+			if _, ok := entity.SortFields[val]; !ok {
+				errMsg.Detail = `'sort_by=' query value should be one of ` +
+					entity.SortFieldNames()
+			}
 		}
 		if val := getQuery(QueryOrderBy); val != "" && val != QueryValueAsc &&
 			val != QueryValueDesc {
 			errMsg.Detail = `'order_by=' query value should be either 'asc' or 'desc'`
 		}
+		// 'sort_by=' and 'order_by=' are kept as short form of 'sort='
+		var order entity.Sort
+		if val := getQuery(QuerySort); val != "" {
+			var err error
+			if order, err = entity.ParseSort(val); err != nil {
+				errMsg.Detail = `'sort=' query value is not valid: ` + err.Error()
+			} else if getQuery(QuerySortBy) != "" || getQuery(QueryOrderBy) != "" {
+				errMsg.Detail = `'sort=' query can not be used with 'sort_by=' and 'order_by='`
+			}
+		} else if errMsg.Detail == "" {
+			order = legacySort(getQuery(QuerySortBy), getQuery(QueryOrderBy))
+		}
 		if val := getQuery(QueryOffset); val != "" {
 			if parsedToInt, err := strconv.Atoi(val); err != nil || parsedToInt <= 0 {
 				errMsg.Detail = `'offset=' query value should be positive number`
@@ -35,16 +66,55 @@ func (h *Handler) ParseQuery(next http.Handler) http.Handler {
 				errMsg.Detail = `'limit=' query value should be positive number`
 			}
 		}
+		if val := getQuery(QuerySearch); utf8.RuneCountInString(val) > MaxSearchLength {
+			errMsg.Detail = fmt.Sprintf(`'q=' query value should not exceed %d symbols`,
+				MaxSearchLength)
+		}
+		var cursor entity.Cursor
+		if val := getQuery(QueryCursor); val != "" {
+			var cursorOrder entity.Sort
+			var err error
+			cursor, cursorOrder, err = decodeCursor(val)
+			switch {
+			case err != nil:
+				errMsg.Detail = `'cursor=' query value is not valid`
+			case getQuery(QueryOffset) != "":
+				errMsg.Detail = `'cursor=' and 'offset=' queries can not be used together`
+			case order != nil && order.String() != cursor.Sort:
+				errMsg.Detail = `'cursor=' query value does not match requested sort`
+			case getQuery(QuerySearch) != "" && cursor.Sort == "":
+				errMsg.Detail = `'cursor=' query can be used with 'q=' only if sort is given`
+			}
+			order = cursorOrder
+		}
+		filter, detail := parseFilter(r.URL.Query())
+		if detail != "" {
+			errMsg.Detail = detail
+		}
+		// adverts taken by ids are given in order of ids, as they are
+		var ids []int64
+		if val := getQuery(QueryIds); val != "" {
+			var err error
+			ids, err = parseIds(val)
+			switch {
+			case err != nil:
+				errMsg.Detail = `'ids=' query value ` + err.Error()
+			case getQuery(QueryOffset) != "" || getQuery(QueryLimit) != "" ||
+				getQuery(QueryCursor) != "" || order != nil:
+				errMsg.Detail = `'ids=' query can not be used with paging and sorting queries`
+			case getQuery(QuerySearch) != "" || !filter.Empty():
+				errMsg.Detail = `'ids=' query can not be used with search and filter queries`
+			}
+		}
 		if errMsg.Detail != "" {
 			h.writeResponse(w, errMsg)
 			return
 		}
 
 		//parsing and adding queries to context
-		queries := []string{QueryLimit, QueryOffset, QuerySortBy, QueryOrderBy, QueryFields}
-		keys := []entity.ContextKey{entity.KeyLimit, entity.KeyOffset, entity.KeySortBy,
-			entity.KeyOrderBy, entity.KeyFields}
-		ctx := context.Background()
+		queries := []string{QueryLimit, QueryOffset}
+		keys := []entity.ContextKey{entity.KeyLimit, entity.KeyOffset}
+		ctx := r.Context()
 		for i := 0; i < len(queries); i++ {
 			if value := r.URL.Query().Get(queries[i]); value != "" {
 				if parsedToInt, err := strconv.Atoi(value); err == nil {
@@ -55,6 +125,267 @@ func (h *Handler) ParseQuery(next http.Handler) http.Handler {
 			}
 		}
 
+		// cursor carries sort order of the page it was given with
+		if order != nil {
+			ctx = context.WithValue(ctx, entity.KeySort, order)
+		}
+		if getQuery(QueryCursor) != "" {
+			ctx = context.WithValue(ctx, entity.KeyCursor, cursor)
+		}
+
+		// selection is kept only for reading, so that adverts are
+		// not loaded partially to be changed
+		if fields != nil && r.Method == http.MethodGet {
+			ctx = context.WithValue(ctx, entity.KeyFields, fields)
+		}
+
+		if !filter.Empty() {
+			ctx = context.WithValue(ctx, entity.KeyFilter, filter)
+		}
+
+		if ids != nil && r.Method == http.MethodGet {
+			ctx = context.WithValue(ctx, entity.KeyIds, ids)
+		}
+
+		// search query is kept as string even if it looks like a number
+		if value := strings.TrimSpace(getQuery(QuerySearch)); value != "" {
+			ctx = context.WithValue(ctx, entity.KeyQuery, value)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// legacySort returns sort given by 'sort_by=' and 'order_by=' queries,
+// nil if none of them is given
+func legacySort(sortBy, orderBy string) entity.Sort {
+	if sortBy == "" && orderBy == "" {
+		return nil
+	}
+	if sortBy == "" {
+		sortBy = "id"
+	}
+	if orderBy == QueryValueDesc {
+		sortBy = "-" + sortBy
+	}
+	order, _ := entity.ParseSort(sortBy)
+	return order
+}
+
+// Authenticate puts identity of caller into request's context if request
+// has session token or API key in 'Authorization: Bearer' header, requests without
+// the header pass as anonymous, requests with wrong token are rejected
+func (h *Handler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := bearerToken(r)
+		if !ok {
+			h.writeUnauthenticated(w)
+			return
+		}
+		identity, err := h.Service.Authenticate(r.Context(), token)
+		if err != nil {
+			h.l.WriteLog(fmt.Errorf("v1 - Authenticate - h.Service.Authenticate: %w", err))
+			if errors.Is(err, entity.ErrUnauthenticated) {
+				h.writeUnauthenticated(w)
+			} else {
+				h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), entity.KeyIdentity, identity)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// RequireScope rejects requests of callers authenticated with API key
+// which lacks scope of request, reading needs 'adverts:read' scope
+// and other methods need 'adverts:write'. Denials are audited.
+func (h *Handler) RequireScope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scope := entity.ScopeAdvertsWrite
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			scope = entity.ScopeAdvertsRead
+		}
+
+		resource := strings.TrimPrefix(r.URL.Path, "/v1/")
+		err := h.Service.CheckScope(r.Context(), scope, resource)
+		if err != nil {
+			h.l.WriteLog(fmt.Errorf("v1 - RequireScope - h.Service.CheckScope: %w", err))
+			if errors.Is(err, entity.ErrForbidden) {
+				h.writeResponse(w, ErrMessage{code: http.StatusForbidden,
+					Error: fmt.Sprintf(ScopeMissing, scope)})
+				return
+			}
+			h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken returns token from 'Authorization: Bearer' header
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(BearerPrefix) ||
+		!strings.EqualFold(header[:len(BearerPrefix)], BearerPrefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(header[len(BearerPrefix):])
+	return token, token != ""
+}
+
+func (h *Handler) writeUnauthenticated(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="adverts"`)
+	h.writeResponse(w, ErrMessage{code: http.StatusUnauthorized, Error: Unauthenticated})
+}
+
+// parseIds parses comma separated ids of 'ids=' query,
+// repeated ids are kept so that every one of them has result
+func parseIds(value string) ([]int64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) > MaxBatchIds {
+		return nil, fmt.Errorf("should not hold more than %d ids", MaxBatchIds)
+	}
+
+	ids := make([]int64, 0, len(parts))
+	for _, part := range parts {
+		id, err := parseId(strings.TrimSpace(part))
+		if err != nil {
+			return nil, errors.New("should be comma separated positive numbers")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseFilter validates filtering queries and collects them into filter,
+// returns error detail if some query has wrong value
+func parseFilter(query url.Values) (entity.Filter, string) {
+	filter := entity.Filter{}
+	getQuery := query.Get
+
+	if val := getQuery(QueryPriceMin); val != "" {
+		price, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || price < 0 {
+			return filter, `'price_min=' query value should be non-negative number`
+		}
+		filter.PriceMin = &price
+	}
+	if val := getQuery(QueryPriceMax); val != "" {
+		price, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || price < 0 {
+			return filter, `'price_max=' query value should be non-negative number`
+		}
+		filter.PriceMax = &price
+	}
+	if filter.PriceMin != nil && filter.PriceMax != nil && *filter.PriceMin > *filter.PriceMax {
+		return filter, `'price_min=' query value should not be greater than 'price_max='`
+	}
+
+	var err error
+	if val := getQuery(QueryCreatedAfter); val != "" {
+		if filter.CreatedAfter, err = parseDate(val); err != nil {
+			return filter, `'created_after=' query value should be date as 'YYYY-MM-DD' or RFC 3339`
+		}
+	}
+	if val := getQuery(QueryCreatedBefore); val != "" {
+		if filter.CreatedBefore, err = parseDate(val); err != nil {
+			return filter, `'created_before=' query value should be date as 'YYYY-MM-DD' or RFC 3339`
+		}
+	}
+
+	prefix := getQuery(QueryNamePrefix)
+	if utf8.RuneCountInString(prefix) > MaxNameLength {
+		return filter, fmt.Sprintf(`'name_prefix=' query value should not exceed %d symbols`,
+			MaxNameLength)
+	}
+	filter.NamePrefix = prefix
+
+	filter.Attributes, err = parseAttributes(query)
+	if err != nil {
+		return filter, err.Error()
+	}
+
+	return filter, ""
+}
+
+// parseAttributes collects 'attr.{name}=', 'attr.{name}_min=' and
+// 'attr.{name}_max=' queries into conditions ordered by attribute name,
+// value of 'attr.{name}=' is taken as JSON if it is valid and as string otherwise
+func parseAttributes(query url.Values) ([]entity.AttributeFilter, error) {
+	var keys []string
+	for key := range query {
+		if strings.HasPrefix(key, QueryAttribute) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	byName := map[string]*entity.AttributeFilter{}
+	var names []string
+	for _, key := range keys {
+		val := query.Get(key)
+		name := strings.TrimPrefix(key, QueryAttribute)
+		min := strings.HasSuffix(name, QueryAttributeMin)
+		max := !min && strings.HasSuffix(name, QueryAttributeMax)
+		if min {
+			name = strings.TrimSuffix(name, QueryAttributeMin)
+		} else if max {
+			name = strings.TrimSuffix(name, QueryAttributeMax)
+		}
+		if !attributeName.MatchString(name) {
+			return nil, fmt.Errorf(`'%v=' query should name attribute with latin letters, `+
+				`digits and '_'`, key)
+		}
+
+		cond := byName[name]
+		if cond == nil {
+			cond = &entity.AttributeFilter{Name: name}
+			byName[name] = cond
+			names = append(names, name)
+		}
+		if min || max {
+			number, err := strconv.ParseFloat(val, 64)
+			if err != nil || math.IsInf(number, 0) || math.IsNaN(number) {
+				return nil, fmt.Errorf(`'%v=' query value should be number`, key)
+			}
+			if min {
+				cond.Min = &number
+			} else {
+				cond.Max = &number
+			}
+			continue
+		}
+		if json.Valid([]byte(val)) {
+			cond.Equal = json.RawMessage(val)
+		} else {
+			cond.Equal, _ = json.Marshal(val)
+		}
+	}
+
+	sort.Strings(names)
+	var conditions []entity.AttributeFilter
+	for _, name := range names {
+		cond := byName[name]
+		if cond.Min != nil && cond.Max != nil && *cond.Min > *cond.Max {
+			return nil, fmt.Errorf(`'%v%v%v=' query value should not be greater than '%v%v%v='`,
+				QueryAttribute, name, QueryAttributeMin, QueryAttribute, name, QueryAttributeMax)
+		}
+		conditions = append(conditions, *cond)
+	}
+	return conditions, nil
+}
+
+func parseDate(val string) (time.Time, error) {
+	if date, err := time.ParseInLocation(QueryDateFormat, val, time.Local); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, val)
+}
@@ -1,9 +1,15 @@
 package v1_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/mrsubudei/adv-store-service/internal/config"
@@ -27,28 +33,53 @@ func setup() *v1.Handler {
 	return handler
 }
 
+// sessionToken registers user with given roles and returns token of its session
+func sessionToken(t *testing.T, handler *v1.Handler, email string, roles ...string) string {
+	serve := func(url, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, url, bytes.NewBufferString(body))
+		handler.Mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	cred := `{"email":"` + email + `","password":"password"}`
+	var user struct {
+		Data []entity.User `json:"data"`
+	}
+	if err := json.Unmarshal(serve("/v1/users", cred).Body.Bytes(), &user); err != nil ||
+		len(user.Data) != 1 {
+		t.Fatalf("user is not registered: %v", err)
+	}
+	handler.Service.(*mock.MockService).UserRoles[user.Data[0].Id] = roles
+
+	var session struct {
+		Data entity.Session `json:"data"`
+	}
+	if err := json.Unmarshal(serve("/v1/sessions", cred).Body.Bytes(), &session); err != nil {
+		t.Fatal(err)
+	}
+	return session.Data.Token
+}
+
 func getMockHandler() http.HandlerFunc {
 	mockHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		limit := 0
 		offset := 0
 		sortBy := ""
-		orderBy := ""
 		if val, ok := r.Context().Value(entity.KeyLimit).(int); ok && val != 0 {
 			limit = val
 		}
 		if val, ok := r.Context().Value(entity.KeyOffset).(int); ok && val != 0 {
 			offset = val
 		}
-		if val, ok := r.Context().Value(entity.KeySortBy).(string); ok && val != "" {
-			if val == "price" {
-				sortBy = val
+		if val, ok := r.Context().Value(entity.KeySort).(entity.Sort); ok && len(val) != 0 {
+			if val.String() == "price,id" {
+				sortBy = val.String()
 			}
 		}
-		if val, ok := r.Context().Value(entity.KeyOrderBy).(string); ok && val != "" {
-			orderBy = val
-		}
-		if limit != 0 && offset != 0 && sortBy != "" && orderBy != "" {
+		_, cursor := r.Context().Value(entity.KeyCursor).(entity.Cursor)
+		if limit != 0 && (offset != 0 || cursor) && sortBy != "" {
 			w.WriteHeader(http.StatusOK)
 		} else {
 			w.WriteHeader(http.StatusBadRequest)
@@ -60,6 +91,8 @@ func getMockHandler() http.HandlerFunc {
 
 func TestParseQuery(t *testing.T) {
 	handler := setup()
+	cursor := base64.RawURLEncoding.EncodeToString(
+		[]byte(`{"s":"price,id","v":["10"],"id":3}`))
 
 	tests := []struct {
 		name       string
@@ -72,17 +105,144 @@ func TestParseQuery(t *testing.T) {
 			url:        "/v1/adverts?limit=10&offset=20&sort_by=price&order_by=asc",
 			wantStatus: http.StatusOK,
 		},
+		{
+			name:       "OK with search",
+			url:        "/v1/adverts?limit=10&offset=20&sort_by=price&order_by=asc&q=red+car",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Error wrong query: q",
+			url:        "/v1/adverts?q=" + strings.Repeat("a", 201),
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'q=' query value should not exceed 200 symbols"}`,
+		},
+		{
+			name:       "OK with filters",
+			url:        "/v1/adverts?limit=10&offset=20&sort_by=price&order_by=asc&price_min=5&price_max=10&created_after=2023-01-01&created_before=2023-02-01T10:00:00Z&name_prefix=car",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Error wrong query: ids",
+			url:        "/v1/adverts?ids=1,0",
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'ids=' query value should be comma separated positive numbers"}`,
+		},
+		{
+			name:       "Error wrong query: ids with filter",
+			url:        "/v1/adverts?ids=1,2&price_min=5",
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'ids=' query can not be used with search and filter queries"}`,
+		},
+		{
+			name:       "Error wrong query: price_min",
+			url:        "/v1/adverts?price_min=-1",
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'price_min=' query value should be non-negative number"}`,
+		},
+		{
+			name:       "Error wrong query: price_max",
+			url:        "/v1/adverts?price_max=abc",
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'price_max=' query value should be non-negative number"}`,
+		},
+		{
+			name:       "Error wrong query: price range",
+			url:        "/v1/adverts?price_min=10&price_max=5",
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'price_min=' query value should not be greater than 'price_max='"}`,
+		},
+		{
+			name:       "OK with attribute filters",
+			url:        "/v1/adverts?limit=10&offset=20&sort_by=price&order_by=asc&attr.year_min=2015&attr.year_max=2020.5&attr.color=red",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Error wrong query: attribute bound",
+			url:        "/v1/adverts?attr.year_min=new",
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'attr.year_min=' query value should be number"}`,
+		},
+		{
+			name:       "Error wrong query: attribute range",
+			url:        "/v1/adverts?attr.year_min=2020&attr.year_max=2015",
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'attr.year_min=' query value should not be greater than 'attr.year_max='"}`,
+		},
+		{
+			name:       "Error wrong query: attribute name",
+			url:        "/v1/adverts?attr.a.b=1",
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'attr.a.b=' query should name attribute with latin letters, digits and '_'"}`,
+		},
+		{
+			name:       "Error wrong query: created_after",
+			url:        "/v1/adverts?created_after=01.01.2023",
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'created_after=' query value should be date as 'YYYY-MM-DD' or RFC 3339"}`,
+		},
+		{
+			name:       "Error wrong query: created_before",
+			url:        "/v1/adverts?created_before=yesterday",
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'created_before=' query value should be date as 'YYYY-MM-DD' or RFC 3339"}`,
+		},
+		{
+			name:       "OK with cursor",
+			url:        "/v1/adverts?limit=10&cursor=" + cursor,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Error wrong query: cursor",
+			url:        "/v1/adverts?cursor=abc",
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'cursor=' query value is not valid"}`,
+		},
+		{
+			name:       "Error wrong query: cursor with offset",
+			url:        "/v1/adverts?offset=10&cursor=" + cursor,
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'cursor=' and 'offset=' queries can not be used together"}`,
+		},
+		{
+			name:       "Error wrong query: cursor with other order",
+			url:        "/v1/adverts?order_by=desc&cursor=" + cursor,
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'cursor=' query value does not match requested sort"}`,
+		},
 		{
 			name:       "Error wrong query: fields",
 			url:        "/v1/adverts?fields=abc",
 			wantStatus: http.StatusBadRequest,
-			wantResult: `{"error":"queries have wrong value","detail":"'fields=' query value should be 'true'"}`,
+			wantResult: `{"error":"queries have wrong value","detail":"'fields=' query value is not valid: unknown field 'abc', allowed fields are 'attributes', 'category_id', 'created_at', 'description', 'id', 'main_photo_url', 'name', 'owner_id', 'photo_urls', 'price'"}`,
 		},
 		{
 			name:       "Error wrong query: sort_by",
 			url:        "/v1/adverts?sort_by=abc",
 			wantStatus: http.StatusBadRequest,
-			wantResult: `{"error":"queries have wrong value","detail":"'sort_by=' query value should be either 'created_at' or 'price'"}`,
+			wantResult: `{"error":"queries have wrong value","detail":"'sort_by=' query value should be one of 'created_at', 'id', 'name', 'price'"}`,
+		},
+		{
+			name:       "OK with sort",
+			url:        "/v1/adverts?limit=10&offset=20&sort=price",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Error wrong query: sort unknown key",
+			url:        "/v1/adverts?sort=-price,color",
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'sort=' query value is not valid: unknown key 'color', allowed keys are 'created_at', 'id', 'name', 'price'"}`,
+		},
+		{
+			name:       "Error wrong query: sort repeated key",
+			url:        "/v1/adverts?sort=price,-price",
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'sort=' query value is not valid: key 'price' is repeated"}`,
+		},
+		{
+			name:       "Error wrong query: sort with sort_by",
+			url:        "/v1/adverts?sort=price&sort_by=price",
+			wantStatus: http.StatusBadRequest,
+			wantResult: `{"error":"queries have wrong value","detail":"'sort=' query can not be used with 'sort_by=' and 'order_by='"}`,
 		},
 		{
 			name:       "Error wrong query: order_by",
@@ -127,3 +287,95 @@ func TestParseQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestRateLimit(t *testing.T) {
+	handler := setup()
+	handler.Cfg.RateLimit.Routes = map[string]config.RateLimitRule{
+		"POST /v1/users": {Limit: 2, WindowSeconds: 60},
+	}
+	serve := func(method, url, remoteAddr string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, url, strings.NewReader(`{}`))
+		req.RemoteAddr = remoteAddr
+		handler.Mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		rec := serve(http.MethodPost, "/v1/users", "10.0.0.1:1234")
+		if rec.Code == http.StatusTooManyRequests {
+			t.Fatalf("request %d should be allowed", i+1)
+		}
+		if got := rec.Header().Get("RateLimit-Remaining"); got != strconv.Itoa(1-i) {
+			t.Fatalf("want remaining: %v, got: %v", 1-i, got)
+		}
+	}
+
+	rec := serve(http.MethodPost, "/v1/users", "10.0.0.1:4321")
+	wantResult := `{"error":"too many requests, retry after 30 seconds"}`
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("want: %v, got: %v", http.StatusTooManyRequests, rec.Code)
+	} else if rec.Body.String() != wantResult {
+		t.Fatalf("want: %v, got: %v", wantResult, rec.Body.String())
+	}
+	if got := rec.Header().Get("Retry-After"); got != "30" {
+		t.Fatalf("want Retry-After: 30, got: %v", got)
+	}
+	if got := rec.Header().Get("RateLimit-Limit"); got != "2" {
+		t.Fatalf("want RateLimit-Limit: 2, got: %v", got)
+	}
+
+	if rec = serve(http.MethodPost, "/v1/users", "10.0.0.2:1234"); rec.Code ==
+		http.StatusTooManyRequests {
+		t.Fatal("other client should not be limited")
+	}
+	if rec = serve(http.MethodGet, "/v1/users/me", "10.0.0.1:1234"); rec.Header().Get(
+		"RateLimit-Limit") != "" {
+		t.Fatal("route without quota should not be limited")
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	handler := setup()
+	handler.Cfg.Timeout.Routes = map[string]int{"GET /v1/slow": 10}
+	handler.Cfg.Timeout.DefaultMilliseconds = 0
+	handler.Mux.Handle("/v1/slow", handler.Timeout(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			w.WriteHeader(http.StatusInternalServerError)
+		})))
+	handler.Mux.Handle("/v1/fast", handler.Timeout(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Deadline(); ok {
+				t.Error("route without timeout should not get deadline")
+			}
+			w.Header().Set("X-Test", "fast")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{}`))
+		})))
+
+	rec := httptest.NewRecorder()
+	handler.Mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/slow", nil))
+	wantResult := `{"error":"request did not finish within 10ms"}`
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("want: %v, got: %v", http.StatusGatewayTimeout, rec.Code)
+	} else if rec.Body.String() != wantResult {
+		t.Fatalf("want: %v, got: %v", wantResult, rec.Body.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec = httptest.NewRecorder()
+	handler.Mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/slow",
+		nil).WithContext(ctx))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("want: %v, got: %v", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.Mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/fast", nil))
+	if rec.Code != http.StatusCreated || rec.Body.String() != `{}` ||
+		rec.Header().Get("X-Test") != "fast" {
+		t.Fatalf("response should be passed as is, got: %v %v", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,363 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+var (
+	errPatchType       = errors.New("unsupported patch content type")
+	errPatchNotCorrect = errors.New("patch is not correct")
+	errPatchTestFailed = errors.New("patch test operation failed")
+)
+
+// advertDocument is advert's representation patches are applied to
+type advertDocument struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Price       int64           `json:"price"`
+	PhotosUrls  []string        `json:"photo_urls"`
+	CategoryId  int64           `json:"category_id,omitempty"`
+	Attributes  json.RawMessage `json:"attributes,omitempty"`
+}
+
+type patchOperation struct {
+	Op    string           `json:"op"`
+	Path  *string          `json:"path"`
+	From  *string          `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// applyPatch applies JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902)
+// to advert depending on content type, returns patched advert and
+// names of its fields that are present after patching
+func applyPatch(adv entity.Advert, contentType string,
+	patch []byte) (entity.Advert, map[string]bool, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return adv, nil, fmt.Errorf("applyPatch - ParseMediaType: %w", errPatchType)
+	}
+
+	docJson, err := json.Marshal(advertDocument{
+		Name:        adv.Name,
+		Description: adv.Description,
+		Price:       adv.Price,
+		PhotosUrls:  adv.PhotosUrls,
+		CategoryId:  adv.CategoryId,
+		Attributes:  adv.Attributes,
+	})
+	if err != nil {
+		return adv, nil, fmt.Errorf("applyPatch - Marshal: %w", err)
+	}
+	var doc interface{}
+	if err = decodeJson(docJson, &doc); err != nil {
+		return adv, nil, fmt.Errorf("applyPatch - %w", err)
+	}
+
+	switch mediaType {
+	case MergePatchType:
+		var mergePatch interface{}
+		if err = decodeJson(patch, &mergePatch); err != nil {
+			return adv, nil, fmt.Errorf("applyPatch - %v: %w", err, errPatchNotCorrect)
+		}
+		doc = applyMergePatch(doc, mergePatch)
+	case JsonPatchType:
+		var operations []patchOperation
+		if err = json.Unmarshal(patch, &operations); err != nil {
+			return adv, nil, fmt.Errorf("applyPatch - Unmarshal: %v: %w", err, errPatchNotCorrect)
+		}
+		for _, operation := range operations {
+			if doc, err = applyOperation(doc, operation); err != nil {
+				return adv, nil, fmt.Errorf("applyPatch - %w", err)
+			}
+		}
+	default:
+		return adv, nil, fmt.Errorf("applyPatch - %v: %w", mediaType, errPatchType)
+	}
+
+	object, ok := doc.(map[string]interface{})
+	if !ok {
+		return adv, nil, fmt.Errorf("applyPatch - result is not object: %w", errPatchNotCorrect)
+	}
+	fields := map[string]bool{}
+	for name, value := range object {
+		if value != nil {
+			fields[name] = true
+		}
+	}
+
+	// patched document should still be an advert
+	patchedJson, err := json.Marshal(object)
+	if err != nil {
+		return adv, nil, fmt.Errorf("applyPatch - Marshal: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(patchedJson))
+	decoder.DisallowUnknownFields()
+	patched := advertDocument{}
+	if err = decoder.Decode(&patched); err != nil {
+		return adv, nil, fmt.Errorf("applyPatch - Decode: %v: %w", err, errPatchNotCorrect)
+	}
+
+	adv.Name = patched.Name
+	adv.Description = patched.Description
+	adv.Price = patched.Price
+	adv.PhotosUrls = patched.PhotosUrls
+	adv.CategoryId = patched.CategoryId
+	adv.Attributes = patched.Attributes
+	return adv, fields, nil
+}
+
+func decodeJson(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("decodeJson - Decode: %w", err)
+	}
+	return nil
+}
+
+// applyMergePatch follows algorithm from RFC 7396
+func applyMergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = applyMergePatch(targetObject[name], value)
+		}
+	}
+
+	return targetObject
+}
+
+// applyOperation applies single operation of JSON Patch from RFC 6902
+func applyOperation(doc interface{}, operation patchOperation) (interface{}, error) {
+	if operation.Path == nil {
+		return doc, fmt.Errorf("applyOperation - missing path: %w", errPatchNotCorrect)
+	}
+	path, err := parsePointer(*operation.Path)
+	if err != nil {
+		return doc, fmt.Errorf("applyOperation - %w", err)
+	}
+
+	var value interface{}
+	switch operation.Op {
+	case "add", "replace", "test":
+		if operation.Value == nil {
+			return doc, fmt.Errorf("applyOperation - missing value: %w", errPatchNotCorrect)
+		}
+		if err = decodeJson(*operation.Value, &value); err != nil {
+			return doc, fmt.Errorf("applyOperation - %v: %w", err, errPatchNotCorrect)
+		}
+	case "move", "copy":
+		if operation.From == nil {
+			return doc, fmt.Errorf("applyOperation - missing from: %w", errPatchNotCorrect)
+		}
+		from, err := parsePointer(*operation.From)
+		if err != nil {
+			return doc, fmt.Errorf("applyOperation - %w", err)
+		}
+		if value, err = getValue(doc, from); err != nil {
+			return doc, fmt.Errorf("applyOperation - %w", err)
+		}
+		if operation.Op == "move" {
+			if strings.HasPrefix(*operation.Path+"/", *operation.From+"/") &&
+				*operation.Path != *operation.From {
+				return doc, fmt.Errorf("applyOperation - move into itself: %w",
+					errPatchNotCorrect)
+			}
+			if doc, err = removeValue(doc, from); err != nil {
+				return doc, fmt.Errorf("applyOperation - %w", err)
+			}
+		} else if value, err = copyValue(value); err != nil {
+			return doc, fmt.Errorf("applyOperation - %w", err)
+		}
+	}
+
+	switch operation.Op {
+	case "add", "move", "copy":
+		doc, err = addValue(doc, path, value)
+	case "remove":
+		doc, err = removeValue(doc, path)
+	case "replace":
+		if doc, err = removeValue(doc, path); err == nil {
+			doc, err = addValue(doc, path, value)
+		}
+	case "test":
+		var current interface{}
+		if current, err = getValue(doc, path); err == nil && !equalValues(current, value) {
+			err = fmt.Errorf("%v: %w", *operation.Path, errPatchTestFailed)
+		}
+	default:
+		err = fmt.Errorf("unknown operation '%v': %w", operation.Op, errPatchNotCorrect)
+	}
+	if err != nil {
+		return doc, fmt.Errorf("applyOperation - %w", err)
+	}
+
+	return doc, nil
+}
+
+// parsePointer splits JSON Pointer from RFC 6901 into reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("parsePointer - %v: %w", pointer, errPatchNotCorrect)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i := 0; i < len(tokens); i++ {
+		tokens[i] = strings.ReplaceAll(tokens[i], "~1", "/")
+		tokens[i] = strings.ReplaceAll(tokens[i], "~0", "~")
+	}
+	return tokens, nil
+}
+
+func arrayIndex(token string, length int, appendable bool) (int, error) {
+	if appendable && token == "-" {
+		return length, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("arrayIndex - %v: %w", token, errPatchNotCorrect)
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > length || (!appendable && index == length) {
+		return 0, fmt.Errorf("arrayIndex - %v: %w", token, errPatchNotCorrect)
+	}
+	return index, nil
+}
+
+func getValue(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("getValue - %v not found: %w", token, errPatchNotCorrect)
+			}
+			doc = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, fmt.Errorf("getValue - %w", err)
+			}
+			doc = node[index]
+		default:
+			return nil, fmt.Errorf("getValue - %v not found: %w", token, errPatchNotCorrect)
+		}
+	}
+	return doc, nil
+}
+
+// updateParent calls update with container holding value at path and
+// last token of path, containers on path are replaced with updated ones
+func updateParent(doc interface{}, path []string,
+	update func(interface{}, string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return update(doc, path[0])
+	}
+
+	child, err := getValue(doc, path[:1])
+	if err != nil {
+		return doc, err
+	}
+	child, err = updateParent(child, path[1:], update)
+	if err != nil {
+		return doc, err
+	}
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		node[path[0]] = child
+	case []interface{}:
+		index, _ := arrayIndex(path[0], len(node), false)
+		node[index] = child
+	}
+	return doc, nil
+}
+
+func addValue(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return updateParent(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), true)
+			if err != nil {
+				return parent, fmt.Errorf("addValue - %w", err)
+			}
+			node = append(node[:index], append([]interface{}{value}, node[index:]...)...)
+			return node, nil
+		}
+		return parent, fmt.Errorf("addValue - %v not found: %w", token, errPatchNotCorrect)
+	})
+}
+
+func removeValue(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+
+	return updateParent(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := node[token]; ok {
+				delete(node, token)
+				return node, nil
+			}
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return parent, fmt.Errorf("removeValue - %w", err)
+			}
+			return append(node[:index], node[index+1:]...), nil
+		}
+		return parent, fmt.Errorf("removeValue - %v not found: %w", token, errPatchNotCorrect)
+	})
+}
+
+func copyValue(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("copyValue - Marshal: %w", err)
+	}
+	var copied interface{}
+	if err = decodeJson(data, &copied); err != nil {
+		return nil, fmt.Errorf("copyValue - %w", err)
+	}
+	return copied, nil
+}
+
+func equalValues(a, b interface{}) bool {
+	first, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	second, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(first, second)
+}
@@ -0,0 +1,180 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mrsubudei/adv-store-service/internal/config"
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// RateLimiter keeps token buckets of clients in memory, bucket
+// is created on first request and evicted once refilled
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	rule    config.RateLimitRule
+}
+
+// quota is state of client's bucket after request
+type quota struct {
+	allowed    bool
+	remaining  int
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// take takes token from client's bucket if there is one
+func (l *RateLimiter) take(key string, rule config.RateLimitRule) quota {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok || b.rule != rule {
+		b = &bucket{tokens: float64(rule.Limit), updated: now, rule: rule}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+
+	q := quota{}
+	if b.tokens >= 1 {
+		b.tokens--
+		q.allowed = true
+	} else {
+		q.retryAfter = b.duration(1 - b.tokens)
+	}
+	q.remaining = int(b.tokens)
+	q.reset = b.duration(float64(rule.Limit) - b.tokens)
+	return q
+}
+
+// Evict removes buckets which are full, they are
+// no different from buckets of new clients
+func (l *RateLimiter) Evict() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.rule.Limit) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Run evicts buckets every interval until context is done
+func (l *RateLimiter) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.Evict()
+		}
+	}
+}
+
+// rate is number of tokens added per second
+func (b *bucket) rate() float64 {
+	return float64(b.rule.Limit) / float64(b.rule.WindowSeconds)
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.rule.Limit), b.tokens+elapsed*b.rate())
+	}
+	b.updated = now
+}
+
+// duration is time in which bucket gets given number of tokens
+func (b *bucket) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / b.rate() * float64(time.Second))
+}
+
+// RateLimit rejects requests of client which used up quota of route,
+// client is told by its API key, user or IP. It should go after Authenticate.
+func (h *Handler) RateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, route, ok := routeRule(h, r, h.Cfg.RateLimit.Routes)
+		if !ok || rule.Limit <= 0 || rule.WindowSeconds <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		q := h.Limiter.take(route+" "+client(r), rule)
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(rule.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(q.remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(q.reset)))
+		if !q.allowed {
+			retryAfter := seconds(q.retryAfter)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			h.writeResponse(w, ErrMessage{code: http.StatusTooManyRequests,
+				Error: fmt.Sprintf(TooManyRequests, retryAfter)})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeRule looks up rule of route request goes to by method
+// and pattern of route first and then by pattern alone
+func routeRule[T any](h *Handler, r *http.Request, rules map[string]T) (T, string, bool) {
+	_, pattern := h.Mux.Handler(r)
+	route := r.Method + " " + pattern
+	rule, ok := rules[route]
+	if !ok {
+		route = pattern
+		rule, ok = rules[route]
+	}
+	return rule, route, ok
+}
+
+// client returns key of caller's bucket
+func client(r *http.Request) string {
+	if identity, ok := r.Context().Value(entity.KeyIdentity).(entity.Identity); ok {
+		if identity.ApiKeyId != 0 {
+			return fmt.Sprintf("key:%v", identity.ApiKeyId)
+		}
+		return fmt.Sprintf("user:%v", identity.UserId)
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// seconds rounds duration up to whole seconds
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
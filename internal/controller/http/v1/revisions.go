@@ -0,0 +1,87 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+func (h *Handler) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	id := r.Context().Value(entity.KeyId).(int64)
+
+	revisions, err := h.Service.GetRevisions(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, entity.ErrItemNotExists) {
+			h.l.WriteLog(fmt.Errorf("v1 - GetRevisions - h.Service.GetRevisions: %w", err))
+			h.writeResponse(w, ErrMessage{code: http.StatusNotFound,
+				Error: NoContentFound + strconv.Itoa(int(id))})
+			return
+		}
+		h.writeAccessError(w, fmt.Errorf("v1 - GetRevisions - h.Service.GetRevisions: %w", err))
+		return
+	}
+
+	ans := RevisionsResponse{
+		Data: revisions,
+		code: http.StatusOK,
+	}
+	h.writeResponse(w, ans)
+}
+
+func (h *Handler) GetRevision(w http.ResponseWriter, r *http.Request) {
+	id := r.Context().Value(entity.KeyId).(int64)
+	rev := r.Context().Value(entity.KeyRevision).(int64)
+
+	revision, err := h.Service.GetRevision(r.Context(), id, rev)
+	if err != nil {
+		if errors.Is(err, entity.ErrItemNotExists) {
+			h.l.WriteLog(fmt.Errorf("v1 - GetRevision - h.Service.GetRevision: %w", err))
+			h.writeResponse(w, ErrMessage{code: http.StatusNotFound,
+				Error: NoRevisionFound + strconv.Itoa(int(rev))})
+			return
+		}
+		h.writeAccessError(w, fmt.Errorf("v1 - GetRevision - h.Service.GetRevision: %w", err))
+		return
+	}
+
+	ans := RevisionsResponse{
+		Data: []entity.Revision{revision},
+		code: http.StatusOK,
+	}
+	h.writeResponse(w, ans)
+}
+
+// DiffRevisions responds with fields changed between revisions
+// given by 'from=' and 'to=' queries
+func (h *Handler) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	id := r.Context().Value(entity.KeyId).(int64)
+
+	from, errFrom := parseId(r.URL.Query().Get(QueryFrom))
+	to, errTo := parseId(r.URL.Query().Get(QueryTo))
+	if errFrom != nil || errTo != nil {
+		h.writeResponse(w, ErrMessage{code: http.StatusBadRequest, Error: WrongQueryRequest,
+			Detail: `'from=' and 'to=' query values should be revision numbers`})
+		return
+	}
+
+	diff, err := h.Service.DiffRevisions(r.Context(), id, from, to)
+	if err != nil {
+		if errors.Is(err, entity.ErrItemNotExists) {
+			h.l.WriteLog(fmt.Errorf("v1 - DiffRevisions - h.Service.DiffRevisions: %w", err))
+			h.writeResponse(w, ErrMessage{code: http.StatusNotFound,
+				Error: fmt.Sprintf("%v%v or %v", NoRevisionFound, from, to)})
+			return
+		}
+		h.writeAccessError(w, fmt.Errorf("v1 - DiffRevisions - h.Service.DiffRevisions: %w", err))
+		return
+	}
+
+	ans := DiffResponse{
+		Data: diff,
+		code: http.StatusOK,
+	}
+	h.writeResponse(w, ans)
+}
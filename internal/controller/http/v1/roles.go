@@ -0,0 +1,148 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// HideAdvert hides advert from lists and from users who may not hide it,
+// or shows it again
+func (h *Handler) HideAdvert(w http.ResponseWriter, r *http.Request, hidden bool) {
+	id := r.Context().Value(entity.KeyId).(int64)
+
+	err := h.Service.HideAdvert(r.Context(), id, hidden)
+	if err != nil {
+		err = fmt.Errorf("v1 - HideAdvert - h.Service.HideAdvert: %w", err)
+		switch {
+		case errors.Is(err, entity.ErrItemNotExists):
+			h.l.WriteLog(err)
+			h.writeResponse(w, ErrMessage{code: http.StatusNotFound,
+				Error: NoContentFound + strconv.Itoa(int(id))})
+		case errors.Is(err, entity.ErrForbidden):
+			h.l.WriteLog(err)
+			h.writeResponse(w, ErrMessage{code: http.StatusForbidden, Error: HideForbidden})
+		default:
+			h.writeAccessError(w, err)
+		}
+		return
+	}
+
+	h.writeResponse(w, Response{code: http.StatusNoContent})
+}
+
+// GetUser responds with user and names of its roles
+func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+	id := r.Context().Value(entity.KeyId).(int64)
+
+	user, err := h.Service.GetUser(r.Context(), id)
+	if err != nil {
+		h.writeAdminError(w, fmt.Errorf("v1 - GetUser - h.Service.GetUser: %w", err),
+			NoContentFound+strconv.Itoa(int(id)))
+		return
+	}
+
+	h.writeResponse(w, UsersResponse{Data: []entity.User{user}, code: http.StatusOK})
+}
+
+func (h *Handler) GetRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.Service.GetRoles(r.Context())
+	if err != nil {
+		h.writeAdminError(w, fmt.Errorf("v1 - GetRoles - h.Service.GetRoles: %w", err), "")
+		return
+	}
+
+	h.writeResponse(w, RolesResponse{Data: roles, code: http.StatusOK})
+}
+
+func (h *Handler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	id := r.Context().Value(entity.KeyId).(int64)
+	role := r.Context().Value(entity.KeyRole).(string)
+
+	err := h.Service.AssignRole(r.Context(), id, role)
+	if err != nil {
+		notFound := NoContentFound + strconv.Itoa(int(id))
+		if errors.Is(err, entity.ErrWrongRole) {
+			notFound = NoRoleFound + role
+		}
+		h.writeAdminError(w, fmt.Errorf("v1 - AssignRole - h.Service.AssignRole: %w", err), notFound)
+		return
+	}
+
+	h.writeResponse(w, Response{code: http.StatusNoContent})
+}
+
+func (h *Handler) UnassignRole(w http.ResponseWriter, r *http.Request) {
+	id := r.Context().Value(entity.KeyId).(int64)
+	role := r.Context().Value(entity.KeyRole).(string)
+
+	err := h.Service.UnassignRole(r.Context(), id, role)
+	if err != nil {
+		notFound := NoContentFound + strconv.Itoa(int(id))
+		if errors.Is(err, entity.ErrWrongRole) {
+			notFound = NoRoleFound + role
+		}
+		h.writeAdminError(w, fmt.Errorf("v1 - UnassignRole - h.Service.UnassignRole: %w", err), notFound)
+		return
+	}
+
+	h.writeResponse(w, Response{code: http.StatusNoContent})
+}
+
+// GetUserApiKeys responds with keys of any user, revoked ones included
+func (h *Handler) GetUserApiKeys(w http.ResponseWriter, r *http.Request) {
+	id := r.Context().Value(entity.KeyId).(int64)
+
+	keys, err := h.Service.GetUserApiKeys(r.Context(), id)
+	if err != nil {
+		h.writeAdminError(w, fmt.Errorf("v1 - GetUserApiKeys - h.Service.GetUserApiKeys: %w",
+			err), NoContentFound+strconv.Itoa(int(id)))
+		return
+	}
+
+	h.writeResponse(w, ApiKeysResponse{Data: keys, code: http.StatusOK})
+}
+
+func (h *Handler) RevokeUserApiKey(w http.ResponseWriter, r *http.Request) {
+	id := r.Context().Value(entity.KeyId).(int64)
+	keyId := r.Context().Value(entity.KeyApiKey).(int64)
+
+	err := h.Service.RevokeUserApiKey(r.Context(), id, keyId)
+	if err != nil {
+		h.writeAdminError(w, fmt.Errorf("v1 - RevokeUserApiKey - h.Service.RevokeUserApiKey: %w",
+			err), NoContentFound+strconv.Itoa(int(keyId)))
+		return
+	}
+
+	h.writeResponse(w, Response{code: http.StatusNoContent})
+}
+
+// GetAudit responds with page of audit trail by limit and offset queries
+func (h *Handler) GetAudit(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.Service.GetAudit(r.Context())
+	if err != nil {
+		h.writeAdminError(w, fmt.Errorf("v1 - GetAudit - h.Service.GetAudit: %w", err), "")
+		return
+	}
+
+	h.writeResponse(w, AuditResponse{Data: entries, code: http.StatusOK})
+}
+
+// writeAdminError responds to failed request to administration endpoints,
+// notFound is message for user, key or role that does not exist
+func (h *Handler) writeAdminError(w http.ResponseWriter, err error, notFound string) {
+	h.l.WriteLog(err)
+	switch {
+	case errors.Is(err, entity.ErrUnauthenticated):
+		h.writeUnauthenticated(w)
+	case errors.Is(err, entity.ErrForbidden):
+		h.writeResponse(w, ErrMessage{code: http.StatusForbidden, Error: AdminForbidden})
+	case errors.Is(err, entity.ErrItemNotExists), errors.Is(err, entity.ErrWrongRole):
+		h.writeResponse(w, ErrMessage{code: http.StatusNotFound, Error: notFound})
+	default:
+		h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+	}
+}
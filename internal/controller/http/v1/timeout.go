@@ -0,0 +1,80 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// bufferedWriter holds response until handler returns, so failed
+// response could be replaced once request context is done
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (bw *bufferedWriter) Header() http.Header {
+	return bw.header
+}
+
+func (bw *bufferedWriter) WriteHeader(code int) {
+	if bw.status == 0 {
+		bw.status = code
+	}
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	return bw.body.Write(b)
+}
+
+// flush writes held response to w
+func (bw *bufferedWriter) flush(w http.ResponseWriter) {
+	for k, v := range bw.header {
+		w.Header()[k] = v
+	}
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	w.WriteHeader(bw.status)
+	w.Write(bw.body.Bytes())
+}
+
+// Timeout sets deadline of route on request context. Server error written
+// after deadline is hit or request is canceled becomes 504 or 503 response.
+func (h *Handler) Timeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ms, _, ok := routeRule(h, r, h.Cfg.Timeout.Routes)
+		if !ok {
+			ms = h.Cfg.Timeout.DefaultMilliseconds
+		}
+		ctx := r.Context()
+		if ms > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+			defer cancel()
+		}
+
+		bw := &bufferedWriter{header: make(http.Header)}
+		next.ServeHTTP(bw, r.WithContext(ctx))
+
+		err := ctx.Err()
+		if err == nil || bw.status < http.StatusInternalServerError {
+			bw.flush(w)
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			h.writeResponse(w, ErrMessage{code: http.StatusGatewayTimeout,
+				Error: fmt.Sprintf(RequestTimedOut, time.Duration(ms)*time.Millisecond)})
+			return
+		}
+		h.writeResponse(w, ErrMessage{code: http.StatusServiceUnavailable,
+			Error: RequestCanceled})
+	})
+}
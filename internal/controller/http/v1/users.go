@@ -0,0 +1,148 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// credentials are given on registration and login
+type credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Register creates user account
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
+	cred, errAns, err := parseCredentials(r, true)
+	if err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - Register - %w", err))
+		h.writeResponse(w, errAns)
+		return
+	}
+
+	id, err := h.Service.Register(r.Context(), cred.Email, cred.Password)
+	if err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - Register - h.Service.Register: %w", err))
+		if errors.Is(err, entity.ErrEmailAlreadyExist) {
+			h.writeResponse(w, ErrMessage{code: http.StatusConflict,
+				Error: fmt.Sprintf(EmailExists, cred.Email)})
+		} else {
+			h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+		}
+		return
+	}
+
+	h.writeResponse(w, UsersResponse{Data: []entity.User{{Id: id}}, code: http.StatusCreated})
+}
+
+// GetMe responds with user whose session token is given
+func (h *Handler) GetMe(w http.ResponseWriter, r *http.Request) {
+	identity, ok := r.Context().Value(entity.KeyIdentity).(entity.Identity)
+	if !ok {
+		h.writeUnauthenticated(w)
+		return
+	}
+
+	h.writeResponse(w, UsersResponse{
+		Data: []entity.User{{Id: identity.UserId, Email: identity.Email}},
+		code: http.StatusOK,
+	})
+}
+
+// Login starts session, its token is shown only in this response
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	cred, errAns, err := parseCredentials(r, false)
+	if err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - Login - %w", err))
+		h.writeResponse(w, errAns)
+		return
+	}
+
+	ttl := time.Duration(h.Cfg.Auth.SessionTtlHours) * time.Hour
+	if ttl <= 0 {
+		ttl = DefaultSessionTtl
+	}
+	session, err := h.Service.Login(r.Context(), cred.Email, cred.Password, ttl)
+	if err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - Login - h.Service.Login: %w", err))
+		if errors.Is(err, entity.ErrWrongCredentials) {
+			h.writeResponse(w, ErrMessage{code: http.StatusUnauthorized, Error: WrongCredentials})
+		} else {
+			h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+		}
+		return
+	}
+
+	h.writeResponse(w, SessionResponse{Data: session, code: http.StatusCreated})
+}
+
+// Logout ends session whose token is given
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if _, authenticated := r.Context().Value(entity.KeyIdentity).(entity.Identity); !ok ||
+		!authenticated {
+		h.writeUnauthenticated(w)
+		return
+	}
+
+	err := h.Service.Logout(r.Context(), token)
+	if err != nil {
+		h.l.WriteLog(fmt.Errorf("v1 - Logout - h.Service.Logout: %w", err))
+		h.writeResponse(w, ErrMessage{code: http.StatusInternalServerError})
+		return
+	}
+
+	h.writeResponse(w, Response{code: http.StatusNoContent})
+}
+
+// parseCredentials decodes email and password given in request's body,
+// their format is checked only on registration
+func parseCredentials(r *http.Request, register bool) (credentials, ErrMessage, error) {
+	errMsg := ErrMessage{code: http.StatusBadRequest}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		errMsg.Error = JsonNotCorrect
+		return credentials{}, errMsg, fmt.Errorf("parseCredentials - ReadAll: %w", err)
+	}
+
+	var cred credentials
+	if err = json.Unmarshal(body, &cred); err != nil {
+		errMsg.Error = JsonNotCorrect
+		return credentials{}, errMsg, fmt.Errorf("parseCredentials - Unmarshal: %w", err)
+	}
+	cred.Email = strings.ToLower(strings.TrimSpace(cred.Email))
+
+	switch {
+	case cred.Email == "":
+		errMsg.Error = EmptyFiledRequest
+		errMsg.Detail = `'email:' field is required`
+	case cred.Password == "":
+		errMsg.Error = EmptyFiledRequest
+		errMsg.Detail = `'password:' field is required`
+	case !register:
+		return cred, errMsg, nil
+	case len(cred.Email) > MaxEmailLength || !validEmail(cred.Email):
+		errMsg.Error = WrongDataFormat
+		errMsg.Detail = EmailNotValid
+	case len(cred.Password) < MinPasswordLength || len(cred.Password) > MaxPasswordLength:
+		errMsg.Error = WrongDataFormat
+		errMsg.Detail = fmt.Sprintf(PasswordLength, MinPasswordLength, MaxPasswordLength)
+	default:
+		return cred, errMsg, nil
+	}
+	return credentials{}, errMsg, fmt.Errorf("parseCredentials - %v", errMsg.Error)
+}
+
+// validEmail accepts bare address without display name
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
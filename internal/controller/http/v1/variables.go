@@ -1,6 +1,10 @@
 package v1
 
-import "github.com/mrsubudei/adv-store-service/internal/entity"
+import (
+	"time"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
 
 type Answer interface {
 	getCode() int
@@ -18,14 +22,166 @@ type Response struct {
 	code int
 }
 
+// PageResponse holds page of adverts, data is present even if page is empty
+type PageResponse struct {
+	Meta *MetaData       `json:"meta_data,omitempty"`
+	Data []entity.Advert `json:"data"`
+	code int
+}
+
 type MetaData struct {
-	MaxPage int64 `json:"max_page,omitempty"`
+	MaxPage    int64  `json:"max_page,omitempty"`
+	TotalCount int64  `json:"total_count"`
+	Page       int64  `json:"page,omitempty"`
+	PageSize   int    `json:"page_size"`
+	HasNext    bool   `json:"has_next"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Links      *Links `json:"links,omitempty"`
+}
+
+type Links struct {
+	Self string `json:"self,omitempty"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// RevisionsResponse holds advert's revisions
+type RevisionsResponse struct {
+	Data []entity.Revision `json:"data"`
+	code int
+}
+
+// DiffResponse holds difference between two revisions of advert
+type DiffResponse struct {
+	Data entity.RevisionsDiff `json:"data"`
+	code int
+}
+
+// UsersResponse holds users without their passwords
+type UsersResponse struct {
+	Data []entity.User `json:"data"`
+	code int
+}
+
+// SessionResponse holds session started on login with its token
+type SessionResponse struct {
+	Data entity.Session `json:"data"`
+	code int
+}
+
+// CategoriesResponse holds categories with their subcategories
+type CategoriesResponse struct {
+	Data []entity.Category `json:"data"`
+	code int
+}
+
+// ApiKeysResponse holds API keys, key itself is present only
+// after creation and rotation
+type ApiKeysResponse struct {
+	Data []entity.ApiKey `json:"data"`
+	code int
+}
+
+// RolesResponse holds roles with their permissions
+type RolesResponse struct {
+	Data []entity.Role `json:"data"`
+	code int
+}
+
+// AuditResponse holds page of audit trail newest first
+type AuditResponse struct {
+	Data []entity.AuditEntry `json:"data"`
+	code int
+}
+
+// FieldsResponse holds adverts with fields selected by client
+type FieldsResponse struct {
+	Meta *MetaData                `json:"meta_data,omitempty"`
+	Data []map[string]interface{} `json:"data"`
+	code int
+}
+
+// BatchResponse holds result for every id requested at once
+type BatchResponse struct {
+	Data []BatchItem `json:"data"`
+	code int
+}
+
+// BatchItem holds either advert or error of one of requested ids,
+// advert is given with fields selected by client
+type BatchItem struct {
+	Id     int64       `json:"id"`
+	Advert interface{} `json:"advert,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// OperationsResponse holds results of batch operations in their order
+type OperationsResponse struct {
+	Data []OperationResult `json:"data"`
+	code int
+}
+
+// OperationResult holds status of one of batch operations, id of advert
+// is given if operation succeeded
+type OperationResult struct {
+	Status int    `json:"status"`
+	Id     int64  `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Detail string `json:"detail,omitempty"`
 }
 
 func (r Response) getCode() int {
 	return r.code
 }
 
+func (r RevisionsResponse) getCode() int {
+	return r.code
+}
+
+func (r DiffResponse) getCode() int {
+	return r.code
+}
+
+func (r CategoriesResponse) getCode() int {
+	return r.code
+}
+
+func (r UsersResponse) getCode() int {
+	return r.code
+}
+
+func (r SessionResponse) getCode() int {
+	return r.code
+}
+
+func (r ApiKeysResponse) getCode() int {
+	return r.code
+}
+
+func (r RolesResponse) getCode() int {
+	return r.code
+}
+
+func (r AuditResponse) getCode() int {
+	return r.code
+}
+
+func (r PageResponse) getCode() int {
+	return r.code
+}
+
+func (r FieldsResponse) getCode() int {
+	return r.code
+}
+
+func (r BatchResponse) getCode() int {
+	return r.code
+}
+
+func (r OperationsResponse) getCode() int {
+	return r.code
+}
+
 func (e ErrMessage) getCode() int {
 	return e.code
 }
@@ -41,17 +197,106 @@ const (
 	DescLengthExceeded = "'description:' field's length exceeded"
 	NameLengthExceeded = "'name:' field's length exceeded"
 	UrlsNumberExceeded = "'photo_urls:' field's quantity exceeded"
+	PatchNotCorrect    = "patch is not correct"
+	VersionMismatch    = "advert's version does not match 'If-Match' header"
+	ConcurrentUpdate   = "advert was changed by another request"
+	PatchTestFailed    = "patch test operation failed"
+	PatchTypeWrong     = "patch content type should be either '" + MergePatchType +
+		"' or '" + JsonPatchType + "'"
+	NoRevisionFound  = "no revision found with number: "
+	WrongCategory    = "category with id %v does not exist"
+	WrongParent      = "parent category does not exist or is inside category"
+	CategoryNotEmpty = "category has subcategories or adverts"
+	CategoryDenied   = "only admin can change categories"
+	WrongAttributes  = "'attributes:' field does not match category schema"
+	WrongSchema      = "'schema:' field is not valid JSON Schema"
+	EmailExists      = "user with email '%v' already exists"
+	WrongCredentials = "email or password is wrong"
+	Unauthenticated  = "valid session token is required"
+	EmailNotValid    = "'email:' field should be valid email address"
+	PasswordLength   = "'password:' field should have from %d to %d bytes"
+	AdvertForbidden  = "only owner of advert or admin can change it"
+	ApiKeysForbidden = "API keys are managed only with session token"
+	WrongScope       = "'scopes:' field should hold only 'adverts:read' and 'adverts:write'"
+	ScopeMissing     = "API key has no '%v' scope"
+	HideForbidden    = "only moderator or admin can hide advert"
+	AdminForbidden   = "action needs session token of user with permission for it"
+	NoRoleFound      = "no role found with name: "
+	TooManyRequests  = "too many requests, retry after %d seconds"
+	KeyReused        = "'Idempotency-Key' header is already used for request with other body"
+	KeyInProgress    = "request with the same 'Idempotency-Key' header is in progress"
+	KeyTooLong       = "'Idempotency-Key' header should not exceed 255 bytes"
+	RequestTimedOut  = "request did not finish within %v"
+	RequestCanceled  = "request was canceled before it finished, retry later"
+	BatchAborted     = "operation is not applied as other operation of atomic batch failed"
+	WrongVersion     = "advert's version does not match 'version:' field"
+	OpsExceeded      = "'operations:' field's quantity exceeded"
+)
+
+const (
+	MergePatchType = "application/merge-patch+json"
+	JsonPatchType  = "application/json-patch+json"
+)
+
+const (
+	FieldName        = "name"
+	FieldDescription = "description"
+	FieldPrice       = "price"
+	FieldPhotoUrls   = "photo_urls"
+	FieldAttributes  = "attributes"
+)
+
+const (
+	MaxSearchLength   = 200
+	MaxBatchIds       = 100
+	MaxBatchOps       = 1000
+	MaxNameLength     = 200
+	MaxEmailLength    = 254
+	MinPasswordLength = 8
+	// MaxPasswordLength is limit of bcrypt
+	MaxPasswordLength = 72
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	MaxIdempotencyKeyLength  = 255
+	// DefaultIdempotencyTtl is used if config does not set one
+	DefaultIdempotencyTtl = 24 * time.Hour
+	// IdempotencySaveTimeout limits saving of response after request is done
+	IdempotencySaveTimeout = 5 * time.Second
+)
+
+const (
+	BearerPrefix = "Bearer "
+	// DefaultSessionTtl is used if config does not set one
+	DefaultSessionTtl = 24 * time.Hour
 )
 
 const (
 	QueryFields         = "fields"
+	QueryIds            = "ids"
 	QueryLimit          = "limit"
 	QueryOffset         = "offset"
+	QuerySort           = "sort"
 	QuerySortBy         = "sort_by"
 	QueryOrderBy        = "order_by"
+	QuerySearch         = "q"
+	QueryCursor         = "cursor"
+	QueryPriceMin       = "price_min"
+	QueryPriceMax       = "price_max"
+	QueryCreatedAfter   = "created_after"
+	QueryCreatedBefore  = "created_before"
+	QueryNamePrefix     = "name_prefix"
+	QueryAttribute      = "attr."
+	QueryAttributeMin   = "_min"
+	QueryAttributeMax   = "_max"
+	QueryFrom           = "from"
+	QueryTo             = "to"
+	QueryDateFormat     = "2006-01-02"
 	QueryValueTrue      = "true"
 	QueryValueAsc       = "asc"
 	QueryValueDesc      = "desc"
-	QueryValueCreatedAt = "create_at"
+	QueryValueCreatedAt = "created_at"
 	QueryValuePrice     = "price"
 )
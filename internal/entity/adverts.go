@@ -1,5 +1,10 @@
 package entity
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type Advert struct {
 	Id           int64    `json:"id,omitempty"`
 	Name         string   `json:"name,omitempty"`
@@ -7,17 +12,112 @@ type Advert struct {
 	Price        int64    `json:"price,omitempty"`
 	MainPhotoUrl string   `json:"main_photo_url,omitempty"`
 	PhotosUrls   []string `json:"photo_urls,omitempty"`
-	CreatedAt    string   `json:"-"`
-	MaxCount     int64    `json:"-"`
+	CategoryId   int64    `json:"category_id,omitempty"`
+	// OwnerId is id of user who created advert, 0 if it was created anonymously
+	OwnerId int64 `json:"owner_id,omitempty"`
+	// Attributes is JSON object checked against schema of advert's category
+	Attributes json.RawMessage `json:"attributes,omitempty"`
+	Snippet    string          `json:"snippet,omitempty"`
+	DeletedAt  string          `json:"deleted_at,omitempty"`
+	// HiddenAt is time moderator hid advert from lists, empty if it is shown
+	HiddenAt  string `json:"hidden_at,omitempty"`
+	CreatedAt string `json:"-"`
+	Version   int64  `json:"-"`
+}
+
+// AdvertsPage is a page of adverts list with details of pagination,
+// Page is known only if page is taken by offset
+type AdvertsPage struct {
+	Adverts    []Advert
+	TotalCount int64
+	Page       int64
+	PageSize   int
+	HasNext    bool
+}
+
+// BatchAdvert is result of taking advert by one of requested ids,
+// Err is set instead of Advert when advert can not be given
+type BatchAdvert struct {
+	Id     int64
+	Advert Advert
+	Err    error
+}
+
+// BatchOperation is one of changes of adverts made at once, Action is
+// ActionCreate, ActionUpdate or ActionDelete. Update and delete take advert's
+// id and version, created advert gets its id. Err tells why operation is not applied
+type BatchOperation struct {
+	Action string
+	Advert Advert
+	Err    error
+}
+
+// AbortBatch marks operations which have not failed as not applied
+func AbortBatch(ops []BatchOperation) {
+	for i := range ops {
+		if ops[i].Err == nil {
+			ops[i].Err = ErrBatchAborted
+		}
+	}
 }
 
+// Filter holds conditions adverts list is narrowed with, nil and zero values
+// mean that condition is not applied
+type Filter struct {
+	PriceMin      *int64
+	PriceMax      *int64
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	NamePrefix    string
+	// CategoryId narrows list to adverts of category and its descendants
+	CategoryId int64
+	Attributes []AttributeFilter
+	// OwnerId narrows list to adverts of user, it is not taken from query
+	OwnerId int64
+}
+
+// Empty tells if filter has no conditions
+func (f Filter) Empty() bool {
+	return f.PriceMin == nil && f.PriceMax == nil && f.CreatedAfter.IsZero() &&
+		f.CreatedBefore.IsZero() && f.NamePrefix == "" && f.CategoryId == 0 &&
+		len(f.Attributes) == 0 && f.OwnerId == 0
+}
+
+// AttributeFilter is condition on advert's attribute, Min and Max
+// hold only numbers and Equal holds any JSON value
+type AttributeFilter struct {
+	Name  string
+	Min   *float64
+	Max   *float64
+	Equal json.RawMessage
+}
+
+// Cursor points to the last advert of a page, next page starts right
+// after it in the same sort order. Values hold fields of advert sort
+// is made of except id, Sort is empty for default order.
+type Cursor struct {
+	Sort   string   `json:"s,omitempty"`
+	Values []string `json:"v,omitempty"`
+	Id     int64    `json:"id"`
+}
+
+// DefaultLimit is number of adverts per page if limit is not given
+const DefaultLimit = 10
+
 type ContextKey string
 
 const (
-	KeyId      ContextKey = "id"
-	KeyLimit   ContextKey = "limit"
-	KeyOffset  ContextKey = "offset"
-	KeySortBy  ContextKey = "sort_by"
-	KeyOrderBy ContextKey = "order_by"
-	KeyFields  ContextKey = "fields"
+	KeyId       ContextKey = "id"
+	KeyIds      ContextKey = "ids"
+	KeyLimit    ContextKey = "limit"
+	KeyOffset   ContextKey = "offset"
+	KeySort     ContextKey = "sort"
+	KeyFields   ContextKey = "fields"
+	KeyQuery    ContextKey = "q"
+	KeyFilter   ContextKey = "filter"
+	KeyCursor   ContextKey = "cursor"
+	KeyRevision ContextKey = "revision"
+	KeyIdentity ContextKey = "identity"
+	KeyRole     ContextKey = "role"
+	KeyApiKey   ContextKey = "api_key"
 )
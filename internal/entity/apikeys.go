@@ -0,0 +1,25 @@
+package entity
+
+const (
+	ScopeAdvertsRead  = "adverts:read"
+	ScopeAdvertsWrite = "adverts:write"
+)
+
+// Scopes lists scopes API key may be given
+var Scopes = []string{ScopeAdvertsRead, ScopeAdvertsWrite}
+
+// ApiKey lets machine client act on behalf of user within its scopes.
+// Key is shown only when it is created or rotated, prefix is kept
+// as is to find key and tell keys apart
+type ApiKey struct {
+	Id         int64    `json:"id,omitempty"`
+	UserId     int64    `json:"-"`
+	Name       string   `json:"name,omitempty"`
+	Prefix     string   `json:"prefix,omitempty"`
+	Key        string   `json:"key,omitempty"`
+	KeyHash    string   `json:"-"`
+	Scopes     []string `json:"scopes,omitempty"`
+	CreatedAt  string   `json:"created_at,omitempty"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+	RevokedAt  string   `json:"revoked_at,omitempty"`
+}
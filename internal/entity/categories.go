@@ -0,0 +1,16 @@
+package entity
+
+import "encoding/json"
+
+// Category groups adverts, categories are nested by parent and zero
+// ParentId means top level category. AdvertsCount includes adverts
+// of all descendants. Schema is JSON Schema attributes of category's
+// adverts are validated with.
+type Category struct {
+	Id           int64           `json:"id,omitempty"`
+	Name         string          `json:"name,omitempty"`
+	ParentId     int64           `json:"parent_id,omitempty"`
+	Schema       json.RawMessage `json:"schema,omitempty"`
+	AdvertsCount int64           `json:"adverts_count"`
+	Children     []Category      `json:"children,omitempty"`
+}
@@ -1,9 +1,36 @@
 package entity
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
 
 var (
-	ErrNameAlreadyExist = errors.New("name already exists")
-	ErrItemNotExists    = errors.New("item does not exist")
-	ErrNoItems          = errors.New("there are no items")
+	ErrNameAlreadyExist  = errors.New("name already exists")
+	ErrItemNotExists     = errors.New("item does not exist")
+	ErrNoItems           = errors.New("there are no items")
+	ErrVersionMismatch   = errors.New("item version does not match")
+	ErrWrongCategory     = errors.New("category does not exist")
+	ErrWrongParent       = errors.New("parent category does not exist or is inside category")
+	ErrCategoryNotEmpty  = errors.New("category has subcategories or adverts")
+	ErrWrongSchema       = errors.New("category schema is not valid")
+	ErrEmailAlreadyExist = errors.New("email already exists")
+	ErrWrongCredentials  = errors.New("email or password is wrong")
+	ErrUnauthenticated   = errors.New("session is not valid")
+	ErrForbidden         = errors.New("action is forbidden")
+	ErrWrongScope        = errors.New("scope is not known")
+	ErrWrongRole         = errors.New("role does not exist")
+	ErrKeyAlreadyExist   = errors.New("idempotency key already exists")
+	ErrKeyReused         = errors.New("idempotency key is used for other request")
+	ErrKeyInProgress     = errors.New("request with idempotency key is in progress")
+	ErrBatchAborted      = errors.New("operation is not applied as other operation failed")
 )
+
+// AttributesError lists violations of category schema by advert's attributes
+type AttributesError struct {
+	Violations []string
+}
+
+func (e *AttributesError) Error() string {
+	return "attributes do not match category schema: " + strings.Join(e.Violations, "; ")
+}
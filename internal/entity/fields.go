@@ -0,0 +1,143 @@
+package entity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AdvertField is field of advert client can select
+type AdvertField struct {
+	// Value returns the field as it is given to client
+	Value func(adv Advert) interface{}
+}
+
+// AdvertFields are fields of advert client can select, id is always given
+var AdvertFields = map[string]AdvertField{
+	"id":             {Value: func(adv Advert) interface{} { return adv.Id }},
+	"name":           {Value: func(adv Advert) interface{} { return adv.Name }},
+	"description":    {Value: func(adv Advert) interface{} { return adv.Description }},
+	"price":          {Value: func(adv Advert) interface{} { return adv.Price }},
+	"main_photo_url": {Value: func(adv Advert) interface{} { return adv.MainPhotoUrl }},
+	"photo_urls": {Value: func(adv Advert) interface{} {
+		if adv.PhotosUrls == nil {
+			return []string{}
+		}
+		return adv.PhotosUrls
+	}},
+	"category_id": {Value: func(adv Advert) interface{} { return adv.CategoryId }},
+	"owner_id":    {Value: func(adv Advert) interface{} { return adv.OwnerId }},
+	"attributes": {Value: func(adv Advert) interface{} {
+		if adv.Attributes == nil {
+			return nil
+		}
+		return adv.Attributes
+	}},
+	"created_at": {Value: func(adv Advert) interface{} { return adv.CreatedAt }},
+}
+
+// Fields is selection of advert's fields, nil selects all of them.
+// Repositories still load fields they need themselves, like id or version.
+type Fields map[string]bool
+
+// DefaultFields are fields of advert given if client selects none
+var DefaultFields = Fields{"name": true, "price": true, "main_photo_url": true}
+
+// ListFields are fields of advert given in lists if client selects none
+var ListFields = Fields{"name": true, "price": true, "main_photo_url": true,
+	"category_id": true, "owner_id": true, "attributes": true, "created_at": true}
+
+// ParseFields parses comma separated names of advert's fields
+func ParseFields(value string) (Fields, error) {
+	fields := Fields{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := AdvertFields[name]; !ok {
+			return nil, fmt.Errorf("unknown field '%v', allowed fields are %v",
+				name, AdvertFieldNames())
+		}
+		fields[name] = true
+	}
+	return fields, nil
+}
+
+// AdvertFieldNames lists quoted names of advert's fields in alphabetical order
+func AdvertFieldNames() string {
+	names := []string{}
+	for name := range AdvertFields {
+		names = append(names, "'"+name+"'")
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// Has reports whether field is selected
+func (f Fields) Has(name string) bool {
+	return f == nil || f[name]
+}
+
+// WithSort returns fields extended by fields adverts are sorted by,
+// they are needed to make cursor of a page
+func (f Fields) WithSort(order Sort) Fields {
+	if f == nil {
+		return nil
+	}
+	fields := Fields{}
+	for name := range f {
+		fields[name] = true
+	}
+	for _, key := range order {
+		fields[key.Field] = true
+	}
+	return fields
+}
+
+// Trim clears fields of advert which are not selected, fields needed
+// to authorize access to advert and to version it are kept
+func (f Fields) Trim(adv Advert) Advert {
+	trimmed := Advert{Id: adv.Id, OwnerId: adv.OwnerId, HiddenAt: adv.HiddenAt,
+		DeletedAt: adv.DeletedAt, Snippet: adv.Snippet, Version: adv.Version}
+	if f.Has("name") {
+		trimmed.Name = adv.Name
+	}
+	if f.Has("description") {
+		trimmed.Description = adv.Description
+	}
+	if f.Has("price") {
+		trimmed.Price = adv.Price
+	}
+	if f.Has("main_photo_url") {
+		trimmed.MainPhotoUrl = adv.MainPhotoUrl
+	}
+	if f.Has("photo_urls") {
+		trimmed.PhotosUrls = adv.PhotosUrls
+	}
+	if f.Has("category_id") {
+		trimmed.CategoryId = adv.CategoryId
+	}
+	if f.Has("attributes") {
+		trimmed.Attributes = adv.Attributes
+	}
+	if f.Has("created_at") {
+		trimmed.CreatedAt = adv.CreatedAt
+	}
+	return trimmed
+}
+
+// Select returns selected fields of advert keyed by their names, id is
+// always given and so are snippet of search and date of deletion if they are set
+func (f Fields) Select(adv Advert) map[string]interface{} {
+	selected := map[string]interface{}{"id": adv.Id}
+	for name, field := range AdvertFields {
+		if f.Has(name) {
+			selected[name] = field.Value(adv)
+		}
+	}
+	if adv.Snippet != "" {
+		selected["snippet"] = adv.Snippet
+	}
+	if adv.DeletedAt != "" {
+		selected["deleted_at"] = adv.DeletedAt
+	}
+	return selected
+}
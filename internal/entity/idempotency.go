@@ -0,0 +1,13 @@
+package entity
+
+// IdempotentResponse is response to request sent with idempotency key, it is
+// replayed for retries of the request until it expires. Response without status
+// is reservation of key for request which is still being handled.
+type IdempotentResponse struct {
+	Key         string
+	RequestHash string
+	Status      int
+	Body        []byte
+	CreatedAt   string
+	ExpiresAt   string
+}
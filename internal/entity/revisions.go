@@ -0,0 +1,37 @@
+package entity
+
+// Actions revisions are made by
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionHide    = "hide"
+	ActionUnhide  = "unhide"
+)
+
+// Revision is snapshot of advert taken right after its change,
+// revisions are numbered by advert's version
+type Revision struct {
+	Revision int64  `json:"revision"`
+	Action   string `json:"action"`
+	Advert   Advert `json:"advert"`
+	// UserId and ApiKeyId tell who made the change,
+	// they are empty for changes of anonymous callers
+	UserId    int64  `json:"user_id,omitempty"`
+	ApiKeyId  int64  `json:"api_key_id,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// FieldChange is difference in advert's field between two revisions
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+type RevisionsDiff struct {
+	From    int64         `json:"from"`
+	To      int64         `json:"to"`
+	Changes []FieldChange `json:"changes"`
+}
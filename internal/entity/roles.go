@@ -0,0 +1,35 @@
+package entity
+
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+)
+
+// Permissions granted by roles, roles and their permissions are kept in database
+const (
+	PermissionUpdateAnyAdvert  = "adverts:update_any"
+	PermissionDeleteAnyAdvert  = "adverts:delete_any"
+	PermissionHideAdvert       = "adverts:hide"
+	PermissionManageUsers      = "users:manage"
+	PermissionManageApiKeys    = "api_keys:manage"
+	PermissionReadAudit        = "audit:read"
+	PermissionManageCategories = "categories:manage"
+)
+
+type Role struct {
+	Id          int64    `json:"id"`
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// AuditEntry is authorization decision made on caller's request,
+// resource is path of item action was requested on
+type AuditEntry struct {
+	Id        int64  `json:"id"`
+	UserId    int64  `json:"user_id,omitempty"`
+	ApiKeyId  int64  `json:"api_key_id,omitempty"`
+	Action    string `json:"action"`
+	Resource  string `json:"resource"`
+	Allowed   bool   `json:"allowed"`
+	CreatedAt string `json:"created_at"`
+}
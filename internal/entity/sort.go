@@ -0,0 +1,189 @@
+package entity
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SortField is field of advert adverts can be ordered by
+type SortField struct {
+	// Column is column of adverts table holding the field
+	Column string
+	// Compare compares the field of two adverts
+	Compare func(a, b Advert) int
+	// Format and Parse convert the field to and from value kept in cursor
+	Format func(adv Advert) string
+	Parse  func(adv *Advert, value string) error
+	// Value returns the field as query argument
+	Value func(adv Advert) interface{}
+}
+
+// SortFields are fields adverts can be ordered by, keys of sort
+// are checked against it before they get into SQL
+var SortFields = map[string]SortField{
+	"id": {
+		Column:  "id",
+		Compare: func(a, b Advert) int { return compareInt(a.Id, b.Id) },
+		Format:  func(adv Advert) string { return strconv.FormatInt(adv.Id, 10) },
+		Parse: func(adv *Advert, value string) (err error) {
+			adv.Id, err = strconv.ParseInt(value, 10, 64)
+			return err
+		},
+		Value: func(adv Advert) interface{} { return adv.Id },
+	},
+	"price": {
+		Column:  "price",
+		Compare: func(a, b Advert) int { return compareInt(a.Price, b.Price) },
+		Format:  func(adv Advert) string { return strconv.FormatInt(adv.Price, 10) },
+		Parse: func(adv *Advert, value string) (err error) {
+			adv.Price, err = strconv.ParseInt(value, 10, 64)
+			return err
+		},
+		Value: func(adv Advert) interface{} { return adv.Price },
+	},
+	"created_at": {
+		Column:  "created_at",
+		Compare: func(a, b Advert) int { return strings.Compare(a.CreatedAt, b.CreatedAt) },
+		Format:  func(adv Advert) string { return adv.CreatedAt },
+		Parse: func(adv *Advert, value string) error {
+			adv.CreatedAt = value
+			return nil
+		},
+		Value: func(adv Advert) interface{} { return adv.CreatedAt },
+	},
+	"name": {
+		Column:  "name",
+		Compare: func(a, b Advert) int { return strings.Compare(a.Name, b.Name) },
+		Format:  func(adv Advert) string { return adv.Name },
+		Parse: func(adv *Advert, value string) error {
+			adv.Name = value
+			return nil
+		},
+		Value: func(adv Advert) interface{} { return adv.Name },
+	},
+}
+
+// SortKey is field adverts are ordered by and its direction
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// Sort is order of adverts, keys are applied in turn and the
+// last key is always id so that order is stable between pages
+type Sort []SortKey
+
+// DefaultSort is order of adverts if no sort is given
+var DefaultSort = Sort{{Field: "id"}}
+
+// ParseSort parses comma separated keys of sort, key prefixed
+// with '-' is descending. Id breaks ties in direction of the last key.
+func ParseSort(value string) (Sort, error) {
+	order := Sort{}
+	seen := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		key := SortKey{Field: strings.TrimSpace(name)}
+		if strings.HasPrefix(key.Field, "-") {
+			key.Field = key.Field[1:]
+			key.Desc = true
+		}
+		if _, ok := SortFields[key.Field]; !ok {
+			return nil, fmt.Errorf("unknown key '%v', allowed keys are %v",
+				key.Field, SortFieldNames())
+		}
+		if seen[key.Field] {
+			return nil, fmt.Errorf("key '%v' is repeated", key.Field)
+		}
+		seen[key.Field] = true
+		order = append(order, key)
+		// keys after id would never be compared
+		if key.Field == "id" {
+			return order, nil
+		}
+	}
+	return append(order, SortKey{Field: "id", Desc: order[len(order)-1].Desc}), nil
+}
+
+// SortFieldNames lists quoted names of sort fields in alphabetical order
+func SortFieldNames() string {
+	names := []string{}
+	for name := range SortFields {
+		names = append(names, "'"+name+"'")
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func (s Sort) String() string {
+	keys := []string{}
+	for _, key := range s {
+		if key.Desc {
+			keys = append(keys, "-"+key.Field)
+		} else {
+			keys = append(keys, key.Field)
+		}
+	}
+	return strings.Join(keys, ",")
+}
+
+// Compare compares adverts in order of sort
+func (s Sort) Compare(a, b Advert) int {
+	for _, key := range s {
+		c := SortFields[key.Field].Compare(a, b)
+		if key.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// NewCursor returns cursor pointing to advert in given sort,
+// empty sort stands for default one
+func NewCursor(adv Advert, order Sort) Cursor {
+	cursor := Cursor{Id: adv.Id}
+	if len(order) != 0 {
+		cursor.Sort = order.String()
+	} else {
+		order = DefaultSort
+	}
+	for _, key := range order[:len(order)-1] {
+		cursor.Values = append(cursor.Values, SortFields[key.Field].Format(adv))
+	}
+	return cursor
+}
+
+// Advert returns advert holding values cursor points to in given sort
+func (c Cursor) Advert(order Sort) (Advert, error) {
+	adv := Advert{Id: c.Id}
+	if len(order) == 0 {
+		order = DefaultSort
+	}
+	if len(c.Values) != len(order)-1 {
+		return adv, fmt.Errorf("cursor has %v values for %v keys", len(c.Values), len(order))
+	}
+	for i, key := range order[:len(order)-1] {
+		field, ok := SortFields[key.Field]
+		if !ok {
+			return adv, fmt.Errorf("unknown key '%v'", key.Field)
+		}
+		if err := field.Parse(&adv, c.Values[i]); err != nil {
+			return adv, fmt.Errorf("value of key '%v': %w", key.Field, err)
+		}
+	}
+	return adv, nil
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
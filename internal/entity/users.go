@@ -0,0 +1,55 @@
+package entity
+
+// User is account adverts are managed from, email is kept in lower case
+type User struct {
+	Id           int64  `json:"id,omitempty"`
+	Email        string `json:"email,omitempty"`
+	PasswordHash string `json:"-"`
+	CreatedAt    string `json:"created_at,omitempty"`
+	// Roles are names of user's roles, they are shown only to admins
+	Roles []string `json:"roles,omitempty"`
+}
+
+// Session is given to user on login, only hash of its token is stored
+// and token itself is shown once
+type Session struct {
+	Token     string `json:"token,omitempty"`
+	TokenHash string `json:"-"`
+	UserId    int64  `json:"user_id"`
+	CreatedAt string `json:"-"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// Identity is authenticated caller of request with permissions of
+// its roles, caller authenticated with API key is limited to scopes of the key
+type Identity struct {
+	UserId      int64    `json:"user_id"`
+	Email       string   `json:"email"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	ApiKeyId    int64    `json:"api_key_id,omitempty"`
+	Scopes      []string `json:"scopes,omitempty"`
+}
+
+// Can tells if roles of caller grant permission
+func (i Identity) Can(permission string) bool {
+	for _, p := range i.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope tells if caller may act within scope, session grants all scopes
+func (i Identity) HasScope(scope string) bool {
+	if i.ApiKeyId == 0 {
+		return true
+	}
+	for _, s := range i.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,646 @@
+// Package memory keeps adverts in process memory, it is meant
+// for development and tests and loses everything on restart.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// dateFormat is format adverts' dates are kept in
+const dateFormat = "2006-01-02 15:04:05"
+
+// AdvertsRepo is safe for concurrent use, adverts are copied
+// on the way in and out so callers never share them with repo.
+// Context is only checked for cancellation before call.
+type AdvertsRepo struct {
+	mu             sync.RWMutex
+	lastId         int64
+	adverts        map[int64]entity.Advert
+	revisions      map[int64][]entity.Revision
+	lastCategoryId int64
+	categories     map[int64]entity.Category
+}
+
+func NewAdvertsRepo() *AdvertsRepo {
+	return &AdvertsRepo{
+		adverts:    map[int64]entity.Advert{},
+		revisions:  map[int64][]entity.Revision{},
+		categories: map[int64]entity.Category{},
+	}
+}
+
+func (ar *AdvertsRepo) Store(ctx context.Context, adv *entity.Advert) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("AdvertsRepo - Store: %w", err)
+	}
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	if err := ar.store(ctx, adv); err != nil {
+		return fmt.Errorf("AdvertsRepo - Store: %w", err)
+	}
+	return nil
+}
+
+// store keeps advert with its first revision, mutex should be held by caller
+func (ar *AdvertsRepo) store(ctx context.Context, adv *entity.Advert) error {
+	if ar.nameTaken(adv.Name, 0) {
+		return entity.ErrNameAlreadyExist
+	}
+
+	ar.lastId++
+	adv.Id = ar.lastId
+	adv.Version = 1
+
+	stored := copyAdvert(*adv)
+	stored.MainPhotoUrl = adv.PhotosUrls[0]
+	stored.DeletedAt = ""
+	stored.HiddenAt = ""
+	stored.Snippet = ""
+	ar.adverts[stored.Id] = stored
+	ar.storeRevision(ctx, stored, entity.ActionCreate)
+
+	return nil
+}
+
+func (ar *AdvertsRepo) GetById(ctx context.Context, id int64) (entity.Advert, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.Advert{}, fmt.Errorf("AdvertsRepo - GetById: %w", err)
+	}
+
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+
+	adv, ok := ar.adverts[id]
+	if !ok || adv.DeletedAt != "" {
+		return entity.Advert{}, fmt.Errorf("AdvertsRepo - GetById: %w", sql.ErrNoRows)
+	}
+
+	fields, _ := ctx.Value(entity.KeyFields).(entity.Fields)
+	return fields.Trim(copyAdvert(adv)), nil
+}
+
+// GetDeletedById returns advert which is in trash
+func (ar *AdvertsRepo) GetDeletedById(ctx context.Context, id int64) (entity.Advert, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.Advert{}, fmt.Errorf("AdvertsRepo - GetDeletedById: %w", err)
+	}
+
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+
+	adv, ok := ar.adverts[id]
+	if !ok || adv.DeletedAt == "" {
+		return entity.Advert{}, fmt.Errorf("AdvertsRepo - GetDeletedById: %w", sql.ErrNoRows)
+	}
+
+	fields, _ := ctx.Value(entity.KeyFields).(entity.Fields)
+	return fields.Trim(copyAdvert(adv)), nil
+}
+
+func (ar *AdvertsRepo) GetByIds(ctx context.Context, ids []int64) ([]entity.Advert, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("AdvertsRepo - GetByIds: %w", err)
+	}
+
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+
+	fields, _ := ctx.Value(entity.KeyFields).(entity.Fields)
+	adverts := []entity.Advert{}
+	seen := map[int64]bool{}
+	for _, id := range ids {
+		adv, ok := ar.adverts[id]
+		if !ok || adv.DeletedAt != "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		adverts = append(adverts, fields.Trim(copyAdvert(adv)))
+	}
+	return adverts, nil
+}
+
+func (ar *AdvertsRepo) Fetch(ctx context.Context) (entity.AdvertsPage, error) {
+	page, err := ar.fetch(ctx, false)
+	if err != nil {
+		return page, fmt.Errorf("AdvertsRepo - Fetch - %w", err)
+	}
+	return page, nil
+}
+
+// FetchDeleted returns page of adverts moved to trash
+func (ar *AdvertsRepo) FetchDeleted(ctx context.Context) (entity.AdvertsPage, error) {
+	page, err := ar.fetch(ctx, true)
+	if err != nil {
+		return page, fmt.Errorf("AdvertsRepo - FetchDeleted - %w", err)
+	}
+	return page, nil
+}
+
+func (ar *AdvertsRepo) fetch(ctx context.Context, deleted bool) (entity.AdvertsPage, error) {
+	page := entity.AdvertsPage{Adverts: []entity.Advert{}}
+
+	if err := ctx.Err(); err != nil {
+		return page, fmt.Errorf("fetch: %w", err)
+	}
+
+	limit := entity.DefaultLimit
+	offset := 0
+	order := entity.DefaultSort
+	sorted := false
+	search := []string{}
+	if val, ok := ctx.Value(entity.KeyLimit).(int); ok && val != 0 {
+		limit = val
+	}
+	if val, ok := ctx.Value(entity.KeyOffset).(int); ok && val != 0 {
+		offset = val
+	}
+	if val, ok := ctx.Value(entity.KeySort).(entity.Sort); ok && len(val) != 0 {
+		order = val
+		sorted = true
+	}
+	if val, ok := ctx.Value(entity.KeyQuery).(string); ok && val != "" {
+		search = tokens(val)
+	}
+	filter, _ := ctx.Value(entity.KeyFilter).(entity.Filter)
+	// fields adverts are sorted by are kept for cursor even if they are not selected
+	fields := entity.ListFields
+	if val, ok := ctx.Value(entity.KeyFields).(entity.Fields); ok {
+		fields = val.WithSort(order)
+	}
+
+	ar.mu.RLock()
+	var categories map[int64]bool
+	if filter.CategoryId != 0 {
+		categories = ar.subtree(filter.CategoryId)
+	}
+	matched := []entity.Advert{}
+	ranks := map[int64]int{}
+	for _, adv := range ar.adverts {
+		// hidden adverts are not listed, but stay in trash if they are deleted
+		if (adv.DeletedAt != "") != deleted || !deleted && adv.HiddenAt != "" ||
+			!matchFilter(adv, filter) ||
+			categories != nil && !categories[adv.CategoryId] {
+			continue
+		}
+		if len(search) != 0 {
+			rank, snippet, ok := matchSearch(adv, search)
+			if !ok {
+				continue
+			}
+			ranks[adv.Id] = rank
+			adv.Snippet = snippet
+		}
+		adv = fields.Trim(copyAdvert(adv))
+		adv.HiddenAt = ""
+		adv.Version = 0
+		matched = append(matched, adv)
+	}
+	ar.mu.RUnlock()
+
+	for _, key := range order {
+		if _, ok := entity.SortFields[key.Field]; !ok {
+			return page, fmt.Errorf("fetch - unknown sort key: %v", key.Field)
+		}
+	}
+	less := func(a, b entity.Advert) bool {
+		return order.Compare(a, b) < 0
+	}
+	// search results are ranked by relevance unless sorting is requested
+	if len(search) != 0 && !sorted {
+		less = func(a, b entity.Advert) bool {
+			if ranks[a.Id] != ranks[b.Id] {
+				return ranks[a.Id] > ranks[b.Id]
+			}
+			return a.Id < b.Id
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return less(matched[i], matched[j])
+	})
+
+	// total count is not affected by cursor
+	page.TotalCount = int64(len(matched))
+	page.PageSize = limit
+	if cursor, ok := ctx.Value(entity.KeyCursor).(entity.Cursor); ok {
+		after, err := cursor.Advert(order)
+		if err != nil {
+			return page, fmt.Errorf("fetch - Advert: %w", err)
+		}
+		offset = sort.Search(len(matched), func(i int) bool {
+			return less(after, matched[i])
+		})
+	} else {
+		page.Page = int64(offset/limit) + 1
+	}
+
+	if offset > len(matched) {
+		offset = len(matched)
+	}
+	matched = matched[offset:]
+	if len(matched) > limit {
+		matched = matched[:limit]
+		page.HasNext = true
+	}
+	page.Adverts = append(page.Adverts, matched...)
+
+	return page, nil
+}
+
+func matchFilter(adv entity.Advert, filter entity.Filter) bool {
+	switch {
+	case filter.OwnerId != 0 && adv.OwnerId != filter.OwnerId,
+		filter.PriceMin != nil && adv.Price < *filter.PriceMin,
+		filter.PriceMax != nil && adv.Price > *filter.PriceMax,
+		!filter.CreatedAfter.IsZero() &&
+			adv.CreatedAt <= filter.CreatedAfter.Local().Format(dateFormat),
+		!filter.CreatedBefore.IsZero() &&
+			adv.CreatedAt >= filter.CreatedBefore.Local().Format(dateFormat),
+		!strings.HasPrefix(strings.ToLower(adv.Name), strings.ToLower(filter.NamePrefix)):
+		return false
+	}
+	if len(filter.Attributes) == 0 {
+		return true
+	}
+
+	attributes := map[string]interface{}{}
+	if len(adv.Attributes) != 0 {
+		if err := json.Unmarshal(adv.Attributes, &attributes); err != nil {
+			return false
+		}
+	}
+	for _, attr := range filter.Attributes {
+		value, ok := attributes[attr.Name]
+		if !ok {
+			return false
+		}
+		// attributes which are not numbers never match bounds
+		number, isNumber := value.(float64)
+		if (attr.Min != nil || attr.Max != nil) && !isNumber ||
+			attr.Min != nil && number < *attr.Min || attr.Max != nil && number > *attr.Max {
+			return false
+		}
+		if attr.Equal != nil {
+			var equal interface{}
+			if err := json.Unmarshal(attr.Equal, &equal); err != nil ||
+				!reflect.DeepEqual(value, equal) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchSearch checks that every word of search is in advert's name or description,
+// matches in name weigh more than matches in description
+func matchSearch(adv entity.Advert, search []string) (int, string, bool) {
+	name := wordSet(adv.Name)
+	description := wordSet(adv.Description)
+
+	rank := 0
+	nameRank := 0
+	for _, word := range search {
+		switch {
+		case name[word]:
+			rank += 10
+			nameRank++
+		case description[word]:
+			rank++
+		default:
+			return 0, "", false
+		}
+	}
+
+	text := adv.Description
+	if nameRank*2 >= len(search) {
+		text = adv.Name
+	}
+	return rank, highlight(text, search), true
+}
+
+// highlight wraps words of text found in search with <b> tag
+func highlight(text string, search []string) string {
+	found := map[string]bool{}
+	for _, word := range search {
+		found[word] = true
+	}
+
+	words := strings.Fields(text)
+	for i, word := range words {
+		if found[strings.ToLower(strings.TrimFunc(word, isSeparator))] {
+			words[i] = "<b>" + word + "</b>"
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func wordSet(text string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range tokens(text) {
+		set[word] = true
+	}
+	return set
+}
+
+// tokens splits text into lower case words, everything
+// but letters and digits separates words
+func tokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), isSeparator)
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+func (ar *AdvertsRepo) Update(ctx context.Context, adv entity.Advert) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("AdvertsRepo - Update: %w", err)
+	}
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	if err := ar.update(ctx, adv); err != nil {
+		return fmt.Errorf("AdvertsRepo - Update: %w", err)
+	}
+	return nil
+}
+
+// update replaces advert, version 0 means advert is updated regardless
+// of its version, mutex should be held by caller
+func (ar *AdvertsRepo) update(ctx context.Context, adv entity.Advert) error {
+	exist, ok := ar.adverts[adv.Id]
+	if !ok || exist.DeletedAt != "" {
+		return entity.ErrItemNotExists
+	}
+	if adv.Version != 0 && adv.Version != exist.Version {
+		return entity.ErrVersionMismatch
+	}
+	if ar.nameTaken(adv.Name, adv.Id) {
+		return entity.ErrNameAlreadyExist
+	}
+
+	exist.Name = adv.Name
+	exist.Description = adv.Description
+	exist.Price = adv.Price
+	exist.MainPhotoUrl = adv.MainPhotoUrl
+	exist.PhotosUrls = append([]string{}, adv.PhotosUrls...)
+	exist.CategoryId = adv.CategoryId
+	exist.Attributes = copyJson(adv.Attributes)
+	exist.Version++
+	ar.adverts[exist.Id] = exist
+	ar.storeRevision(ctx, exist, entity.ActionUpdate)
+
+	return nil
+}
+
+// Delete moves advert to trash
+func (ar *AdvertsRepo) Delete(ctx context.Context, id, version int64) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("AdvertsRepo - Delete: %w", err)
+	}
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	if err := ar.trash(ctx, id, version); err != nil {
+		return fmt.Errorf("AdvertsRepo - Delete: %w", err)
+	}
+	return nil
+}
+
+// trash moves advert to trash, mutex should be held by caller
+func (ar *AdvertsRepo) trash(ctx context.Context, id, version int64) error {
+	exist, ok := ar.adverts[id]
+	if !ok || exist.DeletedAt != "" {
+		return entity.ErrItemNotExists
+	}
+	if version != 0 && version != exist.Version {
+		return entity.ErrVersionMismatch
+	}
+
+	exist.DeletedAt = time.Now().Format(dateFormat)
+	exist.Version++
+	ar.adverts[id] = exist
+	ar.storeRevision(ctx, exist, entity.ActionDelete)
+
+	return nil
+}
+
+// Batch applies operations under one lock, every operation checks everything
+// before it changes adverts, so failed one changes nothing. Atomic batch is
+// undone by putting back adverts and revisions kept before it
+func (ar *AdvertsRepo) Batch(ctx context.Context, ops []entity.BatchOperation,
+	atomic bool) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("AdvertsRepo - Batch: %w", err)
+	}
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	lastId := ar.lastId
+	adverts := make(map[int64]entity.Advert, len(ar.adverts))
+	for id, adv := range ar.adverts {
+		adverts[id] = adv
+	}
+	revisions := make(map[int64][]entity.Revision, len(ar.revisions))
+	for id, revs := range ar.revisions {
+		revisions[id] = revs
+	}
+
+	for i := range ops {
+		if ops[i].Err != nil {
+			continue
+		}
+
+		var err error
+		switch ops[i].Action {
+		case entity.ActionCreate:
+			err = ar.store(ctx, &ops[i].Advert)
+		case entity.ActionUpdate:
+			err = ar.update(ctx, ops[i].Advert)
+		case entity.ActionDelete:
+			err = ar.trash(ctx, ops[i].Advert.Id, ops[i].Advert.Version)
+		default:
+			ar.lastId, ar.adverts, ar.revisions = lastId, adverts, revisions
+			return fmt.Errorf("AdvertsRepo - Batch: unknown action '%v'", ops[i].Action)
+		}
+		if err == nil {
+			continue
+		}
+
+		ops[i].Err = err
+		if atomic {
+			ar.lastId, ar.adverts, ar.revisions = lastId, adverts, revisions
+			entity.AbortBatch(ops)
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// Restore takes advert out of trash
+func (ar *AdvertsRepo) Restore(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("AdvertsRepo - Restore: %w", err)
+	}
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	exist, ok := ar.adverts[id]
+	if !ok || exist.DeletedAt == "" {
+		return fmt.Errorf("AdvertsRepo - Restore: %w", entity.ErrItemNotExists)
+	}
+
+	exist.DeletedAt = ""
+	exist.Version++
+	ar.adverts[id] = exist
+	ar.storeRevision(ctx, exist, entity.ActionRestore)
+
+	return nil
+}
+
+// Hide hides advert from lists or shows it again, hiding
+// keeps time advert was hidden first
+func (ar *AdvertsRepo) Hide(ctx context.Context, id int64, hidden bool) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("AdvertsRepo - Hide: %w", err)
+	}
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	exist, ok := ar.adverts[id]
+	if !ok || exist.DeletedAt != "" {
+		return fmt.Errorf("AdvertsRepo - Hide: %w", entity.ErrItemNotExists)
+	}
+
+	action := entity.ActionHide
+	switch {
+	case !hidden:
+		action = entity.ActionUnhide
+		exist.HiddenAt = ""
+	case exist.HiddenAt == "":
+		exist.HiddenAt = time.Now().Format(dateFormat)
+	}
+	exist.Version++
+	ar.adverts[id] = exist
+	ar.storeRevision(ctx, exist, action)
+
+	return nil
+}
+
+// Purge removes for good adverts which were moved to trash before given time
+func (ar *AdvertsRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("AdvertsRepo - Purge: %w", err)
+	}
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	deletedBefore := before.Local().Format(dateFormat)
+	var purged int64
+	for id, adv := range ar.adverts {
+		if adv.DeletedAt != "" && adv.DeletedAt < deletedBefore {
+			delete(ar.adverts, id)
+			delete(ar.revisions, id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// GetRevisions returns all revisions of advert, oldest first
+func (ar *AdvertsRepo) GetRevisions(ctx context.Context, id int64) ([]entity.Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("AdvertsRepo - GetRevisions: %w", err)
+	}
+
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+
+	revisions := []entity.Revision{}
+	for _, revision := range ar.revisions[id] {
+		revision.Advert = copyAdvert(revision.Advert)
+		revisions = append(revisions, revision)
+	}
+	return revisions, nil
+}
+
+func (ar *AdvertsRepo) GetRevision(ctx context.Context, id, rev int64) (entity.Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.Revision{}, fmt.Errorf("AdvertsRepo - GetRevision: %w", err)
+	}
+
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+
+	for _, revision := range ar.revisions[id] {
+		if revision.Revision == rev {
+			revision.Advert = copyAdvert(revision.Advert)
+			return revision, nil
+		}
+	}
+	return entity.Revision{}, fmt.Errorf("AdvertsRepo - GetRevision: %w", sql.ErrNoRows)
+}
+
+// storeRevision saves snapshot of advert, mutex should be held by caller
+func (ar *AdvertsRepo) storeRevision(ctx context.Context, adv entity.Advert, action string) {
+	identity, _ := ctx.Value(entity.KeyIdentity).(entity.Identity)
+	snapshot := copyAdvert(adv)
+	snapshot.CreatedAt = ""
+	snapshot.DeletedAt = ""
+	snapshot.HiddenAt = ""
+	snapshot.Version = 0
+	ar.revisions[adv.Id] = append(ar.revisions[adv.Id], entity.Revision{
+		Revision:  adv.Version,
+		Action:    action,
+		Advert:    snapshot,
+		UserId:    identity.UserId,
+		ApiKeyId:  identity.ApiKeyId,
+		CreatedAt: time.Now().Format(dateFormat),
+	})
+}
+
+// nameTaken tells if name belongs to advert other than given one,
+// adverts in trash keep their names, mutex should be held by caller
+func (ar *AdvertsRepo) nameTaken(name string, id int64) bool {
+	for _, adv := range ar.adverts {
+		if adv.Name == name && adv.Id != id {
+			return true
+		}
+	}
+	return false
+}
+
+func copyAdvert(adv entity.Advert) entity.Advert {
+	if adv.PhotosUrls != nil {
+		adv.PhotosUrls = append([]string{}, adv.PhotosUrls...)
+	}
+	adv.Attributes = copyJson(adv.Attributes)
+	return adv
+}
+
+func copyJson(value json.RawMessage) json.RawMessage {
+	if value == nil {
+		return nil
+	}
+	return append(json.RawMessage{}, value...)
+}
@@ -0,0 +1,93 @@
+package memory_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/internal/repository"
+	"github.com/mrsubudei/adv-store-service/internal/repository/memory"
+	"github.com/mrsubudei/adv-store-service/internal/repository/repotest"
+)
+
+func newAdvert(name string, price int64) entity.Advert {
+	return entity.Advert{
+		Name:        name,
+		Description: "Lorem ipsum dolor sit amet",
+		Price:       price,
+		PhotosUrls:  []string{"http:fs.com/1", "http:fs.com/2"},
+		CreatedAt:   "2022-11-05 10:00:00",
+	}
+}
+
+func TestStore(t *testing.T) {
+	repo := memory.NewAdvertsRepo()
+	ctx := context.Background()
+
+	adv := newAdvert("car", 150)
+	if err := repo.Store(ctx, &adv); err != nil {
+		t.Fatal("Unable to store:", err)
+	}
+	if adv.Id != 1 || adv.Version != 1 {
+		t.Fatalf("want id 1 version 1, got: %v %v", adv.Id, adv.Version)
+	}
+
+	// stored advert does not share photo urls with caller
+	adv.PhotosUrls[0] = "changed"
+	got, err := repo.GetById(ctx, adv.Id)
+	if err != nil {
+		t.Fatal("Unable to get:", err)
+	}
+	if got.PhotosUrls[0] != "http:fs.com/1" || got.MainPhotoUrl != "http:fs.com/1" {
+		t.Fatalf("want photo urls to be kept, got: %v", got)
+	}
+
+	same := newAdvert("car", 10)
+	if err := repo.Store(ctx, &same); !errors.Is(err, entity.ErrNameAlreadyExist) {
+		t.Fatalf("want: %v, got: %v", entity.ErrNameAlreadyExist, err)
+	}
+
+	if _, err := repo.GetById(ctx, 10); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("want: %v, got: %v", sql.ErrNoRows, err)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repository.Advert {
+		return memory.NewAdvertsRepo()
+	})
+}
+
+func TestCategoriesConformance(t *testing.T) {
+	repotest.RunCategories(t, func(t *testing.T) (repository.Advert, repository.Category) {
+		adverts := memory.NewAdvertsRepo()
+		return adverts, memory.NewCategoriesRepo(adverts)
+	})
+}
+
+func TestUsersConformance(t *testing.T) {
+	repotest.RunUsers(t, func(t *testing.T) (repository.Advert, repository.User) {
+		return memory.NewAdvertsRepo(), memory.NewUsersRepo()
+	})
+}
+
+func TestApiKeysConformance(t *testing.T) {
+	repotest.RunApiKeys(t, func(t *testing.T) (repository.User, repository.ApiKey) {
+		return memory.NewUsersRepo(), memory.NewApiKeysRepo()
+	})
+}
+
+func TestRolesConformance(t *testing.T) {
+	repotest.RunRoles(t, func(t *testing.T) (repository.User, repository.Role,
+		repository.Audit) {
+		return memory.NewUsersRepo(), memory.NewRolesRepo(), memory.NewAuditRepo()
+	})
+}
+
+func TestIdempotencyConformance(t *testing.T) {
+	repotest.RunIdempotency(t, func(t *testing.T) repository.Idempotency {
+		return memory.NewIdempotencyRepo()
+	})
+}
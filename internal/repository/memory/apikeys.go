@@ -0,0 +1,156 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// ApiKeysRepo keeps API keys of users, it is safe for concurrent use
+type ApiKeysRepo struct {
+	mu     sync.RWMutex
+	lastId int64
+	keys   map[int64]entity.ApiKey
+}
+
+func NewApiKeysRepo() *ApiKeysRepo {
+	return &ApiKeysRepo{keys: map[int64]entity.ApiKey{}}
+}
+
+func (kr *ApiKeysRepo) Store(ctx context.Context, key *entity.ApiKey) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("ApiKeysRepo - Store: %w", err)
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	for _, stored := range kr.keys {
+		if stored.Prefix == key.Prefix {
+			return fmt.Errorf("ApiKeysRepo - Store: prefix %v is taken", key.Prefix)
+		}
+	}
+
+	kr.lastId++
+	key.Id = kr.lastId
+	stored := copyApiKey(*key)
+	stored.Key = ""
+	kr.keys[key.Id] = stored
+
+	return nil
+}
+
+func (kr *ApiKeysRepo) GetById(ctx context.Context, id int64) (entity.ApiKey, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.ApiKey{}, fmt.Errorf("ApiKeysRepo - GetById: %w", err)
+	}
+
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	key, ok := kr.keys[id]
+	if !ok {
+		return entity.ApiKey{}, fmt.Errorf("ApiKeysRepo - GetById: %w", sql.ErrNoRows)
+	}
+	return copyApiKey(key), nil
+}
+
+func (kr *ApiKeysRepo) GetByPrefix(ctx context.Context, prefix string) (entity.ApiKey, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.ApiKey{}, fmt.Errorf("ApiKeysRepo - GetByPrefix: %w", err)
+	}
+
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	for _, key := range kr.keys {
+		if key.Prefix == prefix {
+			return copyApiKey(key), nil
+		}
+	}
+	return entity.ApiKey{}, fmt.Errorf("ApiKeysRepo - GetByPrefix: %w", sql.ErrNoRows)
+}
+
+func (kr *ApiKeysRepo) GetByUser(ctx context.Context, userId int64) ([]entity.ApiKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("ApiKeysRepo - GetByUser: %w", err)
+	}
+
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	keys := []entity.ApiKey{}
+	for _, key := range kr.keys {
+		if key.UserId == userId {
+			keys = append(keys, copyApiKey(key))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Id < keys[j].Id
+	})
+	return keys, nil
+}
+
+func (kr *ApiKeysRepo) Rotate(ctx context.Context, key entity.ApiKey) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("ApiKeysRepo - Rotate: %w", err)
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	exist, ok := kr.keys[key.Id]
+	if !ok || exist.RevokedAt != "" {
+		return fmt.Errorf("ApiKeysRepo - Rotate: %w", sql.ErrNoRows)
+	}
+	exist.Prefix = key.Prefix
+	exist.KeyHash = key.KeyHash
+	kr.keys[key.Id] = exist
+
+	return nil
+}
+
+func (kr *ApiKeysRepo) Revoke(ctx context.Context, id int64, revokedAt string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("ApiKeysRepo - Revoke: %w", err)
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	exist, ok := kr.keys[id]
+	if !ok || exist.RevokedAt != "" {
+		return fmt.Errorf("ApiKeysRepo - Revoke: %w", sql.ErrNoRows)
+	}
+	exist.RevokedAt = revokedAt
+	kr.keys[id] = exist
+
+	return nil
+}
+
+func (kr *ApiKeysRepo) Touch(ctx context.Context, id int64, usedAt string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("ApiKeysRepo - Touch: %w", err)
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if exist, ok := kr.keys[id]; ok {
+		exist.LastUsedAt = usedAt
+		kr.keys[id] = exist
+	}
+
+	return nil
+}
+
+func copyApiKey(key entity.ApiKey) entity.ApiKey {
+	if key.Scopes != nil {
+		key.Scopes = append([]string{}, key.Scopes...)
+	}
+	return key
+}
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// AuditRepo keeps trail of authorization decisions, it is safe for concurrent use
+type AuditRepo struct {
+	mu      sync.RWMutex
+	entries []entity.AuditEntry
+}
+
+func NewAuditRepo() *AuditRepo {
+	return &AuditRepo{}
+}
+
+func (ar *AuditRepo) Store(ctx context.Context, entry *entity.AuditEntry) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("AuditRepo - Store: %w", err)
+	}
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	entry.Id = int64(len(ar.entries) + 1)
+	ar.entries = append(ar.entries, *entry)
+
+	return nil
+}
+
+func (ar *AuditRepo) Fetch(ctx context.Context) ([]entity.AuditEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("AuditRepo - Fetch: %w", err)
+	}
+
+	limit, offset := entity.DefaultLimit, 0
+	if val, ok := ctx.Value(entity.KeyLimit).(int); ok && val != 0 {
+		limit = val
+	}
+	if val, ok := ctx.Value(entity.KeyOffset).(int); ok && val != 0 {
+		offset = val
+	}
+
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+
+	entries := []entity.AuditEntry{}
+	for i := len(ar.entries) - 1 - offset; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, ar.entries[i])
+	}
+	return entries, nil
+}
@@ -0,0 +1,180 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// CategoriesRepo keeps categories in repository of adverts
+// they group, so that adverts can be counted and filtered by them
+type CategoriesRepo struct {
+	ar *AdvertsRepo
+}
+
+func NewCategoriesRepo(ar *AdvertsRepo) *CategoriesRepo {
+	return &CategoriesRepo{ar: ar}
+}
+
+func (cr *CategoriesRepo) Store(ctx context.Context, cat *entity.Category) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("CategoriesRepo - Store: %w", err)
+	}
+
+	cr.ar.mu.Lock()
+	defer cr.ar.mu.Unlock()
+
+	if err := cr.ar.checkParent(0, cat.ParentId); err != nil {
+		return fmt.Errorf("CategoriesRepo - Store: %w", err)
+	}
+
+	cr.ar.lastCategoryId++
+	cat.Id = cr.ar.lastCategoryId
+	cr.ar.categories[cat.Id] = entity.Category{
+		Id:       cat.Id,
+		Name:     cat.Name,
+		ParentId: cat.ParentId,
+		Schema:   copyJson(cat.Schema),
+	}
+
+	return nil
+}
+
+func (cr *CategoriesRepo) GetById(ctx context.Context, id int64) (entity.Category, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.Category{}, fmt.Errorf("CategoriesRepo - GetById: %w", err)
+	}
+
+	cr.ar.mu.RLock()
+	defer cr.ar.mu.RUnlock()
+
+	cat, ok := cr.ar.categories[id]
+	if !ok {
+		return entity.Category{}, fmt.Errorf("CategoriesRepo - GetById: %w", sql.ErrNoRows)
+	}
+	cat.Schema = copyJson(cat.Schema)
+	return cat, nil
+}
+
+func (cr *CategoriesRepo) Fetch(ctx context.Context) ([]entity.Category, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("CategoriesRepo - Fetch: %w", err)
+	}
+
+	cr.ar.mu.RLock()
+	defer cr.ar.mu.RUnlock()
+
+	counts := map[int64]int64{}
+	for _, adv := range cr.ar.adverts {
+		if adv.DeletedAt == "" && adv.HiddenAt == "" && adv.CategoryId != 0 {
+			counts[adv.CategoryId]++
+		}
+	}
+
+	categories := []entity.Category{}
+	for _, cat := range cr.ar.categories {
+		for id := range cr.ar.subtree(cat.Id) {
+			cat.AdvertsCount += counts[id]
+		}
+		cat.Schema = copyJson(cat.Schema)
+		categories = append(categories, cat)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Id < categories[j].Id
+	})
+
+	return categories, nil
+}
+
+// Update renames category and moves it to another parent,
+// category can not be moved inside itself
+func (cr *CategoriesRepo) Update(ctx context.Context, cat entity.Category) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("CategoriesRepo - Update: %w", err)
+	}
+
+	cr.ar.mu.Lock()
+	defer cr.ar.mu.Unlock()
+
+	if _, ok := cr.ar.categories[cat.Id]; !ok {
+		return fmt.Errorf("CategoriesRepo - Update: %w", entity.ErrItemNotExists)
+	}
+	if err := cr.ar.checkParent(cat.Id, cat.ParentId); err != nil {
+		return fmt.Errorf("CategoriesRepo - Update: %w", err)
+	}
+
+	cr.ar.categories[cat.Id] = entity.Category{
+		Id:       cat.Id,
+		Name:     cat.Name,
+		ParentId: cat.ParentId,
+		Schema:   copyJson(cat.Schema),
+	}
+
+	return nil
+}
+
+// Delete removes category which has neither subcategories nor adverts,
+// adverts in trash are counted too as they can be restored
+func (cr *CategoriesRepo) Delete(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("CategoriesRepo - Delete: %w", err)
+	}
+
+	cr.ar.mu.Lock()
+	defer cr.ar.mu.Unlock()
+
+	if _, ok := cr.ar.categories[id]; !ok {
+		return fmt.Errorf("CategoriesRepo - Delete: %w", entity.ErrItemNotExists)
+	}
+	for _, cat := range cr.ar.categories {
+		if cat.ParentId == id {
+			return fmt.Errorf("CategoriesRepo - Delete: %w", entity.ErrCategoryNotEmpty)
+		}
+	}
+	for _, adv := range cr.ar.adverts {
+		if adv.CategoryId == id {
+			return fmt.Errorf("CategoriesRepo - Delete: %w", entity.ErrCategoryNotEmpty)
+		}
+	}
+
+	delete(cr.ar.categories, id)
+
+	return nil
+}
+
+// checkParent checks that parent exists and is not inside category,
+// zero parent means top level, mutex should be held by caller
+func (ar *AdvertsRepo) checkParent(id, parentId int64) error {
+	if parentId == 0 {
+		return nil
+	}
+	if _, ok := ar.categories[parentId]; !ok || ar.subtree(id)[parentId] {
+		return entity.ErrWrongParent
+	}
+	return nil
+}
+
+// subtree returns ids of category and all its descendants,
+// mutex should be held by caller
+func (ar *AdvertsRepo) subtree(id int64) map[int64]bool {
+	ids := map[int64]bool{}
+	if _, ok := ar.categories[id]; !ok {
+		return ids
+	}
+
+	children := map[int64][]int64{}
+	for _, cat := range ar.categories {
+		children[cat.ParentId] = append(children[cat.ParentId], cat.Id)
+	}
+	queue := []int64{id}
+	for len(queue) != 0 {
+		current := queue[0]
+		queue = queue[1:]
+		ids[current] = true
+		queue = append(queue, children[current]...)
+	}
+	return ids
+}
@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// IdempotencyRepo keeps responses by idempotency keys, it is safe for concurrent use
+type IdempotencyRepo struct {
+	mu        sync.RWMutex
+	responses map[string]entity.IdempotentResponse
+}
+
+func NewIdempotencyRepo() *IdempotencyRepo {
+	return &IdempotencyRepo{responses: map[string]entity.IdempotentResponse{}}
+}
+
+// Reserve takes over expired key, its old response is dropped
+func (ir *IdempotencyRepo) Reserve(ctx context.Context, resp *entity.IdempotentResponse) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("IdempotencyRepo - Reserve: %w", err)
+	}
+
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+
+	if stored, ok := ir.responses[resp.Key]; ok && stored.ExpiresAt > resp.CreatedAt {
+		return entity.ErrKeyAlreadyExist
+	}
+	resp.Status = 0
+	resp.Body = nil
+	ir.responses[resp.Key] = *resp
+
+	return nil
+}
+
+func (ir *IdempotencyRepo) GetByKey(ctx context.Context,
+	key string) (entity.IdempotentResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.IdempotentResponse{}, fmt.Errorf("IdempotencyRepo - GetByKey: %w", err)
+	}
+
+	ir.mu.RLock()
+	defer ir.mu.RUnlock()
+
+	resp, ok := ir.responses[key]
+	if !ok {
+		return resp, fmt.Errorf("IdempotencyRepo - GetByKey: %w", sql.ErrNoRows)
+	}
+	resp.Body = append([]byte(nil), resp.Body...)
+	return resp, nil
+}
+
+// Complete stores status and body of response to reserved key
+func (ir *IdempotencyRepo) Complete(ctx context.Context, resp entity.IdempotentResponse) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("IdempotencyRepo - Complete: %w", err)
+	}
+
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+
+	stored, ok := ir.responses[resp.Key]
+	if !ok {
+		return fmt.Errorf("IdempotencyRepo - Complete: %w", sql.ErrNoRows)
+	}
+	stored.Status = resp.Status
+	stored.Body = append([]byte(nil), resp.Body...)
+	ir.responses[resp.Key] = stored
+
+	return nil
+}
+
+func (ir *IdempotencyRepo) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("IdempotencyRepo - Delete: %w", err)
+	}
+
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+
+	delete(ir.responses, key)
+	return nil
+}
+
+func (ir *IdempotencyRepo) Purge(ctx context.Context, now time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("IdempotencyRepo - Purge: %w", err)
+	}
+
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+
+	var purged int64
+	expiredBefore := now.Local().Format(dateFormat)
+	for key, resp := range ir.responses {
+		if resp.ExpiresAt <= expiredBefore {
+			delete(ir.responses, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,124 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// RolesRepo keeps roles of users, roles themselves are the same
+// as ones created by database migrations. It is safe for concurrent use.
+type RolesRepo struct {
+	mu        sync.RWMutex
+	roles     []entity.Role
+	userRoles map[int64]map[int64]bool
+}
+
+func NewRolesRepo() *RolesRepo {
+	return &RolesRepo{
+		roles: []entity.Role{
+			{Id: 1, Name: entity.RoleAdmin, Permissions: []string{
+				entity.PermissionDeleteAnyAdvert,
+				entity.PermissionHideAdvert,
+				entity.PermissionUpdateAnyAdvert,
+				entity.PermissionManageApiKeys,
+				entity.PermissionReadAudit,
+				entity.PermissionManageUsers,
+				entity.PermissionManageCategories,
+			}},
+			{Id: 2, Name: entity.RoleModerator, Permissions: []string{
+				entity.PermissionHideAdvert,
+			}},
+		},
+		userRoles: map[int64]map[int64]bool{},
+	}
+}
+
+func (rr *RolesRepo) Fetch(ctx context.Context) ([]entity.Role, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("RolesRepo - Fetch: %w", err)
+	}
+
+	roles := []entity.Role{}
+	for _, role := range rr.roles {
+		roles = append(roles, copyRole(role))
+	}
+	return roles, nil
+}
+
+func (rr *RolesRepo) GetByUser(ctx context.Context, userId int64) ([]entity.Role, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("RolesRepo - GetByUser: %w", err)
+	}
+
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+
+	roles := []entity.Role{}
+	for _, role := range rr.roles {
+		if rr.userRoles[userId][role.Id] {
+			roles = append(roles, copyRole(role))
+		}
+	}
+	return roles, nil
+}
+
+func (rr *RolesRepo) Assign(ctx context.Context, userId int64, role string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("RolesRepo - Assign: %w", err)
+	}
+
+	roleId, ok := rr.roleId(role)
+	if !ok {
+		return fmt.Errorf("RolesRepo - Assign: %w", sql.ErrNoRows)
+	}
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if rr.userRoles[userId] == nil {
+		rr.userRoles[userId] = map[int64]bool{}
+	}
+	rr.userRoles[userId][roleId] = true
+
+	return nil
+}
+
+func (rr *RolesRepo) Unassign(ctx context.Context, userId int64, role string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("RolesRepo - Unassign: %w", err)
+	}
+
+	roleId, ok := rr.roleId(role)
+	if !ok {
+		return fmt.Errorf("RolesRepo - Unassign: %w", sql.ErrNoRows)
+	}
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	delete(rr.userRoles[userId], roleId)
+
+	return nil
+}
+
+// roleId finds role by name, roles never change so mutex is not needed
+func (rr *RolesRepo) roleId(name string) (int64, bool) {
+	for _, role := range rr.roles {
+		if role.Name == name {
+			return role.Id, true
+		}
+	}
+	return 0, false
+}
+
+// copyRole returns role with permissions sorted as databases return them
+func copyRole(role entity.Role) entity.Role {
+	role.Permissions = append([]string{}, role.Permissions...)
+	sort.Strings(role.Permissions)
+	return role
+}
@@ -0,0 +1,124 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// UsersRepo keeps users and their sessions, it is safe for concurrent use
+type UsersRepo struct {
+	mu       sync.RWMutex
+	lastId   int64
+	users    map[int64]entity.User
+	sessions map[string]entity.Session
+}
+
+func NewUsersRepo() *UsersRepo {
+	return &UsersRepo{
+		users:    map[int64]entity.User{},
+		sessions: map[string]entity.Session{},
+	}
+}
+
+func (ur *UsersRepo) Store(ctx context.Context, user *entity.User) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("UsersRepo - Store: %w", err)
+	}
+
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	for _, stored := range ur.users {
+		if stored.Email == user.Email {
+			return fmt.Errorf("UsersRepo - Store: %w", entity.ErrEmailAlreadyExist)
+		}
+	}
+
+	ur.lastId++
+	user.Id = ur.lastId
+	stored := *user
+	stored.Roles = nil
+	ur.users[user.Id] = stored
+
+	return nil
+}
+
+func (ur *UsersRepo) GetById(ctx context.Context, id int64) (entity.User, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.User{}, fmt.Errorf("UsersRepo - GetById: %w", err)
+	}
+
+	ur.mu.RLock()
+	defer ur.mu.RUnlock()
+
+	user, ok := ur.users[id]
+	if !ok {
+		return entity.User{}, fmt.Errorf("UsersRepo - GetById: %w", sql.ErrNoRows)
+	}
+	return user, nil
+}
+
+func (ur *UsersRepo) GetByEmail(ctx context.Context, email string) (entity.User, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.User{}, fmt.Errorf("UsersRepo - GetByEmail: %w", err)
+	}
+
+	ur.mu.RLock()
+	defer ur.mu.RUnlock()
+
+	for _, user := range ur.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return entity.User{}, fmt.Errorf("UsersRepo - GetByEmail: %w", sql.ErrNoRows)
+}
+
+func (ur *UsersRepo) StoreSession(ctx context.Context, session entity.Session) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("UsersRepo - StoreSession: %w", err)
+	}
+
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	if _, ok := ur.users[session.UserId]; !ok {
+		return fmt.Errorf("UsersRepo - StoreSession: user %v does not exist", session.UserId)
+	}
+	session.Token = ""
+	ur.sessions[session.TokenHash] = session
+
+	return nil
+}
+
+func (ur *UsersRepo) GetSession(ctx context.Context, tokenHash string) (entity.Session, error) {
+	if err := ctx.Err(); err != nil {
+		return entity.Session{}, fmt.Errorf("UsersRepo - GetSession: %w", err)
+	}
+
+	ur.mu.RLock()
+	defer ur.mu.RUnlock()
+
+	session, ok := ur.sessions[tokenHash]
+	if !ok {
+		return entity.Session{}, fmt.Errorf("UsersRepo - GetSession: %w", sql.ErrNoRows)
+	}
+	return session, nil
+}
+
+func (ur *UsersRepo) DeleteSession(ctx context.Context, tokenHash string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("UsersRepo - DeleteSession: %w", err)
+	}
+
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	delete(ur.sessions, tokenHash)
+
+	return nil
+}
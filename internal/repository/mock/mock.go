@@ -3,14 +3,15 @@ package mock_repository
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"time"
 
 	"github.com/mrsubudei/adv-store-service/internal/entity"
-	"github.com/mrsubudei/adv-store-service/internal/service"
 )
 
 type MockRepo struct {
-	Adverts []entity.Advert
+	Adverts   []entity.Advert
+	Trash     []entity.Advert
+	Revisions []entity.Revision
 }
 
 func NewMockRepo() *MockRepo {
@@ -20,10 +21,12 @@ func NewMockRepo() *MockRepo {
 func (mr *MockRepo) Store(ctx context.Context, adv *entity.Advert) error {
 	for i := 0; i < len(mr.Adverts); i++ {
 		if mr.Adverts[i].Id == adv.Id {
-			return fmt.Errorf(service.UniqueNameConstraint)
+			return entity.ErrNameAlreadyExist
 		}
 	}
+	adv.Version = 1
 	mr.Adverts = append(mr.Adverts, *adv)
+	mr.storeRevision(ctx, *adv, entity.ActionCreate)
 	return nil
 }
 func (mr *MockRepo) GetById(ctx context.Context, id int64) (entity.Advert, error) {
@@ -35,13 +38,33 @@ func (mr *MockRepo) GetById(ctx context.Context, id int64) (entity.Advert, error
 
 	return entity.Advert{}, sql.ErrNoRows
 }
-func (mr *MockRepo) Fetch(ctx context.Context) ([]entity.Advert, error) {
-	return mr.Adverts, nil
+func (mr *MockRepo) GetByIds(ctx context.Context, ids []int64) ([]entity.Advert, error) {
+	adverts := []entity.Advert{}
+	for _, id := range ids {
+		if adv, err := mr.GetById(ctx, id); err == nil {
+			adverts = append(adverts, adv)
+		}
+	}
+	return adverts, nil
+}
+func (mr *MockRepo) Fetch(ctx context.Context) (entity.AdvertsPage, error) {
+	return entity.AdvertsPage{
+		Adverts:    mr.Adverts,
+		TotalCount: int64(len(mr.Adverts)),
+		Page:       1,
+		PageSize:   entity.DefaultLimit,
+	}, nil
 }
 func (mr *MockRepo) Update(ctx context.Context, adv entity.Advert) error {
 	for i := 0; i < len(mr.Adverts); i++ {
 		if mr.Adverts[i].Id == adv.Id {
+			if adv.Version != 0 && adv.Version != mr.Adverts[i].Version {
+				return entity.ErrVersionMismatch
+			}
+			adv.Version = mr.Adverts[i].Version + 1
+			adv.OwnerId = mr.Adverts[i].OwnerId
 			mr.Adverts[i] = adv
+			mr.storeRevision(ctx, adv, entity.ActionUpdate)
 			return nil
 		}
 
@@ -49,22 +72,490 @@ func (mr *MockRepo) Update(ctx context.Context, adv entity.Advert) error {
 	return sql.ErrNoRows
 }
 
-func (mr *MockRepo) Delete(ctx context.Context, id int64) error {
-	newAdverts := []entity.Advert{}
-	found := false
+func (mr *MockRepo) Delete(ctx context.Context, id, version int64) error {
+	for i, v := range mr.Adverts {
+		if v.Id == id {
+			if version != 0 && version != v.Version {
+				return entity.ErrVersionMismatch
+			}
+			v.DeletedAt = time.Now().Format("2006-01-02 15:04:05")
+			v.Version++
+			mr.Trash = append(mr.Trash, v)
+			mr.Adverts = deleteElement(mr.Adverts, i)
+			mr.storeRevision(ctx, v, entity.ActionDelete)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+// Batch applies operations one by one, atomic batch is undone
+// by putting back adverts kept before it
+func (mr *MockRepo) Batch(ctx context.Context, ops []entity.BatchOperation, atomic bool) error {
+	adverts := append([]entity.Advert{}, mr.Adverts...)
+	trash := append([]entity.Advert{}, mr.Trash...)
+	revisions := append([]entity.Revision{}, mr.Revisions...)
+	for i := range ops {
+		if ops[i].Err != nil {
+			continue
+		}
+		var err error
+		switch ops[i].Action {
+		case entity.ActionCreate:
+			err = mr.Store(ctx, &ops[i].Advert)
+		case entity.ActionUpdate:
+			err = mr.Update(ctx, ops[i].Advert)
+		case entity.ActionDelete:
+			err = mr.Delete(ctx, ops[i].Advert.Id, ops[i].Advert.Version)
+		}
+		if err != nil {
+			ops[i].Err = err
+			if atomic {
+				mr.Adverts, mr.Trash, mr.Revisions = adverts, trash, revisions
+				entity.AbortBatch(ops)
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// FetchDeleted takes only owner from filter
+func (mr *MockRepo) FetchDeleted(ctx context.Context) (entity.AdvertsPage, error) {
+	filter, _ := ctx.Value(entity.KeyFilter).(entity.Filter)
+	trash := []entity.Advert{}
+	for _, v := range mr.Trash {
+		if filter.OwnerId == 0 || v.OwnerId == filter.OwnerId {
+			trash = append(trash, v)
+		}
+	}
+	return entity.AdvertsPage{
+		Adverts:    trash,
+		TotalCount: int64(len(trash)),
+		Page:       1,
+		PageSize:   entity.DefaultLimit,
+	}, nil
+}
+
+func (mr *MockRepo) GetDeletedById(ctx context.Context, id int64) (entity.Advert, error) {
+	for _, v := range mr.Trash {
+		if v.Id == id {
+			return v, nil
+		}
+	}
+	return entity.Advert{}, sql.ErrNoRows
+}
+
+func (mr *MockRepo) Restore(ctx context.Context, id int64) error {
+	for i, v := range mr.Trash {
+		if v.Id == id {
+			v.DeletedAt = ""
+			v.Version++
+			mr.Adverts = append(mr.Adverts, v)
+			mr.Trash = deleteElement(mr.Trash, i)
+			mr.storeRevision(ctx, v, entity.ActionRestore)
+			return nil
+		}
+	}
+	return entity.ErrItemNotExists
+}
+
+func (mr *MockRepo) Hide(ctx context.Context, id int64, hidden bool) error {
 	for i, v := range mr.Adverts {
 		if v.Id == id {
-			found = true
-			newAdverts = deleteElement(mr.Adverts, i)
+			action := entity.ActionUnhide
+			mr.Adverts[i].HiddenAt = ""
+			if hidden {
+				action = entity.ActionHide
+				mr.Adverts[i].HiddenAt = "2022-10-01 12:00:00"
+			}
+			mr.Adverts[i].Version++
+			mr.storeRevision(ctx, mr.Adverts[i], action)
+			return nil
+		}
+	}
+	return entity.ErrItemNotExists
+}
+
+func (mr *MockRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	kept := []entity.Advert{}
+	for _, v := range mr.Trash {
+		if v.DeletedAt >= before.Format("2006-01-02 15:04:05") {
+			kept = append(kept, v)
+		}
+	}
+	purged := int64(len(mr.Trash) - len(kept))
+	mr.Trash = kept
+	return purged, nil
+}
+
+func (mr *MockRepo) storeRevision(ctx context.Context, adv entity.Advert, action string) {
+	identity, _ := ctx.Value(entity.KeyIdentity).(entity.Identity)
+	adv.DeletedAt = ""
+	mr.Revisions = append(mr.Revisions, entity.Revision{
+		Revision:  adv.Version,
+		Action:    action,
+		Advert:    adv,
+		UserId:    identity.UserId,
+		ApiKeyId:  identity.ApiKeyId,
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+func (mr *MockRepo) GetRevisions(ctx context.Context, id int64) ([]entity.Revision, error) {
+	revisions := []entity.Revision{}
+	for _, v := range mr.Revisions {
+		if v.Advert.Id == id {
+			revisions = append(revisions, v)
+		}
+	}
+	return revisions, nil
+}
+
+func (mr *MockRepo) GetRevision(ctx context.Context, id, rev int64) (entity.Revision, error) {
+	for _, v := range mr.Revisions {
+		if v.Advert.Id == id && v.Revision == rev {
+			return v, nil
+		}
+	}
+	return entity.Revision{}, sql.ErrNoRows
+}
+
+type MockCategoryRepo struct {
+	Categories []entity.Category
+}
+
+func NewMockCategoryRepo() *MockCategoryRepo {
+	return &MockCategoryRepo{}
+}
+
+func (mc *MockCategoryRepo) Store(ctx context.Context, cat *entity.Category) error {
+	if cat.ParentId != 0 {
+		if _, err := mc.GetById(ctx, cat.ParentId); err != nil {
+			return entity.ErrWrongParent
+		}
+	}
+	cat.Id = int64(len(mc.Categories) + 1)
+	mc.Categories = append(mc.Categories, *cat)
+	return nil
+}
+
+func (mc *MockCategoryRepo) GetById(ctx context.Context, id int64) (entity.Category, error) {
+	for _, v := range mc.Categories {
+		if v.Id == id {
+			return v, nil
+		}
+	}
+	return entity.Category{}, sql.ErrNoRows
+}
+
+func (mc *MockCategoryRepo) Fetch(ctx context.Context) ([]entity.Category, error) {
+	return mc.Categories, nil
+}
+
+func (mc *MockCategoryRepo) Update(ctx context.Context, cat entity.Category) error {
+	for i, v := range mc.Categories {
+		if v.Id == cat.Id {
+			if cat.ParentId == cat.Id {
+				return entity.ErrWrongParent
+			}
+			mc.Categories[i] = cat
+			return nil
+		}
+	}
+	return entity.ErrItemNotExists
+}
+
+func (mc *MockCategoryRepo) Delete(ctx context.Context, id int64) error {
+	for _, v := range mc.Categories {
+		if v.ParentId == id || v.Id == id && v.AdvertsCount != 0 {
+			return entity.ErrCategoryNotEmpty
+		}
+	}
+	for i, v := range mc.Categories {
+		if v.Id == id {
+			mc.Categories = deleteElement(mc.Categories, i)
+			return nil
+		}
+	}
+	return entity.ErrItemNotExists
+}
+
+type MockUserRepo struct {
+	Users    []entity.User
+	Sessions []entity.Session
+}
+
+func NewMockUserRepo() *MockUserRepo {
+	return &MockUserRepo{}
+}
+
+func (mu *MockUserRepo) Store(ctx context.Context, user *entity.User) error {
+	for _, v := range mu.Users {
+		if v.Email == user.Email {
+			return entity.ErrEmailAlreadyExist
+		}
+	}
+	user.Id = int64(len(mu.Users) + 1)
+	mu.Users = append(mu.Users, *user)
+	return nil
+}
+
+func (mu *MockUserRepo) GetById(ctx context.Context, id int64) (entity.User, error) {
+	for _, v := range mu.Users {
+		if v.Id == id {
+			return v, nil
 		}
 	}
-	if found {
-		mr.Adverts = []entity.Advert{}
-		mr.Adverts = append(mr.Adverts, newAdverts...)
-		return nil
-	} else {
+	return entity.User{}, sql.ErrNoRows
+}
+
+func (mu *MockUserRepo) GetByEmail(ctx context.Context, email string) (entity.User, error) {
+	for _, v := range mu.Users {
+		if v.Email == email {
+			return v, nil
+		}
+	}
+	return entity.User{}, sql.ErrNoRows
+}
+
+func (mu *MockUserRepo) StoreSession(ctx context.Context, session entity.Session) error {
+	mu.Sessions = append(mu.Sessions, session)
+	return nil
+}
+
+func (mu *MockUserRepo) GetSession(ctx context.Context, tokenHash string) (entity.Session, error) {
+	for _, v := range mu.Sessions {
+		if v.TokenHash == tokenHash {
+			return v, nil
+		}
+	}
+	return entity.Session{}, sql.ErrNoRows
+}
+
+func (mu *MockUserRepo) DeleteSession(ctx context.Context, tokenHash string) error {
+	for i, v := range mu.Sessions {
+		if v.TokenHash == tokenHash {
+			mu.Sessions = deleteElement(mu.Sessions, i)
+			return nil
+		}
+	}
+	return nil
+}
+
+type MockApiKeyRepo struct {
+	Keys []entity.ApiKey
+}
+
+func NewMockApiKeyRepo() *MockApiKeyRepo {
+	return &MockApiKeyRepo{}
+}
+
+func (mk *MockApiKeyRepo) Store(ctx context.Context, key *entity.ApiKey) error {
+	key.Id = int64(len(mk.Keys) + 1)
+	mk.Keys = append(mk.Keys, *key)
+	return nil
+}
+
+func (mk *MockApiKeyRepo) GetById(ctx context.Context, id int64) (entity.ApiKey, error) {
+	for _, v := range mk.Keys {
+		if v.Id == id {
+			return v, nil
+		}
+	}
+	return entity.ApiKey{}, sql.ErrNoRows
+}
+
+func (mk *MockApiKeyRepo) GetByPrefix(ctx context.Context, prefix string) (entity.ApiKey, error) {
+	for _, v := range mk.Keys {
+		if v.Prefix == prefix {
+			return v, nil
+		}
+	}
+	return entity.ApiKey{}, sql.ErrNoRows
+}
+
+func (mk *MockApiKeyRepo) GetByUser(ctx context.Context, userId int64) ([]entity.ApiKey, error) {
+	keys := []entity.ApiKey{}
+	for _, v := range mk.Keys {
+		if v.UserId == userId {
+			keys = append(keys, v)
+		}
+	}
+	return keys, nil
+}
+
+func (mk *MockApiKeyRepo) Rotate(ctx context.Context, key entity.ApiKey) error {
+	for i, v := range mk.Keys {
+		if v.Id == key.Id && v.RevokedAt == "" {
+			mk.Keys[i].Prefix = key.Prefix
+			mk.Keys[i].KeyHash = key.KeyHash
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (mk *MockApiKeyRepo) Revoke(ctx context.Context, id int64, revokedAt string) error {
+	for i, v := range mk.Keys {
+		if v.Id == id && v.RevokedAt == "" {
+			mk.Keys[i].RevokedAt = revokedAt
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (mk *MockApiKeyRepo) Touch(ctx context.Context, id int64, usedAt string) error {
+	for i, v := range mk.Keys {
+		if v.Id == id {
+			mk.Keys[i].LastUsedAt = usedAt
+		}
+	}
+	return nil
+}
+
+// MockRoleRepo has roles of the same names and permissions as database
+type MockRoleRepo struct {
+	Roles     []entity.Role
+	UserRoles map[int64][]string
+}
+
+func NewMockRoleRepo() *MockRoleRepo {
+	return &MockRoleRepo{
+		Roles: []entity.Role{
+			{Id: 1, Name: entity.RoleAdmin, Permissions: []string{
+				entity.PermissionDeleteAnyAdvert, entity.PermissionHideAdvert,
+				entity.PermissionUpdateAnyAdvert, entity.PermissionManageApiKeys,
+				entity.PermissionReadAudit, entity.PermissionManageUsers,
+				entity.PermissionManageCategories,
+			}},
+			{Id: 2, Name: entity.RoleModerator, Permissions: []string{
+				entity.PermissionHideAdvert,
+			}},
+		},
+		UserRoles: map[int64][]string{},
+	}
+}
+
+func (mr *MockRoleRepo) Fetch(ctx context.Context) ([]entity.Role, error) {
+	return mr.Roles, nil
+}
+
+func (mr *MockRoleRepo) GetByUser(ctx context.Context, userId int64) ([]entity.Role, error) {
+	roles := []entity.Role{}
+	for _, role := range mr.Roles {
+		for _, name := range mr.UserRoles[userId] {
+			if role.Name == name {
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles, nil
+}
+
+func (mr *MockRoleRepo) Assign(ctx context.Context, userId int64, role string) error {
+	if !mr.known(role) {
 		return sql.ErrNoRows
 	}
+	for _, name := range mr.UserRoles[userId] {
+		if name == role {
+			return nil
+		}
+	}
+	mr.UserRoles[userId] = append(mr.UserRoles[userId], role)
+	return nil
+}
+
+func (mr *MockRoleRepo) Unassign(ctx context.Context, userId int64, role string) error {
+	if !mr.known(role) {
+		return sql.ErrNoRows
+	}
+	for i, name := range mr.UserRoles[userId] {
+		if name == role {
+			mr.UserRoles[userId] = deleteElement(mr.UserRoles[userId], i)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (mr *MockRoleRepo) known(role string) bool {
+	for _, v := range mr.Roles {
+		if v.Name == role {
+			return true
+		}
+	}
+	return false
+}
+
+type MockAuditRepo struct {
+	Entries []entity.AuditEntry
+}
+
+func NewMockAuditRepo() *MockAuditRepo {
+	return &MockAuditRepo{}
+}
+
+func (ma *MockAuditRepo) Store(ctx context.Context, entry *entity.AuditEntry) error {
+	entry.Id = int64(len(ma.Entries) + 1)
+	ma.Entries = append(ma.Entries, *entry)
+	return nil
+}
+
+// Fetch returns all entries newest first
+func (ma *MockAuditRepo) Fetch(ctx context.Context) ([]entity.AuditEntry, error) {
+	entries := []entity.AuditEntry{}
+	for i := len(ma.Entries) - 1; i >= 0; i-- {
+		entries = append(entries, ma.Entries[i])
+	}
+	return entries, nil
+}
+
+// MockIdempotencyRepo ignores expiry of responses
+type MockIdempotencyRepo struct {
+	Responses map[string]entity.IdempotentResponse
+}
+
+func NewMockIdempotencyRepo() *MockIdempotencyRepo {
+	return &MockIdempotencyRepo{Responses: map[string]entity.IdempotentResponse{}}
+}
+
+func (mi *MockIdempotencyRepo) Reserve(ctx context.Context, resp *entity.IdempotentResponse) error {
+	if _, ok := mi.Responses[resp.Key]; ok {
+		return entity.ErrKeyAlreadyExist
+	}
+	mi.Responses[resp.Key] = *resp
+	return nil
+}
+
+func (mi *MockIdempotencyRepo) GetByKey(ctx context.Context,
+	key string) (entity.IdempotentResponse, error) {
+	resp, ok := mi.Responses[key]
+	if !ok {
+		return resp, sql.ErrNoRows
+	}
+	return resp, nil
+}
+
+func (mi *MockIdempotencyRepo) Complete(ctx context.Context, resp entity.IdempotentResponse) error {
+	stored, ok := mi.Responses[resp.Key]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	stored.Status = resp.Status
+	stored.Body = resp.Body
+	mi.Responses[resp.Key] = stored
+	return nil
+}
+
+func (mi *MockIdempotencyRepo) Delete(ctx context.Context, key string) error {
+	delete(mi.Responses, key)
+	return nil
+}
+
+func (mi *MockIdempotencyRepo) Purge(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
 }
 
 func deleteElement[C any](sl []C, index int) []C {
@@ -0,0 +1,342 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/internal/repository/postgres"
+)
+
+var (
+	advert1 = entity.Advert{
+		Name:         "car",
+		Description:  "Lorem ipsum dolor sit amet",
+		Price:        150,
+		MainPhotoUrl: "http:fs.com/1",
+		PhotosUrls: []string{
+			"http:fs.com/1",
+			"http:fs.com/2",
+			"http:fs.com/3",
+		},
+		CreatedAt: "2023-01-10 10:00:00",
+	}
+
+	advert2 = entity.Advert{
+		Name:         "toy",
+		Description:  "Lorem ipsum dolor sit",
+		Price:        90,
+		MainPhotoUrl: "http:fs.com/6",
+		PhotosUrls: []string{
+			"http:fs.com/6",
+			"http:fs.com/7",
+			"http:fs.com/8",
+		},
+		CreatedAt: "2023-01-11 10:00:00",
+	}
+	advert3 = entity.Advert{
+		Name:         "suit",
+		Description:  "Lorem ipsum dolor",
+		Price:        50,
+		MainPhotoUrl: "http:fs.com/8",
+		PhotosUrls: []string{
+			"http:fs.com/8",
+			"http:fs.com/9",
+			"http:fs.com/10",
+		},
+		CreatedAt: "2023-01-12 10:00:00",
+	}
+)
+
+func newRepo(t *testing.T) *postgres.AdvertsRepo {
+	db := postgres.MustOpenDB(t)
+	t.Cleanup(func() { postgres.MustCloseDB(t, db) })
+	if err := postgres.Migrate(context.Background(), db); err != nil {
+		t.Fatal("Unable to migrate db:", err)
+	}
+	return postgres.NewAdvertsRepo(db)
+}
+
+func storeAll(t *testing.T, repo *postgres.AdvertsRepo, adverts ...entity.Advert) {
+	for _, adv := range adverts {
+		adv := adv
+		if err := repo.Store(context.Background(), &adv); err != nil {
+			t.Fatal("Unable to store:", err)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	repo := newRepo(t)
+	ctx := context.Background()
+
+	t.Run("OK", func(t *testing.T) {
+		adv := advert1
+		if err := repo.Store(ctx, &adv); err != nil {
+			t.Fatal("Unable to store:", err)
+		} else if adv.Id != 1 || adv.Version != 1 {
+			t.Fatalf("want id and version 1, got: %v, %v", adv.Id, adv.Version)
+		}
+	})
+
+	t.Run("Err name already exists", func(t *testing.T) {
+		adv := advert2
+		adv.Name = advert1.Name
+		if err := repo.Store(ctx, &adv); !errors.Is(err, entity.ErrNameAlreadyExist) {
+			t.Fatalf("want: %v, got: %v", entity.ErrNameAlreadyExist, err)
+		}
+	})
+}
+
+func TestGetById(t *testing.T) {
+	repo := newRepo(t)
+	ctx := context.Background()
+	storeAll(t, repo, advert1)
+
+	t.Run("OK", func(t *testing.T) {
+		want := advert1
+		want.Id = 1
+		want.Version = 1
+		want.CreatedAt = ""
+		if found, err := repo.GetById(ctx, 1); err != nil {
+			t.Fatal("Unable to GetById:", err)
+		} else if !reflect.DeepEqual(want, found) {
+			t.Fatalf("mismatch: %#v != %#v", want, found)
+		}
+	})
+
+	t.Run("Err no rows", func(t *testing.T) {
+		if _, err := repo.GetById(ctx, 7); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("want: %v, got: %v", sql.ErrNoRows, err)
+		}
+	})
+}
+
+func TestUpdate(t *testing.T) {
+	repo := newRepo(t)
+	ctx := context.Background()
+	storeAll(t, repo, advert1, advert2)
+
+	t.Run("OK", func(t *testing.T) {
+		updated := advert1
+		updated.Id = 1
+		updated.Version = 1
+		updated.Price = 300
+		updated.PhotosUrls = []string{"http:fs.com/4", "http:fs.com/5"}
+		updated.MainPhotoUrl = "http:fs.com/4"
+		if err := repo.Update(ctx, updated); err != nil {
+			t.Fatal("Unable to update:", err)
+		}
+
+		updated.Version = 2
+		updated.CreatedAt = ""
+		if found, err := repo.GetById(ctx, 1); err != nil {
+			t.Fatal("Unable to GetById:", err)
+		} else if !reflect.DeepEqual(updated, found) {
+			t.Fatalf("mismatch: %#v != %#v", updated, found)
+		}
+	})
+
+	t.Run("Err version mismatch", func(t *testing.T) {
+		updated := advert1
+		updated.Id = 1
+		updated.Version = 1
+		if err := repo.Update(ctx, updated); !errors.Is(err, entity.ErrVersionMismatch) {
+			t.Fatalf("want: %v, got: %v", entity.ErrVersionMismatch, err)
+		}
+	})
+
+	t.Run("Err name already exists", func(t *testing.T) {
+		updated := advert1
+		updated.Id = 1
+		updated.Name = advert2.Name
+		if err := repo.Update(ctx, updated); !errors.Is(err, entity.ErrNameAlreadyExist) {
+			t.Fatalf("want: %v, got: %v", entity.ErrNameAlreadyExist, err)
+		}
+	})
+
+	t.Run("Err item not found", func(t *testing.T) {
+		updated := advert1
+		updated.Id = 9
+		if err := repo.Update(ctx, updated); !errors.Is(err, entity.ErrItemNotExists) {
+			t.Fatalf("want: %v, got: %v", entity.ErrItemNotExists, err)
+		}
+	})
+}
+
+func TestFetch(t *testing.T) {
+	repo := newRepo(t)
+	ctx := context.Background()
+	storeAll(t, repo, advert1, advert2, advert3)
+
+	ids := func(page entity.AdvertsPage) []int64 {
+		ids := []int64{}
+		for _, adv := range page.Adverts {
+			ids = append(ids, adv.Id)
+		}
+		return ids
+	}
+
+	priceMin := int64(60)
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		wantIds  []int64
+		wantNext bool
+	}{
+		{
+			name:    "OK all",
+			ctx:     ctx,
+			wantIds: []int64{1, 2, 3},
+		},
+		{
+			name: "OK sorted by price with limit",
+			ctx: context.WithValue(context.WithValue(ctx, entity.KeySort,
+				entity.Sort{{Field: "price"}, {Field: "id"}}), entity.KeyLimit, 2),
+			wantIds:  []int64{3, 2},
+			wantNext: true,
+		},
+		{
+			name: "OK cursor by created_at",
+			ctx: context.WithValue(context.WithValue(ctx, entity.KeySort,
+				entity.Sort{{Field: "created_at"}, {Field: "id"}}), entity.KeyCursor,
+				entity.Cursor{Sort: "created_at,id", Values: []string{advert1.CreatedAt}, Id: 1}),
+			wantIds: []int64{2, 3},
+		},
+		{
+			name: "OK filter",
+			ctx: context.WithValue(ctx, entity.KeyFilter, entity.Filter{PriceMin: &priceMin,
+				CreatedBefore: time.Date(2023, 1, 11, 12, 0, 0, 0, time.Local)}),
+			wantIds: []int64{1, 2},
+		},
+		{
+			name:    "OK name prefix",
+			ctx:     context.WithValue(ctx, entity.KeyFilter, entity.Filter{NamePrefix: "to"}),
+			wantIds: []int64{2},
+		},
+		{
+			name:    "OK search",
+			ctx:     context.WithValue(ctx, entity.KeyQuery, "amet"),
+			wantIds: []int64{1},
+		},
+		{
+			name:    "OK search special characters",
+			ctx:     context.WithValue(ctx, entity.KeyQuery, `toy" OR "car*`),
+			wantIds: []int64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := repo.Fetch(tt.ctx)
+			if err != nil {
+				t.Fatal("Unable to Fetch:", err)
+			}
+			if !reflect.DeepEqual(tt.wantIds, ids(page)) {
+				t.Fatalf("want: %v, got: %v", tt.wantIds, ids(page))
+			}
+			if page.HasNext != tt.wantNext {
+				t.Fatalf("want has next: %v, got: %v", tt.wantNext, page.HasNext)
+			}
+		})
+	}
+
+	t.Run("OK search snippet", func(t *testing.T) {
+		ctx := context.WithValue(ctx, entity.KeyQuery, "amet")
+		if page, err := repo.Fetch(ctx); err != nil {
+			t.Fatal("Unable to Fetch:", err)
+		} else if len(page.Adverts) != 1 ||
+			!strings.Contains(page.Adverts[0].Snippet, "<b>amet</b>") {
+			t.Fatalf("snippet is not highlighted: %#v", page.Adverts)
+		}
+	})
+}
+
+func TestTrash(t *testing.T) {
+	repo := newRepo(t)
+	ctx := context.Background()
+	storeAll(t, repo, advert1, advert2)
+
+	if err := repo.Delete(ctx, 1, 1); err != nil {
+		t.Fatal("Unable to delete:", err)
+	}
+
+	t.Run("Deleted advert is hidden", func(t *testing.T) {
+		if _, err := repo.GetById(ctx, 1); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("want: %v, got: %v", sql.ErrNoRows, err)
+		}
+		if err := repo.Delete(ctx, 1, 0); !errors.Is(err, entity.ErrItemNotExists) {
+			t.Fatalf("want: %v, got: %v", entity.ErrItemNotExists, err)
+		}
+		if page, err := repo.FetchDeleted(ctx); err != nil {
+			t.Fatal("Unable to fetch deleted:", err)
+		} else if len(page.Adverts) != 1 || page.Adverts[0].DeletedAt == "" {
+			t.Fatalf("want deleted advert 1, got: %#v", page.Adverts)
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		if err := repo.Restore(ctx, 1); err != nil {
+			t.Fatal("Unable to restore:", err)
+		}
+		if found, err := repo.GetById(ctx, 1); err != nil {
+			t.Fatal("Unable to GetById:", err)
+		} else if found.Version != 3 {
+			t.Fatalf("want version: %v, got: %v", 3, found.Version)
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		if err := repo.Delete(ctx, 2, 0); err != nil {
+			t.Fatal("Unable to delete:", err)
+		}
+		if purged, err := repo.Purge(ctx, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal("Unable to purge:", err)
+		} else if purged != 1 {
+			t.Fatalf("want purged: %v, got: %v", 1, purged)
+		}
+		if revisions, err := repo.GetRevisions(ctx, 2); err != nil {
+			t.Fatal("Unable to get revisions:", err)
+		} else if len(revisions) != 0 {
+			t.Fatalf("want no revisions, got: %v", revisions)
+		}
+	})
+}
+
+func TestRevisions(t *testing.T) {
+	repo := newRepo(t)
+	ctx := context.Background()
+	storeAll(t, repo, advert1)
+
+	updated := advert1
+	updated.Id = 1
+	updated.Price = 200
+	if err := repo.Update(ctx, updated); err != nil {
+		t.Fatal("Unable to update:", err)
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		revisions, err := repo.GetRevisions(ctx, 1)
+		if err != nil {
+			t.Fatal("Unable to get revisions:", err)
+		}
+		if len(revisions) != 2 || revisions[0].Action != entity.ActionCreate ||
+			revisions[1].Action != entity.ActionUpdate || revisions[1].Advert.Price != 200 {
+			t.Fatalf("unexpected revisions: %#v", revisions)
+		}
+		if !reflect.DeepEqual(advert1.PhotosUrls, revisions[0].Advert.PhotosUrls) {
+			t.Fatalf("want: %v, got: %v", advert1.PhotosUrls, revisions[0].Advert.PhotosUrls)
+		}
+	})
+
+	t.Run("Err revision not found", func(t *testing.T) {
+		if _, err := repo.GetRevision(ctx, 1, 5); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("want: %v, got: %v", sql.ErrNoRows, err)
+		}
+	})
+}
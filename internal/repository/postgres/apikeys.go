@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/pkg/postgres"
+)
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, created_at,
+	last_used_at, revoked_at`
+
+type ApiKeysRepo struct {
+	*postgres.Postgres
+}
+
+func NewApiKeysRepo(pg *postgres.Postgres) *ApiKeysRepo {
+	return &ApiKeysRepo{pg}
+}
+
+func (kr *ApiKeysRepo) Store(ctx context.Context, key *entity.ApiKey) error {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return fmt.Errorf("ApiKeysRepo - Store - Marshal: %w", err)
+	}
+
+	err = kr.DB.QueryRowContext(ctx,
+		`INSERT INTO api_keys(user_id, name, prefix, key_hash, scopes, created_at)
+		VALUES($1, $2, $3, $4, $5, $6)
+		RETURNING id`,
+		key.UserId, key.Name, key.Prefix, key.KeyHash, string(scopes),
+		nullString(key.CreatedAt)).Scan(&key.Id)
+	if err != nil {
+		return fmt.Errorf("ApiKeysRepo - Store - Scan: %w", err)
+	}
+
+	return nil
+}
+
+func (kr *ApiKeysRepo) GetById(ctx context.Context, id int64) (entity.ApiKey, error) {
+	key, err := scanApiKey(kr.DB.QueryRowContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`, id))
+	if err != nil {
+		return key, fmt.Errorf("ApiKeysRepo - GetById - %w", err)
+	}
+	return key, nil
+}
+
+func (kr *ApiKeysRepo) GetByPrefix(ctx context.Context, prefix string) (entity.ApiKey, error) {
+	key, err := scanApiKey(kr.DB.QueryRowContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE prefix = $1`, prefix))
+	if err != nil {
+		return key, fmt.Errorf("ApiKeysRepo - GetByPrefix - %w", err)
+	}
+	return key, nil
+}
+
+func (kr *ApiKeysRepo) GetByUser(ctx context.Context, userId int64) ([]entity.ApiKey, error) {
+	keys := []entity.ApiKey{}
+	rows, err := kr.DB.QueryContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE user_id = $1 ORDER BY id`, userId)
+	if err != nil {
+		return keys, fmt.Errorf("ApiKeysRepo - GetByUser - QueryContext: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		key, err := scanApiKey(rows)
+		if err != nil {
+			return keys, fmt.Errorf("ApiKeysRepo - GetByUser - %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return keys, fmt.Errorf("ApiKeysRepo - GetByUser - Rows: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (kr *ApiKeysRepo) Rotate(ctx context.Context, key entity.ApiKey) error {
+	res, err := kr.DB.ExecContext(ctx,
+		`UPDATE api_keys SET prefix = $1, key_hash = $2
+		WHERE id = $3 AND revoked_at IS NULL`,
+		key.Prefix, key.KeyHash, key.Id)
+	if err != nil {
+		return fmt.Errorf("ApiKeysRepo - Rotate - ExecContext: %w", err)
+	}
+	if err = checkAffected(res); err != nil {
+		return fmt.Errorf("ApiKeysRepo - Rotate - %w", err)
+	}
+
+	return nil
+}
+
+func (kr *ApiKeysRepo) Revoke(ctx context.Context, id int64, revokedAt string) error {
+	res, err := kr.DB.ExecContext(ctx,
+		`UPDATE api_keys SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`,
+		revokedAt, id)
+	if err != nil {
+		return fmt.Errorf("ApiKeysRepo - Revoke - ExecContext: %w", err)
+	}
+	if err = checkAffected(res); err != nil {
+		return fmt.Errorf("ApiKeysRepo - Revoke - %w", err)
+	}
+
+	return nil
+}
+
+func (kr *ApiKeysRepo) Touch(ctx context.Context, id int64, usedAt string) error {
+	_, err := kr.DB.ExecContext(ctx,
+		`UPDATE api_keys SET last_used_at = $1 WHERE id = $2`, usedAt, id)
+	if err != nil {
+		return fmt.Errorf("ApiKeysRepo - Touch - ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func scanApiKey(row interface{ Scan(...interface{}) error }) (entity.ApiKey, error) {
+	key := entity.ApiKey{}
+	var name sql.NullString
+	var scopes []byte
+	var createdAt, lastUsedAt, revokedAt sql.NullTime
+
+	err := row.Scan(&key.Id, &key.UserId, &name, &key.Prefix, &key.KeyHash, &scopes,
+		&createdAt, &lastUsedAt, &revokedAt)
+	if err != nil {
+		return key, fmt.Errorf("scanApiKey - Scan: %w", err)
+	}
+	if err = json.Unmarshal(scopes, &key.Scopes); err != nil {
+		return key, fmt.Errorf("scanApiKey - Unmarshal: %w", err)
+	}
+	key.Name = name.String
+	key.CreatedAt = formatTime(createdAt)
+	key.LastUsedAt = formatTime(lastUsedAt)
+	key.RevokedAt = formatTime(revokedAt)
+
+	return key, nil
+}
+
+// checkAffected returns sql.ErrNoRows if statement changed nothing
+func checkAffected(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("RowsAffected: %w", err)
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
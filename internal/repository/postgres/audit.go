@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/pkg/postgres"
+)
+
+type AuditRepo struct {
+	*postgres.Postgres
+}
+
+func NewAuditRepo(pg *postgres.Postgres) *AuditRepo {
+	return &AuditRepo{pg}
+}
+
+func (ar *AuditRepo) Store(ctx context.Context, entry *entity.AuditEntry) error {
+	err := ar.DB.QueryRowContext(ctx,
+		`INSERT INTO audit_log(user_id, api_key_id, action, resource, allowed, created_at)
+		VALUES($1, $2, $3, $4, $5, $6)
+		RETURNING id`,
+		nullId(entry.UserId), nullId(entry.ApiKeyId), entry.Action, entry.Resource,
+		entry.Allowed, nullString(entry.CreatedAt)).Scan(&entry.Id)
+	if err != nil {
+		return fmt.Errorf("AuditRepo - Store - Scan: %w", err)
+	}
+
+	return nil
+}
+
+func (ar *AuditRepo) Fetch(ctx context.Context) ([]entity.AuditEntry, error) {
+	entries := []entity.AuditEntry{}
+	limit, offset := entity.DefaultLimit, 0
+	if val, ok := ctx.Value(entity.KeyLimit).(int); ok && val != 0 {
+		limit = val
+	}
+	if val, ok := ctx.Value(entity.KeyOffset).(int); ok && val != 0 {
+		offset = val
+	}
+
+	rows, err := ar.DB.QueryContext(ctx,
+		`SELECT id, user_id, api_key_id, action, resource, allowed, created_at
+		FROM audit_log ORDER BY id DESC LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		return entries, fmt.Errorf("AuditRepo - Fetch - QueryContext: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry entity.AuditEntry
+		var userId, apiKeyId sql.NullInt64
+		var createdAt sql.NullTime
+		err = rows.Scan(&entry.Id, &userId, &apiKeyId, &entry.Action, &entry.Resource,
+			&entry.Allowed, &createdAt)
+		if err != nil {
+			return entries, fmt.Errorf("AuditRepo - Fetch - Scan: %w", err)
+		}
+		entry.UserId = userId.Int64
+		entry.ApiKeyId = apiKeyId.Int64
+		entry.CreatedAt = formatTime(createdAt)
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return entries, fmt.Errorf("AuditRepo - Fetch - Rows: %w", err)
+	}
+
+	return entries, nil
+}
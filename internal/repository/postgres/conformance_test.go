@@ -0,0 +1,64 @@
+package postgres_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mrsubudei/adv-store-service/internal/repository"
+	"github.com/mrsubudei/adv-store-service/internal/repository/postgres"
+	"github.com/mrsubudei/adv-store-service/internal/repository/repotest"
+	pg "github.com/mrsubudei/adv-store-service/pkg/postgres"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repository.Advert {
+		return postgres.NewAdvertsRepo(mustMigratedDB(t))
+	})
+}
+
+func TestCategoriesConformance(t *testing.T) {
+	repotest.RunCategories(t, func(t *testing.T) (repository.Advert, repository.Category) {
+		db := mustMigratedDB(t)
+		return postgres.NewAdvertsRepo(db), postgres.NewCategoriesRepo(db)
+	})
+}
+
+func TestUsersConformance(t *testing.T) {
+	repotest.RunUsers(t, func(t *testing.T) (repository.Advert, repository.User) {
+		db := mustMigratedDB(t)
+		return postgres.NewAdvertsRepo(db), postgres.NewUsersRepo(db)
+	})
+}
+
+func TestApiKeysConformance(t *testing.T) {
+	repotest.RunApiKeys(t, func(t *testing.T) (repository.User, repository.ApiKey) {
+		db := mustMigratedDB(t)
+		return postgres.NewUsersRepo(db), postgres.NewApiKeysRepo(db)
+	})
+}
+
+func TestRolesConformance(t *testing.T) {
+	repotest.RunRoles(t, func(t *testing.T) (repository.User, repository.Role,
+		repository.Audit) {
+		db := mustMigratedDB(t)
+		return postgres.NewUsersRepo(db), postgres.NewRolesRepo(db), postgres.NewAuditRepo(db)
+	})
+}
+
+// mustMigratedDB returns new database closed at the end of test
+func mustMigratedDB(t *testing.T) *pg.Postgres {
+	db := postgres.MustOpenDB(t)
+	t.Cleanup(func() {
+		postgres.MustCloseDB(t, db)
+	})
+	if err := postgres.Migrate(context.Background(), db); err != nil {
+		t.Fatal("Unable to migrate db:", err)
+	}
+	return db
+}
+
+func TestIdempotencyConformance(t *testing.T) {
+	repotest.RunIdempotency(t, func(t *testing.T) repository.Idempotency {
+		return postgres.NewIdempotencyRepo(mustMigratedDB(t))
+	})
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/pkg/postgres"
+)
+
+type IdempotencyRepo struct {
+	*postgres.Postgres
+}
+
+func NewIdempotencyRepo(pg *postgres.Postgres) *IdempotencyRepo {
+	return &IdempotencyRepo{pg}
+}
+
+// Reserve takes over expired key, its old response is dropped
+func (ir *IdempotencyRepo) Reserve(ctx context.Context, resp *entity.IdempotentResponse) error {
+	res, err := ir.DB.ExecContext(ctx,
+		`INSERT INTO idempotency_keys(idempotency_key, request_hash, status, body,
+		created_at, expires_at)
+		VALUES($1, $2, 0, NULL, $3, $4)
+		ON CONFLICT(idempotency_key) DO UPDATE SET request_hash = excluded.request_hash,
+		status = 0, body = NULL, created_at = excluded.created_at,
+		expires_at = excluded.expires_at
+		WHERE idempotency_keys.expires_at <= excluded.created_at`,
+		resp.Key, resp.RequestHash, resp.CreatedAt, resp.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("IdempotencyRepo - Reserve - ExecContext: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("IdempotencyRepo - Reserve - RowsAffected: %w", err)
+	}
+	if affected == 0 {
+		return entity.ErrKeyAlreadyExist
+	}
+	resp.Status = 0
+	resp.Body = nil
+
+	return nil
+}
+
+func (ir *IdempotencyRepo) GetByKey(ctx context.Context,
+	key string) (entity.IdempotentResponse, error) {
+	resp := entity.IdempotentResponse{}
+	var createdAt, expiresAt sql.NullTime
+	err := ir.DB.QueryRowContext(ctx,
+		`SELECT idempotency_key, request_hash, status, body, created_at, expires_at
+		FROM idempotency_keys WHERE idempotency_key = $1`, key).Scan(&resp.Key,
+		&resp.RequestHash, &resp.Status, &resp.Body, &createdAt, &expiresAt)
+	if err != nil {
+		return resp, fmt.Errorf("IdempotencyRepo - GetByKey - Scan: %w", err)
+	}
+	resp.CreatedAt = formatTime(createdAt)
+	resp.ExpiresAt = formatTime(expiresAt)
+
+	return resp, nil
+}
+
+// Complete stores status and body of response to reserved key
+func (ir *IdempotencyRepo) Complete(ctx context.Context, resp entity.IdempotentResponse) error {
+	res, err := ir.DB.ExecContext(ctx,
+		`UPDATE idempotency_keys SET status = $1, body = $2 WHERE idempotency_key = $3`,
+		resp.Status, resp.Body, resp.Key)
+	if err != nil {
+		return fmt.Errorf("IdempotencyRepo - Complete - ExecContext: %w", err)
+	}
+	if err = checkAffected(res); err != nil {
+		return fmt.Errorf("IdempotencyRepo - Complete - %w", err)
+	}
+	return nil
+}
+
+func (ir *IdempotencyRepo) Delete(ctx context.Context, key string) error {
+	_, err := ir.DB.ExecContext(ctx,
+		`DELETE FROM idempotency_keys WHERE idempotency_key = $1`, key)
+	if err != nil {
+		return fmt.Errorf("IdempotencyRepo - Delete - ExecContext: %w", err)
+	}
+	return nil
+}
+
+func (ir *IdempotencyRepo) Purge(ctx context.Context, now time.Time) (int64, error) {
+	res, err := ir.DB.ExecContext(ctx,
+		`DELETE FROM idempotency_keys WHERE expires_at <= $1`, now.Local().Format(dateFormat))
+	if err != nil {
+		return 0, fmt.Errorf("IdempotencyRepo - Purge - ExecContext: %w", err)
+	}
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("IdempotencyRepo - Purge - RowsAffected: %w", err)
+	}
+	return purged, nil
+}
@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+
+	"github.com/mrsubudei/adv-store-service/pkg/migrate"
+	"github.com/mrsubudei/adv-store-service/pkg/postgres"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// NewMigrator returns migrator of adverts database schema
+func NewMigrator(pg *postgres.Postgres) (*migrate.Migrator, error) {
+	fsys, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("NewMigrator - Sub: %w", err)
+	}
+
+	m, err := migrate.New(pg.DB, fsys)
+	if err != nil {
+		return nil, fmt.Errorf("NewMigrator - %w", err)
+	}
+	return m, nil
+}
+
+// migrationsLock is key of advisory lock held while migrations are applied,
+// replicas sharing database which migrate it at once apply them one by one
+const migrationsLock = 20230111
+
+// Migrate applies pending migrations under advisory lock,
+// it fails if database was migrated by newer version of application
+func Migrate(ctx context.Context, pg *postgres.Postgres) (err error) {
+	m, err := NewMigrator(pg)
+	if err != nil {
+		return fmt.Errorf("Migrate - %w", err)
+	}
+
+	// advisory lock belongs to session, so it is released on the connection it is taken on
+	conn, err := pg.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("Migrate - Conn: %w", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationsLock)
+	if err != nil {
+		return fmt.Errorf("Migrate - pg_advisory_lock: %w", err)
+	}
+	defer func() {
+		_, unlockErr := conn.ExecContext(context.Background(),
+			`SELECT pg_advisory_unlock($1)`, migrationsLock)
+		if unlockErr != nil && err == nil {
+			err = fmt.Errorf("Migrate - pg_advisory_unlock: %w", unlockErr)
+		}
+	}()
+
+	err = m.Up(ctx)
+	if err != nil {
+		return fmt.Errorf("Migrate - %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package postgres_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/mrsubudei/adv-store-service/internal/repository/postgres"
+)
+
+func TestMigrateConcurrently(t *testing.T) {
+	db := postgres.MustOpenDB(t)
+	defer postgres.MustCloseDB(t, db)
+	ctx := context.Background()
+
+	// every replica migrates shared database on start
+	replicas := 5
+	errs := make(chan error, replicas)
+	var wg sync.WaitGroup
+	for i := 0; i < replicas; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- postgres.Migrate(ctx, db)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal("Unable to migrate:", err)
+		}
+	}
+
+	m, err := postgres.NewMigrator(db)
+	if err != nil {
+		t.Fatal("Unable to create migrator:", err)
+	}
+	if err = m.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
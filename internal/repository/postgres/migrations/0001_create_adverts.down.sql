@@ -0,0 +1,2 @@
+DROP TABLE photo_urls;
+DROP TABLE adverts;
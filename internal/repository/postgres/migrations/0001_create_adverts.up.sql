@@ -0,0 +1,23 @@
+CREATE TABLE adverts (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT UNIQUE NOT NULL,
+	description TEXT,
+	price BIGINT,
+	photo_url TEXT,
+	created_at TIMESTAMP(0),
+	version BIGINT NOT NULL DEFAULT 1,
+	deleted_at TIMESTAMP(0),
+	search TSVECTOR GENERATED ALWAYS AS (
+		setweight(to_tsvector('simple', name), 'A') ||
+		setweight(to_tsvector('simple', coalesce(description, '')), 'B')
+		) STORED
+	);
+
+CREATE INDEX adverts_search_idx ON adverts USING GIN (search);
+
+CREATE TABLE photo_urls (
+	id BIGSERIAL PRIMARY KEY,
+	advert_id BIGINT NOT NULL REFERENCES adverts(id),
+	url TEXT,
+	UNIQUE (advert_id, url)
+	);
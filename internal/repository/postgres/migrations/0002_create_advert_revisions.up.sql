@@ -0,0 +1,12 @@
+CREATE TABLE advert_revisions (
+	advert_id BIGINT,
+	revision BIGINT,
+	action TEXT NOT NULL,
+	name TEXT,
+	description TEXT,
+	price BIGINT,
+	photo_url TEXT,
+	photo_urls JSONB,
+	created_at TIMESTAMP(0) NOT NULL DEFAULT LOCALTIMESTAMP(0),
+	PRIMARY KEY (advert_id, revision)
+	);
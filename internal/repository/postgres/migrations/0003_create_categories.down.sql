@@ -0,0 +1,5 @@
+ALTER TABLE advert_revisions DROP COLUMN category_id;
+
+ALTER TABLE adverts DROP COLUMN category_id;
+
+DROP TABLE categories;
@@ -0,0 +1,13 @@
+CREATE TABLE categories (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	parent_id BIGINT REFERENCES categories(id)
+	);
+
+CREATE INDEX categories_parent_id_idx ON categories(parent_id);
+
+ALTER TABLE adverts ADD COLUMN category_id BIGINT REFERENCES categories(id);
+
+CREATE INDEX adverts_category_id_idx ON adverts(category_id);
+
+ALTER TABLE advert_revisions ADD COLUMN category_id BIGINT;
@@ -0,0 +1,5 @@
+ALTER TABLE categories ADD COLUMN schema JSONB;
+
+ALTER TABLE adverts ADD COLUMN attributes JSONB;
+
+ALTER TABLE advert_revisions ADD COLUMN attributes JSONB;
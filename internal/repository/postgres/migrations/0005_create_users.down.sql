@@ -0,0 +1,5 @@
+DROP INDEX sessions_user_id_idx;
+
+DROP TABLE sessions;
+
+DROP TABLE users;
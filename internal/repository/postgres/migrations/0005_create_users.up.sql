@@ -0,0 +1,15 @@
+CREATE TABLE users (
+	id BIGSERIAL PRIMARY KEY,
+	email TEXT UNIQUE NOT NULL,
+	password_hash TEXT NOT NULL,
+	created_at TIMESTAMP(0)
+	);
+
+CREATE TABLE sessions (
+	token_hash TEXT PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users(id),
+	created_at TIMESTAMP(0),
+	expires_at TIMESTAMP(0) NOT NULL
+	);
+
+CREATE INDEX sessions_user_id_idx ON sessions(user_id);
@@ -0,0 +1,5 @@
+ALTER TABLE adverts ADD COLUMN owner_id BIGINT REFERENCES users(id);
+
+CREATE INDEX adverts_owner_id_idx ON adverts(owner_id);
+
+ALTER TABLE users ADD COLUMN admin BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,13 @@
+CREATE TABLE api_keys (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users(id),
+	name TEXT,
+	prefix TEXT UNIQUE NOT NULL,
+	key_hash TEXT NOT NULL,
+	scopes JSONB NOT NULL,
+	created_at TIMESTAMP(0),
+	last_used_at TIMESTAMP(0),
+	revoked_at TIMESTAMP(0)
+	);
+
+CREATE INDEX api_keys_user_id_idx ON api_keys(user_id);
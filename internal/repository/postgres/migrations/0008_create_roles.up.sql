@@ -0,0 +1,37 @@
+CREATE TABLE roles (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT UNIQUE NOT NULL
+	);
+
+CREATE TABLE role_permissions (
+	role_id BIGINT NOT NULL REFERENCES roles(id),
+	permission TEXT NOT NULL,
+	PRIMARY KEY (role_id, permission)
+	);
+
+CREATE TABLE user_roles (
+	user_id BIGINT NOT NULL REFERENCES users(id),
+	role_id BIGINT NOT NULL REFERENCES roles(id),
+	PRIMARY KEY (user_id, role_id)
+	);
+
+INSERT INTO roles(name) VALUES ('admin'), ('moderator');
+
+INSERT INTO role_permissions(role_id, permission)
+SELECT roles.id, permissions.name FROM roles, (
+	SELECT 'adverts:update_any' AS name UNION ALL
+	SELECT 'adverts:delete_any' UNION ALL
+	SELECT 'adverts:hide' UNION ALL
+	SELECT 'users:manage' UNION ALL
+	SELECT 'api_keys:manage' UNION ALL
+	SELECT 'audit:read'
+	) AS permissions
+WHERE roles.name = 'admin';
+
+INSERT INTO role_permissions(role_id, permission)
+SELECT id, 'adverts:hide' FROM roles WHERE name = 'moderator';
+
+INSERT INTO user_roles(user_id, role_id)
+SELECT users.id, roles.id FROM users, roles WHERE users.admin AND roles.name = 'admin';
+
+ALTER TABLE users DROP COLUMN admin;
@@ -0,0 +1,11 @@
+CREATE TABLE audit_log (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT,
+	api_key_id BIGINT,
+	action TEXT NOT NULL,
+	resource TEXT NOT NULL,
+	allowed BOOLEAN NOT NULL,
+	created_at TIMESTAMP(0)
+	);
+
+CREATE INDEX audit_log_user_id_idx ON audit_log(user_id);
@@ -0,0 +1,3 @@
+ALTER TABLE advert_revisions ADD COLUMN user_id BIGINT;
+
+ALTER TABLE advert_revisions ADD COLUMN api_key_id BIGINT;
@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mrsubudei/adv-store-service/pkg/postgres"
+)
+
+// server is postgres started from local binaries for the tests of package
+var server struct {
+	once    sync.Once
+	bin     string
+	dir     string
+	err     error
+	created int64
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if server.dir != "" {
+		exec.Command(filepath.Join(server.bin, "pg_ctl"), "stop", "-D",
+			filepath.Join(server.dir, "data"), "-m", "immediate").Run()
+		os.RemoveAll(server.dir)
+	}
+	os.Exit(code)
+}
+
+// MustOpenDB returns connection to new empty database. Postgres is looked up
+// in POSTGRES_BIN directory, PATH and /usr/lib/postgresql, test is skipped
+// if it is not found unless POSTGRES_REQUIRED is set.
+func MustOpenDB(tb testing.TB) *postgres.Postgres {
+	server.once.Do(startServer)
+	if server.bin == "" && os.Getenv("POSTGRES_REQUIRED") != "" {
+		tb.Fatal("postgres binaries are not found, set POSTGRES_BIN to run the test")
+	}
+	if server.bin == "" {
+		tb.Skip("postgres binaries are not found, set POSTGRES_BIN to run the test")
+	}
+	if server.err != nil {
+		tb.Fatal("Unable to start postgres:", server.err)
+	}
+
+	admin, err := postgres.New(dsn("postgres"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer admin.Close()
+
+	name := fmt.Sprintf("test_%d", atomic.AddInt64(&server.created, 1))
+	if _, err = admin.DB.Exec("CREATE DATABASE " + name); err != nil {
+		tb.Fatal("Unable to create database:", err)
+	}
+
+	db, err := postgres.New(dsn(name))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return db
+}
+
+func MustCloseDB(tb testing.TB, db *postgres.Postgres) {
+	if err := db.DB.Close(); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func dsn(database string) string {
+	return fmt.Sprintf("host=%v port=5432 user=postgres dbname=%v sslmode=disable",
+		server.dir, database)
+}
+
+func startServer() {
+	server.bin = findBinaries()
+	if server.bin == "" {
+		return
+	}
+
+	server.dir, server.err = os.MkdirTemp("", "postgres")
+	if server.err != nil {
+		return
+	}
+	data := filepath.Join(server.dir, "data")
+
+	out, err := exec.Command(filepath.Join(server.bin, "initdb"), "-D", data,
+		"-U", "postgres", "-A", "trust", "--no-sync").CombinedOutput()
+	if err != nil {
+		server.err = fmt.Errorf("initdb: %v: %s", err, out)
+		return
+	}
+
+	// server listens only on unix socket in its directory
+	out, err = exec.Command(filepath.Join(server.bin, "pg_ctl"), "start", "-w",
+		"-D", data, "-l", filepath.Join(server.dir, "log"),
+		"-o", fmt.Sprintf("-p 5432 -k %v -c listen_addresses='' -c fsync=off", server.dir),
+	).CombinedOutput()
+	if err != nil {
+		server.err = fmt.Errorf("pg_ctl: %v: %s", err, out)
+	}
+}
+
+func findBinaries() string {
+	if dir := os.Getenv("POSTGRES_BIN"); dir != "" {
+		return dir
+	}
+	if path, err := exec.LookPath("initdb"); err == nil {
+		return filepath.Dir(path)
+	}
+	if paths, _ := filepath.Glob("/usr/lib/postgresql/*/bin/initdb"); len(paths) != 0 {
+		return filepath.Dir(paths[len(paths)-1])
+	}
+	return ""
+}
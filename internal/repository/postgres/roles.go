@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/pkg/postgres"
+)
+
+type RolesRepo struct {
+	*postgres.Postgres
+}
+
+func NewRolesRepo(pg *postgres.Postgres) *RolesRepo {
+	return &RolesRepo{pg}
+}
+
+func (rr *RolesRepo) Fetch(ctx context.Context) ([]entity.Role, error) {
+	roles, err := rr.fetch(ctx, `SELECT roles.id, roles.name, role_permissions.permission
+		FROM roles LEFT JOIN role_permissions ON role_permissions.role_id = roles.id
+		ORDER BY roles.id, role_permissions.permission`)
+	if err != nil {
+		return roles, fmt.Errorf("RolesRepo - Fetch - %w", err)
+	}
+	return roles, nil
+}
+
+func (rr *RolesRepo) GetByUser(ctx context.Context, userId int64) ([]entity.Role, error) {
+	roles, err := rr.fetch(ctx, `SELECT roles.id, roles.name, role_permissions.permission
+		FROM user_roles JOIN roles ON roles.id = user_roles.role_id
+		LEFT JOIN role_permissions ON role_permissions.role_id = roles.id
+		WHERE user_roles.user_id = $1
+		ORDER BY roles.id, role_permissions.permission`, userId)
+	if err != nil {
+		return roles, fmt.Errorf("RolesRepo - GetByUser - %w", err)
+	}
+	return roles, nil
+}
+
+// fetch collects rows of role and its permission into roles
+func (rr *RolesRepo) fetch(ctx context.Context, query string,
+	args ...interface{}) ([]entity.Role, error) {
+	roles := []entity.Role{}
+	rows, err := rr.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return roles, fmt.Errorf("fetch - QueryContext: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var role entity.Role
+		var permission sql.NullString
+		if err = rows.Scan(&role.Id, &role.Name, &permission); err != nil {
+			return roles, fmt.Errorf("fetch - Scan: %w", err)
+		}
+		if len(roles) == 0 || roles[len(roles)-1].Id != role.Id {
+			role.Permissions = []string{}
+			roles = append(roles, role)
+		}
+		if permission.Valid {
+			last := &roles[len(roles)-1]
+			last.Permissions = append(last.Permissions, permission.String)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return roles, fmt.Errorf("fetch - Rows: %w", err)
+	}
+
+	return roles, nil
+}
+
+func (rr *RolesRepo) Assign(ctx context.Context, userId int64, role string) error {
+	roleId, err := rr.roleId(ctx, role)
+	if err != nil {
+		return fmt.Errorf("RolesRepo - Assign - %w", err)
+	}
+
+	_, err = rr.DB.ExecContext(ctx,
+		`INSERT INTO user_roles(user_id, role_id) VALUES($1, $2)
+		ON CONFLICT DO NOTHING`, userId, roleId)
+	if err != nil {
+		return fmt.Errorf("RolesRepo - Assign - ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (rr *RolesRepo) Unassign(ctx context.Context, userId int64, role string) error {
+	roleId, err := rr.roleId(ctx, role)
+	if err != nil {
+		return fmt.Errorf("RolesRepo - Unassign - %w", err)
+	}
+
+	_, err = rr.DB.ExecContext(ctx,
+		`DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2`, userId, roleId)
+	if err != nil {
+		return fmt.Errorf("RolesRepo - Unassign - ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (rr *RolesRepo) roleId(ctx context.Context, role string) (int64, error) {
+	var id int64
+	err := rr.DB.QueryRowContext(ctx, `SELECT id FROM roles WHERE name = $1`, role).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("roleId - Scan: %w", err)
+	}
+	return id, nil
+}
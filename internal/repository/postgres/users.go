@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/pkg/postgres"
+)
+
+type UsersRepo struct {
+	*postgres.Postgres
+}
+
+func NewUsersRepo(pg *postgres.Postgres) *UsersRepo {
+	return &UsersRepo{pg}
+}
+
+func (ur *UsersRepo) Store(ctx context.Context, user *entity.User) error {
+	err := ur.DB.QueryRowContext(ctx,
+		`INSERT INTO users(email, password_hash, created_at)
+		VALUES($1, $2, $3)
+		RETURNING id`,
+		user.Email, user.PasswordHash, nullString(user.CreatedAt)).Scan(&user.Id)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("UsersRepo - Store - Scan: %v: %w", err, entity.ErrEmailAlreadyExist)
+	} else if err != nil {
+		return fmt.Errorf("UsersRepo - Store - Scan: %w", err)
+	}
+
+	return nil
+}
+
+func (ur *UsersRepo) GetById(ctx context.Context, id int64) (entity.User, error) {
+	user, err := ur.getUser(ctx, `WHERE id = $1`, id)
+	if err != nil {
+		return user, fmt.Errorf("UsersRepo - GetById - %w", err)
+	}
+	return user, nil
+}
+
+func (ur *UsersRepo) GetByEmail(ctx context.Context, email string) (entity.User, error) {
+	user, err := ur.getUser(ctx, `WHERE email = $1`, email)
+	if err != nil {
+		return user, fmt.Errorf("UsersRepo - GetByEmail - %w", err)
+	}
+	return user, nil
+}
+
+func (ur *UsersRepo) getUser(ctx context.Context, where string, arg interface{}) (entity.User, error) {
+	user := entity.User{}
+	var createdAt sql.NullTime
+	err := ur.DB.QueryRowContext(ctx,
+		`SELECT id, email, password_hash, created_at FROM users `+where,
+		arg).Scan(&user.Id, &user.Email, &user.PasswordHash, &createdAt)
+	if err != nil {
+		return user, fmt.Errorf("getUser - Scan: %w", err)
+	}
+	user.CreatedAt = formatTime(createdAt)
+
+	return user, nil
+}
+
+func (ur *UsersRepo) StoreSession(ctx context.Context, session entity.Session) error {
+	_, err := ur.DB.ExecContext(ctx,
+		`INSERT INTO sessions(token_hash, user_id, created_at, expires_at)
+		VALUES($1, $2, $3, $4)`,
+		session.TokenHash, session.UserId, nullString(session.CreatedAt), session.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("UsersRepo - StoreSession - ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (ur *UsersRepo) GetSession(ctx context.Context, tokenHash string) (entity.Session, error) {
+	session := entity.Session{}
+	var createdAt, expiresAt sql.NullTime
+	err := ur.DB.QueryRowContext(ctx,
+		`SELECT token_hash, user_id, created_at, expires_at FROM sessions
+		WHERE token_hash = $1`,
+		tokenHash).Scan(&session.TokenHash, &session.UserId, &createdAt, &expiresAt)
+	if err != nil {
+		return session, fmt.Errorf("UsersRepo - GetSession - Scan: %w", err)
+	}
+	session.CreatedAt = formatTime(createdAt)
+	session.ExpiresAt = formatTime(expiresAt)
+
+	return session, nil
+}
+
+func (ur *UsersRepo) DeleteSession(ctx context.Context, tokenHash string) error {
+	_, err := ur.DB.ExecContext(ctx,
+		`DELETE FROM sessions WHERE token_hash = $1`, tokenHash)
+	if err != nil {
+		return fmt.Errorf("UsersRepo - DeleteSession - ExecContext: %w", err)
+	}
+
+	return nil
+}
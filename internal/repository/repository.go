@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/mrsubudei/adv-store-service/internal/entity"
 )
@@ -9,7 +10,81 @@ import (
 type Advert interface {
 	Store(ctx context.Context, adv *entity.Advert) error
 	GetById(ctx context.Context, id int64) (entity.Advert, error)
-	Fetch(ctx context.Context) ([]entity.Advert, error)
+	// GetByIds returns found adverts in order of ids, missing ones are skipped
+	GetByIds(ctx context.Context, ids []int64) ([]entity.Advert, error)
+	Fetch(ctx context.Context) (entity.AdvertsPage, error)
 	Update(ctx context.Context, adv entity.Advert) error
+	Delete(ctx context.Context, id, version int64) error
+	FetchDeleted(ctx context.Context) (entity.AdvertsPage, error)
+	// GetDeletedById returns advert which is in trash
+	GetDeletedById(ctx context.Context, id int64) (entity.Advert, error)
+	// Batch applies operations which have not failed yet in one transaction,
+	// failed ones get their errors, if atomic nothing is applied once any fails
+	Batch(ctx context.Context, ops []entity.BatchOperation, atomic bool) error
+	Restore(ctx context.Context, id int64) error
+	// Hide hides advert from lists or shows it again
+	Hide(ctx context.Context, id int64, hidden bool) error
+	Purge(ctx context.Context, before time.Time) (int64, error)
+	GetRevisions(ctx context.Context, id int64) ([]entity.Revision, error)
+	GetRevision(ctx context.Context, id, rev int64) (entity.Revision, error)
+}
+
+// Category keeps adverts' categories, Fetch returns all categories
+// with their adverts counts ordered by id
+type Category interface {
+	Store(ctx context.Context, cat *entity.Category) error
+	GetById(ctx context.Context, id int64) (entity.Category, error)
+	Fetch(ctx context.Context) ([]entity.Category, error)
+	Update(ctx context.Context, cat entity.Category) error
 	Delete(ctx context.Context, id int64) error
 }
+
+// User keeps users and their sessions, sessions are found by hash of token
+type User interface {
+	Store(ctx context.Context, user *entity.User) error
+	GetById(ctx context.Context, id int64) (entity.User, error)
+	GetByEmail(ctx context.Context, email string) (entity.User, error)
+	StoreSession(ctx context.Context, session entity.Session) error
+	GetSession(ctx context.Context, tokenHash string) (entity.Session, error)
+	DeleteSession(ctx context.Context, tokenHash string) error
+}
+
+// ApiKey keeps API keys of users, revoked keys are kept with time of revocation
+type ApiKey interface {
+	Store(ctx context.Context, key *entity.ApiKey) error
+	GetById(ctx context.Context, id int64) (entity.ApiKey, error)
+	GetByPrefix(ctx context.Context, prefix string) (entity.ApiKey, error)
+	GetByUser(ctx context.Context, userId int64) ([]entity.ApiKey, error)
+	// Rotate replaces prefix and hash of key which is not revoked
+	Rotate(ctx context.Context, key entity.ApiKey) error
+	Revoke(ctx context.Context, id int64, revokedAt string) error
+	Touch(ctx context.Context, id int64, usedAt string) error
+}
+
+// Role keeps roles with their permissions and roles of users,
+// roles are given by name and sql.ErrNoRows is returned for unknown role.
+// Assigning role twice and unassigning role user does not have are no-ops.
+type Role interface {
+	Fetch(ctx context.Context) ([]entity.Role, error)
+	GetByUser(ctx context.Context, userId int64) ([]entity.Role, error)
+	Assign(ctx context.Context, userId int64, role string) error
+	Unassign(ctx context.Context, userId int64, role string) error
+}
+
+// Audit keeps trail of authorization decisions, Fetch returns
+// page of entries newest first by limit and offset of context
+type Audit interface {
+	Store(ctx context.Context, entry *entity.AuditEntry) error
+	Fetch(ctx context.Context) ([]entity.AuditEntry, error)
+}
+
+// Idempotency keeps responses by idempotency keys. Reserve stores response
+// without status and returns entity.ErrKeyAlreadyExist if key is taken by
+// response which has not expired, Purge removes responses expired before now.
+type Idempotency interface {
+	Reserve(ctx context.Context, resp *entity.IdempotentResponse) error
+	GetByKey(ctx context.Context, key string) (entity.IdempotentResponse, error)
+	Complete(ctx context.Context, resp entity.IdempotentResponse) error
+	Delete(ctx context.Context, key string) error
+	Purge(ctx context.Context, now time.Time) (int64, error)
+}
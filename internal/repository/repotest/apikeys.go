@@ -0,0 +1,151 @@
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/internal/repository"
+)
+
+// ApiKeyFactory returns new empty repositories of users and their
+// API keys sharing the same storage
+type ApiKeyFactory func(t *testing.T) (repository.User, repository.ApiKey)
+
+// RunApiKeys runs tests of API keys against repositories made by newRepos
+func RunApiKeys(t *testing.T, newRepos ApiKeyFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, users repository.User, keys repository.ApiKey)
+	}{
+		{"Store", testStoreApiKey},
+		{"Rotate", testRotateApiKey},
+		{"Revoke", testRevokeApiKey},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			users, keys := newRepos(t)
+			tc.test(t, users, keys)
+		})
+	}
+}
+
+// storeApiKeys stores user and two keys of the user
+func storeApiKeys(t *testing.T, users repository.User, keys repository.ApiKey) []entity.ApiKey {
+	t.Helper()
+	ctx := context.Background()
+
+	user := entity.User{Email: "user@example.com", PasswordHash: "hash"}
+	if err := users.Store(ctx, &user); err != nil {
+		t.Fatal("Unable to store user:", err)
+	}
+
+	stored := []entity.ApiKey{
+		{UserId: user.Id, Name: "reader", Prefix: "ak_1", KeyHash: "hash1",
+			Scopes: []string{entity.ScopeAdvertsRead}, CreatedAt: "2022-10-01 12:00:00"},
+		{UserId: user.Id, Name: "writer", Prefix: "ak_2", KeyHash: "hash2",
+			Scopes:    []string{entity.ScopeAdvertsRead, entity.ScopeAdvertsWrite},
+			CreatedAt: "2022-10-01 13:00:00"},
+	}
+	for i := range stored {
+		if err := keys.Store(ctx, &stored[i]); err != nil {
+			t.Fatal("Unable to store key:", err)
+		}
+		if stored[i].Id == 0 {
+			t.Fatal("Stored key got no id")
+		}
+	}
+	return stored
+}
+
+func testStoreApiKey(t *testing.T, users repository.User, keys repository.ApiKey) {
+	ctx := context.Background()
+	stored := storeApiKeys(t, users, keys)
+
+	byId, err := keys.GetById(ctx, stored[0].Id)
+	if err != nil {
+		t.Fatal("Unable to get key:", err)
+	}
+	byPrefix, err := keys.GetByPrefix(ctx, stored[1].Prefix)
+	if err != nil {
+		t.Fatal("Unable to get key by prefix:", err)
+	}
+	if !reflect.DeepEqual(byId, stored[0]) || !reflect.DeepEqual(byPrefix, stored[1]) {
+		t.Fatalf("want %+v and %+v, got %+v and %+v", stored[0], stored[1], byId, byPrefix)
+	}
+
+	byUser, err := keys.GetByUser(ctx, stored[0].UserId)
+	if err != nil {
+		t.Fatal("Unable to get keys of user:", err)
+	}
+	if !reflect.DeepEqual(byUser, stored) {
+		t.Fatalf("want %+v, got %+v", stored, byUser)
+	}
+
+	if _, err = keys.GetByPrefix(ctx, "ak_3"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("want %v, got %v", sql.ErrNoRows, err)
+	}
+
+	if err = keys.Touch(ctx, stored[0].Id, "2022-10-02 12:00:00"); err != nil {
+		t.Fatal("Unable to touch key:", err)
+	}
+	if byId, _ = keys.GetById(ctx, stored[0].Id); byId.LastUsedAt != "2022-10-02 12:00:00" {
+		t.Fatalf("want last used at %v, got %v", "2022-10-02 12:00:00", byId.LastUsedAt)
+	}
+}
+
+func testRotateApiKey(t *testing.T, users repository.User, keys repository.ApiKey) {
+	ctx := context.Background()
+	stored := storeApiKeys(t, users, keys)
+
+	rotated := stored[0]
+	rotated.Prefix = "ak_3"
+	rotated.KeyHash = "hash3"
+	if err := keys.Rotate(ctx, rotated); err != nil {
+		t.Fatal("Unable to rotate key:", err)
+	}
+	if _, err := keys.GetByPrefix(ctx, stored[0].Prefix); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("want %v, got %v", sql.ErrNoRows, err)
+	}
+	got, err := keys.GetByPrefix(ctx, rotated.Prefix)
+	if err != nil {
+		t.Fatal("Unable to get key by prefix:", err)
+	}
+	if !reflect.DeepEqual(got, rotated) {
+		t.Fatalf("want %+v, got %+v", rotated, got)
+	}
+
+	rotated.Id = stored[1].Id + 1
+	if err = keys.Rotate(ctx, rotated); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("want %v, got %v", sql.ErrNoRows, err)
+	}
+}
+
+func testRevokeApiKey(t *testing.T, users repository.User, keys repository.ApiKey) {
+	ctx := context.Background()
+	stored := storeApiKeys(t, users, keys)
+
+	if err := keys.Revoke(ctx, stored[0].Id, "2022-10-02 12:00:00"); err != nil {
+		t.Fatal("Unable to revoke key:", err)
+	}
+	got, err := keys.GetById(ctx, stored[0].Id)
+	if err != nil {
+		t.Fatal("Unable to get key:", err)
+	}
+	if got.RevokedAt != "2022-10-02 12:00:00" {
+		t.Fatalf("want revoked at %v, got %v", "2022-10-02 12:00:00", got.RevokedAt)
+	}
+
+	// revoked key can be neither revoked again nor rotated
+	if err = keys.Revoke(ctx, stored[0].Id, "2022-10-03 12:00:00"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("want %v, got %v", sql.ErrNoRows, err)
+	}
+	got.Prefix = "ak_3"
+	if err = keys.Rotate(ctx, got); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("want %v, got %v", sql.ErrNoRows, err)
+	}
+}
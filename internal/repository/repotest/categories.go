@@ -0,0 +1,243 @@
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/internal/repository"
+)
+
+// CategoryFactory returns new empty repositories of adverts and their
+// categories sharing the same storage
+type CategoryFactory func(t *testing.T) (repository.Advert, repository.Category)
+
+// RunCategories runs tests of categories against repositories made by newRepos
+func RunCategories(t *testing.T, newRepos CategoryFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, adverts repository.Advert, categories repository.Category)
+	}{
+		{"Tree", testCategoriesTree},
+		{"FetchByCategory", testFetchByCategory},
+		{"Parent", testCategoryParent},
+		{"Delete", testDeleteCategory},
+		{"Schema", testCategorySchema},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			adverts, categories := newRepos(t)
+			tc.test(t, adverts, categories)
+		})
+	}
+}
+
+// categoryTree stores categories root > child > grandchild and other,
+// and adverts in them, one of grandchild's adverts is in trash
+func categoryTree(t *testing.T, adverts repository.Advert,
+	categories repository.Category) []entity.Category {
+	t.Helper()
+	ctx := context.Background()
+
+	tree := []entity.Category{{Name: "root"}, {Name: "child"}, {Name: "grandchild"}, {Name: "other"}}
+	for i := range tree {
+		if i == 1 || i == 2 {
+			tree[i].ParentId = tree[i-1].Id
+		}
+		if err := categories.Store(ctx, &tree[i]); err != nil {
+			t.Fatal("Unable to store category:", err)
+		}
+	}
+
+	for i, categoryIdx := range []int{0, 1, 1, 2, 2, -1} {
+		adv := newAdvert(i, 100)
+		if categoryIdx >= 0 {
+			adv.CategoryId = tree[categoryIdx].Id
+		}
+		mustStore(t, adverts, &adv)
+		if i == 4 {
+			if err := adverts.Delete(ctx, adv.Id, 0); err != nil {
+				t.Fatal("Unable to delete:", err)
+			}
+		}
+	}
+
+	return tree
+}
+
+func testCategoriesTree(t *testing.T, adverts repository.Advert, categories repository.Category) {
+	tree := categoryTree(t, adverts, categories)
+
+	got, err := categories.Fetch(context.Background())
+	if err != nil {
+		t.Fatal("Unable to fetch categories:", err)
+	}
+	want := []entity.Category{
+		{Id: tree[0].Id, Name: "root", AdvertsCount: 4},
+		{Id: tree[1].Id, Name: "child", ParentId: tree[0].Id, AdvertsCount: 3},
+		{Id: tree[2].Id, Name: "grandchild", ParentId: tree[1].Id, AdvertsCount: 1},
+		{Id: tree[3].Id, Name: "other"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want: %+v, got: %+v", want, got)
+	}
+
+	cat, err := categories.GetById(context.Background(), tree[1].Id)
+	if err != nil {
+		t.Fatal("Unable to get category:", err)
+	}
+	if cat.Name != "child" || cat.ParentId != tree[0].Id {
+		t.Fatalf("want child of %v, got: %+v", tree[0].Id, cat)
+	}
+	if _, err = categories.GetById(context.Background(), tree[3].Id+100); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("want: %v, got: %v", sql.ErrNoRows, err)
+	}
+}
+
+func testFetchByCategory(t *testing.T, adverts repository.Advert, categories repository.Category) {
+	tree := categoryTree(t, adverts, categories)
+
+	ctx := context.WithValue(context.Background(), entity.KeyFilter,
+		entity.Filter{CategoryId: tree[1].Id})
+	page := mustFetch(t, adverts, ctx)
+	if page.TotalCount != 3 {
+		t.Fatalf("want 3 adverts of child and grandchild, got: %+v", page.Adverts)
+	}
+	for _, adv := range page.Adverts {
+		if adv.CategoryId != tree[1].Id && adv.CategoryId != tree[2].Id {
+			t.Fatalf("want adverts of child and grandchild, got: %+v", adv)
+		}
+	}
+
+	ctx = context.WithValue(context.Background(), entity.KeyFilter,
+		entity.Filter{CategoryId: tree[3].Id})
+	if page = mustFetch(t, adverts, ctx); page.TotalCount != 0 {
+		t.Fatalf("want no adverts of other, got: %+v", page.Adverts)
+	}
+
+	// advert keeps its category and is moved by update
+	ctx = context.WithValue(context.Background(), entity.KeyFilter,
+		entity.Filter{CategoryId: tree[0].Id})
+	var id int64
+	for _, adv := range mustFetch(t, adverts, ctx).Adverts {
+		if adv.CategoryId == tree[0].Id {
+			id = adv.Id
+		}
+	}
+	adv, err := adverts.GetById(context.Background(), id)
+	if err != nil {
+		t.Fatal("Unable to get:", err)
+	}
+	if adv.CategoryId != tree[0].Id {
+		t.Fatalf("want category %v, got: %v", tree[0].Id, adv.CategoryId)
+	}
+	adv.CategoryId = tree[3].Id
+	if err = adverts.Update(context.Background(), adv); err != nil {
+		t.Fatal("Unable to update:", err)
+	}
+	ctx = context.WithValue(context.Background(), entity.KeyFilter,
+		entity.Filter{CategoryId: tree[3].Id})
+	if page = mustFetch(t, adverts, ctx); !reflect.DeepEqual(ids(page.Adverts), []int64{adv.Id}) {
+		t.Fatalf("want only %v in other, got: %v", adv.Id, ids(page.Adverts))
+	}
+}
+
+func testCategoryParent(t *testing.T, adverts repository.Advert, categories repository.Category) {
+	tree := categoryTree(t, adverts, categories)
+	ctx := context.Background()
+
+	orphan := entity.Category{Name: "orphan", ParentId: tree[3].Id + 100}
+	if err := categories.Store(ctx, &orphan); !errors.Is(err, entity.ErrWrongParent) {
+		t.Fatalf("Store: want: %v, got: %v", entity.ErrWrongParent, err)
+	}
+
+	root := tree[0]
+	for _, parentId := range []int64{root.Id, tree[2].Id, tree[3].Id + 100} {
+		root.ParentId = parentId
+		if err := categories.Update(ctx, root); !errors.Is(err, entity.ErrWrongParent) {
+			t.Fatalf("Update under %v: want: %v, got: %v", parentId, entity.ErrWrongParent, err)
+		}
+	}
+
+	missing := entity.Category{Id: tree[3].Id + 100, Name: "missing"}
+	if err := categories.Update(ctx, missing); !errors.Is(err, entity.ErrItemNotExists) {
+		t.Fatalf("Update missing: want: %v, got: %v", entity.ErrItemNotExists, err)
+	}
+
+	// moving child with its subtree changes counts of both parents
+	child := tree[1]
+	child.Name = "moved"
+	child.ParentId = tree[3].Id
+	if err := categories.Update(ctx, child); err != nil {
+		t.Fatal("Unable to update category:", err)
+	}
+	got, err := categories.Fetch(ctx)
+	if err != nil {
+		t.Fatal("Unable to fetch categories:", err)
+	}
+	want := []int64{1, 3, 1, 3}
+	for i, cat := range got {
+		if cat.AdvertsCount != want[i] {
+			t.Fatalf("want counts %v, got: %+v", want, got)
+		}
+	}
+	if got[1].Name != "moved" || got[1].ParentId != tree[3].Id {
+		t.Fatalf("want child moved to other, got: %+v", got[1])
+	}
+}
+
+func testDeleteCategory(t *testing.T, adverts repository.Advert, categories repository.Category) {
+	tree := categoryTree(t, adverts, categories)
+	ctx := context.Background()
+
+	// root has child, grandchild has advert in trash
+	for _, cat := range tree[:3] {
+		if err := categories.Delete(ctx, cat.Id); !errors.Is(err, entity.ErrCategoryNotEmpty) {
+			t.Fatalf("Delete %v: want: %v, got: %v", cat.Name, entity.ErrCategoryNotEmpty, err)
+		}
+	}
+
+	if err := categories.Delete(ctx, tree[3].Id); err != nil {
+		t.Fatal("Unable to delete category:", err)
+	}
+	if err := categories.Delete(ctx, tree[3].Id); !errors.Is(err, entity.ErrItemNotExists) {
+		t.Fatalf("Delete twice: want: %v, got: %v", entity.ErrItemNotExists, err)
+	}
+	if _, err := categories.GetById(ctx, tree[3].Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetById: want: %v, got: %v", sql.ErrNoRows, err)
+	}
+}
+
+func testCategorySchema(t *testing.T, adverts repository.Advert, categories repository.Category) {
+	ctx := context.Background()
+	schema := json.RawMessage(`{"type": "object", "required": ["year"]}`)
+	cat := entity.Category{Name: "cars", Schema: schema}
+	if err := categories.Store(ctx, &cat); err != nil {
+		t.Fatal("Unable to store category:", err)
+	}
+
+	got, err := categories.GetById(ctx, cat.Id)
+	if err != nil {
+		t.Fatal("Unable to get category:", err)
+	}
+	if !sameJson(got.Schema, schema) {
+		t.Fatalf("want: %s, got: %s", schema, got.Schema)
+	}
+
+	cat.Schema = nil
+	if err = categories.Update(ctx, cat); err != nil {
+		t.Fatal("Unable to update category:", err)
+	}
+	fetched, err := categories.Fetch(ctx)
+	if err != nil {
+		t.Fatal("Unable to fetch categories:", err)
+	}
+	if len(fetched) != 1 || fetched[0].Schema != nil {
+		t.Fatalf("want category without schema, got: %+v", fetched)
+	}
+}
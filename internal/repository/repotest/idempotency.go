@@ -0,0 +1,138 @@
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/internal/repository"
+)
+
+// IdempotencyFactory returns new empty repository of idempotent responses
+type IdempotencyFactory func(t *testing.T) repository.Idempotency
+
+// RunIdempotency runs tests of idempotent responses against repositories made by newRepo
+func RunIdempotency(t *testing.T, newRepo IdempotencyFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo repository.Idempotency)
+	}{
+		{"Reserve", testReserveKey},
+		{"Expired", testExpiredKey},
+		{"Purge", testPurgeKeys},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, newRepo(t))
+		})
+	}
+}
+
+func reservation(key string) entity.IdempotentResponse {
+	return entity.IdempotentResponse{
+		Key:         key,
+		RequestHash: "hash",
+		CreatedAt:   "2022-10-01 12:00:00",
+		ExpiresAt:   "2022-10-02 12:00:00",
+	}
+}
+
+func testReserveKey(t *testing.T, repo repository.Idempotency) {
+	ctx := context.Background()
+	resp := reservation("user:1 key")
+	if err := repo.Reserve(ctx, &resp); err != nil {
+		t.Fatal("Unable to reserve key:", err)
+	}
+	got, err := repo.GetByKey(ctx, resp.Key)
+	if err != nil {
+		t.Fatal("Unable to get response:", err)
+	}
+	if !reflect.DeepEqual(got, resp) {
+		t.Fatalf("want %+v, got %+v", resp, got)
+	}
+
+	again := reservation(resp.Key)
+	again.CreatedAt = "2022-10-01 13:00:00"
+	if err = repo.Reserve(ctx, &again); !errors.Is(err, entity.ErrKeyAlreadyExist) {
+		t.Fatalf("want %v, got %v", entity.ErrKeyAlreadyExist, err)
+	}
+
+	resp.Status = 201
+	resp.Body = []byte(`{"data":[{"id":1}]}`)
+	if err = repo.Complete(ctx, resp); err != nil {
+		t.Fatal("Unable to complete response:", err)
+	}
+	if got, _ = repo.GetByKey(ctx, resp.Key); !reflect.DeepEqual(got, resp) {
+		t.Fatalf("want %+v, got %+v", resp, got)
+	}
+
+	if err = repo.Delete(ctx, resp.Key); err != nil {
+		t.Fatal("Unable to delete response:", err)
+	}
+	if _, err = repo.GetByKey(ctx, resp.Key); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("want %v, got %v", sql.ErrNoRows, err)
+	}
+	if err = repo.Complete(ctx, resp); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("want %v, got %v", sql.ErrNoRows, err)
+	}
+}
+
+func testExpiredKey(t *testing.T, repo repository.Idempotency) {
+	ctx := context.Background()
+	resp := reservation("user:1 key")
+	if err := repo.Reserve(ctx, &resp); err != nil {
+		t.Fatal("Unable to reserve key:", err)
+	}
+	resp.Status = 201
+	resp.Body = []byte(`{}`)
+	if err := repo.Complete(ctx, resp); err != nil {
+		t.Fatal("Unable to complete response:", err)
+	}
+
+	again := reservation(resp.Key)
+	again.RequestHash = "other hash"
+	again.CreatedAt = resp.ExpiresAt
+	again.ExpiresAt = "2022-10-03 12:00:00"
+	if err := repo.Reserve(ctx, &again); err != nil {
+		t.Fatal("Unable to reserve expired key:", err)
+	}
+	got, err := repo.GetByKey(ctx, resp.Key)
+	if err != nil {
+		t.Fatal("Unable to get response:", err)
+	}
+	if !reflect.DeepEqual(got, again) {
+		t.Fatalf("want %+v, got %+v", again, got)
+	}
+}
+
+func testPurgeKeys(t *testing.T, repo repository.Idempotency) {
+	ctx := context.Background()
+	expired := reservation("expired")
+	alive := reservation("alive")
+	alive.ExpiresAt = "2022-10-03 12:00:00"
+	for _, resp := range []*entity.IdempotentResponse{&expired, &alive} {
+		if err := repo.Reserve(ctx, resp); err != nil {
+			t.Fatal("Unable to reserve key:", err)
+		}
+	}
+
+	now := time.Date(2022, 10, 2, 12, 0, 0, 0, time.Local)
+	purged, err := repo.Purge(ctx, now)
+	if err != nil {
+		t.Fatal("Unable to purge responses:", err)
+	}
+	if purged != 1 {
+		t.Fatalf("want 1 purged response, got %v", purged)
+	}
+	if _, err = repo.GetByKey(ctx, expired.Key); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("want %v, got %v", sql.ErrNoRows, err)
+	}
+	if _, err = repo.GetByKey(ctx, alive.Key); err != nil {
+		t.Fatal("Unable to get response:", err)
+	}
+}
@@ -0,0 +1,911 @@
+// Package repotest is conformance suite every repository.Advert
+// implementation is required to pass, backends run it from their tests
+//
+//	func TestConformance(t *testing.T) {
+//		repotest.Run(t, func(t *testing.T) repository.Advert {
+//			return newEmptyRepo(t)
+//		})
+//	}
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/internal/repository"
+)
+
+// Factory returns new empty repository, it is called once for every test of suite
+type Factory func(t *testing.T) repository.Advert
+
+// Run runs every test of suite against repositories made by newRepo
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo repository.Advert)
+	}{
+		{"Store", testStore},
+		{"GetById", testGetById},
+		{"GetByIds", testGetByIds},
+		{"Fields", testFields},
+		{"UniqueName", testUniqueName},
+		{"Update", testUpdate},
+		{"Delete", testDelete},
+		{"Batch", testBatch},
+		{"Hide", testHide},
+		{"Fetch", testFetch},
+		{"FetchFilter", testFetchFilter},
+		{"Attributes", testAttributes},
+		{"Pagination", testPagination},
+		{"ConcurrentWriters", testConcurrentWriters},
+		{"ContextCancellation", testContextCancellation},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, newRepo(t))
+		})
+	}
+}
+
+// newAdvert returns valid advert, advert's date is made of n
+// so that adverts can be sorted by it
+func newAdvert(n int, price int64) entity.Advert {
+	return entity.Advert{
+		Name:         fmt.Sprintf("advert %v", n),
+		Description:  fmt.Sprintf("description of advert %v", n),
+		Price:        price,
+		MainPhotoUrl: fmt.Sprintf("http:fs.com/%v/1", n),
+		PhotosUrls: []string{
+			fmt.Sprintf("http:fs.com/%v/1", n),
+			fmt.Sprintf("http:fs.com/%v/2", n),
+		},
+		CreatedAt: fmt.Sprintf("2022-11-%02d 10:00:00", n%28+1),
+	}
+}
+
+func mustStore(t *testing.T, repo repository.Advert, adv *entity.Advert) {
+	t.Helper()
+	if err := repo.Store(context.Background(), adv); err != nil {
+		t.Fatal("Unable to store:", err)
+	}
+}
+
+func mustFetch(t *testing.T, repo repository.Advert, ctx context.Context) entity.AdvertsPage {
+	t.Helper()
+	page, err := repo.Fetch(ctx)
+	if err != nil {
+		t.Fatal("Unable to fetch:", err)
+	}
+	return page
+}
+
+func ids(adverts []entity.Advert) []int64 {
+	ids := []int64{}
+	for _, adv := range adverts {
+		ids = append(ids, adv.Id)
+	}
+	return ids
+}
+
+func testStore(t *testing.T, repo repository.Advert) {
+	seen := map[int64]bool{}
+	for i := 1; i <= 3; i++ {
+		adv := newAdvert(i, 100)
+		mustStore(t, repo, &adv)
+		if adv.Id <= 0 || seen[adv.Id] {
+			t.Fatalf("want new positive id, got: %v", adv.Id)
+		}
+		if adv.Version != 1 {
+			t.Fatalf("want version 1, got: %v", adv.Version)
+		}
+		seen[adv.Id] = true
+	}
+}
+
+func testGetById(t *testing.T, repo repository.Advert) {
+	ctx := context.Background()
+	adv := newAdvert(1, 150)
+	mustStore(t, repo, &adv)
+
+	got, err := repo.GetById(ctx, adv.Id)
+	if err != nil {
+		t.Fatal("Unable to get:", err)
+	}
+	want := entity.Advert{
+		Id:           adv.Id,
+		Name:         adv.Name,
+		Description:  adv.Description,
+		Price:        adv.Price,
+		MainPhotoUrl: adv.PhotosUrls[0],
+		PhotosUrls:   adv.PhotosUrls,
+		CreatedAt:    adv.CreatedAt,
+		Version:      1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want: %+v, got: %+v", want, got)
+	}
+
+	if _, err = repo.GetById(ctx, adv.Id+100); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("want: %v, got: %v", sql.ErrNoRows, err)
+	}
+}
+
+// testFields checks that only selected fields are given, while
+// version of advert and fields it is sorted by are always given
+func testGetByIds(t *testing.T, repo repository.Advert) {
+	ctx := context.Background()
+	stored := []entity.Advert{newAdvert(1, 150), newAdvert(2, 50), newAdvert(3, 70)}
+	for i := range stored {
+		mustStore(t, repo, &stored[i])
+	}
+	if err := repo.Delete(ctx, stored[1].Id, stored[1].Version); err != nil {
+		t.Fatal("Unable to delete:", err)
+	}
+
+	// missing, deleted and repeated ids are skipped, order of ids is kept
+	got, err := repo.GetByIds(ctx, []int64{stored[2].Id, 999, stored[0].Id,
+		stored[1].Id, stored[2].Id})
+	if err != nil {
+		t.Fatal("Unable to get:", err)
+	}
+	want := []int64{stored[2].Id, stored[0].Id}
+	if !reflect.DeepEqual(ids(got), want) {
+		t.Fatalf("want ids: %v, got: %v", want, ids(got))
+	}
+	for _, adv := range got {
+		single, err := repo.GetById(ctx, adv.Id)
+		if err != nil {
+			t.Fatal("Unable to get:", err)
+		}
+		if !reflect.DeepEqual(adv, single) {
+			t.Fatalf("want: %+v, got: %+v", single, adv)
+		}
+	}
+
+	got, err = repo.GetByIds(ctx, []int64{})
+	if err != nil || len(got) != 0 {
+		t.Fatalf("want no adverts, got: %v, %v", got, err)
+	}
+}
+
+func testFields(t *testing.T, repo repository.Advert) {
+	stored := []entity.Advert{newAdvert(1, 150), newAdvert(2, 50)}
+	for i := range stored {
+		mustStore(t, repo, &stored[i])
+	}
+	ctx := context.WithValue(context.Background(), entity.KeyFields,
+		entity.Fields{"name": true, "photo_urls": true})
+
+	got, err := repo.GetById(ctx, stored[0].Id)
+	if err != nil {
+		t.Fatal("Unable to get:", err)
+	}
+	want := entity.Advert{
+		Id:         stored[0].Id,
+		Name:       stored[0].Name,
+		PhotosUrls: stored[0].PhotosUrls,
+		Version:    1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want: %+v, got: %+v", want, got)
+	}
+
+	// price is given for cursor as adverts are sorted by it
+	order, _ := entity.ParseSort("price")
+	page := mustFetch(t, repo, context.WithValue(ctx, entity.KeySort, order))
+	if len(page.Adverts) != 2 {
+		t.Fatalf("want 2 adverts, got: %v", len(page.Adverts))
+	}
+	for i, adv := range page.Adverts {
+		want := stored[1-i]
+		if adv.Name != want.Name || adv.Price != want.Price ||
+			!reflect.DeepEqual(adv.PhotosUrls, want.PhotosUrls) {
+			t.Fatalf("want selected fields of %+v, got: %+v", want, adv)
+		}
+		if adv.Description != "" || adv.MainPhotoUrl != "" || adv.CreatedAt != "" {
+			t.Fatalf("want no other fields, got: %+v", adv)
+		}
+	}
+}
+
+func testUniqueName(t *testing.T, repo repository.Advert) {
+	ctx := context.Background()
+	first := newAdvert(1, 100)
+	second := newAdvert(2, 100)
+	mustStore(t, repo, &first)
+	mustStore(t, repo, &second)
+
+	same := newAdvert(3, 100)
+	same.Name = first.Name
+	if err := repo.Store(ctx, &same); !errors.Is(err, entity.ErrNameAlreadyExist) {
+		t.Fatalf("Store: want: %v, got: %v", entity.ErrNameAlreadyExist, err)
+	}
+
+	second.Name = first.Name
+	if err := repo.Update(ctx, second); !errors.Is(err, entity.ErrNameAlreadyExist) {
+		t.Fatalf("Update: want: %v, got: %v", entity.ErrNameAlreadyExist, err)
+	}
+
+	// advert keeps its name and adverts in trash keep theirs
+	first.Price = 200
+	if err := repo.Update(ctx, first); err != nil {
+		t.Fatal("Unable to update:", err)
+	}
+	if err := repo.Delete(ctx, first.Id, 0); err != nil {
+		t.Fatal("Unable to delete:", err)
+	}
+	if err := repo.Store(ctx, &same); !errors.Is(err, entity.ErrNameAlreadyExist) {
+		t.Fatalf("Store after Delete: want: %v, got: %v", entity.ErrNameAlreadyExist, err)
+	}
+}
+
+func testBatch(t *testing.T, repo repository.Advert) {
+	ctx := context.Background()
+	first := newAdvert(1, 100)
+	second := newAdvert(2, 100)
+	mustStore(t, repo, &first)
+	mustStore(t, repo, &second)
+
+	changed := newAdvert(3, 300)
+	changed.Id = first.Id
+	changed.Version = 1
+	changed.MainPhotoUrl = changed.PhotosUrls[0]
+	taken := newAdvert(4, 100)
+	taken.Name = second.Name
+
+	// failed operations are undone alone, operation which failed before is skipped
+	ops := []entity.BatchOperation{
+		{Action: entity.ActionCreate, Advert: newAdvert(5, 100)},
+		{Action: entity.ActionUpdate, Advert: changed},
+		{Action: entity.ActionCreate, Advert: taken},
+		{Action: entity.ActionDelete, Advert: entity.Advert{Id: second.Id, Version: 7}},
+		{Action: entity.ActionDelete, Advert: entity.Advert{Id: 999}},
+		{Action: entity.ActionCreate, Advert: newAdvert(6, 100), Err: entity.ErrForbidden},
+	}
+	if err := repo.Batch(ctx, ops, false); err != nil {
+		t.Fatal("Unable to run batch:", err)
+	}
+	want := []error{nil, nil, entity.ErrNameAlreadyExist, entity.ErrVersionMismatch,
+		entity.ErrItemNotExists, entity.ErrForbidden}
+	for i, op := range ops {
+		if !errors.Is(op.Err, want[i]) {
+			t.Fatalf("operation %v: want: %v, got: %v", i, want[i], op.Err)
+		}
+	}
+	created, err := repo.GetById(ctx, ops[0].Advert.Id)
+	if err != nil || created.Name != ops[0].Advert.Name {
+		t.Fatalf("want created advert %v, got: %+v, %v", ops[0].Advert.Name, created, err)
+	}
+	got, err := repo.GetById(ctx, first.Id)
+	if err != nil || got.Name != changed.Name || got.Version != 2 {
+		t.Fatalf("want updated advert %v, got: %+v, %v", changed.Name, got, err)
+	}
+	if got, err := repo.GetById(ctx, second.Id); err != nil || got.Version != 1 {
+		t.Fatalf("want unchanged advert, got: %+v, %v", got, err)
+	}
+
+	// atomic batch is undone as a whole
+	ops = []entity.BatchOperation{
+		{Action: entity.ActionCreate, Advert: newAdvert(7, 100)},
+		{Action: entity.ActionDelete, Advert: entity.Advert{Id: second.Id}},
+		{Action: entity.ActionUpdate, Advert: changed},
+	}
+	if err := repo.Batch(ctx, ops, true); err != nil {
+		t.Fatal("Unable to run batch:", err)
+	}
+	want = []error{entity.ErrBatchAborted, entity.ErrBatchAborted, entity.ErrVersionMismatch}
+	for i, op := range ops {
+		if !errors.Is(op.Err, want[i]) {
+			t.Fatalf("atomic operation %v: want: %v, got: %v", i, want[i], op.Err)
+		}
+	}
+	if got, err := repo.GetById(ctx, second.Id); err != nil || got.Version != 1 {
+		t.Fatalf("want advert kept, got: %+v, %v", got, err)
+	}
+	page := mustFetch(t, repo, ctx)
+	if page.TotalCount != 3 {
+		t.Fatalf("want 3 adverts, got: %v", page.TotalCount)
+	}
+}
+
+func testUpdate(t *testing.T, repo repository.Advert) {
+	ctx := context.WithValue(context.Background(), entity.KeyIdentity,
+		entity.Identity{UserId: 5})
+	adv := newAdvert(1, 100)
+	mustStore(t, repo, &adv)
+
+	changed := newAdvert(2, 300)
+	changed.Id = adv.Id
+	changed.Version = 1
+	changed.PhotosUrls = []string{"http:fs.com/new"}
+	changed.MainPhotoUrl = "http:fs.com/new"
+	if err := repo.Update(ctx, changed); err != nil {
+		t.Fatal("Unable to update:", err)
+	}
+
+	got, err := repo.GetById(ctx, adv.Id)
+	if err != nil {
+		t.Fatal("Unable to get:", err)
+	}
+	want := entity.Advert{
+		Id:           adv.Id,
+		Name:         changed.Name,
+		Description:  changed.Description,
+		Price:        changed.Price,
+		MainPhotoUrl: changed.MainPhotoUrl,
+		PhotosUrls:   changed.PhotosUrls,
+		CreatedAt:    adv.CreatedAt,
+		Version:      2,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want: %+v, got: %+v", want, got)
+	}
+
+	// changed still holds version 1
+	if err = repo.Update(ctx, changed); !errors.Is(err, entity.ErrVersionMismatch) {
+		t.Fatalf("want: %v, got: %v", entity.ErrVersionMismatch, err)
+	}
+	changed.Version = 0
+	keyCtx := context.WithValue(context.Background(), entity.KeyIdentity,
+		entity.Identity{UserId: 5, ApiKeyId: 3})
+	if err = repo.Update(keyCtx, changed); err != nil {
+		t.Fatal("Unable to update regardless of version:", err)
+	}
+
+	// revisions remember who changed advert
+	revisions, err := repo.GetRevisions(ctx, adv.Id)
+	if err != nil || len(revisions) != 3 {
+		t.Fatalf("want 3 revisions, got: %+v, %v", revisions, err)
+	}
+	authors := [][2]int64{}
+	for _, revision := range revisions {
+		authors = append(authors, [2]int64{revision.UserId, revision.ApiKeyId})
+	}
+	if want := [][2]int64{{0, 0}, {5, 0}, {5, 3}}; !reflect.DeepEqual(authors, want) {
+		t.Fatalf("want authors: %v, got: %v", want, authors)
+	}
+	if revision, err := repo.GetRevision(ctx, adv.Id, 3); err != nil ||
+		revision.UserId != 5 || revision.ApiKeyId != 3 {
+		t.Fatalf("want revision of api key 3, got: %+v, %v", revision, err)
+	}
+
+	changed.Id += 100
+	if err = repo.Update(ctx, changed); !errors.Is(err, entity.ErrItemNotExists) {
+		t.Fatalf("want: %v, got: %v", entity.ErrItemNotExists, err)
+	}
+}
+
+func testDelete(t *testing.T, repo repository.Advert) {
+	ctx := context.Background()
+	adv := newAdvert(1, 100)
+	kept := newAdvert(2, 100)
+	mustStore(t, repo, &adv)
+	mustStore(t, repo, &kept)
+
+	if err := repo.Delete(ctx, adv.Id, 2); !errors.Is(err, entity.ErrVersionMismatch) {
+		t.Fatalf("want: %v, got: %v", entity.ErrVersionMismatch, err)
+	}
+	if err := repo.Delete(ctx, adv.Id, 1); err != nil {
+		t.Fatal("Unable to delete:", err)
+	}
+	if err := repo.Delete(ctx, adv.Id, 0); !errors.Is(err, entity.ErrItemNotExists) {
+		t.Fatalf("Delete twice: want: %v, got: %v", entity.ErrItemNotExists, err)
+	}
+	if _, err := repo.GetById(ctx, adv.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetById: want: %v, got: %v", sql.ErrNoRows, err)
+	}
+	changed := adv
+	changed.Version = 0
+	if err := repo.Update(ctx, changed); !errors.Is(err, entity.ErrItemNotExists) {
+		t.Fatalf("Update: want: %v, got: %v", entity.ErrItemNotExists, err)
+	}
+
+	page := mustFetch(t, repo, ctx)
+	if !reflect.DeepEqual(ids(page.Adverts), []int64{kept.Id}) || page.TotalCount != 1 {
+		t.Fatalf("Fetch: want only %v, got: %v", kept.Id, ids(page.Adverts))
+	}
+	page, err := repo.FetchDeleted(ctx)
+	if err != nil {
+		t.Fatal("Unable to fetch deleted:", err)
+	}
+	if !reflect.DeepEqual(ids(page.Adverts), []int64{adv.Id}) || page.Adverts[0].DeletedAt == "" {
+		t.Fatalf("FetchDeleted: want only %v, got: %+v", adv.Id, page.Adverts)
+	}
+	if got, err := repo.GetDeletedById(ctx, adv.Id); err != nil || got.Name != adv.Name {
+		t.Fatalf("GetDeletedById: want: %v, got: %+v, %v", adv.Name, got, err)
+	}
+	if _, err := repo.GetDeletedById(ctx, kept.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetDeletedById: want: %v, got: %v", sql.ErrNoRows, err)
+	}
+
+	if err = repo.Restore(ctx, adv.Id); err != nil {
+		t.Fatal("Unable to restore:", err)
+	}
+	if err = repo.Restore(ctx, adv.Id); !errors.Is(err, entity.ErrItemNotExists) {
+		t.Fatalf("Restore twice: want: %v, got: %v", entity.ErrItemNotExists, err)
+	}
+	got, err := repo.GetById(ctx, adv.Id)
+	if err != nil {
+		t.Fatal("Unable to get restored:", err)
+	}
+	if got.Version != 3 || !reflect.DeepEqual(got.PhotosUrls, adv.PhotosUrls) {
+		t.Fatalf("want version 3 and photo urls kept, got: %+v", got)
+	}
+}
+
+func testHide(t *testing.T, repo repository.Advert) {
+	ctx := context.Background()
+	adv := newAdvert(1, 100)
+	kept := newAdvert(2, 100)
+	mustStore(t, repo, &adv)
+	mustStore(t, repo, &kept)
+
+	if err := repo.Hide(ctx, adv.Id, true); err != nil {
+		t.Fatal("Unable to hide:", err)
+	}
+	got, err := repo.GetById(ctx, adv.Id)
+	if err != nil {
+		t.Fatal("Unable to get hidden:", err)
+	}
+	if got.HiddenAt == "" || got.Version != 2 {
+		t.Fatalf("want hidden advert of version 2, got: %+v", got)
+	}
+	page := mustFetch(t, repo, ctx)
+	if !reflect.DeepEqual(ids(page.Adverts), []int64{kept.Id}) || page.TotalCount != 1 {
+		t.Fatalf("Fetch: want only %v, got: %v", kept.Id, ids(page.Adverts))
+	}
+
+	// hiding again keeps time advert was hidden
+	if err = repo.Hide(ctx, adv.Id, true); err != nil {
+		t.Fatal("Unable to hide twice:", err)
+	}
+	if again, err := repo.GetById(ctx, adv.Id); err != nil || again.HiddenAt != got.HiddenAt {
+		t.Fatalf("want hidden at %v, got: %+v, %v", got.HiddenAt, again, err)
+	}
+
+	if err = repo.Hide(ctx, adv.Id, false); err != nil {
+		t.Fatal("Unable to unhide:", err)
+	}
+	page = mustFetch(t, repo, ctx)
+	if !reflect.DeepEqual(ids(page.Adverts), []int64{adv.Id, kept.Id}) {
+		t.Fatalf("Fetch: want %v and %v, got: %v", adv.Id, kept.Id, ids(page.Adverts))
+	}
+	revisions, err := repo.GetRevisions(ctx, adv.Id)
+	if err != nil || len(revisions) != 4 || revisions[1].Action != entity.ActionHide ||
+		revisions[3].Action != entity.ActionUnhide {
+		t.Fatalf("want revisions of hiding, got: %+v, %v", revisions, err)
+	}
+
+	if err = repo.Hide(ctx, adv.Id+100, true); !errors.Is(err, entity.ErrItemNotExists) {
+		t.Fatalf("want: %v, got: %v", entity.ErrItemNotExists, err)
+	}
+}
+
+func testFetch(t *testing.T, repo repository.Advert) {
+	adv := newAdvert(1, 150)
+	mustStore(t, repo, &adv)
+
+	page := mustFetch(t, repo, context.Background())
+	want := entity.AdvertsPage{
+		Adverts: []entity.Advert{{
+			Id:           adv.Id,
+			Name:         adv.Name,
+			Price:        adv.Price,
+			MainPhotoUrl: adv.PhotosUrls[0],
+			CreatedAt:    adv.CreatedAt,
+		}},
+		TotalCount: 1,
+		Page:       1,
+		PageSize:   entity.DefaultLimit,
+	}
+	if !reflect.DeepEqual(page, want) {
+		t.Fatalf("want: %+v, got: %+v", want, page)
+	}
+
+	if err := repo.Delete(context.Background(), adv.Id, 0); err != nil {
+		t.Fatal("Unable to delete:", err)
+	}
+	page = mustFetch(t, repo, context.Background())
+	if page.Adverts == nil || len(page.Adverts) != 0 || page.TotalCount != 0 {
+		t.Fatalf("want empty page, got: %+v", page)
+	}
+}
+
+func testFetchFilter(t *testing.T, repo repository.Advert) {
+	prices := []int64{50, 100, 150, 200}
+	stored := []entity.Advert{}
+	for i, price := range prices {
+		adv := newAdvert(i+1, price)
+		mustStore(t, repo, &adv)
+		stored = append(stored, adv)
+	}
+	special := newAdvert(10, 100)
+	special.Name = "Toy_car 100%"
+	mustStore(t, repo, &special)
+
+	priceMin, priceMax := int64(100), int64(150)
+	tests := []struct {
+		name   string
+		filter entity.Filter
+		want   []int64
+	}{
+		{
+			name:   "Price range",
+			filter: entity.Filter{PriceMin: &priceMin, PriceMax: &priceMax},
+			want:   []int64{stored[1].Id, stored[2].Id, special.Id},
+		},
+		{
+			name:   "Name prefix ignores case",
+			filter: entity.Filter{NamePrefix: "ADVERT"},
+			want:   ids(stored),
+		},
+		{
+			name:   "Name prefix is not pattern",
+			filter: entity.Filter{NamePrefix: "toy_"},
+			want:   []int64{special.Id},
+		},
+		{
+			name:   "Name prefix wildcard",
+			filter: entity.Filter{NamePrefix: "toy%"},
+			want:   []int64{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), entity.KeyFilter, tc.filter)
+			page := mustFetch(t, repo, ctx)
+			if !reflect.DeepEqual(ids(page.Adverts), tc.want) {
+				t.Fatalf("want: %v, got: %v", tc.want, ids(page.Adverts))
+			}
+			if page.TotalCount != int64(len(tc.want)) {
+				t.Fatalf("want total count: %v, got: %v", len(tc.want), page.TotalCount)
+			}
+		})
+	}
+}
+
+// testAttributes checks that attributes are kept as JSON, formatting aside,
+// and that adverts are filtered by them
+func testAttributes(t *testing.T, repo repository.Advert) {
+	ctx := context.Background()
+	attributes := []string{
+		`{"year": 2010, "color": "red"}`,
+		`{"year": 2016, "color": "blue", "electric": true}`,
+		`{"year": 2020.5, "color": "red"}`,
+		`{"year": "new", "color": "red"}`,
+		``,
+	}
+	stored := []entity.Advert{}
+	for i, attr := range attributes {
+		adv := newAdvert(i+1, 100)
+		if attr != "" {
+			adv.Attributes = json.RawMessage(attr)
+		}
+		mustStore(t, repo, &adv)
+		stored = append(stored, adv)
+	}
+
+	got, err := repo.GetById(ctx, stored[1].Id)
+	if err != nil {
+		t.Fatal("Unable to get:", err)
+	}
+	if !sameJson(got.Attributes, stored[1].Attributes) {
+		t.Fatalf("want: %s, got: %s", stored[1].Attributes, got.Attributes)
+	}
+	got, err = repo.GetById(ctx, stored[4].Id)
+	if err != nil {
+		t.Fatal("Unable to get:", err)
+	}
+	if got.Attributes != nil {
+		t.Fatalf("want no attributes, got: %s", got.Attributes)
+	}
+
+	stored[0].Attributes = json.RawMessage(`{"year": 2012, "color": "green"}`)
+	if err = repo.Update(ctx, stored[0]); err != nil {
+		t.Fatal("Unable to update:", err)
+	}
+
+	min, max := 2012.0, 2020.0
+	tests := []struct {
+		name   string
+		filter []entity.AttributeFilter
+		want   []int64
+	}{
+		{
+			name:   "Number range skips other types",
+			filter: []entity.AttributeFilter{{Name: "year", Min: &min, Max: &max}},
+			want:   []int64{stored[0].Id, stored[1].Id},
+		},
+		{
+			name:   "Lower bound",
+			filter: []entity.AttributeFilter{{Name: "year", Min: &max}},
+			want:   []int64{stored[2].Id},
+		},
+		{
+			name:   "Equal string",
+			filter: []entity.AttributeFilter{{Name: "color", Equal: json.RawMessage(`"red"`)}},
+			want:   []int64{stored[2].Id, stored[3].Id},
+		},
+		{
+			name:   "Equal number",
+			filter: []entity.AttributeFilter{{Name: "year", Equal: json.RawMessage(`2016`)}},
+			want:   []int64{stored[1].Id},
+		},
+		{
+			name: "Several attributes",
+			filter: []entity.AttributeFilter{
+				{Name: "color", Equal: json.RawMessage(`"blue"`)},
+				{Name: "electric", Equal: json.RawMessage(`true`)},
+			},
+			want: []int64{stored[1].Id},
+		},
+		{
+			name:   "Missing attribute",
+			filter: []entity.AttributeFilter{{Name: "rooms", Min: &min}},
+			want:   []int64{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			filter := entity.Filter{Attributes: tc.filter}
+			page := mustFetch(t, repo, context.WithValue(ctx, entity.KeyFilter, filter))
+			if !reflect.DeepEqual(ids(page.Adverts), tc.want) {
+				t.Fatalf("want: %v, got: %v", tc.want, ids(page.Adverts))
+			}
+		})
+	}
+
+	page := mustFetch(t, repo, ctx)
+	if !sameJson(page.Adverts[0].Attributes, stored[0].Attributes) {
+		t.Fatalf("want listed attributes: %s, got: %s", stored[0].Attributes,
+			page.Adverts[0].Attributes)
+	}
+}
+
+// sameJson compares JSON values regardless of their formatting
+func sameJson(a, b json.RawMessage) bool {
+	var first, second interface{}
+	if json.Unmarshal(a, &first) != nil || json.Unmarshal(b, &second) != nil {
+		return false
+	}
+	return reflect.DeepEqual(first, second)
+}
+
+// testPagination walks every sort order page by page, by offset and by cursor,
+// and checks that pages make up whole sorted list without gaps and repeats
+func testPagination(t *testing.T, repo repository.Advert) {
+	// prices and dates repeat so that ties are broken by id
+	prices := []int64{90, 150, 50, 90, 150, 90, 10}
+	all := []entity.Advert{}
+	for i, price := range prices {
+		adv := newAdvert(i%3, price)
+		adv.Name = fmt.Sprintf("advert %v", i)
+		mustStore(t, repo, &adv)
+		all = append(all, adv)
+	}
+
+	const limit = 3
+	// the last key is followed by id in its direction
+	for _, spec := range []string{"", "id", "-id", "price", "-price", "created_at",
+		"-created_at", "name", "-name", "-price,created_at", "created_at,-price,name"} {
+		want := sorted(all, spec)
+
+		t.Run(fmt.Sprintf("Offset %v", spec), func(t *testing.T) {
+			got := []int64{}
+			for offset := 0; ; offset += limit {
+				ctx := fetchContext(t, spec, limit)
+				ctx = context.WithValue(ctx, entity.KeyOffset, offset)
+				page := mustFetch(t, repo, ctx)
+				checkPage(t, page, len(all), offset+limit < len(all))
+				if page.Page != int64(offset/limit)+1 {
+					t.Fatalf("want page %v, got: %v", offset/limit+1, page.Page)
+				}
+				got = append(got, ids(page.Adverts)...)
+				if !page.HasNext {
+					break
+				}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("want: %v, got: %v", want, got)
+			}
+		})
+
+		t.Run(fmt.Sprintf("Cursor %v", spec), func(t *testing.T) {
+			got := []int64{}
+			ctx := fetchContext(t, spec, limit)
+			for {
+				page := mustFetch(t, repo, ctx)
+				checkPage(t, page, len(all), len(got)+limit < len(all))
+				got = append(got, ids(page.Adverts)...)
+				if !page.HasNext {
+					break
+				}
+				last := page.Adverts[len(page.Adverts)-1]
+				ctx = context.WithValue(fetchContext(t, spec, limit),
+					entity.KeyCursor, entity.NewCursor(last, sortFrom(ctx)))
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("want: %v, got: %v", want, got)
+			}
+		})
+	}
+}
+
+func fetchContext(t *testing.T, spec string, limit int) context.Context {
+	t.Helper()
+	ctx := context.WithValue(context.Background(), entity.KeyLimit, limit)
+	if spec == "" {
+		return ctx
+	}
+	order, err := entity.ParseSort(spec)
+	if err != nil {
+		t.Fatal("Unable to parse sort:", err)
+	}
+	return context.WithValue(ctx, entity.KeySort, order)
+}
+
+func sortFrom(ctx context.Context) entity.Sort {
+	order, _ := ctx.Value(entity.KeySort).(entity.Sort)
+	return order
+}
+
+func checkPage(t *testing.T, page entity.AdvertsPage, total int, hasNext bool) {
+	t.Helper()
+	if page.TotalCount != int64(total) {
+		t.Fatalf("want total count %v, got: %v", total, page.TotalCount)
+	}
+	if page.HasNext != hasNext {
+		t.Fatalf("want has next %v, got: %v", hasNext, page.HasNext)
+	}
+}
+
+// sorted returns ids of adverts in order repository is expected to return
+// them, keys of spec are applied in turn and id breaks ties
+func sorted(adverts []entity.Advert, spec string) []int64 {
+	adverts = append([]entity.Advert{}, adverts...)
+	keys := []string{}
+	if spec != "" {
+		keys = strings.Split(spec, ",")
+	}
+	value := func(adv entity.Advert, key string) string {
+		switch strings.TrimPrefix(key, "-") {
+		case "price":
+			return fmt.Sprintf("%020d", adv.Price)
+		case "created_at":
+			return adv.CreatedAt
+		case "name":
+			return adv.Name
+		}
+		return fmt.Sprintf("%020d", adv.Id)
+	}
+	sort.Slice(adverts, func(i, j int) bool {
+		for _, key := range keys {
+			a, b := adverts[i], adverts[j]
+			if strings.HasPrefix(key, "-") {
+				a, b = b, a
+			}
+			if value(a, key) != value(b, key) {
+				return value(a, key) < value(b, key)
+			}
+		}
+		a, b := adverts[i], adverts[j]
+		if len(keys) != 0 && strings.HasPrefix(keys[len(keys)-1], "-") {
+			a, b = b, a
+		}
+		return a.Id < b.Id
+	})
+	return ids(adverts)
+}
+
+func testConcurrentWriters(t *testing.T, repo repository.Advert) {
+	ctx := context.Background()
+	const writers = 10
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sameStored := 0
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			adv := newAdvert(i, int64(i))
+			if err := repo.Store(ctx, &adv); err != nil {
+				t.Error("Unable to store:", err)
+				return
+			}
+			adv.Price += 1000
+			if err := repo.Update(ctx, adv); err != nil {
+				t.Error("Unable to update:", err)
+			}
+			if _, err := repo.Fetch(ctx); err != nil {
+				t.Error("Unable to fetch:", err)
+			}
+
+			// only one of writers stores advert with the same name
+			same := newAdvert(writers, 0)
+			err := repo.Store(ctx, &same)
+			switch {
+			case err == nil:
+				mu.Lock()
+				sameStored++
+				mu.Unlock()
+			case !errors.Is(err, entity.ErrNameAlreadyExist):
+				t.Error("Unable to store:", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	if sameStored != 1 {
+		t.Fatalf("want advert with the same name stored once, got: %v", sameStored)
+	}
+	page := mustFetch(t, repo, context.WithValue(ctx, entity.KeyLimit, 100))
+	if page.TotalCount != writers+1 {
+		t.Fatalf("want %v adverts, got: %v", writers+1, page.TotalCount)
+	}
+	for _, adv := range page.Adverts {
+		if adv.Price < 1000 && adv.Name != newAdvert(writers, 0).Name {
+			t.Fatalf("want every advert updated, got: %+v", adv)
+		}
+	}
+}
+
+func testContextCancellation(t *testing.T, repo repository.Advert) {
+	adv := newAdvert(1, 100)
+	mustStore(t, repo, &adv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := []struct {
+		name string
+		call func() error
+	}{
+		{"Store", func() error {
+			adv := newAdvert(2, 100)
+			return repo.Store(ctx, &adv)
+		}},
+		{"GetById", func() error {
+			_, err := repo.GetById(ctx, adv.Id)
+			return err
+		}},
+		{"Fetch", func() error {
+			_, err := repo.Fetch(ctx)
+			return err
+		}},
+		{"Update", func() error {
+			changed := adv
+			changed.Price = 200
+			return repo.Update(ctx, changed)
+		}},
+		{"Delete", func() error {
+			return repo.Delete(ctx, adv.Id, 0)
+		}},
+	}
+	for _, c := range calls {
+		if err := c.call(); !errors.Is(err, context.Canceled) {
+			t.Fatalf("%v: want: %v, got: %v", c.name, context.Canceled, err)
+		}
+	}
+
+	// nothing is changed by cancelled calls
+	page := mustFetch(t, repo, context.Background())
+	if page.TotalCount != 1 || page.Adverts[0].Price != 100 {
+		t.Fatalf("want only unchanged advert, got: %+v", page.Adverts)
+	}
+}
@@ -0,0 +1,137 @@
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/internal/repository"
+)
+
+// RoleFactory returns new empty repositories of users, their roles
+// and audit trail sharing the same storage
+type RoleFactory func(t *testing.T) (repository.User, repository.Role, repository.Audit)
+
+// RunRoles runs tests of roles and audit trail against repositories made by newRepos
+func RunRoles(t *testing.T, newRepos RoleFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, users repository.User, roles repository.Role,
+			audit repository.Audit)
+	}{
+		{"Fetch", testFetchRoles},
+		{"Assign", testAssignRoles},
+		{"Audit", testAudit},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			users, roles, audit := newRepos(t)
+			tc.test(t, users, roles, audit)
+		})
+	}
+}
+
+func roleNames(roles []entity.Role) []string {
+	names := []string{}
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
+
+func testFetchRoles(t *testing.T, _ repository.User, repo repository.Role, _ repository.Audit) {
+	roles, err := repo.Fetch(context.Background())
+	if err != nil {
+		t.Fatal("Unable to fetch roles:", err)
+	}
+
+	want := []string{entity.RoleAdmin, entity.RoleModerator}
+	if !reflect.DeepEqual(roleNames(roles), want) {
+		t.Fatalf("want roles %v, got: %+v", want, roles)
+	}
+	if len(roles[0].Permissions) != 7 ||
+		!reflect.DeepEqual(roles[1].Permissions, []string{entity.PermissionHideAdvert}) {
+		t.Fatalf("want permissions of admin and moderator, got: %+v", roles)
+	}
+}
+
+func testAssignRoles(t *testing.T, users repository.User, repo repository.Role,
+	_ repository.Audit) {
+	ctx := context.Background()
+	user := entity.User{Email: "user@example.com", PasswordHash: "hash"}
+	if err := users.Store(ctx, &user); err != nil {
+		t.Fatal("Unable to store user:", err)
+	}
+
+	for _, role := range []string{entity.RoleModerator, entity.RoleAdmin, entity.RoleModerator} {
+		if err := repo.Assign(ctx, user.Id, role); err != nil {
+			t.Fatalf("Unable to assign %v: %v", role, err)
+		}
+	}
+	roles, err := repo.GetByUser(ctx, user.Id)
+	if err != nil {
+		t.Fatal("Unable to get roles:", err)
+	}
+	if want := []string{entity.RoleAdmin, entity.RoleModerator}; !reflect.DeepEqual(
+		roleNames(roles), want) {
+		t.Fatalf("want roles %v, got: %+v", want, roles)
+	}
+
+	for _, role := range []string{entity.RoleAdmin, entity.RoleAdmin} {
+		if err = repo.Unassign(ctx, user.Id, role); err != nil {
+			t.Fatalf("Unable to unassign %v: %v", role, err)
+		}
+	}
+	roles, err = repo.GetByUser(ctx, user.Id)
+	if err != nil || !reflect.DeepEqual(roleNames(roles), []string{entity.RoleModerator}) {
+		t.Fatalf("want only moderator role, got: %+v, %v", roles, err)
+	}
+
+	if err = repo.Assign(ctx, user.Id, "owner"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("want: %v, got: %v", sql.ErrNoRows, err)
+	}
+	if err = repo.Unassign(ctx, user.Id, "owner"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("want: %v, got: %v", sql.ErrNoRows, err)
+	}
+	if roles, err = repo.GetByUser(ctx, user.Id+1); err != nil || len(roles) != 0 {
+		t.Fatalf("want no roles, got: %+v, %v", roles, err)
+	}
+}
+
+func testAudit(t *testing.T, _ repository.User, _ repository.Role, repo repository.Audit) {
+	ctx := context.Background()
+	entries := []entity.AuditEntry{
+		{Action: "adverts:create", Resource: "adverts", Allowed: true,
+			CreatedAt: "2022-10-01 12:00:00"},
+		{UserId: 1, Action: "adverts:update", Resource: "adverts/1", Allowed: true,
+			CreatedAt: "2022-10-01 12:00:01"},
+		{UserId: 2, ApiKeyId: 3, Action: "adverts:delete", Resource: "adverts/1",
+			CreatedAt: "2022-10-01 12:00:02"},
+	}
+	for i := range entries {
+		if err := repo.Store(ctx, &entries[i]); err != nil {
+			t.Fatal("Unable to store entry:", err)
+		}
+		if entries[i].Id == 0 {
+			t.Fatal("Stored entry got no id")
+		}
+	}
+
+	got, err := repo.Fetch(ctx)
+	if err != nil {
+		t.Fatal("Unable to fetch entries:", err)
+	}
+	want := []entity.AuditEntry{entries[2], entries[1], entries[0]}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want newest first %+v, got: %+v", want, got)
+	}
+
+	ctx = context.WithValue(context.WithValue(ctx, entity.KeyLimit, 1), entity.KeyOffset, 1)
+	if got, err = repo.Fetch(ctx); err != nil || !reflect.DeepEqual(got, want[1:2]) {
+		t.Fatalf("want %+v, got: %+v, %v", want[1:2], got, err)
+	}
+}
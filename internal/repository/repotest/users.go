@@ -0,0 +1,137 @@
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/internal/repository"
+)
+
+// UserFactory returns new empty repositories of adverts and users
+// sharing the same storage
+type UserFactory func(t *testing.T) (repository.Advert, repository.User)
+
+// RunUsers runs tests of users and sessions against repositories made by newRepo
+func RunUsers(t *testing.T, newRepo UserFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, adverts repository.Advert, users repository.User)
+	}{
+		{"Store", testStoreUser},
+		{"Sessions", testSessions},
+		{"Owner", testOwner},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			adverts, users := newRepo(t)
+			tc.test(t, adverts, users)
+		})
+	}
+}
+
+func testStoreUser(t *testing.T, _ repository.Advert, repo repository.User) {
+	ctx := context.Background()
+	user := entity.User{Email: "user@example.com", PasswordHash: "hash",
+		CreatedAt: "2022-10-01 12:00:00"}
+	if err := repo.Store(ctx, &user); err != nil {
+		t.Fatal("Unable to store user:", err)
+	}
+	if user.Id == 0 {
+		t.Fatal("Stored user got no id")
+	}
+
+	byId, err := repo.GetById(ctx, user.Id)
+	if err != nil {
+		t.Fatal("Unable to get user:", err)
+	}
+	byEmail, err := repo.GetByEmail(ctx, user.Email)
+	if err != nil {
+		t.Fatal("Unable to get user by email:", err)
+	}
+	if !reflect.DeepEqual(byId, user) || !reflect.DeepEqual(byEmail, user) {
+		t.Fatalf("want %+v, got %+v and %+v", user, byId, byEmail)
+	}
+
+	same := entity.User{Email: user.Email, PasswordHash: "other"}
+	if err = repo.Store(ctx, &same); !errors.Is(err, entity.ErrEmailAlreadyExist) {
+		t.Fatalf("want %v, got %v", entity.ErrEmailAlreadyExist, err)
+	}
+
+	if _, err = repo.GetByEmail(ctx, "missing@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("want %v, got %v", sql.ErrNoRows, err)
+	}
+	if _, err = repo.GetById(ctx, user.Id+1); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("want %v, got %v", sql.ErrNoRows, err)
+	}
+}
+
+func testSessions(t *testing.T, _ repository.Advert, repo repository.User) {
+	ctx := context.Background()
+	user := entity.User{Email: "user@example.com", PasswordHash: "hash"}
+	if err := repo.Store(ctx, &user); err != nil {
+		t.Fatal("Unable to store user:", err)
+	}
+
+	session := entity.Session{TokenHash: "abc", UserId: user.Id,
+		CreatedAt: "2022-10-01 12:00:00", ExpiresAt: "2022-10-02 12:00:00"}
+	if err := repo.StoreSession(ctx, session); err != nil {
+		t.Fatal("Unable to store session:", err)
+	}
+
+	got, err := repo.GetSession(ctx, session.TokenHash)
+	if err != nil {
+		t.Fatal("Unable to get session:", err)
+	}
+	if got != session {
+		t.Fatalf("want %+v, got %+v", session, got)
+	}
+
+	if err = repo.DeleteSession(ctx, session.TokenHash); err != nil {
+		t.Fatal("Unable to delete session:", err)
+	}
+	if _, err = repo.GetSession(ctx, session.TokenHash); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("want %v, got %v", sql.ErrNoRows, err)
+	}
+}
+
+func testOwner(t *testing.T, adverts repository.Advert, users repository.User) {
+	ctx := context.Background()
+	user := entity.User{Email: "user@example.com", PasswordHash: "hash"}
+	if err := users.Store(ctx, &user); err != nil {
+		t.Fatal("Unable to store user:", err)
+	}
+
+	owned := newAdvert(1, 100)
+	owned.OwnerId = user.Id
+	mustStore(t, adverts, &owned)
+	anonymous := newAdvert(2, 100)
+	mustStore(t, adverts, &anonymous)
+
+	got, err := adverts.GetById(ctx, owned.Id)
+	if err != nil {
+		t.Fatal("Unable to get advert:", err)
+	}
+	if got.OwnerId != user.Id {
+		t.Fatalf("want owner %v, got: %v", user.Id, got.OwnerId)
+	}
+
+	// owner is kept on update
+	got.Name = "renamed"
+	if err = adverts.Update(ctx, got); err != nil {
+		t.Fatal("Unable to update advert:", err)
+	}
+
+	page := mustFetch(t, adverts, context.Background())
+	owners := map[int64]int64{}
+	for _, adv := range page.Adverts {
+		owners[adv.Id] = adv.OwnerId
+	}
+	if owners[owned.Id] != user.Id || owners[anonymous.Id] != 0 {
+		t.Fatalf("want owners %v and 0, got: %v", user.Id, owners)
+	}
+}
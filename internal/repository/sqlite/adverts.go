@@ -3,12 +3,21 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	gosqlite3 "github.com/mattn/go-sqlite3"
 	"github.com/mrsubudei/adv-store-service/internal/entity"
 	"github.com/mrsubudei/adv-store-service/pkg/sqlite3"
 )
 
+// dateFormat is format adverts' creation time is stored in
+const dateFormat = "2006-01-02 15:04:05"
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 type AdvertsRepo struct {
 	*sqlite3.Sqlite
 }
@@ -18,7 +27,7 @@ func NewAdvertsRepo(sq *sqlite3.Sqlite) *AdvertsRepo {
 }
 
 func (ar *AdvertsRepo) Store(ctx context.Context, adv *entity.Advert) error {
-	tx, err := ar.DB.Begin()
+	tx, err := ar.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("AdvertsRepo - Store - Begin: %w", err)
 	}
@@ -26,21 +35,36 @@ func (ar *AdvertsRepo) Store(ctx context.Context, adv *entity.Advert) error {
 		err = tx.Rollback()
 	}()
 
-	err = ar.storeAdvert(ctx, tx, adv)
+	err = ar.store(ctx, tx, adv)
 	if err != nil {
 		return fmt.Errorf("AdvertsRepo - Store - %w", err)
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("AdvertsRepo - Store - Commit: %w", err)
+	}
+
+	return nil
+}
+
+// store stores advert with its photo urls and first revision
+func (ar *AdvertsRepo) store(ctx context.Context, tx *sql.Tx, adv *entity.Advert) error {
+	err := ar.storeAdvert(ctx, tx, adv)
+	if err != nil {
+		return fmt.Errorf("store - %w", err)
+	}
+
 	for i := 0; i < len(adv.PhotosUrls); i++ {
 		err := ar.storeUrl(ctx, tx, adv.Id, adv.PhotosUrls[i])
 		if err != nil {
-			return fmt.Errorf("AdvertsRepo - Store - %w", err)
+			return fmt.Errorf("store - %w", err)
 		}
 	}
 
-	err = tx.Commit()
+	err = ar.storeRevision(ctx, tx, adv.Id, entity.ActionCreate)
 	if err != nil {
-		return fmt.Errorf("AdvertsRepo - Store - Commit: %w", err)
+		return fmt.Errorf("store - %w", err)
 	}
 
 	return nil
@@ -48,10 +72,13 @@ func (ar *AdvertsRepo) Store(ctx context.Context, adv *entity.Advert) error {
 
 func (ar *AdvertsRepo) storeAdvert(ctx context.Context, tx *sql.Tx, adv *entity.Advert) error {
 	res, err := tx.ExecContext(ctx,
-		`INSERT INTO adverts(name, description, price, photo_url, created_at) 
-		values(?, ?, ?, ?, ?)`,
-		adv.Name, adv.Description, adv.Price, adv.PhotosUrls[0], adv.CreatedAt)
-	if err != nil {
+		`INSERT INTO adverts(name, description, price, photo_url, category_id, attributes,
+		owner_id, created_at) values(?, ?, ?, ?, ?, ?, ?, ?)`,
+		adv.Name, adv.Description, adv.Price, adv.PhotosUrls[0], nullId(adv.CategoryId),
+		nullJson(adv.Attributes), nullId(adv.OwnerId), adv.CreatedAt)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("storeAdvert - ExecContext: %v: %w", err, entity.ErrNameAlreadyExist)
+	} else if err != nil {
 		return fmt.Errorf("storeAdvert - ExecContext: %w", err)
 	}
 
@@ -64,6 +91,7 @@ func (ar *AdvertsRepo) storeAdvert(ctx context.Context, tx *sql.Tx, adv *entity.
 		return fmt.Errorf("storeAdvert - LastInsertId: %w", err)
 	}
 	adv.Id = id
+	adv.Version = 1
 
 	return nil
 }
@@ -85,112 +113,521 @@ func (ar *AdvertsRepo) storeUrl(ctx context.Context, tx *sql.Tx, advId int64, ur
 }
 
 func (ar *AdvertsRepo) GetById(ctx context.Context, id int64) (entity.Advert, error) {
+	advert, err := ar.getById(ctx, id, false)
+	if err != nil {
+		return advert, fmt.Errorf("AdvertsRepo - GetById - %w", err)
+	}
+	return advert, nil
+}
+
+// GetDeletedById returns advert which is in trash
+func (ar *AdvertsRepo) GetDeletedById(ctx context.Context, id int64) (entity.Advert, error) {
+	advert, err := ar.getById(ctx, id, true)
+	if err != nil {
+		return advert, fmt.Errorf("AdvertsRepo - GetDeletedById - %w", err)
+	}
+	return advert, nil
+}
+
+// getById returns advert either out of trash or in it
+func (ar *AdvertsRepo) getById(ctx context.Context, id int64,
+	deleted bool) (entity.Advert, error) {
 	advert := entity.Advert{}
 
-	tx, err := ar.DB.Begin()
+	tx, err := ar.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return advert, fmt.Errorf("AdvertsRepo - GetById - Begin: %w", err)
+		return advert, fmt.Errorf("getById - Begin: %w", err)
 	}
 	defer func() {
 		err = tx.Rollback()
 	}()
 
-	row := tx.QueryRowContext(ctx,
-		`SELECT id, name, description, price, photo_url
-        FROM adverts             
-        WHERE id = ?`, id)
+	condition := "deleted_at IS NULL"
+	if deleted {
+		condition = "deleted_at IS NOT NULL"
+	}
+	fields, _ := ctx.Value(entity.KeyFields).(entity.Fields)
+	row := tx.QueryRowContext(ctx, `SELECT `+advertColumns(fields)+`
+		FROM adverts
+		WHERE id = ? AND `+condition, id)
 
-	var description sql.NullString
-	var price sql.NullInt64
-	var url sql.NullString
+	advert, err = scanAdvert(row)
+	if err != nil {
+		return advert, fmt.Errorf("getById - %w", err)
+	}
+
+	if fields.Has("photo_urls") {
+		urls, err := ar.getUrls(ctx, tx, advert.Id)
+		if err != nil {
+			return advert, fmt.Errorf("getById - %w", err)
+		}
+		advert.PhotosUrls = append(advert.PhotosUrls, urls...)
+	}
 
-	err = row.Scan(&advert.Id, &advert.Name, &description, &price, &url)
+	err = tx.Commit()
 	if err != nil {
-		return advert, fmt.Errorf("AdvertsRepo - GetById - Scan: %w", err)
+		return advert, fmt.Errorf("getById - Commit: %w", err)
 	}
 
-	advert.Description = description.String
-	advert.Price = price.Int64
-	advert.MainPhotoUrl = url.String
+	return advert, nil
 
-	urls, err := ar.getUrls(ctx, tx, advert.Id)
+}
+
+// GetByIds returns adverts which are not deleted in order of ids, adverts
+// which are not found are skipped. Photo urls of all adverts are taken at once.
+func (ar *AdvertsRepo) GetByIds(ctx context.Context, ids []int64) ([]entity.Advert, error) {
+	adverts := []entity.Advert{}
+	if len(ids) == 0 {
+		return adverts, nil
+	}
+
+	tx, err := ar.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return advert, fmt.Errorf("AdvertsRepo - GetById - %w", err)
+		return adverts, fmt.Errorf("AdvertsRepo - GetByIds - Begin: %w", err)
+	}
+	defer func() {
+		err = tx.Rollback()
+	}()
+
+	placeholders := []string{}
+	args := []interface{}{}
+	for _, id := range ids {
+		placeholders = append(placeholders, "?")
+		args = append(args, id)
+	}
+	fields, _ := ctx.Value(entity.KeyFields).(entity.Fields)
+	query := fmt.Sprintf(`SELECT %v
+		FROM adverts
+		WHERE id IN (%v) AND deleted_at IS NULL`,
+		advertColumns(fields), strings.Join(placeholders, ", "))
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return adverts, fmt.Errorf("AdvertsRepo - GetByIds - QueryContext: %w", err)
 	}
 
-	advert.PhotosUrls = append(advert.PhotosUrls, urls...)
+	defer rows.Close()
+
+	found := map[int64]entity.Advert{}
+	for rows.Next() {
+		advert, err := scanAdvert(rows)
+		if err != nil {
+			return adverts, fmt.Errorf("AdvertsRepo - GetByIds - %w", err)
+		}
+		found[advert.Id] = advert
+	}
+	if err = rows.Err(); err != nil {
+		return adverts, fmt.Errorf("AdvertsRepo - GetByIds - Rows: %w", err)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if advert, ok := found[id]; ok {
+			adverts = append(adverts, advert)
+			delete(found, id)
+		}
+	}
+
+	if fields.Has("photo_urls") {
+		err = ar.getAdvertsUrls(ctx, tx, adverts)
+		if err != nil {
+			return adverts, fmt.Errorf("AdvertsRepo - GetByIds - %w", err)
+		}
+	}
 
 	err = tx.Commit()
 	if err != nil {
-		return advert, fmt.Errorf("AdvertsRepo - GetById - Commit: %w", err)
+		return adverts, fmt.Errorf("AdvertsRepo - GetByIds - Commit: %w", err)
 	}
 
-	return advert, nil
+	return adverts, nil
+}
 
+func (ar *AdvertsRepo) Fetch(ctx context.Context) (entity.AdvertsPage, error) {
+	page, err := ar.fetch(ctx, false)
+	if err != nil {
+		return page, fmt.Errorf("AdvertsRepo - Fetch - %w", err)
+	}
+	return page, nil
 }
 
-func (ar *AdvertsRepo) Fetch(ctx context.Context) ([]entity.Advert, error) {
-	adverts := []entity.Advert{}
+// FetchDeleted returns page of adverts moved to trash
+func (ar *AdvertsRepo) FetchDeleted(ctx context.Context) (entity.AdvertsPage, error) {
+	page, err := ar.fetch(ctx, true)
+	if err != nil {
+		return page, fmt.Errorf("AdvertsRepo - FetchDeleted - %w", err)
+	}
+	return page, nil
+}
+
+func (ar *AdvertsRepo) fetch(ctx context.Context, deleted bool) (entity.AdvertsPage, error) {
+	page := entity.AdvertsPage{Adverts: []entity.Advert{}}
 
-	limit := 10
+	limit := entity.DefaultLimit
 	offset := 0
-	sortBy := "id"
-	orderBy := "asc"
+	order := entity.DefaultSort
+	sorted := false
+	search := ""
+	words := []string{}
 	if val, ok := ctx.Value(entity.KeyLimit).(int); ok && val != 0 {
 		limit = val
 	}
 	if val, ok := ctx.Value(entity.KeyOffset).(int); ok && val != 0 {
 		offset = val
 	}
-	if val, ok := ctx.Value(entity.KeySortBy).(string); ok && val != "" {
-		sortBy = val
+	if val, ok := ctx.Value(entity.KeySort).(entity.Sort); ok && len(val) != 0 {
+		order = val
+		sorted = true
+	}
+	// fields adverts are sorted by are read for cursor even if they are not selected
+	fields := entity.ListFields
+	if val, ok := ctx.Value(entity.KeyFields).(entity.Fields); ok {
+		fields = val.WithSort(order)
 	}
-	if val, ok := ctx.Value(entity.KeyOrderBy).(string); ok && val != "" {
-		orderBy = val
+	if val, ok := ctx.Value(entity.KeyQuery).(string); ok && val != "" {
+		search = ftsQuery(val)
+		words = strings.Fields(val)
 	}
 
+	from := "adverts"
+	snippet := "''"
+	orderBy, err := orderClause(order)
+	if err != nil {
+		return page, fmt.Errorf("fetch - %w", err)
+	}
+	// hidden adverts are not listed, but stay in trash if they are deleted
+	conditions := []string{"adverts.deleted_at IS NULL", "adverts.hidden_at IS NULL"}
+	args := []interface{}{}
+	if deleted {
+		conditions = []string{"adverts.deleted_at IS NOT NULL"}
+	}
+
+	indexed := false
+	if search != "" {
+		indexed, err = ar.searchIndexed(ctx)
+		if err != nil {
+			return page, fmt.Errorf("fetch - %w", err)
+		}
+	}
+
+	// without FTS5 every word is looked for in name or description
+	// as is, results are not ranked and have no snippets
+	if search != "" && !indexed {
+		for _, word := range words {
+			conditions = append(conditions, `(adverts.name LIKE ? ESCAPE '\' OR
+				adverts.description LIKE ? ESCAPE '\')`)
+			pattern := "%" + likeEscaper.Replace(word) + "%"
+			args = append(args, pattern, pattern)
+		}
+	}
+
+	if indexed {
+		from = "adverts_fts JOIN adverts ON adverts.id = adverts_fts.rowid"
+		snippet = "snippet(adverts_fts, -1, '<b>', '</b>', '...', 16)"
+		conditions = append(conditions, "adverts_fts MATCH ?")
+		args = append(args, search)
+		// search results are ranked by relevance unless sorting is requested,
+		// matches in name weigh more than matches in description
+		if !sorted {
+			orderBy = "bm25(adverts_fts, 10.0, 1.0)"
+		}
+	}
+
+	if filter, ok := ctx.Value(entity.KeyFilter).(entity.Filter); ok {
+		conditions, args = filterConditions(filter, conditions, args)
+	}
+
+	tx, err := ar.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return page, fmt.Errorf("fetch - Begin: %w", err)
+	}
+	defer func() {
+		err = tx.Rollback()
+	}()
+
+	// total count is not affected by cursor
+	err = tx.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM %v %v`,
+		from, whereClause(conditions)), args...).Scan(&page.TotalCount)
+	if err != nil {
+		return page, fmt.Errorf("fetch - Count: %w", err)
+	}
+
+	// page is taken either after cursor or by offset
+	page.PageSize = limit
+	if cursor, ok := ctx.Value(entity.KeyCursor).(entity.Cursor); ok {
+		condition, conditionArgs, err := keysetCondition(order, cursor)
+		if err != nil {
+			return page, fmt.Errorf("fetch - %w", err)
+		}
+		conditions = append(conditions, condition)
+		args = append(args, conditionArgs...)
+		offset = 0
+	} else {
+		page.Page = int64(offset/limit) + 1
+	}
+
+	// one extra row shows if there is next page
 	query := fmt.Sprintf(
-		`SELECT name, price, photo_url,
-		(SELECT COUNT(*) FROM adverts) AS count
-		FROM adverts
-		WHERE oid NOT IN (SELECT oid FROM adverts ORDER BY %v %v LIMIT %d)
-		ORDER BY %v %v LIMIT %d`,
-		sortBy, orderBy, offset, sortBy, orderBy, limit)
+		`SELECT adverts.id, %v, %v, %v, %v, %v, %v, %v, %v, adverts.deleted_at, %v
+		FROM %v %v
+		ORDER BY %v LIMIT ? OFFSET ?`,
+		column(fields, "name", "adverts.name"),
+		column(fields, "description", "adverts.description"),
+		column(fields, "price", "adverts.price"),
+		column(fields, "main_photo_url", "adverts.photo_url"),
+		column(fields, "category_id", "adverts.category_id"),
+		column(fields, "attributes", "adverts.attributes"),
+		column(fields, "owner_id", "adverts.owner_id"),
+		column(fields, "created_at", "adverts.created_at"),
+		snippet, from, whereClause(conditions), orderBy)
+	args = append(args, limit+1, offset)
 
-	rows, err := ar.DB.QueryContext(ctx, query)
+	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
-		return adverts, fmt.Errorf("AdvertsRepo - Fetch - QueryContext: %w", err)
+		return page, fmt.Errorf("fetch - QueryContext: %w", err)
 	}
 
 	defer rows.Close()
 
 	for rows.Next() {
 		var advert entity.Advert
+		var name sql.NullString
+		var description sql.NullString
 		var price sql.NullInt64
 		var url sql.NullString
-		var count sql.NullInt64
+		var categoryId sql.NullInt64
+		var attributes sql.NullString
+		var ownerId sql.NullInt64
+		var createdAt sql.NullString
+		var deletedAt sql.NullString
+		var snippet sql.NullString
 
-		err = rows.Scan(&advert.Name, &price, &url, &count)
+		err = rows.Scan(&advert.Id, &name, &description, &price, &url, &categoryId,
+			&attributes, &ownerId, &createdAt, &deletedAt, &snippet)
 		if err != nil {
-			return adverts, fmt.Errorf("AdvertsRepo - Fetch - Scan: %w", err)
+			return page, fmt.Errorf("fetch - Scan: %w", err)
 		}
-		advert.MaxCount = count.Int64
+		advert.Name = name.String
+		advert.Description = description.String
 		advert.Price = price.Int64
 		advert.MainPhotoUrl = url.String
-		adverts = append(adverts, advert)
+		advert.CategoryId = categoryId.Int64
+		advert.Attributes = jsonValue(attributes)
+		advert.OwnerId = ownerId.Int64
+		advert.CreatedAt = createdAt.String
+		advert.DeletedAt = deletedAt.String
+		advert.Snippet = snippet.String
+		page.Adverts = append(page.Adverts, advert)
+	}
+	if err = rows.Err(); err != nil {
+		return page, fmt.Errorf("fetch - Rows: %w", err)
 	}
 
-	if len(adverts) == 0 {
-		return adverts, nil
+	if len(page.Adverts) > limit {
+		page.Adverts = page.Adverts[:limit]
+		page.HasNext = true
 	}
 
-	count := adverts[0].MaxCount
-	if count%int64(limit) != 0 {
-		adverts[0].MaxCount = count/int64(limit) + 1
-	} else {
-		adverts[0].MaxCount = count / int64(limit)
+	if fields.Has("photo_urls") {
+		err = ar.getAdvertsUrls(ctx, tx, page.Adverts)
+		if err != nil {
+			return page, fmt.Errorf("fetch - %w", err)
+		}
 	}
-	return adverts, nil
+
+	err = tx.Commit()
+	if err != nil {
+		return page, fmt.Errorf("fetch - Commit: %w", err)
+	}
+
+	return page, nil
+}
+
+// advertColumns lists columns of advert read by scanAdvert, columns of fields
+// which are not selected are not read, owner, visibility and version are
+// always read as they are needed to authorize access
+func advertColumns(fields entity.Fields) string {
+	return fmt.Sprintf(`id, %v, %v, %v, %v, %v, %v, owner_id, hidden_at, version, %v`,
+		column(fields, "name", "name"), column(fields, "description", "description"),
+		column(fields, "price", "price"), column(fields, "main_photo_url", "photo_url"),
+		column(fields, "category_id", "category_id"), column(fields, "attributes", "attributes"),
+		column(fields, "created_at", "created_at"))
+}
+
+// scanner is single row or current row of rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAdvert scans row of columns given by advertColumns
+func scanAdvert(row scanner) (entity.Advert, error) {
+	advert := entity.Advert{}
+	var name sql.NullString
+	var description sql.NullString
+	var price sql.NullInt64
+	var url sql.NullString
+	var categoryId sql.NullInt64
+	var attributes sql.NullString
+	var ownerId sql.NullInt64
+	var hiddenAt sql.NullString
+	var createdAt sql.NullString
+
+	err := row.Scan(&advert.Id, &name, &description, &price, &url, &categoryId,
+		&attributes, &ownerId, &hiddenAt, &advert.Version, &createdAt)
+	if err != nil {
+		return advert, fmt.Errorf("scanAdvert - Scan: %w", err)
+	}
+
+	advert.Name = name.String
+	advert.Description = description.String
+	advert.Price = price.Int64
+	advert.MainPhotoUrl = url.String
+	advert.CategoryId = categoryId.Int64
+	advert.Attributes = jsonValue(attributes)
+	advert.OwnerId = ownerId.Int64
+	advert.HiddenAt = hiddenAt.String
+	advert.CreatedAt = createdAt.String
+	return advert, nil
+}
+
+// column returns column of advert's field if field is selected and NULL
+// otherwise, so that columns client does not need are not read
+func column(fields entity.Fields, field, column string) string {
+	if fields.Has(field) {
+		return column
+	}
+	return "NULL"
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+// orderClause returns ORDER BY list of sort, columns are
+// taken only from known sort fields
+func orderClause(order entity.Sort) (string, error) {
+	columns := []string{}
+	for _, key := range order {
+		field, ok := entity.SortFields[key.Field]
+		if !ok {
+			return "", fmt.Errorf("orderClause - unknown sort key: %v", key.Field)
+		}
+		direction := "ASC"
+		if key.Desc {
+			direction = "DESC"
+		}
+		columns = append(columns, fmt.Sprintf("adverts.%v %v", field.Column, direction))
+	}
+	return strings.Join(columns, ", "), nil
+}
+
+// keysetCondition returns condition selecting adverts placed after
+// cursor in given sort, every key is compared when all keys before
+// it are equal to values of cursor
+func keysetCondition(order entity.Sort, cursor entity.Cursor) (string, []interface{}, error) {
+	after, err := cursor.Advert(order)
+	if err != nil {
+		return "", nil, fmt.Errorf("keysetCondition - Advert: %w", err)
+	}
+
+	alternatives := []string{}
+	args := []interface{}{}
+	for i, key := range order {
+		parts := []string{}
+		for _, prev := range order[:i] {
+			field := entity.SortFields[prev.Field]
+			parts = append(parts, fmt.Sprintf("adverts.%v = ?", field.Column))
+			args = append(args, field.Value(after))
+		}
+		sign := ">"
+		if key.Desc {
+			sign = "<"
+		}
+		field := entity.SortFields[key.Field]
+		parts = append(parts, fmt.Sprintf("adverts.%v %v ?", field.Column, sign))
+		args = append(args, field.Value(after))
+		alternatives = append(alternatives, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args, nil
+}
+
+// filterConditions appends WHERE conditions with their arguments
+// for every filter's field that is set
+func filterConditions(filter entity.Filter, conditions []string,
+	args []interface{}) ([]string, []interface{}) {
+
+	if filter.PriceMin != nil {
+		conditions = append(conditions, "adverts.price >= ?")
+		args = append(args, *filter.PriceMin)
+	}
+	if filter.PriceMax != nil {
+		conditions = append(conditions, "adverts.price <= ?")
+		args = append(args, *filter.PriceMax)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		conditions = append(conditions, "adverts.created_at > ?")
+		args = append(args, filter.CreatedAfter.Local().Format(dateFormat))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		conditions = append(conditions, "adverts.created_at < ?")
+		args = append(args, filter.CreatedBefore.Local().Format(dateFormat))
+	}
+	if filter.NamePrefix != "" {
+		conditions = append(conditions, `adverts.name LIKE ? ESCAPE '\'`)
+		args = append(args, likeEscaper.Replace(filter.NamePrefix)+"%")
+	}
+	if filter.CategoryId != 0 {
+		conditions = append(conditions, "adverts.category_id IN ("+subtreeQuery+")")
+		args = append(args, filter.CategoryId)
+	}
+	if filter.OwnerId != 0 {
+		conditions = append(conditions, "adverts.owner_id = ?")
+		args = append(args, filter.OwnerId)
+	}
+	for _, attr := range filter.Attributes {
+		path := `$."` + attr.Name + `"`
+		if attr.Min != nil {
+			conditions = append(conditions, "json_type(adverts.attributes, ?) IN ('integer', 'real') "+
+				"AND json_extract(adverts.attributes, ?) >= ?")
+			args = append(args, path, path, *attr.Min)
+		}
+		if attr.Max != nil {
+			conditions = append(conditions, "json_type(adverts.attributes, ?) IN ('integer', 'real') "+
+				"AND json_extract(adverts.attributes, ?) <= ?")
+			args = append(args, path, path, *attr.Max)
+		}
+		if attr.Equal != nil {
+			conditions = append(conditions, "json_extract(adverts.attributes, ?) = json_extract(?, '$')")
+			args = append(args, path, string(attr.Equal))
+		}
+	}
+
+	return conditions, args
+}
+
+// searchIndexed tells if search index is kept up to date, its triggers
+// are dropped when database is opened by build without FTS5
+func (ar *AdvertsRepo) searchIndexed(ctx context.Context) (bool, error) {
+	var exists bool
+	err := ar.DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM sqlite_master
+		WHERE type = 'trigger' AND name = 'adverts_fts_insert')`).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("searchIndexed - QueryRowContext: %w", err)
+	}
+	return exists, nil
+}
+
+// ftsQuery turns user input into FTS5 query, every word is quoted
+// so that operators and special characters are matched literally
+func ftsQuery(input string) string {
+	words := strings.Fields(input)
+	for i := 0; i < len(words); i++ {
+		words[i] = `"` + strings.ReplaceAll(words[i], `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
 }
 
 func (ar *AdvertsRepo) getUrls(ctx context.Context, tx *sql.Tx,
@@ -219,39 +656,102 @@ func (ar *AdvertsRepo) getUrls(ctx context.Context, tx *sql.Tx,
 	return urls, nil
 }
 
+// getAdvertsUrls sets photo urls of listed adverts with one query
+func (ar *AdvertsRepo) getAdvertsUrls(ctx context.Context, tx *sql.Tx,
+	adverts []entity.Advert) error {
+	if len(adverts) == 0 {
+		return nil
+	}
+	index := map[int64]int{}
+	placeholders := []string{}
+	args := []interface{}{}
+	for i, adv := range adverts {
+		index[adv.Id] = i
+		placeholders = append(placeholders, "?")
+		args = append(args, adv.Id)
+	}
+
+	query := fmt.Sprintf(`SELECT advert_id, url
+		FROM photo_urls
+		WHERE advert_id IN (%v)`, strings.Join(placeholders, ", "))
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("getAdvertsUrls - QueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var advertId int64
+		var url sql.NullString
+		err = rows.Scan(&advertId, &url)
+		if err != nil {
+			return fmt.Errorf("getAdvertsUrls - Scan: %w", err)
+		}
+		adv := &adverts[index[advertId]]
+		adv.PhotosUrls = append(adv.PhotosUrls, url.String)
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("getAdvertsUrls - Rows: %w", err)
+	}
+
+	return nil
+}
+
 func (ar *AdvertsRepo) Update(ctx context.Context, adv entity.Advert) error {
-	tx, err := ar.DB.Begin()
+	tx, err := ar.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("AdvertsRepo - Delete - Begin: %w", err)
+		return fmt.Errorf("AdvertsRepo - Update - Begin: %w", err)
 	}
 	defer func() {
 		err = tx.Rollback()
 	}()
 
+	err = ar.update(ctx, tx, adv)
+	if err != nil {
+		return fmt.Errorf("AdvertsRepo - Update - %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("AdvertsRepo - Update - Commit: %w", err)
+	}
+
+	return nil
+}
+
+// update replaces advert and its photo urls, version 0 means advert is
+// updated regardless of its version
+func (ar *AdvertsRepo) update(ctx context.Context, tx *sql.Tx, adv entity.Advert) error {
 	res, err := tx.ExecContext(ctx,
 		`UPDATE adverts 
-        SET name = ?, description = ?, price = ?, photo_url = ?
-        WHERE id = ? 
-        `, adv.Name, adv.Description, adv.Price, adv.MainPhotoUrl, adv.Id)
+        SET name = ?, description = ?, price = ?, photo_url = ?, category_id = ?,
+        attributes = ?, version = version + 1
+        WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)
+        `, adv.Name, adv.Description, adv.Price, adv.MainPhotoUrl, nullId(adv.CategoryId),
+		nullJson(adv.Attributes), adv.Id, adv.Version, adv.Version)
 
-	if err != nil {
-		return fmt.Errorf("AdvertsRepo - Update - ExecContext: %w", err)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("update - ExecContext: %v: %w", err,
+			entity.ErrNameAlreadyExist)
+	} else if err != nil {
+		return fmt.Errorf("update - ExecContext: %w", err)
 	}
 
 	affected, err := res.RowsAffected()
 	if affected != 1 || err != nil {
-		return entity.ErrItemNotExists
+		return ar.missingOrChanged(ctx, tx, adv.Id)
 	}
 
 	err = ar.updateUrls(ctx, tx, adv)
 
 	if err != nil {
-		return fmt.Errorf("AdvertsRepo - Update - %w", err)
+		return fmt.Errorf("update - %w", err)
 	}
 
-	err = tx.Commit()
+	err = ar.storeRevision(ctx, tx, adv.Id, entity.ActionUpdate)
 	if err != nil {
-		return fmt.Errorf("AdvertsRepo - Delete - Commit: %w", err)
+		return fmt.Errorf("update - %w", err)
 	}
 
 	return nil
@@ -277,8 +777,9 @@ func (ar *AdvertsRepo) updateUrls(ctx context.Context, tx *sql.Tx,
 	return nil
 }
 
-func (ar *AdvertsRepo) Delete(ctx context.Context, id int64) error {
-	tx, err := ar.DB.Begin()
+// Delete moves advert to trash, its photo urls are kept to be restored with it
+func (ar *AdvertsRepo) Delete(ctx context.Context, id, version int64) error {
+	tx, err := ar.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("AdvertsRepo - Delete - Begin: %w", err)
 	}
@@ -286,32 +787,129 @@ func (ar *AdvertsRepo) Delete(ctx context.Context, id int64) error {
 		err = tx.Rollback()
 	}()
 
-	err = ar.deleteAdvert(ctx, tx, id)
+	err = ar.trash(ctx, tx, id, version)
 	if err != nil {
 		return fmt.Errorf("AdvertsRepo - Delete - %w", err)
 	}
 
-	err = ar.deleteUrls(ctx, tx, id)
+	err = tx.Commit()
 	if err != nil {
-		return fmt.Errorf("AdvertsRepo - Delete - %w", err)
+		return fmt.Errorf("AdvertsRepo - Delete - Commit: %w", err)
+	}
+
+	return nil
+}
+
+// trash moves advert to trash
+func (ar *AdvertsRepo) trash(ctx context.Context, tx *sql.Tx, id, version int64) error {
+	res, err := tx.ExecContext(ctx,
+		`UPDATE adverts
+        SET deleted_at = datetime('now', 'localtime'), version = version + 1
+        WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)
+        `, id, version, version)
+
+	if err != nil {
+		return fmt.Errorf("trash - ExecContext: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if affected != 1 || err != nil {
+		return ar.missingOrChanged(ctx, tx, id)
+	}
+
+	err = ar.storeRevision(ctx, tx, id, entity.ActionDelete)
+	if err != nil {
+		return fmt.Errorf("trash - %w", err)
+	}
+
+	return nil
+}
+
+// Batch applies operations in one transaction, if it is not atomic every
+// operation has its own savepoint so that failed one is undone alone
+func (ar *AdvertsRepo) Batch(ctx context.Context, ops []entity.BatchOperation,
+	atomic bool) error {
+	tx, err := ar.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("AdvertsRepo - Batch - Begin: %w", err)
+	}
+	defer func() {
+		err = tx.Rollback()
+	}()
+
+	for i := range ops {
+		if ops[i].Err != nil {
+			continue
+		}
+		if !atomic {
+			if _, err = tx.ExecContext(ctx, `SAVEPOINT operation`); err != nil {
+				return fmt.Errorf("AdvertsRepo - Batch - Savepoint: %w", err)
+			}
+		}
+
+		err = ar.apply(ctx, tx, &ops[i])
+		switch {
+		case err == nil:
+		case errors.Is(err, entity.ErrNameAlreadyExist) || errors.Is(err, entity.ErrItemNotExists) ||
+			errors.Is(err, entity.ErrVersionMismatch):
+			ops[i].Err = err
+			if atomic {
+				entity.AbortBatch(ops)
+				return nil
+			}
+			if _, err = tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT operation`); err != nil {
+				return fmt.Errorf("AdvertsRepo - Batch - Rollback to savepoint: %w", err)
+			}
+		default:
+			return fmt.Errorf("AdvertsRepo - Batch - %w", err)
+		}
+
+		if !atomic {
+			if _, err = tx.ExecContext(ctx, `RELEASE SAVEPOINT operation`); err != nil {
+				return fmt.Errorf("AdvertsRepo - Batch - Release savepoint: %w", err)
+			}
+		}
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		return fmt.Errorf("AdvertsRepo - Delete - Commit: %w", err)
+		return fmt.Errorf("AdvertsRepo - Batch - Commit: %w", err)
 	}
 
 	return nil
 }
 
-func (ar *AdvertsRepo) deleteAdvert(ctx context.Context, tx *sql.Tx, id int64) error {
+// apply runs operation of batch inside tx
+func (ar *AdvertsRepo) apply(ctx context.Context, tx *sql.Tx, op *entity.BatchOperation) error {
+	switch op.Action {
+	case entity.ActionCreate:
+		return ar.store(ctx, tx, &op.Advert)
+	case entity.ActionUpdate:
+		return ar.update(ctx, tx, op.Advert)
+	case entity.ActionDelete:
+		return ar.trash(ctx, tx, op.Advert.Id, op.Advert.Version)
+	}
+	return fmt.Errorf("apply - unknown action '%v'", op.Action)
+}
+
+// Restore takes advert out of trash
+func (ar *AdvertsRepo) Restore(ctx context.Context, id int64) error {
+	tx, err := ar.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("AdvertsRepo - Restore - Begin: %w", err)
+	}
+	defer func() {
+		err = tx.Rollback()
+	}()
+
 	res, err := tx.ExecContext(ctx,
-		`DELETE FROM adverts
-        WHERE id = ?
+		`UPDATE adverts
+        SET deleted_at = NULL, version = version + 1
+        WHERE id = ? AND deleted_at IS NOT NULL
         `, id)
 
 	if err != nil {
-		return fmt.Errorf("deleteAdvert - ExecContext: %w", err)
+		return fmt.Errorf("AdvertsRepo - Restore - ExecContext: %w", err)
 	}
 
 	affected, err := res.RowsAffected()
@@ -319,9 +917,136 @@ func (ar *AdvertsRepo) deleteAdvert(ctx context.Context, tx *sql.Tx, id int64) e
 		return entity.ErrItemNotExists
 	}
 
+	err = ar.storeRevision(ctx, tx, id, entity.ActionRestore)
+	if err != nil {
+		return fmt.Errorf("AdvertsRepo - Restore - %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("AdvertsRepo - Restore - Commit: %w", err)
+	}
+
 	return nil
 }
 
+// Hide hides advert from lists or shows it again, hiding
+// keeps time advert was hidden first
+func (ar *AdvertsRepo) Hide(ctx context.Context, id int64, hidden bool) error {
+	tx, err := ar.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("AdvertsRepo - Hide - Begin: %w", err)
+	}
+	defer func() {
+		err = tx.Rollback()
+	}()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE adverts
+        SET hidden_at = CASE WHEN ? THEN COALESCE(hidden_at, datetime('now', 'localtime'))
+        ELSE NULL END, version = version + 1
+        WHERE id = ? AND deleted_at IS NULL
+        `, hidden, id)
+
+	if err != nil {
+		return fmt.Errorf("AdvertsRepo - Hide - ExecContext: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if affected != 1 || err != nil {
+		return entity.ErrItemNotExists
+	}
+
+	action := entity.ActionUnhide
+	if hidden {
+		action = entity.ActionHide
+	}
+	err = ar.storeRevision(ctx, tx, id, action)
+	if err != nil {
+		return fmt.Errorf("AdvertsRepo - Hide - %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("AdvertsRepo - Hide - Commit: %w", err)
+	}
+
+	return nil
+}
+
+// Purge removes for good adverts which were moved to trash before given time
+func (ar *AdvertsRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	tx, err := ar.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("AdvertsRepo - Purge - Begin: %w", err)
+	}
+	defer func() {
+		err = tx.Rollback()
+	}()
+
+	deletedBefore := before.Local().Format(dateFormat)
+
+	_, err = tx.ExecContext(ctx,
+		`DELETE FROM advert_revisions
+        WHERE advert_id IN (SELECT id FROM adverts
+        WHERE deleted_at IS NOT NULL AND deleted_at < ?)
+        `, deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("AdvertsRepo - Purge - ExecContext: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`DELETE FROM photo_urls
+        WHERE advert_id IN (SELECT id FROM adverts
+        WHERE deleted_at IS NOT NULL AND deleted_at < ?)
+        `, deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("AdvertsRepo - Purge - ExecContext: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx,
+		`DELETE FROM adverts
+        WHERE deleted_at IS NOT NULL AND deleted_at < ?
+        `, deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("AdvertsRepo - Purge - ExecContext: %w", err)
+	}
+
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("AdvertsRepo - Purge - RowsAffected: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("AdvertsRepo - Purge - Commit: %w", err)
+	}
+
+	return purged, nil
+}
+
+// isUniqueViolation tells if query failed because of unique constraint,
+// the only unique column of adverts is name
+func isUniqueViolation(err error) bool {
+	var sqliteErr gosqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == gosqlite3.ErrConstraintUnique
+}
+
+// missingOrChanged tells why advert was not affected by conditional query
+func (ar *AdvertsRepo) missingOrChanged(ctx context.Context, tx *sql.Tx, id int64) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM adverts WHERE id = ? AND deleted_at IS NULL)`,
+		id).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("missingOrChanged - Scan: %w", err)
+	}
+	if exists {
+		return entity.ErrVersionMismatch
+	}
+	return entity.ErrItemNotExists
+}
+
 func (ar *AdvertsRepo) deleteUrls(ctx context.Context, tx *sql.Tx, id int64) error {
 	_, err := tx.ExecContext(ctx,
 		`DELETE FROM photo_urls
@@ -5,12 +5,13 @@ import (
 	"database/sql"
 	"errors"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mrsubudei/adv-store-service/internal/entity"
 	"github.com/mrsubudei/adv-store-service/internal/repository/sqlite"
-	"github.com/mrsubudei/adv-store-service/internal/service"
 )
 
 var (
@@ -53,9 +54,9 @@ var (
 func TestStore(t *testing.T) {
 	db := sqlite.MustOpenDB(t, "file:foobar?mode=memory&cache=shared")
 	defer sqlite.MustCloseDB(t, db)
-	err := sqlite.CreateDB(db)
+	err := sqlite.Migrate(context.Background(), db)
 	if err != nil {
-		t.Fatal("Unable to create db:", err)
+		t.Fatal("Unable to migrate db:", err)
 	}
 	repo := sqlite.NewAdvertsRepo(db)
 	ctx := context.Background()
@@ -79,7 +80,7 @@ func TestStore(t *testing.T) {
 
 		if err = repo.Store(ctx, &advRepeatedName); err == nil {
 			t.Fatalf("Error expected")
-		} else if !strings.Contains(err.Error(), service.UniqueNameConstraint) {
+		} else if !errors.Is(err, entity.ErrNameAlreadyExist) {
 			t.Fatalf("unexpected error: %v", err)
 		}
 	})
@@ -88,9 +89,9 @@ func TestStore(t *testing.T) {
 func TestGetById(t *testing.T) {
 	db := sqlite.MustOpenDB(t, "file:foobar?mode=memory&cache=shared")
 	defer sqlite.MustCloseDB(t, db)
-	err := sqlite.CreateDB(db)
+	err := sqlite.Migrate(context.Background(), db)
 	if err != nil {
-		t.Fatal("Unable to create db:", err)
+		t.Fatal("Unable to migrate db:", err)
 	}
 	repo := sqlite.NewAdvertsRepo(db)
 	ctx := context.Background()
@@ -130,9 +131,9 @@ func TestGetById(t *testing.T) {
 func TestFetch(t *testing.T) {
 	db := sqlite.MustOpenDB(t, "file:foobar?mode=memory&cache=shared")
 	defer sqlite.MustCloseDB(t, db)
-	err := sqlite.CreateDB(db)
+	err := sqlite.Migrate(context.Background(), db)
 	if err != nil {
-		t.Fatal("Unable to create db:", err)
+		t.Fatal("Unable to migrate db:", err)
 	}
 	repo := sqlite.NewAdvertsRepo(db)
 	ctx := context.Background()
@@ -153,8 +154,8 @@ func TestFetch(t *testing.T) {
 
 		if found, err := repo.Fetch(ctx); err != nil {
 			t.Fatal("Unable to Fetch:", err)
-		} else if len(found) != 3 {
-			t.Fatalf("want: %d, got: %d", 3, len(found))
+		} else if len(found.Adverts) != 3 {
+			t.Fatalf("want: %d, got: %d", 3, len(found.Adverts))
 		}
 	})
 }
@@ -162,9 +163,9 @@ func TestFetch(t *testing.T) {
 func TestUpdate(t *testing.T) {
 	db := sqlite.MustOpenDB(t, "file:foobar?mode=memory&cache=shared")
 	defer sqlite.MustCloseDB(t, db)
-	err := sqlite.CreateDB(db)
+	err := sqlite.Migrate(context.Background(), db)
 	if err != nil {
-		t.Fatal("Unable to create db:", err)
+		t.Fatal("Unable to migrate db:", err)
 	}
 	repo := sqlite.NewAdvertsRepo(db)
 	ctx := context.Background()
@@ -191,6 +192,7 @@ func TestUpdate(t *testing.T) {
 		if err := repo.Update(ctx, updatedAdv); err != nil {
 			t.Fatal("Unable to Fetch:", err)
 		}
+		updatedAdv.Version = 2
 
 		if found, err := repo.GetById(ctx, 1); err != nil {
 			t.Fatal("Unable to GetById:", err)
@@ -199,6 +201,26 @@ func TestUpdate(t *testing.T) {
 		}
 	})
 
+	t.Run("Err version mismatch", func(t *testing.T) {
+		updatedAdv := entity.Advert{
+			Id:      1,
+			Name:    "outdated name",
+			Version: 1,
+		}
+
+		if err := repo.Update(ctx, updatedAdv); err == nil {
+			t.Fatal("Error expected")
+		} else if !errors.Is(err, entity.ErrVersionMismatch) {
+			t.Fatalf("want: %v, got: %v", entity.ErrVersionMismatch, err)
+		}
+
+		if err := repo.Delete(ctx, 1, 1); err == nil {
+			t.Fatal("Error expected")
+		} else if !errors.Is(err, entity.ErrVersionMismatch) {
+			t.Fatalf("want: %v, got: %v", entity.ErrVersionMismatch, err)
+		}
+	})
+
 	t.Run("Err item not found", func(t *testing.T) {
 		updatedAdv := entity.Advert{
 			Id:   15,
@@ -216,9 +238,9 @@ func TestUpdate(t *testing.T) {
 func TestDelete(t *testing.T) {
 	db := sqlite.MustOpenDB(t, "file:foobar?mode=memory&cache=shared")
 	defer sqlite.MustCloseDB(t, db)
-	err := sqlite.CreateDB(db)
+	err := sqlite.Migrate(context.Background(), db)
 	if err != nil {
-		t.Fatal("Unable to create db:", err)
+		t.Fatal("Unable to migrate db:", err)
 	}
 	repo := sqlite.NewAdvertsRepo(db)
 	ctx := context.Background()
@@ -235,7 +257,7 @@ func TestDelete(t *testing.T) {
 			t.Fatalf("mismatch: %#v != %#v", advert1, found)
 		}
 
-		if err := repo.Delete(ctx, 1); err != nil {
+		if err := repo.Delete(ctx, 1, 0); err != nil {
 			t.Fatal("Unable to GetById:", err)
 		}
 
@@ -248,7 +270,7 @@ func TestDelete(t *testing.T) {
 
 	t.Run("Err item not found", func(t *testing.T) {
 
-		if err := repo.Delete(ctx, 156); err == nil {
+		if err := repo.Delete(ctx, 156, 0); err == nil {
 			t.Fatal("Error expected")
 		} else if !errors.Is(err, entity.ErrItemNotExists) {
 			t.Fatalf("want: %v, got: %v", entity.ErrItemNotExists, err)
@@ -256,3 +278,520 @@ func TestDelete(t *testing.T) {
 	})
 
 }
+
+func TestFetchSearch(t *testing.T) {
+	db := sqlite.MustOpenDB(t, "file:foobar?mode=memory&cache=shared")
+	defer sqlite.MustCloseDB(t, db)
+	err := sqlite.Migrate(context.Background(), db)
+	if err != nil {
+		t.Fatal("Unable to migrate db:", err)
+	}
+	var enabled bool
+	if err := db.DB.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).
+		Scan(&enabled); err != nil {
+		t.Fatal(err)
+	} else if !enabled {
+		t.Skip("FTS5 is not enabled, build with '-tags sqlite_fts5'")
+	}
+	repo := sqlite.NewAdvertsRepo(db)
+	ctx := context.Background()
+
+	for _, adv := range []entity.Advert{advert1, advert2, advert3} {
+		adv := adv
+		if err := repo.Store(ctx, &adv); err != nil {
+			t.Fatal("Unable to store:", err)
+		}
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		ctx := context.WithValue(ctx, entity.KeyQuery, "amet")
+		if found, err := repo.Fetch(ctx); err != nil {
+			t.Fatal("Unable to Fetch:", err)
+		} else if len(found.Adverts) != 1 {
+			t.Fatalf("want: %d, got: %d", 1, len(found.Adverts))
+		} else if found.Adverts[0].Name != advert1.Name {
+			t.Fatalf("want: %v, got: %v", advert1.Name, found.Adverts[0].Name)
+		} else if !strings.Contains(found.Adverts[0].Snippet, "<b>amet</b>") {
+			t.Fatalf("snippet is not highlighted: %v", found.Adverts[0].Snippet)
+		}
+	})
+
+	t.Run("OK ranked by name first", func(t *testing.T) {
+		updated := advert3
+		updated.Id = 3
+		updated.Name = "dolor suit"
+		updated.MainPhotoUrl = updated.PhotosUrls[0]
+		if err := repo.Update(ctx, updated); err != nil {
+			t.Fatal("Unable to Update:", err)
+		}
+
+		ctx := context.WithValue(ctx, entity.KeyQuery, "dolor")
+		if found, err := repo.Fetch(ctx); err != nil {
+			t.Fatal("Unable to Fetch:", err)
+		} else if len(found.Adverts) != 3 {
+			t.Fatalf("want: %d, got: %d", 3, len(found.Adverts))
+		} else if found.Adverts[0].Name != updated.Name {
+			t.Fatalf("want: %v, got: %v", updated.Name, found.Adverts[0].Name)
+		}
+	})
+
+	t.Run("OK special characters", func(t *testing.T) {
+		ctx := context.WithValue(ctx, entity.KeyQuery, `toy" OR "car*`)
+		if found, err := repo.Fetch(ctx); err != nil {
+			t.Fatal("Unable to Fetch:", err)
+		} else if len(found.Adverts) != 0 {
+			t.Fatalf("want: %d, got: %d", 0, len(found.Adverts))
+		}
+	})
+}
+
+func TestFetchSearchFallback(t *testing.T) {
+	db := sqlite.MustOpenDB(t, "file:foobar?mode=memory&cache=shared")
+	defer sqlite.MustCloseDB(t, db)
+	err := sqlite.Migrate(context.Background(), db)
+	if err != nil {
+		t.Fatal("Unable to migrate db:", err)
+	}
+	var enabled bool
+	if err := db.DB.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).
+		Scan(&enabled); err != nil {
+		t.Fatal(err)
+	} else if enabled {
+		t.Skip("FTS5 is enabled, fallback search is not used")
+	}
+	repo := sqlite.NewAdvertsRepo(db)
+	ctx := context.Background()
+
+	for _, adv := range []entity.Advert{advert1, advert2, advert3} {
+		adv := adv
+		if err := repo.Store(ctx, &adv); err != nil {
+			t.Fatal("Unable to store:", err)
+		}
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		ctx := context.WithValue(ctx, entity.KeyQuery, "amet")
+		if found, err := repo.Fetch(ctx); err != nil {
+			t.Fatal("Unable to Fetch:", err)
+		} else if len(found.Adverts) != 1 {
+			t.Fatalf("want: %d, got: %d", 1, len(found.Adverts))
+		} else if found.Adverts[0].Name != advert1.Name {
+			t.Fatalf("want: %v, got: %v", advert1.Name, found.Adverts[0].Name)
+		}
+	})
+
+	t.Run("OK special characters", func(t *testing.T) {
+		ctx := context.WithValue(ctx, entity.KeyQuery, `100%_`)
+		if found, err := repo.Fetch(ctx); err != nil {
+			t.Fatal("Unable to Fetch:", err)
+		} else if len(found.Adverts) != 0 {
+			t.Fatalf("want: %d, got: %d", 0, len(found.Adverts))
+		}
+	})
+}
+
+func TestFetchFilter(t *testing.T) {
+	db := sqlite.MustOpenDB(t, "file:foobar?mode=memory&cache=shared")
+	defer sqlite.MustCloseDB(t, db)
+	err := sqlite.Migrate(context.Background(), db)
+	if err != nil {
+		t.Fatal("Unable to migrate db:", err)
+	}
+	repo := sqlite.NewAdvertsRepo(db)
+	ctx := context.Background()
+
+	dates := []string{"2023-01-10 10:00:00", "2023-02-10 10:00:00", "2023-03-10 10:00:00"}
+	for i, adv := range []entity.Advert{advert1, advert2, advert3} {
+		adv := adv
+		adv.CreatedAt = dates[i]
+		if err := repo.Store(ctx, &adv); err != nil {
+			t.Fatal("Unable to store:", err)
+		}
+	}
+	if err := repo.Store(ctx, &entity.Advert{Name: "car_100%", Price: 10,
+		PhotosUrls: []string{"http:fs.com/11"}}); err != nil {
+		t.Fatal("Unable to store:", err)
+	}
+
+	priceMin := int64(60)
+	priceMax := int64(100)
+
+	tests := []struct {
+		name      string
+		filter    entity.Filter
+		wantNames []string
+	}{
+		{
+			name:      "OK price range",
+			filter:    entity.Filter{PriceMin: &priceMin, PriceMax: &priceMax},
+			wantNames: []string{"toy"},
+		},
+		{
+			name: "OK created range",
+			filter: entity.Filter{
+				CreatedAfter:  time.Date(2023, 1, 10, 10, 0, 0, 0, time.Local),
+				CreatedBefore: time.Date(2023, 3, 10, 0, 0, 0, 0, time.Local),
+			},
+			wantNames: []string{"toy"},
+		},
+		{
+			name:      "OK name prefix",
+			filter:    entity.Filter{NamePrefix: "CA"},
+			wantNames: []string{"car", "car_100%"},
+		},
+		{
+			name:      "OK name prefix with wildcards",
+			filter:    entity.Filter{NamePrefix: "car_"},
+			wantNames: []string{"car_100%"},
+		},
+		{
+			name:      "OK nothing found",
+			filter:    entity.Filter{PriceMin: &priceMin, NamePrefix: "suit"},
+			wantNames: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.WithValue(ctx, entity.KeyFilter, tt.filter)
+			found, err := repo.Fetch(ctx)
+			if err != nil {
+				t.Fatal("Unable to Fetch:", err)
+			}
+			names := []string{}
+			for _, adv := range found.Adverts {
+				names = append(names, adv.Name)
+			}
+			if !reflect.DeepEqual(tt.wantNames, names) {
+				t.Fatalf("want: %v, got: %v", tt.wantNames, names)
+			}
+		})
+	}
+}
+
+func TestFetchCursor(t *testing.T) {
+	db := sqlite.MustOpenDB(t, "file:foobar?mode=memory&cache=shared")
+	defer sqlite.MustCloseDB(t, db)
+	err := sqlite.Migrate(context.Background(), db)
+	if err != nil {
+		t.Fatal("Unable to migrate db:", err)
+	}
+	repo := sqlite.NewAdvertsRepo(db)
+	ctx := context.Background()
+
+	prices := []int64{50, 20, 50, 10, 50}
+	for i, price := range prices {
+		adv := entity.Advert{Name: "advert " + strconv.Itoa(i+1), Price: price,
+			PhotosUrls: []string{"http:fs.com/1"}}
+		if err := repo.Store(ctx, &adv); err != nil {
+			t.Fatal("Unable to store:", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		sortBy  string
+		orderBy string
+		wantIds []int64
+	}{
+		{
+			name:    "OK by id",
+			orderBy: "asc",
+			wantIds: []int64{1, 2, 3, 4, 5},
+		},
+		{
+			name:    "OK by id desc",
+			orderBy: "desc",
+			wantIds: []int64{5, 4, 3, 2, 1},
+		},
+		{
+			name:    "OK by price",
+			sortBy:  "price",
+			orderBy: "asc",
+			wantIds: []int64{4, 2, 1, 3, 5},
+		},
+		{
+			name:    "OK by price desc",
+			sortBy:  "price",
+			orderBy: "desc",
+			wantIds: []int64{5, 3, 1, 2, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := tt.sortBy
+			if spec == "" {
+				spec = "id"
+			}
+			if tt.orderBy == "desc" {
+				spec = "-" + spec
+			}
+			order, err := entity.ParseSort(spec)
+			if err != nil {
+				t.Fatal("Unable to parse sort:", err)
+			}
+			ctx := context.WithValue(ctx, entity.KeyLimit, 2)
+			ctx = context.WithValue(ctx, entity.KeySort, order)
+
+			ids := []int64{}
+			pageCtx := ctx
+			for page := 0; page < len(prices); page++ {
+				found, err := repo.Fetch(pageCtx)
+				if err != nil {
+					t.Fatal("Unable to Fetch:", err)
+				}
+				for _, adv := range found.Adverts {
+					ids = append(ids, adv.Id)
+				}
+				if !found.HasNext {
+					break
+				}
+				last := found.Adverts[len(found.Adverts)-1]
+				pageCtx = context.WithValue(ctx, entity.KeyCursor, entity.NewCursor(last, order))
+			}
+
+			if !reflect.DeepEqual(tt.wantIds, ids) {
+				t.Fatalf("want: %v, got: %v", tt.wantIds, ids)
+			}
+		})
+	}
+}
+
+func TestTrash(t *testing.T) {
+	db := sqlite.MustOpenDB(t, "file:foobar?mode=memory&cache=shared")
+	defer sqlite.MustCloseDB(t, db)
+	err := sqlite.Migrate(context.Background(), db)
+	if err != nil {
+		t.Fatal("Unable to migrate db:", err)
+	}
+	repo := sqlite.NewAdvertsRepo(db)
+	ctx := context.Background()
+
+	for _, adv := range []entity.Advert{advert1, advert2} {
+		adv := adv
+		if err := repo.Store(ctx, &adv); err != nil {
+			t.Fatal("Unable to store:", err)
+		}
+	}
+	if err := repo.Delete(ctx, 1, 0); err != nil {
+		t.Fatal("Unable to delete:", err)
+	}
+
+	t.Run("Deleted advert is hidden", func(t *testing.T) {
+		if _, err := repo.GetById(ctx, 1); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("want: %v, got: %v", sql.ErrNoRows, err)
+		}
+		if page, err := repo.Fetch(ctx); err != nil {
+			t.Fatal("Unable to fetch:", err)
+		} else if len(page.Adverts) != 1 || page.Adverts[0].Id != 2 {
+			t.Fatalf("want only advert 2, got: %#v", page.Adverts)
+		}
+		if err := repo.Update(ctx, entity.Advert{Id: 1, Name: "new",
+			PhotosUrls: []string{"http:fs.com/1"}}); !errors.Is(err, entity.ErrItemNotExists) {
+			t.Fatalf("want: %v, got: %v", entity.ErrItemNotExists, err)
+		}
+	})
+
+	t.Run("Deleted advert is in trash", func(t *testing.T) {
+		page, err := repo.FetchDeleted(ctx)
+		if err != nil {
+			t.Fatal("Unable to fetch deleted:", err)
+		}
+		if len(page.Adverts) != 1 || page.Adverts[0].Id != 1 || page.Adverts[0].DeletedAt == "" {
+			t.Fatalf("want deleted advert 1, got: %#v", page.Adverts)
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		if err := repo.Restore(ctx, 1); err != nil {
+			t.Fatal("Unable to restore:", err)
+		}
+		if found, err := repo.GetById(ctx, 1); err != nil {
+			t.Fatal("Unable to GetById:", err)
+		} else if found.Version != 3 {
+			t.Fatalf("want version: %v, got: %v", 3, found.Version)
+		}
+		if err := repo.Restore(ctx, 1); !errors.Is(err, entity.ErrItemNotExists) {
+			t.Fatalf("want: %v, got: %v", entity.ErrItemNotExists, err)
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		if err := repo.Delete(ctx, 2, 0); err != nil {
+			t.Fatal("Unable to delete:", err)
+		}
+		if purged, err := repo.Purge(ctx, time.Now().Add(-time.Hour)); err != nil {
+			t.Fatal("Unable to purge:", err)
+		} else if purged != 0 {
+			t.Fatalf("want purged: %v, got: %v", 0, purged)
+		}
+		if purged, err := repo.Purge(ctx, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal("Unable to purge:", err)
+		} else if purged != 1 {
+			t.Fatalf("want purged: %v, got: %v", 1, purged)
+		}
+		if err := repo.Restore(ctx, 2); !errors.Is(err, entity.ErrItemNotExists) {
+			t.Fatalf("want: %v, got: %v", entity.ErrItemNotExists, err)
+		}
+	})
+}
+
+func TestRevisions(t *testing.T) {
+	db := sqlite.MustOpenDB(t, "file:foobar?mode=memory&cache=shared")
+	defer sqlite.MustCloseDB(t, db)
+	err := sqlite.Migrate(context.Background(), db)
+	if err != nil {
+		t.Fatal("Unable to migrate db:", err)
+	}
+	repo := sqlite.NewAdvertsRepo(db)
+	ctx := context.Background()
+
+	adv := advert1
+	if err := repo.Store(ctx, &adv); err != nil {
+		t.Fatal("Unable to store:", err)
+	}
+	updated := adv
+	updated.Price = 200
+	updated.PhotosUrls = []string{"http:fs.com/4"}
+	updated.MainPhotoUrl = "http:fs.com/4"
+	if err := repo.Update(ctx, updated); err != nil {
+		t.Fatal("Unable to update:", err)
+	}
+	if err := repo.Delete(ctx, adv.Id, 0); err != nil {
+		t.Fatal("Unable to delete:", err)
+	}
+	if err := repo.Restore(ctx, adv.Id); err != nil {
+		t.Fatal("Unable to restore:", err)
+	}
+
+	t.Run("OK revisions", func(t *testing.T) {
+		revisions, err := repo.GetRevisions(ctx, adv.Id)
+		if err != nil {
+			t.Fatal("Unable to get revisions:", err)
+		}
+		actions := []string{}
+		for i, revision := range revisions {
+			if revision.Revision != int64(i+1) {
+				t.Fatalf("want revision: %v, got: %v", i+1, revision.Revision)
+			}
+			actions = append(actions, revision.Action)
+		}
+		want := []string{entity.ActionCreate, entity.ActionUpdate,
+			entity.ActionDelete, entity.ActionRestore}
+		if !reflect.DeepEqual(want, actions) {
+			t.Fatalf("want: %v, got: %v", want, actions)
+		}
+	})
+
+	t.Run("OK revision", func(t *testing.T) {
+		revision, err := repo.GetRevision(ctx, adv.Id, 1)
+		if err != nil {
+			t.Fatal("Unable to get revision:", err)
+		}
+		want := adv
+		want.Version = 0
+		want.CreatedAt = ""
+		if !reflect.DeepEqual(want, revision.Advert) {
+			t.Fatalf("mismatch: %#v != %#v", want, revision.Advert)
+		}
+	})
+
+	t.Run("Err revision not found", func(t *testing.T) {
+		if _, err := repo.GetRevision(ctx, adv.Id, 5); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("want: %v, got: %v", sql.ErrNoRows, err)
+		}
+	})
+}
+
+// TestSingleConnection checks that repositories do not query DB while their
+// transaction is open, on single connection such query waits for it forever
+func TestSingleConnection(t *testing.T) {
+	db := sqlite.MustOpenDB(t, "file:single?mode=memory&cache=shared")
+	defer sqlite.MustCloseDB(t, db)
+	if err := sqlite.Migrate(context.Background(), db); err != nil {
+		t.Fatal("Unable to migrate db:", err)
+	}
+	if max := db.DB.Stats().MaxOpenConnections; max != 1 {
+		t.Fatalf("want single connection, got: %v", max)
+	}
+	repo := sqlite.NewAdvertsRepo(db)
+	categories := sqlite.NewCategoriesRepo(db)
+
+	adv := advert1
+	other := advert2
+	cat := entity.Category{Name: "toys"}
+	listCtx := func(ctx context.Context) context.Context {
+		ctx = context.WithValue(ctx, entity.KeyQuery, "lorem")
+		ctx = context.WithValue(ctx, entity.KeyFields, entity.Fields{"photo_urls": true})
+		return context.WithValue(ctx, entity.KeyFilter, entity.Filter{PriceMin: &adv.Price})
+	}
+	calls := []struct {
+		name string
+		call func(ctx context.Context) error
+	}{
+		{"Store", func(ctx context.Context) error { return repo.Store(ctx, &adv) }},
+		{"GetById", func(ctx context.Context) error {
+			_, err := repo.GetById(ctx, adv.Id)
+			return err
+		}},
+		{"GetByIds", func(ctx context.Context) error {
+			_, err := repo.GetByIds(ctx, []int64{adv.Id})
+			return err
+		}},
+		{"Fetch", func(ctx context.Context) error {
+			_, err := repo.Fetch(listCtx(ctx))
+			return err
+		}},
+		{"Update", func(ctx context.Context) error {
+			adv.Price++
+			return repo.Update(ctx, adv)
+		}},
+		{"Hide", func(ctx context.Context) error { return repo.Hide(ctx, adv.Id, true) }},
+		{"Unhide", func(ctx context.Context) error { return repo.Hide(ctx, adv.Id, false) }},
+		{"Batch", func(ctx context.Context) error {
+			return repo.Batch(ctx, []entity.BatchOperation{
+				{Action: entity.ActionCreate, Advert: other},
+			}, true)
+		}},
+		{"Delete", func(ctx context.Context) error { return repo.Delete(ctx, adv.Id, 0) }},
+		{"FetchDeleted", func(ctx context.Context) error {
+			_, err := repo.FetchDeleted(listCtx(ctx))
+			return err
+		}},
+		{"GetDeletedById", func(ctx context.Context) error {
+			_, err := repo.GetDeletedById(ctx, adv.Id)
+			return err
+		}},
+		{"Restore", func(ctx context.Context) error { return repo.Restore(ctx, adv.Id) }},
+		{"GetRevisions", func(ctx context.Context) error {
+			_, err := repo.GetRevisions(ctx, adv.Id)
+			return err
+		}},
+		{"Purge", func(ctx context.Context) error {
+			_, err := repo.Purge(ctx, time.Now())
+			return err
+		}},
+		{"Store category", func(ctx context.Context) error { return categories.Store(ctx, &cat) }},
+		{"Fetch categories", func(ctx context.Context) error {
+			_, err := categories.Fetch(ctx)
+			return err
+		}},
+		{"Update category", func(ctx context.Context) error {
+			cat.Name = "games"
+			return categories.Update(ctx, cat)
+		}},
+		{"Delete category", func(ctx context.Context) error { return categories.Delete(ctx, cat.Id) }},
+	}
+
+	for _, c := range calls {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		err := c.call(ctx)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("%v: queries DB while its transaction holds connection: %v", c.name, err)
+		} else if err != nil {
+			t.Fatalf("%v: %v", c.name, err)
+		}
+	}
+}
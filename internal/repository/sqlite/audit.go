@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/pkg/sqlite3"
+)
+
+type AuditRepo struct {
+	*sqlite3.Sqlite
+}
+
+func NewAuditRepo(sq *sqlite3.Sqlite) *AuditRepo {
+	return &AuditRepo{sq}
+}
+
+func (ar *AuditRepo) Store(ctx context.Context, entry *entity.AuditEntry) error {
+	res, err := ar.DB.ExecContext(ctx,
+		`INSERT INTO audit_log(user_id, api_key_id, action, resource, allowed, created_at)
+		values(?, ?, ?, ?, ?, ?)`,
+		nullId(entry.UserId), nullId(entry.ApiKeyId), entry.Action, entry.Resource,
+		entry.Allowed, entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("AuditRepo - Store - ExecContext: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("AuditRepo - Store - LastInsertId: %w", err)
+	}
+	entry.Id = id
+
+	return nil
+}
+
+func (ar *AuditRepo) Fetch(ctx context.Context) ([]entity.AuditEntry, error) {
+	entries := []entity.AuditEntry{}
+	limit, offset := entity.DefaultLimit, 0
+	if val, ok := ctx.Value(entity.KeyLimit).(int); ok && val != 0 {
+		limit = val
+	}
+	if val, ok := ctx.Value(entity.KeyOffset).(int); ok && val != 0 {
+		offset = val
+	}
+
+	rows, err := ar.DB.QueryContext(ctx,
+		`SELECT id, user_id, api_key_id, action, resource, allowed, created_at
+		FROM audit_log ORDER BY id DESC LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return entries, fmt.Errorf("AuditRepo - Fetch - QueryContext: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry entity.AuditEntry
+		var userId, apiKeyId sql.NullInt64
+		var createdAt sql.NullString
+		err = rows.Scan(&entry.Id, &userId, &apiKeyId, &entry.Action, &entry.Resource,
+			&entry.Allowed, &createdAt)
+		if err != nil {
+			return entries, fmt.Errorf("AuditRepo - Fetch - Scan: %w", err)
+		}
+		entry.UserId = userId.Int64
+		entry.ApiKeyId = apiKeyId.Int64
+		entry.CreatedAt = createdAt.String
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return entries, fmt.Errorf("AuditRepo - Fetch - Rows: %w", err)
+	}
+
+	return entries, nil
+}
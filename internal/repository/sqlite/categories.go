@@ -0,0 +1,254 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/pkg/sqlite3"
+)
+
+// subtreeQuery selects id of category given as parameter and ids of all its
+// descendants, UNION stops recursion even if categories somehow make a cycle
+const subtreeQuery = `WITH RECURSIVE subtree(id) AS (
+	SELECT id FROM categories WHERE id = ?
+	UNION
+	SELECT categories.id FROM categories
+	JOIN subtree ON categories.parent_id = subtree.id
+	)
+	SELECT id FROM subtree`
+
+type CategoriesRepo struct {
+	*sqlite3.Sqlite
+}
+
+func NewCategoriesRepo(sq *sqlite3.Sqlite) *CategoriesRepo {
+	return &CategoriesRepo{sq}
+}
+
+func (cr *CategoriesRepo) Store(ctx context.Context, cat *entity.Category) error {
+	tx, err := cr.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("CategoriesRepo - Store - Begin: %w", err)
+	}
+	defer func() {
+		err = tx.Rollback()
+	}()
+
+	if err = checkParent(ctx, tx, 0, cat.ParentId); err != nil {
+		return fmt.Errorf("CategoriesRepo - Store - %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO categories(name, parent_id, schema) values(?, ?, ?)`,
+		cat.Name, nullId(cat.ParentId), nullJson(cat.Schema))
+	if err != nil {
+		return fmt.Errorf("CategoriesRepo - Store - ExecContext: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("CategoriesRepo - Store - LastInsertId: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("CategoriesRepo - Store - Commit: %w", err)
+	}
+	cat.Id = id
+
+	return nil
+}
+
+func (cr *CategoriesRepo) GetById(ctx context.Context, id int64) (entity.Category, error) {
+	cat := entity.Category{}
+	var parentId sql.NullInt64
+	var schema sql.NullString
+
+	err := cr.DB.QueryRowContext(ctx,
+		`SELECT id, name, parent_id, schema FROM categories WHERE id = ?`,
+		id).Scan(&cat.Id, &cat.Name, &parentId, &schema)
+	if err != nil {
+		return cat, fmt.Errorf("CategoriesRepo - GetById - Scan: %w", err)
+	}
+	cat.ParentId = parentId.Int64
+	cat.Schema = jsonValue(schema)
+
+	return cat, nil
+}
+
+// Fetch counts adverts of every category once and sums counts
+// over pairs of category and its descendant
+func (cr *CategoriesRepo) Fetch(ctx context.Context) ([]entity.Category, error) {
+	categories := []entity.Category{}
+
+	rows, err := cr.DB.QueryContext(ctx,
+		`WITH RECURSIVE counts(category_id, adverts) AS (
+			SELECT category_id, COUNT(*) FROM adverts
+			WHERE deleted_at IS NULL AND hidden_at IS NULL AND category_id IS NOT NULL
+			GROUP BY category_id
+		),
+		tree(ancestor_id, id) AS (
+			SELECT id, id FROM categories
+			UNION
+			SELECT tree.ancestor_id, categories.id FROM categories
+			JOIN tree ON categories.parent_id = tree.id
+		)
+		SELECT categories.id, categories.name, categories.parent_id, categories.schema,
+		COALESCE(SUM(counts.adverts), 0)
+		FROM categories
+		JOIN tree ON tree.ancestor_id = categories.id
+		LEFT JOIN counts ON counts.category_id = tree.id
+		GROUP BY categories.id, categories.name, categories.parent_id, categories.schema
+		ORDER BY categories.id`)
+	if err != nil {
+		return categories, fmt.Errorf("CategoriesRepo - Fetch - QueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var cat entity.Category
+		var parentId sql.NullInt64
+		var schema sql.NullString
+
+		err = rows.Scan(&cat.Id, &cat.Name, &parentId, &schema, &cat.AdvertsCount)
+		if err != nil {
+			return categories, fmt.Errorf("CategoriesRepo - Fetch - Scan: %w", err)
+		}
+		cat.ParentId = parentId.Int64
+		cat.Schema = jsonValue(schema)
+		categories = append(categories, cat)
+	}
+	if err = rows.Err(); err != nil {
+		return categories, fmt.Errorf("CategoriesRepo - Fetch - Rows: %w", err)
+	}
+
+	return categories, nil
+}
+
+// Update renames category and moves it to another parent,
+// category can not be moved inside itself
+func (cr *CategoriesRepo) Update(ctx context.Context, cat entity.Category) error {
+	tx, err := cr.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("CategoriesRepo - Update - Begin: %w", err)
+	}
+	defer func() {
+		err = tx.Rollback()
+	}()
+
+	if err = categoryExists(ctx, tx, cat.Id); err != nil {
+		return fmt.Errorf("CategoriesRepo - Update - %w", err)
+	}
+	if err = checkParent(ctx, tx, cat.Id, cat.ParentId); err != nil {
+		return fmt.Errorf("CategoriesRepo - Update - %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE categories SET name = ?, parent_id = ?, schema = ? WHERE id = ?`,
+		cat.Name, nullId(cat.ParentId), nullJson(cat.Schema), cat.Id)
+	if err != nil {
+		return fmt.Errorf("CategoriesRepo - Update - ExecContext: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("CategoriesRepo - Update - Commit: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes category which has neither subcategories nor adverts,
+// adverts in trash are counted too as they can be restored
+func (cr *CategoriesRepo) Delete(ctx context.Context, id int64) error {
+	tx, err := cr.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("CategoriesRepo - Delete - Begin: %w", err)
+	}
+	defer func() {
+		err = tx.Rollback()
+	}()
+
+	if err = categoryExists(ctx, tx, id); err != nil {
+		return fmt.Errorf("CategoriesRepo - Delete - %w", err)
+	}
+
+	var used bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM categories WHERE parent_id = ?)
+        OR EXISTS (SELECT 1 FROM adverts WHERE category_id = ?)`,
+		id, id).Scan(&used)
+	if err != nil {
+		return fmt.Errorf("CategoriesRepo - Delete - Scan: %w", err)
+	}
+	if used {
+		return fmt.Errorf("CategoriesRepo - Delete: %w", entity.ErrCategoryNotEmpty)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM categories WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("CategoriesRepo - Delete - ExecContext: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("CategoriesRepo - Delete - Commit: %w", err)
+	}
+
+	return nil
+}
+
+func categoryExists(ctx context.Context, tx *sql.Tx, id int64) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM categories WHERE id = ?)`, id).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("categoryExists - Scan: %w", err)
+	}
+	if !exists {
+		return entity.ErrItemNotExists
+	}
+	return nil
+}
+
+// checkParent checks that parent exists and is not inside category,
+// zero parent means top level and zero id means new category
+func checkParent(ctx context.Context, tx *sql.Tx, id, parentId int64) error {
+	if parentId == 0 {
+		return nil
+	}
+
+	var valid bool
+	err := tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM categories
+        WHERE id = ? AND id NOT IN (`+subtreeQuery+`))`,
+		parentId, id).Scan(&valid)
+	if err != nil {
+		return fmt.Errorf("checkParent - Scan: %w", err)
+	}
+	if !valid {
+		return entity.ErrWrongParent
+	}
+	return nil
+}
+
+// nullId stores zero id as NULL
+func nullId(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+// nullJson stores empty JSON as NULL
+func nullJson(value json.RawMessage) sql.NullString {
+	return sql.NullString{String: string(value), Valid: len(value) != 0}
+}
+
+// jsonValue reads JSON stored by nullJson
+func jsonValue(value sql.NullString) json.RawMessage {
+	if !value.Valid {
+		return nil
+	}
+	return json.RawMessage(value.String)
+}
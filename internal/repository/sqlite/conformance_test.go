@@ -0,0 +1,65 @@
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/mrsubudei/adv-store-service/internal/repository"
+	"github.com/mrsubudei/adv-store-service/internal/repository/repotest"
+	"github.com/mrsubudei/adv-store-service/internal/repository/sqlite"
+	"github.com/mrsubudei/adv-store-service/pkg/sqlite3"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repository.Advert {
+		return sqlite.NewAdvertsRepo(mustMigratedDB(t))
+	})
+}
+
+func TestCategoriesConformance(t *testing.T) {
+	repotest.RunCategories(t, func(t *testing.T) (repository.Advert, repository.Category) {
+		db := mustMigratedDB(t)
+		return sqlite.NewAdvertsRepo(db), sqlite.NewCategoriesRepo(db)
+	})
+}
+
+func TestUsersConformance(t *testing.T) {
+	repotest.RunUsers(t, func(t *testing.T) (repository.Advert, repository.User) {
+		db := mustMigratedDB(t)
+		return sqlite.NewAdvertsRepo(db), sqlite.NewUsersRepo(db)
+	})
+}
+
+func TestApiKeysConformance(t *testing.T) {
+	repotest.RunApiKeys(t, func(t *testing.T) (repository.User, repository.ApiKey) {
+		db := mustMigratedDB(t)
+		return sqlite.NewUsersRepo(db), sqlite.NewApiKeysRepo(db)
+	})
+}
+
+func TestRolesConformance(t *testing.T) {
+	repotest.RunRoles(t, func(t *testing.T) (repository.User, repository.Role,
+		repository.Audit) {
+		db := mustMigratedDB(t)
+		return sqlite.NewUsersRepo(db), sqlite.NewRolesRepo(db), sqlite.NewAuditRepo(db)
+	})
+}
+
+// mustMigratedDB returns new database closed at the end of test
+func mustMigratedDB(t *testing.T) *sqlite3.Sqlite {
+	db := sqlite.MustOpenDB(t, filepath.Join(t.TempDir(), "adverts.db"))
+	t.Cleanup(func() {
+		sqlite.MustCloseDB(t, db)
+	})
+	if err := sqlite.Migrate(context.Background(), db); err != nil {
+		t.Fatal("Unable to migrate db:", err)
+	}
+	return db
+}
+
+func TestIdempotencyConformance(t *testing.T) {
+	repotest.RunIdempotency(t, func(t *testing.T) repository.Idempotency {
+		return sqlite.NewIdempotencyRepo(mustMigratedDB(t))
+	})
+}
@@ -0,0 +1,153 @@
+package sqlite
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+
+	"github.com/mrsubudei/adv-store-service/pkg/migrate"
+	"github.com/mrsubudei/adv-store-service/pkg/sqlite3"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// searchSchema creates full-text index over adverts' names and descriptions,
+// keeps it up to date by triggers and fills it with existing adverts
+const searchSchema = `
+	CREATE VIRTUAL TABLE adverts_fts USING fts5(
+	name,
+	description,
+	content = 'adverts',
+	content_rowid = 'id'
+	);
+
+	CREATE TRIGGER adverts_fts_insert AFTER INSERT ON adverts BEGIN
+	INSERT INTO adverts_fts(rowid, name, description)
+	VALUES (new.id, new.name, new.description);
+	END;
+
+	CREATE TRIGGER adverts_fts_delete AFTER DELETE ON adverts BEGIN
+	INSERT INTO adverts_fts(adverts_fts, rowid, name, description)
+	VALUES ('delete', old.id, old.name, old.description);
+	END;
+
+	CREATE TRIGGER adverts_fts_update AFTER UPDATE ON adverts BEGIN
+	INSERT INTO adverts_fts(adverts_fts, rowid, name, description)
+	VALUES ('delete', old.id, old.name, old.description);
+	INSERT INTO adverts_fts(rowid, name, description)
+	VALUES (new.id, new.name, new.description);
+	END;
+
+	INSERT INTO adverts_fts(adverts_fts) VALUES ('rebuild');`
+
+const dropSearchTriggers = `
+	DROP TRIGGER IF EXISTS adverts_fts_update;
+	DROP TRIGGER IF EXISTS adverts_fts_delete;
+	DROP TRIGGER IF EXISTS adverts_fts_insert;`
+
+const dropSearchTable = `
+	DROP TABLE IF EXISTS adverts_fts;`
+
+// NewMigrator returns migrator of adverts database schema
+func NewMigrator(s *sqlite3.Sqlite) (*migrate.Migrator, error) {
+	fsys, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("NewMigrator - Sub: %w", err)
+	}
+
+	m, err := migrate.New(s.DB, fsys)
+	if err != nil {
+		return nil, fmt.Errorf("NewMigrator - %w", err)
+	}
+	return m, nil
+}
+
+// Migrate applies pending migrations and syncs search index,
+// it fails if database was migrated by newer version of application
+func Migrate(ctx context.Context, s *sqlite3.Sqlite) error {
+	m, err := NewMigrator(s)
+	if err != nil {
+		return fmt.Errorf("Migrate - %w", err)
+	}
+
+	err = m.Up(ctx)
+	if err != nil {
+		return fmt.Errorf("Migrate - %w", err)
+	}
+
+	_, err = SyncSearch(ctx, s)
+	if err != nil {
+		return fmt.Errorf("Migrate - %w", err)
+	}
+
+	return nil
+}
+
+// SyncSearch creates full-text index of adverts or drops it and tells if
+// adverts are searched with index. FTS5 is compiled into go-sqlite3 only with
+// 'sqlite_fts5' build tag, so the index is kept out of numbered migrations and
+// is synced with the build every time database is opened.
+func SyncSearch(ctx context.Context, s *sqlite3.Sqlite) (bool, error) {
+	var enabled bool
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled)
+	if err != nil {
+		return false, fmt.Errorf("SyncSearch - QueryRowContext: %w", err)
+	}
+
+	indexed, err := syncSearch(ctx, s, enabled)
+	if err != nil {
+		return false, fmt.Errorf("SyncSearch - %w", err)
+	}
+	return indexed, nil
+}
+
+// syncSearch creates missing or partly created index if FTS5 is enabled and
+// adverts table exists. Otherwise it drops triggers which keep index up
+// to date, as without FTS5 they fail every write to adverts, index table
+// itself is dropped only if FTS5 is enabled as it can not be dropped without it.
+func syncSearch(ctx context.Context, s *sqlite3.Sqlite, enabled bool) (bool, error) {
+	var adverts bool
+	var objects int
+	err := s.DB.QueryRowContext(ctx, `SELECT
+		EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'adverts'),
+		(SELECT COUNT(*) FROM sqlite_master WHERE name IN ('adverts_fts',
+		'adverts_fts_insert', 'adverts_fts_delete', 'adverts_fts_update'))`).Scan(
+		&adverts, &objects)
+	if err != nil {
+		return false, fmt.Errorf("syncSearch - QueryRowContext: %w", err)
+	}
+
+	var script string
+	switch {
+	case enabled && adverts && objects == 4:
+		return true, nil
+	case enabled && adverts:
+		script = dropSearchTriggers + dropSearchTable + searchSchema
+	case enabled:
+		script = dropSearchTriggers + dropSearchTable
+	case objects != 0:
+		script = dropSearchTriggers
+	default:
+		return false, nil
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("syncSearch - BeginTx: %w", err)
+	}
+	defer func() {
+		err = tx.Rollback()
+	}()
+
+	if _, err = tx.ExecContext(ctx, script); err != nil {
+		return false, fmt.Errorf("syncSearch - ExecContext: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return false, fmt.Errorf("syncSearch - Commit: %w", err)
+	}
+
+	return enabled && adverts, nil
+}
@@ -0,0 +1,165 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/internal/repository/sqlite"
+	"github.com/mrsubudei/adv-store-service/pkg/migrate"
+)
+
+func TestMigrate(t *testing.T) {
+	db := sqlite.MustOpenDB(t, "file:foobar?mode=memory&cache=shared")
+	defer sqlite.MustCloseDB(t, db)
+	ctx := context.Background()
+
+	m, err := sqlite.NewMigrator(db)
+	if err != nil {
+		t.Fatal("Unable to create migrator:", err)
+	}
+
+	t.Run("OK up", func(t *testing.T) {
+		err := m.Check(ctx)
+		if !errors.Is(err, migrate.ErrPending) {
+			t.Fatalf("want: %v, got: %v", migrate.ErrPending, err)
+		} else if !strings.Contains(err.Error(), "0001_create_adverts, 0002_add_adverts_version") {
+			t.Fatalf("want pending migrations listed, got: %v", err)
+		}
+		if err := sqlite.Migrate(ctx, db); err != nil {
+			t.Fatal("Unable to migrate:", err)
+		}
+		if version, err := m.Version(ctx); err != nil {
+			t.Fatal(err)
+		} else if version != m.Latest() {
+			t.Fatalf("want: %v, got: %v", m.Latest(), version)
+		}
+		if err := m.Check(ctx); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("OK down and up again", func(t *testing.T) {
+		if err := m.Down(ctx); err != nil {
+			t.Fatal("Unable to migrate down:", err)
+		}
+		if version, err := m.Version(ctx); err != nil {
+			t.Fatal(err)
+		} else if version != m.Latest()-1 {
+			t.Fatalf("want: %v, got: %v", m.Latest()-1, version)
+		}
+		if err := m.To(ctx, 0); err != nil {
+			t.Fatal("Unable to migrate to 0:", err)
+		}
+		// search index is left without adverts table until it is synced
+		if _, err := sqlite.SyncSearch(ctx, db); err != nil {
+			t.Fatal("Unable to sync search:", err)
+		}
+		var tables int
+		err := db.DB.QueryRow(`SELECT COUNT(*) FROM sqlite_master
+			WHERE type = 'table' AND name LIKE 'advert%'`).Scan(&tables)
+		if err != nil {
+			t.Fatal(err)
+		} else if tables != 0 {
+			t.Fatalf("want no tables, got: %v", tables)
+		}
+		if err := sqlite.Migrate(ctx, db); err != nil {
+			t.Fatal("Unable to migrate:", err)
+		}
+	})
+
+	t.Run("Err unknown version", func(t *testing.T) {
+		_, err := db.DB.Exec(`INSERT INTO schema_migrations(version, name)
+			VALUES (9999, 'from_future')`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sqlite.Migrate(ctx, db); !errors.Is(err, migrate.ErrUnknownVersion) {
+			t.Fatalf("want: %v, got: %v", migrate.ErrUnknownVersion, err)
+		}
+		err = m.Check(ctx)
+		if !errors.Is(err, migrate.ErrUnknownVersion) || !strings.Contains(err.Error(), "9999") {
+			t.Fatalf("want unknown version 9999, got: %v", err)
+		}
+	})
+}
+
+func TestSyncSearch(t *testing.T) {
+	db := sqlite.MustOpenDB(t, "file:syncsearch?mode=memory&cache=shared")
+	defer sqlite.MustCloseDB(t, db)
+	ctx := context.Background()
+	if err := sqlite.Migrate(ctx, db); err != nil {
+		t.Fatal("Unable to migrate:", err)
+	}
+	var enabled bool
+	err := db.DB.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := sqlite.NewAdvertsRepo(db)
+
+	search := func(t *testing.T, query string) []entity.Advert {
+		t.Helper()
+		page, err := repo.Fetch(context.WithValue(ctx, entity.KeyQuery, query))
+		if err != nil {
+			t.Fatal("Unable to Fetch:", err)
+		}
+		return page.Adverts
+	}
+
+	t.Run("OK migrations do not depend on build", func(t *testing.T) {
+		m, err := sqlite.NewMigrator(db)
+		if err != nil {
+			t.Fatal("Unable to create migrator:", err)
+		}
+		if err = m.Check(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if indexed, err := sqlite.SyncSearch(ctx, db); err != nil {
+			t.Fatal("Unable to sync search:", err)
+		} else if indexed != enabled {
+			t.Fatalf("want indexed: %v, got: %v", enabled, indexed)
+		}
+	})
+
+	// database indexed by build with FTS5 is opened by build without it
+	t.Run("OK writes without FTS5", func(t *testing.T) {
+		if !enabled {
+			t.Skip("FTS5 is needed to create search index")
+		}
+		if indexed, err := sqlite.SyncSearchAs(ctx, db, false); err != nil {
+			t.Fatal("Unable to sync search:", err)
+		} else if indexed {
+			t.Fatal("want search without index")
+		}
+
+		adv := advert1
+		if err := repo.Store(ctx, &adv); err != nil {
+			t.Fatal("Unable to store:", err)
+		}
+		adv.Description = "red and fast"
+		if err := repo.Update(ctx, adv); err != nil {
+			t.Fatal("Unable to update:", err)
+		}
+		if found := search(t, "fast"); len(found) != 1 || found[0].Snippet != "" {
+			t.Fatalf("want advert found without index, got: %+v", found)
+		}
+	})
+
+	// database left without index is opened by build with FTS5 again
+	t.Run("OK index created with FTS5", func(t *testing.T) {
+		if !enabled {
+			t.Skip("FTS5 is needed to create search index")
+		}
+		if indexed, err := sqlite.SyncSearch(ctx, db); err != nil {
+			t.Fatal("Unable to sync search:", err)
+		} else if !indexed {
+			t.Fatal("want search with index")
+		}
+		if found := search(t, "fast"); len(found) != 1 || found[0].Snippet == "" {
+			t.Fatalf("want advert found with index, got: %+v", found)
+		}
+	})
+}
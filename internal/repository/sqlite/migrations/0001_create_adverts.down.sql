@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS adverts_fts;
+DROP TABLE IF EXISTS photo_urls;
+DROP TABLE IF EXISTS adverts;
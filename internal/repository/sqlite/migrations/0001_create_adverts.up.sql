@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS adverts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT UNIQUE NOT NULL,
+	description TEXT,
+	price INTEGER,
+	photo_url TEXT,
+	created_at TEXT
+	);
+
+CREATE TABLE IF NOT EXISTS photo_urls (
+	advert_id INTEGER,
+	url TEXT,
+	PRIMARY KEY (advert_id, url),
+	FOREIGN KEY (advert_id) REFERENCES adverts(id)
+	);
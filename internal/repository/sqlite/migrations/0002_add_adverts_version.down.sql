@@ -0,0 +1 @@
+ALTER TABLE adverts DROP COLUMN version;
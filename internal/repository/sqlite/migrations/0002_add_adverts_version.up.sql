@@ -0,0 +1 @@
+ALTER TABLE adverts ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
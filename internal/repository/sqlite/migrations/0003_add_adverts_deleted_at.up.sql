@@ -0,0 +1 @@
+ALTER TABLE adverts ADD COLUMN deleted_at TEXT;
@@ -0,0 +1,12 @@
+CREATE TABLE advert_revisions (
+	advert_id INTEGER,
+	revision INTEGER,
+	action TEXT NOT NULL,
+	name TEXT,
+	description TEXT,
+	price INTEGER,
+	photo_url TEXT,
+	photo_urls TEXT,
+	created_at TEXT,
+	PRIMARY KEY (advert_id, revision)
+	);
@@ -0,0 +1,7 @@
+ALTER TABLE advert_revisions DROP COLUMN category_id;
+
+DROP INDEX adverts_category_id_idx;
+
+ALTER TABLE adverts DROP COLUMN category_id;
+
+DROP TABLE categories;
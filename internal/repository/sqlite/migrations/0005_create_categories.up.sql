@@ -0,0 +1,14 @@
+CREATE TABLE categories (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	parent_id INTEGER,
+	FOREIGN KEY (parent_id) REFERENCES categories(id)
+	);
+
+CREATE INDEX categories_parent_id_idx ON categories(parent_id);
+
+ALTER TABLE adverts ADD COLUMN category_id INTEGER;
+
+CREATE INDEX adverts_category_id_idx ON adverts(category_id);
+
+ALTER TABLE advert_revisions ADD COLUMN category_id INTEGER;
@@ -0,0 +1,5 @@
+ALTER TABLE advert_revisions DROP COLUMN attributes;
+
+ALTER TABLE adverts DROP COLUMN attributes;
+
+ALTER TABLE categories DROP COLUMN schema;
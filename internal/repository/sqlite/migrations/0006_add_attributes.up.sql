@@ -0,0 +1,5 @@
+ALTER TABLE categories ADD COLUMN schema TEXT;
+
+ALTER TABLE adverts ADD COLUMN attributes TEXT;
+
+ALTER TABLE advert_revisions ADD COLUMN attributes TEXT;
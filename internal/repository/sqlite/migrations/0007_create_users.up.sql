@@ -0,0 +1,16 @@
+CREATE TABLE users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	email TEXT UNIQUE NOT NULL,
+	password_hash TEXT NOT NULL,
+	created_at TEXT
+	);
+
+CREATE TABLE sessions (
+	token_hash TEXT PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	created_at TEXT,
+	expires_at TEXT NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+
+CREATE INDEX sessions_user_id_idx ON sessions(user_id);
@@ -0,0 +1,5 @@
+ALTER TABLE users DROP COLUMN admin;
+
+DROP INDEX adverts_owner_id_idx;
+
+ALTER TABLE adverts DROP COLUMN owner_id;
@@ -0,0 +1,3 @@
+DROP INDEX api_keys_user_id_idx;
+
+DROP TABLE api_keys;
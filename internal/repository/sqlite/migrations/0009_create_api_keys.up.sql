@@ -0,0 +1,14 @@
+CREATE TABLE api_keys (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	name TEXT,
+	prefix TEXT UNIQUE NOT NULL,
+	key_hash TEXT NOT NULL,
+	scopes TEXT NOT NULL,
+	created_at TEXT,
+	last_used_at TEXT,
+	revoked_at TEXT,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+
+CREATE INDEX api_keys_user_id_idx ON api_keys(user_id);
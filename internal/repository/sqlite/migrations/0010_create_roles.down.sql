@@ -0,0 +1,8 @@
+ALTER TABLE users ADD COLUMN admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE users SET admin = TRUE WHERE id IN (SELECT user_roles.user_id
+	FROM user_roles JOIN roles ON roles.id = user_roles.role_id WHERE roles.name = 'admin');
+
+DROP TABLE user_roles;
+DROP TABLE role_permissions;
+DROP TABLE roles;
@@ -0,0 +1 @@
+ALTER TABLE adverts ADD COLUMN hidden_at TEXT;
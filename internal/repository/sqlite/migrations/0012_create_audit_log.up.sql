@@ -0,0 +1,11 @@
+CREATE TABLE audit_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER,
+	api_key_id INTEGER,
+	action TEXT NOT NULL,
+	resource TEXT NOT NULL,
+	allowed BOOLEAN NOT NULL,
+	created_at TEXT
+	);
+
+CREATE INDEX audit_log_user_id_idx ON audit_log(user_id);
@@ -0,0 +1,10 @@
+CREATE TABLE idempotency_keys (
+	idempotency_key TEXT PRIMARY KEY,
+	request_hash TEXT NOT NULL,
+	status INTEGER NOT NULL DEFAULT 0,
+	body BLOB,
+	created_at TEXT NOT NULL,
+	expires_at TEXT NOT NULL
+	);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys(expires_at);
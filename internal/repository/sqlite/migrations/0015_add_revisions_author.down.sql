@@ -0,0 +1,3 @@
+ALTER TABLE advert_revisions DROP COLUMN api_key_id;
+
+ALTER TABLE advert_revisions DROP COLUMN user_id;
@@ -0,0 +1,3 @@
+ALTER TABLE advert_revisions ADD COLUMN user_id INTEGER;
+
+ALTER TABLE advert_revisions ADD COLUMN api_key_id INTEGER;
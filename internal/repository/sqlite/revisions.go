@@ -0,0 +1,129 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// storeRevision saves snapshot of advert's current state along with
+// caller who changed it, it should be called in transaction that changed advert
+func (ar *AdvertsRepo) storeRevision(ctx context.Context, tx *sql.Tx,
+	id int64, action string) error {
+	identity, _ := ctx.Value(entity.KeyIdentity).(entity.Identity)
+	var version int64
+	var name string
+	var description, url, attributes sql.NullString
+	var price, categoryId sql.NullInt64
+
+	err := tx.QueryRowContext(ctx,
+		`SELECT version, name, description, price, photo_url, category_id, attributes
+        FROM adverts
+        WHERE id = ?`, id).Scan(&version, &name, &description, &price, &url, &categoryId,
+		&attributes)
+	if err != nil {
+		return fmt.Errorf("storeRevision - Scan: %w", err)
+	}
+
+	urls, err := ar.getUrls(ctx, tx, id)
+	if err != nil {
+		return fmt.Errorf("storeRevision - %w", err)
+	}
+	urlsJson, err := json.Marshal(urls)
+	if err != nil {
+		return fmt.Errorf("storeRevision - Marshal: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO advert_revisions(advert_id, revision, action, name,
+        description, price, photo_url, photo_urls, category_id, attributes,
+        user_id, api_key_id, created_at)
+        values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now', 'localtime'))`,
+		id, version, action, name, description, price, url, string(urlsJson), categoryId,
+		attributes, nullId(identity.UserId), nullId(identity.ApiKeyId))
+	if err != nil {
+		return fmt.Errorf("storeRevision - ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+// GetRevisions returns all revisions of advert, oldest first
+func (ar *AdvertsRepo) GetRevisions(ctx context.Context, id int64) ([]entity.Revision, error) {
+	revisions := []entity.Revision{}
+
+	rows, err := ar.DB.QueryContext(ctx,
+		`SELECT revision, action, name, description, price, photo_url,
+        photo_urls, category_id, attributes, user_id, api_key_id, created_at
+        FROM advert_revisions
+        WHERE advert_id = ?
+        ORDER BY revision`, id)
+	if err != nil {
+		return revisions, fmt.Errorf("AdvertsRepo - GetRevisions - QueryContext: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return revisions, fmt.Errorf("AdvertsRepo - GetRevisions - %w", err)
+		}
+		revision.Advert.Id = id
+		revisions = append(revisions, revision)
+	}
+	if err = rows.Err(); err != nil {
+		return revisions, fmt.Errorf("AdvertsRepo - GetRevisions - Rows: %w", err)
+	}
+
+	return revisions, nil
+}
+
+func (ar *AdvertsRepo) GetRevision(ctx context.Context, id, rev int64) (entity.Revision, error) {
+	row := ar.DB.QueryRowContext(ctx,
+		`SELECT revision, action, name, description, price, photo_url,
+        photo_urls, category_id, attributes, user_id, api_key_id, created_at
+        FROM advert_revisions
+        WHERE advert_id = ? AND revision = ?`, id, rev)
+
+	revision, err := scanRevision(row)
+	if err != nil {
+		return revision, fmt.Errorf("AdvertsRepo - GetRevision - %w", err)
+	}
+	revision.Advert.Id = id
+
+	return revision, nil
+}
+
+func scanRevision(row interface{ Scan(...interface{}) error }) (entity.Revision, error) {
+	revision := entity.Revision{}
+	var description, url, urls, attributes, createdAt sql.NullString
+	var price, categoryId, userId, apiKeyId sql.NullInt64
+
+	err := row.Scan(&revision.Revision, &revision.Action, &revision.Advert.Name,
+		&description, &price, &url, &urls, &categoryId, &attributes, &userId, &apiKeyId,
+		&createdAt)
+	if err != nil {
+		return revision, fmt.Errorf("scanRevision - Scan: %w", err)
+	}
+
+	revision.Advert.Description = description.String
+	revision.Advert.Price = price.Int64
+	revision.Advert.MainPhotoUrl = url.String
+	revision.Advert.CategoryId = categoryId.Int64
+	revision.Advert.Attributes = jsonValue(attributes)
+	revision.UserId = userId.Int64
+	revision.ApiKeyId = apiKeyId.Int64
+	revision.CreatedAt = createdAt.String
+	if urls.String != "" {
+		err = json.Unmarshal([]byte(urls.String), &revision.Advert.PhotosUrls)
+		if err != nil {
+			return revision, fmt.Errorf("scanRevision - Unmarshal: %w", err)
+		}
+	}
+
+	return revision, nil
+}
@@ -1,6 +1,7 @@
 package sqlite
 
 import (
+	"context"
 	"testing"
 
 	"github.com/mrsubudei/adv-store-service/pkg/sqlite3"
@@ -24,3 +25,8 @@ func MustCloseDB(tb testing.TB, db *sqlite3.Sqlite) {
 		tb.Fatal(err)
 	}
 }
+
+// SyncSearchAs syncs search index as build with or without FTS5 does
+func SyncSearchAs(ctx context.Context, db *sqlite3.Sqlite, enabled bool) (bool, error) {
+	return syncSearch(ctx, db, enabled)
+}
@@ -0,0 +1,97 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/pkg/sqlite3"
+)
+
+type UsersRepo struct {
+	*sqlite3.Sqlite
+}
+
+func NewUsersRepo(sq *sqlite3.Sqlite) *UsersRepo {
+	return &UsersRepo{sq}
+}
+
+func (ur *UsersRepo) Store(ctx context.Context, user *entity.User) error {
+	res, err := ur.DB.ExecContext(ctx,
+		`INSERT INTO users(email, password_hash, created_at) values(?, ?, ?)`,
+		user.Email, user.PasswordHash, user.CreatedAt)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("UsersRepo - Store - ExecContext: %v: %w", err,
+			entity.ErrEmailAlreadyExist)
+	} else if err != nil {
+		return fmt.Errorf("UsersRepo - Store - ExecContext: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("UsersRepo - Store - LastInsertId: %w", err)
+	}
+	user.Id = id
+
+	return nil
+}
+
+func (ur *UsersRepo) GetById(ctx context.Context, id int64) (entity.User, error) {
+	user := entity.User{}
+	err := ur.DB.QueryRowContext(ctx,
+		`SELECT id, email, password_hash, created_at FROM users WHERE id = ?`,
+		id).Scan(&user.Id, &user.Email, &user.PasswordHash, &user.CreatedAt)
+	if err != nil {
+		return user, fmt.Errorf("UsersRepo - GetById - Scan: %w", err)
+	}
+
+	return user, nil
+}
+
+func (ur *UsersRepo) GetByEmail(ctx context.Context, email string) (entity.User, error) {
+	user := entity.User{}
+	err := ur.DB.QueryRowContext(ctx,
+		`SELECT id, email, password_hash, created_at FROM users WHERE email = ?`,
+		email).Scan(&user.Id, &user.Email, &user.PasswordHash, &user.CreatedAt)
+	if err != nil {
+		return user, fmt.Errorf("UsersRepo - GetByEmail - Scan: %w", err)
+	}
+
+	return user, nil
+}
+
+func (ur *UsersRepo) StoreSession(ctx context.Context, session entity.Session) error {
+	_, err := ur.DB.ExecContext(ctx,
+		`INSERT INTO sessions(token_hash, user_id, created_at, expires_at)
+		values(?, ?, ?, ?)`,
+		session.TokenHash, session.UserId, session.CreatedAt, session.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("UsersRepo - StoreSession - ExecContext: %w", err)
+	}
+
+	return nil
+}
+
+func (ur *UsersRepo) GetSession(ctx context.Context, tokenHash string) (entity.Session, error) {
+	session := entity.Session{}
+	err := ur.DB.QueryRowContext(ctx,
+		`SELECT token_hash, user_id, created_at, expires_at FROM sessions
+		WHERE token_hash = ?`,
+		tokenHash).Scan(&session.TokenHash, &session.UserId, &session.CreatedAt,
+		&session.ExpiresAt)
+	if err != nil {
+		return session, fmt.Errorf("UsersRepo - GetSession - Scan: %w", err)
+	}
+
+	return session, nil
+}
+
+func (ur *UsersRepo) DeleteSession(ctx context.Context, tokenHash string) error {
+	_, err := ur.DB.ExecContext(ctx,
+		`DELETE FROM sessions WHERE token_hash = ?`, tokenHash)
+	if err != nil {
+		return fmt.Errorf("UsersRepo - DeleteSession - ExecContext: %w", err)
+	}
+
+	return nil
+}
@@ -5,7 +5,6 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/mrsubudei/adv-store-service/internal/entity"
@@ -13,12 +12,28 @@ import (
 )
 
 type AdvertService struct {
-	repo repository.Advert
+	repo        repository.Advert
+	categories  repository.Category
+	users       repository.User
+	keys        repository.ApiKey
+	roles       repository.Role
+	audit       repository.Audit
+	idempotency repository.Idempotency
+	policy      Policy
 }
 
-func NewAdvertService(repo repository.Advert) *AdvertService {
+func NewAdvertService(repo repository.Advert, categories repository.Category,
+	users repository.User, keys repository.ApiKey, roles repository.Role,
+	audit repository.Audit, idempotency repository.Idempotency) *AdvertService {
 	return &AdvertService{
-		repo: repo,
+		repo:        repo,
+		categories:  categories,
+		users:       users,
+		keys:        keys,
+		roles:       roles,
+		audit:       audit,
+		idempotency: idempotency,
+		policy:      OwnerPolicy{},
 	}
 }
 
@@ -27,12 +42,19 @@ func getTime() string {
 	return timeNow.Format(DateFormat)
 }
 
+// Create stores advert owned by caller, anonymous caller's advert has no owner
 func (s *AdvertService) Create(ctx context.Context, adv entity.Advert) (int64, error) {
-	adv.CreatedAt = getTime()
+	err := s.checkCreate(ctx, &adv)
+	if err != nil {
+		if isAdvertError(err) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("AdvertService - Create - %w", err)
+	}
 
-	err := s.repo.Store(ctx, &adv)
+	err = s.repo.Store(ctx, &adv)
 	if err != nil {
-		if strings.Contains(err.Error(), UniqueNameConstraint) {
+		if errors.Is(err, entity.ErrNameAlreadyExist) {
 			return 0, entity.ErrNameAlreadyExist
 		}
 		return 0, fmt.Errorf("AdvertService - Create: %w", err)
@@ -41,6 +63,28 @@ func (s *AdvertService) Create(ctx context.Context, adv entity.Advert) (int64, e
 	return adv.Id, nil
 }
 
+// checkCreate makes caller owner of advert and checks if caller may create it
+func (s *AdvertService) checkCreate(ctx context.Context, adv *entity.Advert) error {
+	adv.CreatedAt = getTime()
+	adv.OwnerId = 0
+	if identity, ok := ctx.Value(entity.KeyIdentity).(entity.Identity); ok {
+		adv.OwnerId = identity.UserId
+	}
+
+	err := s.decide(ctx, ActionCreateAdvert, "adverts",
+		s.policy.Authorize(ctx, ActionCreateAdvert, *adv))
+	if err != nil {
+		return err
+	}
+
+	err = s.checkCategory(ctx, *adv)
+	if err != nil {
+		return fmt.Errorf("checkCreate - %w", err)
+	}
+
+	return nil
+}
+
 func (s *AdvertService) GetById(ctx context.Context, id int64) (entity.Advert, error) {
 	adv, err := s.repo.GetById(ctx, id)
 	if err != nil {
@@ -49,69 +93,196 @@ func (s *AdvertService) GetById(ctx context.Context, id int64) (entity.Advert, e
 		}
 		return adv, fmt.Errorf("AdvertService - GetById: %w", err)
 	}
+
+	if adv.HiddenAt != "" {
+		err = s.decide(ctx, ActionReadHiddenAdvert, advertResource(id),
+			s.policy.Authorize(ctx, ActionReadHiddenAdvert, adv))
+		if err != nil {
+			if errors.Is(err, entity.ErrForbidden) || errors.Is(err, entity.ErrUnauthenticated) {
+				return entity.Advert{}, entity.ErrItemNotExists
+			}
+			return entity.Advert{}, fmt.Errorf("AdvertService - GetById - %w", err)
+		}
+	}
 	return adv, nil
 }
 
-func (s *AdvertService) GetAll(ctx context.Context) ([]entity.Advert, error) {
-	adverts, err := s.repo.Fetch(ctx)
+// GetByIds returns result for every requested id in order of ids, adverts
+// which are not found or hidden from caller are reported as not existing
+func (s *AdvertService) GetByIds(ctx context.Context, ids []int64) ([]entity.BatchAdvert, error) {
+	adverts, err := s.repo.GetByIds(ctx, ids)
 	if err != nil {
-		return nil, fmt.Errorf("AdvertService - GetAll: %w", err)
+		return nil, fmt.Errorf("AdvertService - GetByIds: %w", err)
 	}
-	if len(adverts) == 0 {
-		return nil, entity.ErrNoItems
+
+	found := map[int64]entity.Advert{}
+	for _, adv := range adverts {
+		found[adv.Id] = adv
 	}
-	return adverts, nil
+
+	results := []entity.BatchAdvert{}
+	for _, id := range ids {
+		adv, ok := found[id]
+		if !ok {
+			results = append(results, entity.BatchAdvert{Id: id, Err: entity.ErrItemNotExists})
+			continue
+		}
+		if adv.HiddenAt != "" {
+			err = s.decide(ctx, ActionReadHiddenAdvert, advertResource(id),
+				s.policy.Authorize(ctx, ActionReadHiddenAdvert, adv))
+			if err != nil {
+				if !errors.Is(err, entity.ErrForbidden) && !errors.Is(err, entity.ErrUnauthenticated) {
+					return nil, fmt.Errorf("AdvertService - GetByIds - %w", err)
+				}
+				results = append(results, entity.BatchAdvert{Id: id, Err: entity.ErrItemNotExists})
+				continue
+			}
+		}
+		results = append(results, entity.BatchAdvert{Id: id, Advert: adv})
+	}
+	return results, nil
+}
+
+func (s *AdvertService) GetAll(ctx context.Context) (entity.AdvertsPage, error) {
+	page, err := s.repo.Fetch(ctx)
+	if err != nil {
+		return page, fmt.Errorf("AdvertService - GetAll: %w", err)
+	}
+	if len(page.Adverts) == 0 {
+		return page, entity.ErrNoItems
+	}
+	return page, nil
 }
 
+// Update replaces advert as a whole, first of photo urls becomes main,
+// advert's version 0 means advert is updated regardless of its version
 func (s *AdvertService) Update(ctx context.Context, adv entity.Advert) error {
-	exist, err := s.repo.GetById(ctx, adv.Id)
+	if len(adv.PhotosUrls) != 0 {
+		adv.MainPhotoUrl = adv.PhotosUrls[0]
+	}
+
+	err := s.authorize(ctx, ActionUpdateAdvert, adv.Id)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	err = s.checkCategory(ctx, adv)
+	if err != nil {
+		var attrErr *entity.AttributesError
+		if errors.Is(err, entity.ErrWrongCategory) || errors.As(err, &attrErr) {
+			return err
+		}
+		return fmt.Errorf("AdvertService - Update - %w", err)
+	}
+
+	err = s.repo.Update(ctx, adv)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, entity.ErrItemNotExists) {
 			return entity.ErrItemNotExists
 		}
+		if errors.Is(err, entity.ErrVersionMismatch) {
+			return entity.ErrVersionMismatch
+		}
+		if errors.Is(err, entity.ErrNameAlreadyExist) {
+			return entity.ErrNameAlreadyExist
+		}
 		return fmt.Errorf("AdvertService - Update: %w", err)
 	}
 
-	if adv.Name != "" {
-		exist.Name = adv.Name
+	return nil
+}
+
+// Delete removes advert, version 0 means advert is removed regardless of its version
+func (s *AdvertService) Delete(ctx context.Context, id, version int64) error {
+	err := s.authorize(ctx, ActionDeleteAdvert, id)
+	if err != nil {
+		return err
 	}
 
-	if adv.Description != "" {
-		exist.Description = adv.Description
+	err = s.repo.Delete(ctx, id, version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, entity.ErrItemNotExists) {
+			return entity.ErrItemNotExists
+		}
+		if errors.Is(err, entity.ErrVersionMismatch) {
+			return entity.ErrVersionMismatch
+		}
+		return fmt.Errorf("AdvertService - Delete: %w", err)
 	}
 
-	if adv.Price != 0 {
-		exist.Price = adv.Price
+	return nil
+}
+
+// GetTrash returns page of deleted adverts, callers who may not
+// restore any advert get only their own ones
+func (s *AdvertService) GetTrash(ctx context.Context) (entity.AdvertsPage, error) {
+	identity, _ := ctx.Value(entity.KeyIdentity).(entity.Identity)
+	err := s.decide(ctx, ActionGetTrash, "adverts/trash", s.policy.Authorize(ctx,
+		ActionGetTrash, entity.Advert{OwnerId: identity.UserId}))
+	if err != nil {
+		return entity.AdvertsPage{}, err
+	}
+	if !identity.Can(anyAdvertPermission[ActionGetTrash]) {
+		filter, _ := ctx.Value(entity.KeyFilter).(entity.Filter)
+		filter.OwnerId = identity.UserId
+		ctx = context.WithValue(ctx, entity.KeyFilter, filter)
 	}
 
-	if adv.MainPhotoUrl != "" {
-		exist.MainPhotoUrl = adv.MainPhotoUrl
+	page, err := s.repo.FetchDeleted(ctx)
+	if err != nil {
+		return page, fmt.Errorf("AdvertService - GetTrash: %w", err)
+	}
+	if len(page.Adverts) == 0 {
+		return page, entity.ErrNoItems
 	}
+	return page, nil
+}
 
-	if len(adv.PhotosUrls) != 0 {
-		exist.PhotosUrls = []string{}
-		exist.PhotosUrls = append(exist.PhotosUrls, adv.PhotosUrls...)
+// Restore takes advert out of trash, only its owner or admin may do it
+func (s *AdvertService) Restore(ctx context.Context, id int64) error {
+	adv, err := s.repo.GetDeletedById(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.ErrItemNotExists
+		}
+		return fmt.Errorf("AdvertService - Restore: %w", err)
+	}
+	err = s.decide(ctx, ActionRestoreAdvert, advertResource(id),
+		s.policy.Authorize(ctx, ActionRestoreAdvert, adv))
+	if err != nil {
+		return err
 	}
 
-	err = s.repo.Update(ctx, exist)
+	err = s.repo.Restore(ctx, id)
 	if err != nil {
-		if strings.Contains(err.Error(), UniqueNameConstraint) {
-			return entity.ErrNameAlreadyExist
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, entity.ErrItemNotExists) {
+			return entity.ErrItemNotExists
 		}
-		return fmt.Errorf("AdvertService - Update: %w", err)
+		return fmt.Errorf("AdvertService - Restore: %w", err)
 	}
 
 	return nil
 }
 
-func (s *AdvertService) Delete(ctx context.Context, id int64) error {
-	err := s.repo.Delete(ctx, id)
+// PurgeTrash removes for good adverts which are in trash longer than retention
+func (s *AdvertService) PurgeTrash(ctx context.Context, retention time.Duration) (int64, error) {
+	purged, err := s.repo.Purge(ctx, time.Now().Add(-retention))
+	if err != nil {
+		return 0, fmt.Errorf("AdvertService - PurgeTrash: %w", err)
+	}
+
+	return purged, nil
+}
+
+// authorize checks if caller may perform action on stored advert
+func (s *AdvertService) authorize(ctx context.Context, action Action, id int64) error {
+	adv, err := s.repo.GetById(ctx, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return entity.ErrItemNotExists
 		}
-		return fmt.Errorf("AdvertService - Delete: %w", err)
+		return fmt.Errorf("authorize - %w", err)
 	}
 
-	return nil
+	return s.decide(ctx, action, advertResource(id), s.policy.Authorize(ctx, action, adv))
 }
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+const (
+	// ApiKeyPrefix starts every API key, so that keys are told from session tokens
+	ApiKeyPrefix = "ak_"
+	// prefixLength is number of random bytes in key's prefix
+	prefixLength = 6
+)
+
+// CreateApiKey creates key of caller with given scopes, key itself
+// is returned only here and on rotation
+func (s *AdvertService) CreateApiKey(ctx context.Context, name string,
+	scopes []string) (entity.ApiKey, error) {
+	identity, err := sessionCaller(ctx)
+	if err = s.decide(ctx, ActionCreateApiKey, "api_keys", err); err != nil {
+		return entity.ApiKey{}, err
+	}
+	for _, scope := range scopes {
+		if !knownScope(scope) {
+			return entity.ApiKey{}, fmt.Errorf("%v: %w", scope, entity.ErrWrongScope)
+		}
+	}
+
+	key := entity.ApiKey{
+		UserId:    identity.UserId,
+		Name:      name,
+		Scopes:    scopes,
+		CreatedAt: getTime(),
+	}
+	if err = newApiKey(&key); err != nil {
+		return entity.ApiKey{}, fmt.Errorf("AdvertService - CreateApiKey - %w", err)
+	}
+
+	err = s.keys.Store(ctx, &key)
+	if err != nil {
+		return entity.ApiKey{}, fmt.Errorf("AdvertService - CreateApiKey: %w", err)
+	}
+
+	return key, nil
+}
+
+// GetApiKeys returns keys of caller, revoked ones included
+func (s *AdvertService) GetApiKeys(ctx context.Context) ([]entity.ApiKey, error) {
+	identity, err := sessionCaller(ctx)
+	if err = s.decide(ctx, ActionGetApiKeys, "api_keys", err); err != nil {
+		return nil, err
+	}
+
+	keys, err := s.keys.GetByUser(ctx, identity.UserId)
+	if err != nil {
+		return nil, fmt.Errorf("AdvertService - GetApiKeys: %w", err)
+	}
+	return keys, nil
+}
+
+// RotateApiKey replaces key keeping its scopes, old key stops working at once
+func (s *AdvertService) RotateApiKey(ctx context.Context, id int64) (entity.ApiKey, error) {
+	key, err := s.callerKey(ctx, ActionRotateApiKey, id)
+	if err != nil {
+		return entity.ApiKey{}, err
+	}
+
+	if err = newApiKey(&key); err != nil {
+		return entity.ApiKey{}, fmt.Errorf("AdvertService - RotateApiKey - %w", err)
+	}
+	err = s.keys.Rotate(ctx, key)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.ApiKey{}, entity.ErrItemNotExists
+		}
+		return entity.ApiKey{}, fmt.Errorf("AdvertService - RotateApiKey: %w", err)
+	}
+
+	return key, nil
+}
+
+// RevokeApiKey stops key from working for good
+func (s *AdvertService) RevokeApiKey(ctx context.Context, id int64) error {
+	if _, err := s.callerKey(ctx, ActionRevokeApiKey, id); err != nil {
+		return err
+	}
+
+	err := s.keys.Revoke(ctx, id, getTime())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.ErrItemNotExists
+		}
+		return fmt.Errorf("AdvertService - RevokeApiKey: %w", err)
+	}
+	return nil
+}
+
+// callerKey returns key of caller which is not revoked,
+// keys of other users are reported as not existing
+func (s *AdvertService) callerKey(ctx context.Context, action Action,
+	id int64) (entity.ApiKey, error) {
+	resource := fmt.Sprintf("api_keys/%v", id)
+	identity, err := sessionCaller(ctx)
+	if err != nil {
+		return entity.ApiKey{}, s.decide(ctx, action, resource, err)
+	}
+
+	key, err := s.keys.GetById(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.ApiKey{}, entity.ErrItemNotExists
+		}
+		return entity.ApiKey{}, fmt.Errorf("callerKey - %w", err)
+	}
+
+	var decision error
+	if key.UserId != identity.UserId {
+		decision = entity.ErrForbidden
+	}
+	if err = s.decide(ctx, action, resource, decision); err != nil {
+		if errors.Is(err, entity.ErrForbidden) {
+			return entity.ApiKey{}, entity.ErrItemNotExists
+		}
+		return entity.ApiKey{}, err
+	}
+	if key.RevokedAt != "" {
+		return entity.ApiKey{}, entity.ErrItemNotExists
+	}
+	return key, nil
+}
+
+// authenticateKey returns identity limited to scopes of key
+// and records when key was used
+func (s *AdvertService) authenticateKey(ctx context.Context, token string) (entity.Identity, error) {
+	prefix, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return entity.Identity{}, entity.ErrUnauthenticated
+	}
+
+	key, err := s.keys.GetByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.Identity{}, entity.ErrUnauthenticated
+		}
+		return entity.Identity{}, fmt.Errorf("authenticateKey - %w", err)
+	}
+	if key.RevokedAt != "" ||
+		subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashToken(token))) != 1 {
+		return entity.Identity{}, entity.ErrUnauthenticated
+	}
+
+	if err = s.keys.Touch(ctx, key.Id, getTime()); err != nil {
+		return entity.Identity{}, fmt.Errorf("authenticateKey - %w", err)
+	}
+
+	user, err := s.users.GetById(ctx, key.UserId)
+	if err != nil {
+		return entity.Identity{}, fmt.Errorf("authenticateKey - %w", err)
+	}
+
+	identity, err := s.identity(ctx, user)
+	if err != nil {
+		return entity.Identity{}, fmt.Errorf("authenticateKey - %w", err)
+	}
+	identity.ApiKeyId = key.Id
+	identity.Scopes = key.Scopes
+	return identity, nil
+}
+
+// newApiKey gives key new random prefix and secret
+func newApiKey(key *entity.ApiKey) error {
+	prefix := make([]byte, prefixLength)
+	if _, err := rand.Read(prefix); err != nil {
+		return fmt.Errorf("newApiKey - Read: %w", err)
+	}
+	secret := make([]byte, tokenLength)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("newApiKey - Read: %w", err)
+	}
+
+	key.Prefix = ApiKeyPrefix + hex.EncodeToString(prefix)
+	key.Key = key.Prefix + "." + base64.RawURLEncoding.EncodeToString(secret)
+	key.KeyHash = hashToken(key.Key)
+	return nil
+}
+
+// isApiKey tells key from session token, which never has dot
+func isApiKey(token string) bool {
+	return strings.HasPrefix(token, ApiKeyPrefix) && strings.Contains(token, ".")
+}
+
+func knownScope(scope string) bool {
+	for _, known := range entity.Scopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
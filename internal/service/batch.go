@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// Batch checks every operation the way single one is checked and applies
+// them in one transaction, if atomic nothing is applied once any of them fails.
+// Errors of operations are set to them, operations which already have errors
+// are skipped, returned error means batch is not run
+func (s *AdvertService) Batch(ctx context.Context, ops []entity.BatchOperation,
+	atomic bool) error {
+	// adverts to be changed are taken at once and only to be authorized
+	ids := []int64{}
+	for _, op := range ops {
+		if op.Err == nil && op.Action != entity.ActionCreate {
+			ids = append(ids, op.Advert.Id)
+		}
+	}
+	adverts, err := s.repo.GetByIds(context.WithValue(ctx, entity.KeyFields, entity.Fields{}), ids)
+	if err != nil {
+		return fmt.Errorf("AdvertService - Batch - %w", err)
+	}
+	stored := map[int64]entity.Advert{}
+	for _, adv := range adverts {
+		stored[adv.Id] = adv
+	}
+
+	failed := false
+	for i := range ops {
+		if ops[i].Err != nil {
+			failed = true
+			continue
+		}
+		err = s.checkOperation(ctx, &ops[i], stored)
+		if err != nil {
+			if !isAdvertError(err) {
+				return fmt.Errorf("AdvertService - Batch - %w", err)
+			}
+			ops[i].Err = err
+			failed = true
+		}
+	}
+	if failed && atomic {
+		entity.AbortBatch(ops)
+		return nil
+	}
+
+	err = s.repo.Batch(ctx, ops, atomic)
+	if err != nil {
+		return fmt.Errorf("AdvertService - Batch - %w", err)
+	}
+	for i := range ops {
+		if errors.Is(ops[i].Err, sql.ErrNoRows) {
+			ops[i].Err = entity.ErrItemNotExists
+		}
+	}
+
+	return nil
+}
+
+// checkOperation checks operation of batch against stored adverts
+func (s *AdvertService) checkOperation(ctx context.Context, op *entity.BatchOperation,
+	stored map[int64]entity.Advert) error {
+	if op.Action == entity.ActionCreate {
+		return s.checkCreate(ctx, &op.Advert)
+	}
+
+	action := ActionDeleteAdvert
+	switch op.Action {
+	case entity.ActionUpdate:
+		action = ActionUpdateAdvert
+	case entity.ActionDelete:
+	default:
+		return fmt.Errorf("checkOperation - unknown action '%v'", op.Action)
+	}
+
+	adv, ok := stored[op.Advert.Id]
+	if !ok {
+		return entity.ErrItemNotExists
+	}
+	err := s.decide(ctx, action, advertResource(adv.Id), s.policy.Authorize(ctx, action, adv))
+	if err != nil || op.Action == entity.ActionDelete {
+		return err
+	}
+
+	if len(op.Advert.PhotosUrls) != 0 {
+		op.Advert.MainPhotoUrl = op.Advert.PhotosUrls[0]
+	}
+	err = s.checkCategory(ctx, op.Advert)
+	if err != nil {
+		return fmt.Errorf("checkOperation - %w", err)
+	}
+
+	return nil
+}
+
+// isAdvertError tells if error is caused by advert or its caller
+// rather than by failure of service
+func isAdvertError(err error) bool {
+	var attrErr *entity.AttributesError
+	return errors.Is(err, entity.ErrForbidden) || errors.Is(err, entity.ErrUnauthenticated) ||
+		errors.Is(err, entity.ErrItemNotExists) || errors.Is(err, entity.ErrVersionMismatch) ||
+		errors.Is(err, entity.ErrNameAlreadyExist) || errors.Is(err, entity.ErrWrongCategory) ||
+		errors.As(err, &attrErr)
+}
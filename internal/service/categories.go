@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/pkg/jsonschema"
+)
+
+// CreateCategory stores category, categories are managed by admins
+func (s *AdvertService) CreateCategory(ctx context.Context, cat entity.Category) (int64, error) {
+	err := s.manage(ctx, ActionCreateCategory, entity.PermissionManageCategories, "categories")
+	if err != nil {
+		return 0, err
+	}
+
+	if err := checkSchema(cat); err != nil {
+		return 0, entity.ErrWrongSchema
+	}
+
+	err = s.categories.Store(ctx, &cat)
+	if err != nil {
+		if errors.Is(err, entity.ErrWrongParent) {
+			return 0, entity.ErrWrongParent
+		}
+		return 0, fmt.Errorf("AdvertService - CreateCategory: %w", err)
+	}
+
+	return cat.Id, nil
+}
+
+// GetCategories returns tree of all categories
+func (s *AdvertService) GetCategories(ctx context.Context) ([]entity.Category, error) {
+	categories, err := s.categories.Fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("AdvertService - GetCategories: %w", err)
+	}
+
+	tree := buildTree(categories, 0)
+	if tree == nil {
+		tree = []entity.Category{}
+	}
+	return tree, nil
+}
+
+// GetCategory returns category with tree of its subcategories
+func (s *AdvertService) GetCategory(ctx context.Context, id int64) (entity.Category, error) {
+	categories, err := s.categories.Fetch(ctx)
+	if err != nil {
+		return entity.Category{}, fmt.Errorf("AdvertService - GetCategory: %w", err)
+	}
+
+	for _, cat := range categories {
+		if cat.Id == id {
+			cat.Children = buildTree(categories, id)
+			return cat, nil
+		}
+	}
+	return entity.Category{}, entity.ErrItemNotExists
+}
+
+// buildTree nests categories under given parent
+func buildTree(categories []entity.Category, parentId int64) []entity.Category {
+	var tree []entity.Category
+	for _, cat := range categories {
+		if cat.ParentId == parentId && cat.Id != parentId {
+			cat.Children = buildTree(categories, cat.Id)
+			tree = append(tree, cat)
+		}
+	}
+	return tree
+}
+
+// UpdateCategory replaces category, adverts already in category
+// are not checked against its new schema
+func (s *AdvertService) UpdateCategory(ctx context.Context, cat entity.Category) error {
+	err := s.manage(ctx, ActionUpdateCategory, entity.PermissionManageCategories,
+		categoryResource(cat.Id))
+	if err != nil {
+		return err
+	}
+
+	if err := checkSchema(cat); err != nil {
+		return entity.ErrWrongSchema
+	}
+
+	err = s.categories.Update(ctx, cat)
+	if err != nil {
+		if errors.Is(err, entity.ErrItemNotExists) {
+			return entity.ErrItemNotExists
+		}
+		if errors.Is(err, entity.ErrWrongParent) {
+			return entity.ErrWrongParent
+		}
+		return fmt.Errorf("AdvertService - UpdateCategory: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteCategory removes category which has neither subcategories nor adverts
+func (s *AdvertService) DeleteCategory(ctx context.Context, id int64) error {
+	err := s.manage(ctx, ActionDeleteCategory, entity.PermissionManageCategories,
+		categoryResource(id))
+	if err != nil {
+		return err
+	}
+
+	err = s.categories.Delete(ctx, id)
+	if err != nil {
+		if errors.Is(err, entity.ErrItemNotExists) {
+			return entity.ErrItemNotExists
+		}
+		if errors.Is(err, entity.ErrCategoryNotEmpty) {
+			return entity.ErrCategoryNotEmpty
+		}
+		return fmt.Errorf("AdvertService - DeleteCategory: %w", err)
+	}
+
+	return nil
+}
+
+// GetCategoryAdverts returns page of adverts of category and its descendants
+func (s *AdvertService) GetCategoryAdverts(ctx context.Context, id int64) (entity.AdvertsPage, error) {
+	_, err := s.categories.GetById(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.AdvertsPage{}, entity.ErrItemNotExists
+		}
+		return entity.AdvertsPage{}, fmt.Errorf("AdvertService - GetCategoryAdverts: %w", err)
+	}
+
+	filter, _ := ctx.Value(entity.KeyFilter).(entity.Filter)
+	filter.CategoryId = id
+	ctx = context.WithValue(ctx, entity.KeyFilter, filter)
+
+	page, err := s.repo.Fetch(ctx)
+	if err != nil {
+		return page, fmt.Errorf("AdvertService - GetCategoryAdverts: %w", err)
+	}
+	if len(page.Adverts) == 0 {
+		return page, entity.ErrNoItems
+	}
+	return page, nil
+}
+
+// checkCategory checks that advert's category exists and advert's attributes
+// match category's schema, advert without category may have any attributes
+func (s *AdvertService) checkCategory(ctx context.Context, adv entity.Advert) error {
+	if adv.CategoryId == 0 {
+		return nil
+	}
+
+	cat, err := s.categories.GetById(ctx, adv.CategoryId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.ErrWrongCategory
+		}
+		return fmt.Errorf("checkCategory - GetById: %w", err)
+	}
+	if len(cat.Schema) == 0 {
+		return nil
+	}
+
+	schema, err := jsonschema.Compile(cat.Schema)
+	if err != nil {
+		return fmt.Errorf("checkCategory - %w", err)
+	}
+	attributes := adv.Attributes
+	if len(attributes) == 0 {
+		attributes = json.RawMessage(`{}`)
+	}
+	violations, err := schema.Validate(attributes)
+	if err != nil {
+		return fmt.Errorf("checkCategory - %w", err)
+	}
+	if len(violations) != 0 {
+		return &entity.AttributesError{Violations: violations}
+	}
+	return nil
+}
+
+// checkSchema checks that category's schema is valid JSON Schema
+func checkSchema(cat entity.Category) error {
+	if len(cat.Schema) == 0 {
+		return nil
+	}
+	if _, err := jsonschema.Compile(cat.Schema); err != nil {
+		return fmt.Errorf("checkSchema - %v: %w", err, entity.ErrWrongSchema)
+	}
+	return nil
+}
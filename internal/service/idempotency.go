@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// ReserveIdempotencyKey reserves key for request with given hash for ttl.
+// If key is taken it returns response stored for the same request,
+// entity.ErrKeyReused if key was used for other request and
+// entity.ErrKeyInProgress while the first request is being handled.
+// Response without status means key is reserved for this request.
+func (s *AdvertService) ReserveIdempotencyKey(ctx context.Context, key, requestHash string,
+	ttl time.Duration) (entity.IdempotentResponse, error) {
+	now := time.Now()
+	resp := entity.IdempotentResponse{
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   now.Format(DateFormat),
+		ExpiresAt:   now.Add(ttl).Format(DateFormat),
+	}
+	err := s.idempotency.Reserve(ctx, &resp)
+	if err == nil {
+		return resp, nil
+	}
+	if !errors.Is(err, entity.ErrKeyAlreadyExist) {
+		return entity.IdempotentResponse{}, fmt.Errorf("AdvertService - ReserveIdempotencyKey: %w", err)
+	}
+
+	stored, err := s.idempotency.GetByKey(ctx, key)
+	if err != nil {
+		return entity.IdempotentResponse{}, fmt.Errorf("AdvertService - ReserveIdempotencyKey: %w", err)
+	}
+	switch {
+	case stored.RequestHash != requestHash:
+		return entity.IdempotentResponse{}, entity.ErrKeyReused
+	case stored.Status == 0:
+		return entity.IdempotentResponse{}, entity.ErrKeyInProgress
+	}
+	return stored, nil
+}
+
+// CompleteIdempotencyKey stores response to request which reserved key
+func (s *AdvertService) CompleteIdempotencyKey(ctx context.Context, key string, status int,
+	body []byte) error {
+	err := s.idempotency.Complete(ctx, entity.IdempotentResponse{Key: key, Status: status,
+		Body: body})
+	if err != nil {
+		return fmt.Errorf("AdvertService - CompleteIdempotencyKey: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey frees key of request which failed, so that it may be retried
+func (s *AdvertService) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	if err := s.idempotency.Delete(ctx, key); err != nil {
+		return fmt.Errorf("AdvertService - ReleaseIdempotencyKey: %w", err)
+	}
+	return nil
+}
+
+// PurgeIdempotencyKeys removes responses which expired
+func (s *AdvertService) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	purged, err := s.idempotency.Purge(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("AdvertService - PurgeIdempotencyKeys: %w", err)
+	}
+	return purged, nil
+}
@@ -3,25 +3,67 @@ package mock_service
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"github.com/mrsubudei/adv-store-service/internal/service"
+	"github.com/mrsubudei/adv-store-service/pkg/jsonschema"
 )
 
 type MockService struct {
-	Adverts []entity.Advert
-	Ids     int64
+	Adverts    []entity.Advert
+	Trash      []entity.Advert
+	Revisions  []entity.Revision
+	Categories []entity.Category
+	Users      []entity.User
+	Sessions   []entity.Session
+	Keys       []entity.ApiKey
+	// UserRoles holds names of roles by user id
+	UserRoles map[int64][]string
+	Audit     []entity.AuditEntry
+	// Responses holds responses by idempotency keys
+	Responses map[string]entity.IdempotentResponse
+	Ids       int64
+}
+
+// roles are roles users may be given, as seeded by migrations
+var roles = []entity.Role{
+	{Id: 1, Name: entity.RoleAdmin, Permissions: []string{
+		entity.PermissionUpdateAnyAdvert, entity.PermissionDeleteAnyAdvert,
+		entity.PermissionHideAdvert, entity.PermissionManageUsers,
+		entity.PermissionManageApiKeys, entity.PermissionReadAudit,
+		entity.PermissionManageCategories,
+	}},
+	{Id: 2, Name: entity.RoleModerator, Permissions: []string{entity.PermissionHideAdvert}},
 }
 
 func NewMockService() *MockService {
-	return &MockService{}
+	return &MockService{
+		UserRoles: make(map[int64][]string),
+		Responses: make(map[string]entity.IdempotentResponse),
+	}
 }
 
 func (ms *MockService) Create(ctx context.Context, adv entity.Advert) (int64, error) {
+	err := ms.decide(ctx, service.ActionCreateAdvert, "adverts", service.OwnerPolicy{}.Authorize(
+		ctx, service.ActionCreateAdvert, adv))
+	if err != nil {
+		return 0, err
+	}
+	if err := ms.checkCategory(ctx, adv); err != nil {
+		return 0, err
+	}
 	ms.Ids++
 	adv.MainPhotoUrl = adv.PhotosUrls[0]
 	adv.Id = ms.Ids
+	adv.Version = 1
+	adv.OwnerId = 0
+	if identity, ok := ctx.Value(entity.KeyIdentity).(entity.Identity); ok {
+		adv.OwnerId = identity.UserId
+	}
 
 	for i := 0; i < len(ms.Adverts); i++ {
 		if ms.Adverts[i].Name == adv.Name {
@@ -29,6 +71,7 @@ func (ms *MockService) Create(ctx context.Context, adv entity.Advert) (int64, er
 		}
 	}
 	ms.Adverts = append(ms.Adverts, adv)
+	ms.storeRevision(ctx, adv, entity.ActionCreate)
 
 	return ms.Ids, nil
 }
@@ -38,6 +81,14 @@ func (ms *MockService) GetById(ctx context.Context, id int64) (entity.Advert, er
 	for i := 0; i < len(ms.Adverts); i++ {
 		if ms.Adverts[i].Id == id {
 			adv := ms.Adverts[i]
+			policy := service.OwnerPolicy{}
+			if adv.HiddenAt != "" &&
+				policy.Authorize(ctx, service.ActionReadHiddenAdvert, adv) != nil {
+				return entity.Advert{}, entity.ErrItemNotExists
+			}
+			if fields, ok := ctx.Value(entity.KeyFields).(entity.Fields); ok {
+				return fields.Trim(adv), nil
+			}
 			adv.Id = 0
 			return adv, nil
 		}
@@ -45,6 +96,19 @@ func (ms *MockService) GetById(ctx context.Context, id int64) (entity.Advert, er
 	return entity.Advert{}, entity.ErrItemNotExists
 }
 
+func (ms *MockService) GetByIds(ctx context.Context, ids []int64) ([]entity.BatchAdvert, error) {
+	results := []entity.BatchAdvert{}
+	for _, id := range ids {
+		adv, err := ms.GetById(ctx, id)
+		if err != nil {
+			results = append(results, entity.BatchAdvert{Id: id, Err: err})
+			continue
+		}
+		results = append(results, entity.BatchAdvert{Id: id, Advert: adv})
+	}
+	return results, nil
+}
+
 func (ms *MockService) getById(ctx context.Context, id int64) (*entity.Advert, error) {
 	for i := 0; i < len(ms.Adverts); i++ {
 		if ms.Adverts[i].Id == id {
@@ -54,12 +118,26 @@ func (ms *MockService) getById(ctx context.Context, id int64) (*entity.Advert, e
 	return &entity.Advert{}, entity.ErrItemNotExists
 }
 
-func (ms *MockService) GetAll(ctx context.Context) ([]entity.Advert, error) {
+func (ms *MockService) GetAll(ctx context.Context) (entity.AdvertsPage, error) {
+	limit := entity.DefaultLimit
+	if val, ok := ctx.Value(entity.KeyLimit).(int); ok && val != 0 {
+		limit = val
+	}
+	page := entity.AdvertsPage{
+		Adverts:    ms.Adverts,
+		TotalCount: int64(len(ms.Adverts)),
+		Page:       1,
+		PageSize:   limit,
+	}
 	if len(ms.Adverts) == 0 {
-		return nil, entity.ErrNoItems
+		return page, entity.ErrNoItems
+	}
+	if len(page.Adverts) > limit {
+		page.Adverts = page.Adverts[:limit]
+		page.HasNext = true
 	}
 
-	return ms.Adverts, nil
+	return page, nil
 }
 
 func (ms *MockService) Update(ctx context.Context, adv entity.Advert) error {
@@ -70,48 +148,377 @@ func (ms *MockService) Update(ctx context.Context, adv entity.Advert) error {
 		}
 		return fmt.Errorf("AdvertService - Update: %w", err)
 	}
-	if exist.Name == adv.Name {
-		return entity.ErrNameAlreadyExist
+	if err := authorize(ctx, *exist); err != nil {
+		return err
 	}
-
-	if adv.Name != "" {
-		exist.Name = adv.Name
+	if err := ms.checkCategory(ctx, adv); err != nil {
+		return err
 	}
-
-	if adv.Description != "" {
-		exist.Description = adv.Description
+	for i := 0; i < len(ms.Adverts); i++ {
+		if ms.Adverts[i].Name == adv.Name && ms.Adverts[i].Id != adv.Id {
+			return entity.ErrNameAlreadyExist
+		}
 	}
 
-	if adv.Price != 0 {
-		exist.Price = adv.Price
+	if adv.Version != 0 && adv.Version != exist.Version {
+		return entity.ErrVersionMismatch
 	}
 
 	if len(adv.PhotosUrls) != 0 {
-		exist.MainPhotoUrl = adv.PhotosUrls[0]
-		exist.PhotosUrls = []string{}
-		exist.PhotosUrls = append(exist.PhotosUrls, adv.PhotosUrls...)
+		adv.MainPhotoUrl = adv.PhotosUrls[0]
 	}
+	adv.Version = exist.Version + 1
+	adv.OwnerId = exist.OwnerId
+	*exist = adv
+	ms.storeRevision(ctx, adv, entity.ActionUpdate)
 
 	return nil
 }
 
-func (ms *MockService) Delete(ctx context.Context, id int64) error {
-	newAdverts := []entity.Advert{}
-	found := false
-
+func (ms *MockService) Delete(ctx context.Context, id, version int64) error {
 	for i, v := range ms.Adverts {
 		if v.Id == id {
-			found = true
-			newAdverts = deleteElement(ms.Adverts, i)
+			if err := authorize(ctx, v); err != nil {
+				return err
+			}
+			if version != 0 && version != v.Version {
+				return entity.ErrVersionMismatch
+			}
+			v.DeletedAt = time.Now().Format("2006-01-02 15:04:05")
+			v.Version++
+			ms.Trash = append(ms.Trash, v)
+			ms.Adverts = deleteElement(ms.Adverts, i)
+			ms.storeRevision(ctx, v, entity.ActionDelete)
+			return nil
+		}
+	}
+	return entity.ErrItemNotExists
+}
+
+// Batch applies operations one by one, atomic batch is undone
+// by putting back adverts kept before it
+func (ms *MockService) Batch(ctx context.Context, ops []entity.BatchOperation,
+	atomic bool) error {
+	ids := ms.Ids
+	adverts := append([]entity.Advert{}, ms.Adverts...)
+	trash := append([]entity.Advert{}, ms.Trash...)
+	revisions := append([]entity.Revision{}, ms.Revisions...)
+	for i := range ops {
+		if ops[i].Err != nil && atomic {
+			entity.AbortBatch(ops)
+			return nil
+		}
+	}
+	for i := range ops {
+		if ops[i].Err != nil {
+			continue
+		}
+		var err error
+		switch ops[i].Action {
+		case entity.ActionCreate:
+			ops[i].Advert.Id, err = ms.Create(ctx, ops[i].Advert)
+		case entity.ActionUpdate:
+			err = ms.Update(ctx, ops[i].Advert)
+		case entity.ActionDelete:
+			err = ms.Delete(ctx, ops[i].Advert.Id, ops[i].Advert.Version)
+		}
+		if err != nil {
+			ops[i].Err = err
+			if atomic {
+				ms.Ids, ms.Adverts, ms.Trash, ms.Revisions = ids, adverts, trash, revisions
+				entity.AbortBatch(ops)
+				return nil
+			}
 		}
 	}
-	if found {
-		ms.Adverts = []entity.Advert{}
-		ms.Adverts = append(ms.Adverts, newAdverts...)
+	return nil
+}
+
+// authorize checks only adverts with owner, others may be changed by anyone
+func authorize(ctx context.Context, adv entity.Advert) error {
+	if adv.OwnerId == 0 {
 		return nil
-	} else {
-		return entity.ErrItemNotExists
 	}
+	return service.OwnerPolicy{}.Authorize(ctx, service.ActionUpdateAdvert, adv)
+}
+
+// GetTrash gives whole trash to admins and their own adverts to others
+func (ms *MockService) GetTrash(ctx context.Context) (entity.AdvertsPage, error) {
+	identity, _ := ctx.Value(entity.KeyIdentity).(entity.Identity)
+	err := ms.decide(ctx, service.ActionGetTrash, "adverts/trash", service.OwnerPolicy{}.Authorize(
+		ctx, service.ActionGetTrash, entity.Advert{OwnerId: identity.UserId}))
+	if err != nil {
+		return entity.AdvertsPage{}, err
+	}
+
+	trash := []entity.Advert{}
+	for _, v := range ms.Trash {
+		if identity.Can(entity.PermissionDeleteAnyAdvert) || v.OwnerId == identity.UserId {
+			trash = append(trash, v)
+		}
+	}
+	page := entity.AdvertsPage{
+		Adverts:    trash,
+		TotalCount: int64(len(trash)),
+		Page:       1,
+		PageSize:   entity.DefaultLimit,
+	}
+	if len(trash) == 0 {
+		return page, entity.ErrNoItems
+	}
+	return page, nil
+}
+
+func (ms *MockService) Restore(ctx context.Context, id int64) error {
+	for i, v := range ms.Trash {
+		if v.Id == id {
+			err := ms.decide(ctx, service.ActionRestoreAdvert, "", service.OwnerPolicy{}.Authorize(
+				ctx, service.ActionRestoreAdvert, v))
+			if err != nil {
+				return err
+			}
+			v.DeletedAt = ""
+			v.Version++
+			ms.Adverts = append(ms.Adverts, v)
+			ms.Trash = deleteElement(ms.Trash, i)
+			ms.storeRevision(ctx, v, entity.ActionRestore)
+			return nil
+		}
+	}
+	return entity.ErrItemNotExists
+}
+
+func (ms *MockService) PurgeTrash(ctx context.Context, retention time.Duration) (int64, error) {
+	before := time.Now().Add(-retention).Format("2006-01-02 15:04:05")
+	kept := []entity.Advert{}
+	for _, v := range ms.Trash {
+		if v.DeletedAt >= before {
+			kept = append(kept, v)
+		}
+	}
+	purged := int64(len(ms.Trash) - len(kept))
+	ms.Trash = kept
+	return purged, nil
+}
+
+func (ms *MockService) storeRevision(ctx context.Context, adv entity.Advert, action string) {
+	identity, _ := ctx.Value(entity.KeyIdentity).(entity.Identity)
+	adv.DeletedAt = ""
+	ms.Revisions = append(ms.Revisions, entity.Revision{
+		Revision:  adv.Version,
+		Action:    action,
+		Advert:    adv,
+		UserId:    identity.UserId,
+		ApiKeyId:  identity.ApiKeyId,
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+func (ms *MockService) GetRevisions(ctx context.Context, id int64) ([]entity.Revision, error) {
+	revisions := []entity.Revision{}
+	if err := ms.authorizeRevisions(ctx, id); err != nil {
+		return revisions, err
+	}
+	for _, v := range ms.Revisions {
+		if v.Advert.Id == id {
+			revisions = append(revisions, v)
+		}
+	}
+	if len(revisions) == 0 {
+		return revisions, entity.ErrItemNotExists
+	}
+	return revisions, nil
+}
+
+func (ms *MockService) GetRevision(ctx context.Context, id, rev int64) (entity.Revision, error) {
+	if err := ms.authorizeRevisions(ctx, id); err != nil {
+		return entity.Revision{}, err
+	}
+	return ms.getRevision(id, rev)
+}
+
+func (ms *MockService) getRevision(id, rev int64) (entity.Revision, error) {
+	for _, v := range ms.Revisions {
+		if v.Advert.Id == id && v.Revision == rev {
+			return v, nil
+		}
+	}
+	return entity.Revision{}, entity.ErrItemNotExists
+}
+
+func (ms *MockService) authorizeRevisions(ctx context.Context, id int64) error {
+	for _, v := range append(ms.Adverts, ms.Trash...) {
+		if v.Id == id {
+			return ms.decide(ctx, service.ActionReadRevisions, "", service.OwnerPolicy{}.Authorize(
+				ctx, service.ActionReadRevisions, v))
+		}
+	}
+	return entity.ErrItemNotExists
+}
+
+func (ms *MockService) DiffRevisions(ctx context.Context, id, from,
+	to int64) (entity.RevisionsDiff, error) {
+	diff := entity.RevisionsDiff{From: from, To: to, Changes: []entity.FieldChange{}}
+	if err := ms.authorizeRevisions(ctx, id); err != nil {
+		return diff, err
+	}
+	first, err := ms.getRevision(id, from)
+	if err != nil {
+		return diff, err
+	}
+	second, err := ms.getRevision(id, to)
+	if err != nil {
+		return diff, err
+	}
+	if first.Advert.Name != second.Advert.Name {
+		diff.Changes = append(diff.Changes, entity.FieldChange{Field: "name",
+			From: first.Advert.Name, To: second.Advert.Name})
+	}
+	if first.Advert.Price != second.Advert.Price {
+		diff.Changes = append(diff.Changes, entity.FieldChange{Field: "price",
+			From: first.Advert.Price, To: second.Advert.Price})
+	}
+	return diff, nil
+}
+
+func (ms *MockService) CreateCategory(ctx context.Context, cat entity.Category) (int64, error) {
+	if err := ms.manage(ctx, service.ActionCreateCategory); err != nil {
+		return 0, err
+	}
+	if cat.Schema != nil {
+		if _, err := jsonschema.Compile(cat.Schema); err != nil {
+			return 0, entity.ErrWrongSchema
+		}
+	}
+	if cat.ParentId != 0 {
+		if _, err := ms.GetCategory(ctx, cat.ParentId); err != nil {
+			return 0, entity.ErrWrongParent
+		}
+	}
+	cat.Id = int64(len(ms.Categories) + 1)
+	ms.Categories = append(ms.Categories, cat)
+	return cat.Id, nil
+}
+
+func (ms *MockService) GetCategories(ctx context.Context) ([]entity.Category, error) {
+	tree := ms.children(0)
+	if tree == nil {
+		tree = []entity.Category{}
+	}
+	return tree, nil
+}
+
+func (ms *MockService) GetCategory(ctx context.Context, id int64) (entity.Category, error) {
+	for _, v := range ms.Categories {
+		if v.Id == id {
+			v.Children = ms.children(id)
+			return v, nil
+		}
+	}
+	return entity.Category{}, entity.ErrItemNotExists
+}
+
+func (ms *MockService) children(parentId int64) []entity.Category {
+	var children []entity.Category
+	for _, v := range ms.Categories {
+		if v.ParentId == parentId {
+			v.Children = ms.children(v.Id)
+			children = append(children, v)
+		}
+	}
+	return children
+}
+
+func (ms *MockService) UpdateCategory(ctx context.Context, cat entity.Category) error {
+	if err := ms.manage(ctx, service.ActionUpdateCategory); err != nil {
+		return err
+	}
+	if cat.Schema != nil {
+		if _, err := jsonschema.Compile(cat.Schema); err != nil {
+			return entity.ErrWrongSchema
+		}
+	}
+	for i, v := range ms.Categories {
+		if v.Id == cat.Id {
+			if cat.ParentId == cat.Id {
+				return entity.ErrWrongParent
+			}
+			ms.Categories[i] = cat
+			return nil
+		}
+	}
+	return entity.ErrItemNotExists
+}
+
+func (ms *MockService) DeleteCategory(ctx context.Context, id int64) error {
+	if err := ms.manage(ctx, service.ActionDeleteCategory); err != nil {
+		return err
+	}
+	if _, err := ms.GetCategory(ctx, id); err != nil {
+		return err
+	}
+	for _, v := range ms.Categories {
+		if v.ParentId == id {
+			return entity.ErrCategoryNotEmpty
+		}
+	}
+	for _, v := range ms.Adverts {
+		if v.CategoryId == id {
+			return entity.ErrCategoryNotEmpty
+		}
+	}
+	for i, v := range ms.Categories {
+		if v.Id == id {
+			ms.Categories = deleteElement(ms.Categories, i)
+		}
+	}
+	return nil
+}
+
+func (ms *MockService) GetCategoryAdverts(ctx context.Context, id int64) (entity.AdvertsPage, error) {
+	if _, err := ms.GetCategory(ctx, id); err != nil {
+		return entity.AdvertsPage{}, err
+	}
+	page := entity.AdvertsPage{Adverts: []entity.Advert{}, Page: 1, PageSize: entity.DefaultLimit}
+	for _, v := range ms.Adverts {
+		if v.CategoryId == id {
+			page.Adverts = append(page.Adverts, v)
+		}
+	}
+	page.TotalCount = int64(len(page.Adverts))
+	if len(page.Adverts) == 0 {
+		return page, entity.ErrNoItems
+	}
+	return page, nil
+}
+
+func (ms *MockService) checkCategory(ctx context.Context, adv entity.Advert) error {
+	if adv.CategoryId == 0 {
+		return nil
+	}
+	cat, err := ms.GetCategory(ctx, adv.CategoryId)
+	if err != nil {
+		return entity.ErrWrongCategory
+	}
+	if cat.Schema == nil {
+		return nil
+	}
+	schema, err := jsonschema.Compile(cat.Schema)
+	if err != nil {
+		return err
+	}
+	attributes := adv.Attributes
+	if attributes == nil {
+		attributes = json.RawMessage(`{}`)
+	}
+	violations, err := schema.Validate(attributes)
+	if err != nil {
+		return err
+	}
+	if len(violations) != 0 {
+		return &entity.AttributesError{Violations: violations}
+	}
+	return nil
 }
 
 func deleteElement[C any](sl []C, index int) []C {
@@ -120,3 +527,383 @@ func deleteElement[C any](sl []C, index int) []C {
 	newSlice = append(newSlice, sl[index+1:]...)
 	return newSlice
 }
+
+// Register keeps password as is in place of hash
+func (ms *MockService) Register(ctx context.Context, email, password string) (int64, error) {
+	for _, v := range ms.Users {
+		if v.Email == email {
+			return 0, entity.ErrEmailAlreadyExist
+		}
+	}
+	user := entity.User{Id: int64(len(ms.Users) + 1), Email: email, PasswordHash: password}
+	ms.Users = append(ms.Users, user)
+	return user.Id, nil
+}
+
+func (ms *MockService) Login(ctx context.Context, email, password string,
+	ttl time.Duration) (entity.Session, error) {
+	for _, v := range ms.Users {
+		if v.Email == email && v.PasswordHash == password {
+			session := entity.Session{
+				Token:     fmt.Sprintf("token-%v-%v", v.Id, len(ms.Sessions)+1),
+				UserId:    v.Id,
+				ExpiresAt: time.Now().Add(ttl).Format("2006-01-02 15:04:05"),
+			}
+			ms.Sessions = append(ms.Sessions, session)
+			return session, nil
+		}
+	}
+	return entity.Session{}, entity.ErrWrongCredentials
+}
+
+func (ms *MockService) Logout(ctx context.Context, token string) error {
+	for i, v := range ms.Sessions {
+		if v.Token == token {
+			ms.Sessions = append(ms.Sessions[:i], ms.Sessions[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (ms *MockService) Authenticate(ctx context.Context, token string) (entity.Identity, error) {
+	for _, v := range ms.Keys {
+		if v.Key == token && v.RevokedAt == "" {
+			for _, user := range ms.Users {
+				if user.Id == v.UserId {
+					identity := ms.identity(user)
+					identity.ApiKeyId = v.Id
+					identity.Scopes = v.Scopes
+					return identity, nil
+				}
+			}
+		}
+	}
+	for _, v := range ms.Sessions {
+		if v.Token == token {
+			for _, user := range ms.Users {
+				if user.Id == v.UserId {
+					return ms.identity(user), nil
+				}
+			}
+		}
+	}
+	return entity.Identity{}, entity.ErrUnauthenticated
+}
+
+// CreateApiKey gives keys as 'ak_<id>.<n>' and keeps them as is in place of hash
+func (ms *MockService) CreateApiKey(ctx context.Context, name string,
+	scopes []string) (entity.ApiKey, error) {
+	identity, err := keysOwner(ctx)
+	if err != nil {
+		return entity.ApiKey{}, err
+	}
+	for _, scope := range scopes {
+		if scope != entity.ScopeAdvertsRead && scope != entity.ScopeAdvertsWrite {
+			return entity.ApiKey{}, entity.ErrWrongScope
+		}
+	}
+
+	id := int64(len(ms.Keys) + 1)
+	key := entity.ApiKey{
+		Id:     id,
+		UserId: identity.UserId,
+		Name:   name,
+		Prefix: fmt.Sprintf("%v%v", service.ApiKeyPrefix, id),
+		Scopes: scopes,
+	}
+	key.Key = fmt.Sprintf("%v.%v", key.Prefix, 1)
+	ms.Keys = append(ms.Keys, key)
+	return key, nil
+}
+
+func (ms *MockService) GetApiKeys(ctx context.Context) ([]entity.ApiKey, error) {
+	identity, err := keysOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys := []entity.ApiKey{}
+	for _, v := range ms.Keys {
+		if v.UserId == identity.UserId {
+			v.Key = ""
+			keys = append(keys, v)
+		}
+	}
+	return keys, nil
+}
+
+func (ms *MockService) RotateApiKey(ctx context.Context, id int64) (entity.ApiKey, error) {
+	key, err := ms.callerKey(ctx, id)
+	if err != nil {
+		return entity.ApiKey{}, err
+	}
+	key.Key = fmt.Sprintf("%v.%v", key.Prefix, time.Now().UnixNano())
+	return *key, nil
+}
+
+func (ms *MockService) RevokeApiKey(ctx context.Context, id int64) error {
+	key, err := ms.callerKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	key.RevokedAt = time.Now().Format("2006-01-02 15:04:05")
+	return nil
+}
+
+func (ms *MockService) callerKey(ctx context.Context, id int64) (*entity.ApiKey, error) {
+	identity, err := keysOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ms.Keys {
+		if ms.Keys[i].Id == id && ms.Keys[i].UserId == identity.UserId &&
+			ms.Keys[i].RevokedAt == "" {
+			return &ms.Keys[i], nil
+		}
+	}
+	return nil, entity.ErrItemNotExists
+}
+
+func keysOwner(ctx context.Context) (entity.Identity, error) {
+	identity, ok := ctx.Value(entity.KeyIdentity).(entity.Identity)
+	if !ok {
+		return entity.Identity{}, entity.ErrUnauthenticated
+	}
+	if identity.ApiKeyId != 0 {
+		return entity.Identity{}, entity.ErrForbidden
+	}
+	return identity, nil
+}
+
+func (ms *MockService) identity(user entity.User) entity.Identity {
+	identity := entity.Identity{UserId: user.Id, Email: user.Email}
+	for _, name := range ms.UserRoles[user.Id] {
+		for _, role := range roles {
+			if role.Name == name {
+				identity.Roles = append(identity.Roles, name)
+				identity.Permissions = append(identity.Permissions, role.Permissions...)
+			}
+		}
+	}
+	return identity
+}
+
+// HideAdvert records no revision
+func (ms *MockService) HideAdvert(ctx context.Context, id int64, hidden bool) error {
+	adv, err := ms.getById(ctx, id)
+	if err != nil {
+		return err
+	}
+	action := service.ActionHideAdvert
+	if !hidden {
+		action = service.ActionUnhideAdvert
+	}
+	err = ms.decide(ctx, action, fmt.Sprintf("adverts/%v", id),
+		service.OwnerPolicy{}.Authorize(ctx, action, *adv))
+	if err != nil {
+		return err
+	}
+
+	adv.HiddenAt = ""
+	if hidden {
+		adv.HiddenAt = time.Now().Format("2006-01-02 15:04:05")
+	}
+	return nil
+}
+
+func (ms *MockService) GetUser(ctx context.Context, id int64) (entity.User, error) {
+	if err := ms.can(ctx, service.ActionGetUser, entity.PermissionManageUsers); err != nil {
+		return entity.User{}, err
+	}
+	for _, v := range ms.Users {
+		if v.Id == id {
+			v.Roles = ms.UserRoles[id]
+			return v, nil
+		}
+	}
+	return entity.User{}, entity.ErrItemNotExists
+}
+
+func (ms *MockService) GetRoles(ctx context.Context) ([]entity.Role, error) {
+	if err := ms.can(ctx, service.ActionGetRoles, entity.PermissionManageUsers); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (ms *MockService) AssignRole(ctx context.Context, userId int64, role string) error {
+	if err := ms.checkRole(ctx, service.ActionAssignRole, userId, role); err != nil {
+		return err
+	}
+	for _, v := range ms.UserRoles[userId] {
+		if v == role {
+			return nil
+		}
+	}
+	ms.UserRoles[userId] = append(ms.UserRoles[userId], role)
+	return nil
+}
+
+func (ms *MockService) UnassignRole(ctx context.Context, userId int64, role string) error {
+	if err := ms.checkRole(ctx, service.ActionUnassignRole, userId, role); err != nil {
+		return err
+	}
+	for i, v := range ms.UserRoles[userId] {
+		if v == role {
+			ms.UserRoles[userId] = deleteElement(ms.UserRoles[userId], i)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (ms *MockService) checkRole(ctx context.Context, action service.Action,
+	userId int64, role string) error {
+	if err := ms.can(ctx, action, entity.PermissionManageUsers); err != nil {
+		return err
+	}
+	if !ms.userExists(userId) {
+		return entity.ErrItemNotExists
+	}
+	for _, v := range roles {
+		if v.Name == role {
+			return nil
+		}
+	}
+	return entity.ErrWrongRole
+}
+
+func (ms *MockService) GetUserApiKeys(ctx context.Context, userId int64) ([]entity.ApiKey, error) {
+	err := ms.can(ctx, service.ActionGetUserApiKeys, entity.PermissionManageApiKeys)
+	if err != nil {
+		return nil, err
+	}
+	if !ms.userExists(userId) {
+		return nil, entity.ErrItemNotExists
+	}
+	keys := []entity.ApiKey{}
+	for _, v := range ms.Keys {
+		if v.UserId == userId {
+			v.Key = ""
+			keys = append(keys, v)
+		}
+	}
+	return keys, nil
+}
+
+func (ms *MockService) RevokeUserApiKey(ctx context.Context, userId, id int64) error {
+	err := ms.can(ctx, service.ActionRevokeUserApiKey, entity.PermissionManageApiKeys)
+	if err != nil {
+		return err
+	}
+	for i := range ms.Keys {
+		if ms.Keys[i].Id == id && ms.Keys[i].UserId == userId && ms.Keys[i].RevokedAt == "" {
+			ms.Keys[i].RevokedAt = time.Now().Format("2006-01-02 15:04:05")
+			return nil
+		}
+	}
+	return entity.ErrItemNotExists
+}
+
+// GetAudit returns whole trail newest first
+func (ms *MockService) GetAudit(ctx context.Context) ([]entity.AuditEntry, error) {
+	if err := ms.can(ctx, service.ActionGetAudit, entity.PermissionReadAudit); err != nil {
+		return nil, err
+	}
+	entries := []entity.AuditEntry{}
+	for i := len(ms.Audit) - 1; i >= 0; i-- {
+		entries = append(entries, ms.Audit[i])
+	}
+	return entries, nil
+}
+
+func (ms *MockService) CheckScope(ctx context.Context, scope, resource string) error {
+	identity, ok := ctx.Value(entity.KeyIdentity).(entity.Identity)
+	if !ok || identity.HasScope(scope) {
+		return nil
+	}
+	return ms.decide(ctx, service.Action(scope), resource, entity.ErrForbidden)
+}
+
+func (ms *MockService) can(ctx context.Context, action service.Action, permission string) error {
+	identity, err := keysOwner(ctx)
+	if err == nil && !identity.Can(permission) {
+		err = entity.ErrForbidden
+	}
+	return ms.decide(ctx, action, "", err)
+}
+
+// manage lets admins and their keys with write scope manage categories
+func (ms *MockService) manage(ctx context.Context, action service.Action) error {
+	identity, ok := ctx.Value(entity.KeyIdentity).(entity.Identity)
+	var err error
+	switch {
+	case !ok:
+		err = entity.ErrUnauthenticated
+	case !identity.HasScope(entity.ScopeAdvertsWrite) ||
+		!identity.Can(entity.PermissionManageCategories):
+		err = entity.ErrForbidden
+	}
+	return ms.decide(ctx, action, "", err)
+}
+
+func (ms *MockService) decide(ctx context.Context, action service.Action, resource string,
+	decision error) error {
+	identity, _ := ctx.Value(entity.KeyIdentity).(entity.Identity)
+	ms.Audit = append(ms.Audit, entity.AuditEntry{
+		Id:       int64(len(ms.Audit) + 1),
+		UserId:   identity.UserId,
+		ApiKeyId: identity.ApiKeyId,
+		Action:   string(action),
+		Resource: resource,
+		Allowed:  decision == nil,
+	})
+	return decision
+}
+
+func (ms *MockService) userExists(id int64) bool {
+	for _, v := range ms.Users {
+		if v.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ReserveIdempotencyKey ignores ttl, keys never expire
+func (ms *MockService) ReserveIdempotencyKey(ctx context.Context, key, requestHash string,
+	ttl time.Duration) (entity.IdempotentResponse, error) {
+	stored, ok := ms.Responses[key]
+	switch {
+	case !ok:
+		resp := entity.IdempotentResponse{Key: key, RequestHash: requestHash}
+		ms.Responses[key] = resp
+		return resp, nil
+	case stored.RequestHash != requestHash:
+		return entity.IdempotentResponse{}, entity.ErrKeyReused
+	case stored.Status == 0:
+		return entity.IdempotentResponse{}, entity.ErrKeyInProgress
+	}
+	return stored, nil
+}
+
+func (ms *MockService) CompleteIdempotencyKey(ctx context.Context, key string, status int,
+	body []byte) error {
+	resp, ok := ms.Responses[key]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	resp.Status = status
+	resp.Body = append([]byte(nil), body...)
+	ms.Responses[key] = resp
+	return nil
+}
+
+func (ms *MockService) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	delete(ms.Responses, key)
+	return nil
+}
+
+func (ms *MockService) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	return 0, nil
+}
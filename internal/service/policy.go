@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// Action is operation which policy or permission check allows or denies,
+// every decision is recorded in audit trail
+type Action string
+
+const (
+	ActionCreateAdvert     Action = "adverts:create"
+	ActionUpdateAdvert     Action = "adverts:update"
+	ActionDeleteAdvert     Action = "adverts:delete"
+	ActionHideAdvert       Action = "adverts:hide"
+	ActionUnhideAdvert     Action = "adverts:unhide"
+	ActionReadHiddenAdvert Action = "adverts:read_hidden"
+	ActionGetTrash         Action = "adverts:read_trash"
+	ActionRestoreAdvert    Action = "adverts:restore"
+	ActionReadRevisions    Action = "adverts:read_revisions"
+	ActionCreateApiKey     Action = "api_keys:create"
+	ActionGetApiKeys       Action = "api_keys:read"
+	ActionRotateApiKey     Action = "api_keys:rotate"
+	ActionRevokeApiKey     Action = "api_keys:revoke"
+	ActionGetUser          Action = "users:read"
+	ActionGetRoles         Action = "roles:read"
+	ActionAssignRole       Action = "users:assign_role"
+	ActionUnassignRole     Action = "users:unassign_role"
+	ActionGetUserApiKeys   Action = "users:read_api_keys"
+	ActionRevokeUserApiKey Action = "users:revoke_api_key"
+	ActionGetAudit         Action = "audit:read"
+	ActionCreateCategory   Action = "categories:create"
+	ActionUpdateCategory   Action = "categories:update"
+	ActionDeleteCategory   Action = "categories:delete"
+)
+
+// Policy decides if caller whose identity is in context may perform
+// action on advert, it returns entity.ErrUnauthenticated for anonymous
+// caller and entity.ErrForbidden for caller without rights
+type Policy interface {
+	Authorize(ctx context.Context, action Action, adv entity.Advert) error
+}
+
+// anyAdvertPermission is permission letting caller perform action on any advert
+var anyAdvertPermission = map[Action]string{
+	ActionUpdateAdvert:     entity.PermissionUpdateAnyAdvert,
+	ActionDeleteAdvert:     entity.PermissionDeleteAnyAdvert,
+	ActionHideAdvert:       entity.PermissionHideAdvert,
+	ActionUnhideAdvert:     entity.PermissionHideAdvert,
+	ActionReadHiddenAdvert: entity.PermissionHideAdvert,
+	ActionGetTrash:         entity.PermissionDeleteAnyAdvert,
+	ActionRestoreAdvert:    entity.PermissionDeleteAnyAdvert,
+	ActionReadRevisions:    entity.PermissionHideAdvert,
+}
+
+// OwnerPolicy lets anyone create adverts, owner of advert change and see
+// it and users whose roles grant permission act on any advert. Adverts
+// are hidden only by such users. Users who may delete any advert see
+// and restore whole trash, others only their own adverts in it. History of
+// advert is seen by its owner and moderators. Caller authenticated with API key
+// needs its read scope to see hidden advert, trash or history and its write
+// scope for other actions.
+type OwnerPolicy struct{}
+
+func (OwnerPolicy) Authorize(ctx context.Context, action Action, adv entity.Advert) error {
+	scope := entity.ScopeAdvertsWrite
+	if action == ActionReadHiddenAdvert || action == ActionGetTrash ||
+		action == ActionReadRevisions {
+		scope = entity.ScopeAdvertsRead
+	}
+
+	identity, ok := ctx.Value(entity.KeyIdentity).(entity.Identity)
+	switch {
+	case ok && !identity.HasScope(scope):
+		return entity.ErrForbidden
+	case action == ActionCreateAdvert:
+		return nil
+	case !ok:
+		return entity.ErrUnauthenticated
+	case identity.Can(anyAdvertPermission[action]):
+		return nil
+	case action != ActionHideAdvert && action != ActionUnhideAdvert &&
+		adv.OwnerId != 0 && adv.OwnerId == identity.UserId:
+		return nil
+	}
+	return entity.ErrForbidden
+}
+
+// can checks that caller has permission, users and keys are
+// administered only with session token
+func (s *AdvertService) can(ctx context.Context, action Action, permission,
+	resource string) error {
+	identity, err := sessionCaller(ctx)
+	if err == nil && !identity.Can(permission) {
+		err = entity.ErrForbidden
+	}
+	return s.decide(ctx, action, resource, err)
+}
+
+// CheckScope checks that caller authenticated with API key has scope needed
+// for request to resource, denial is recorded in audit trail. Allowed
+// requests are recorded by actions they lead to.
+func (s *AdvertService) CheckScope(ctx context.Context, scope, resource string) error {
+	identity, ok := ctx.Value(entity.KeyIdentity).(entity.Identity)
+	if !ok || identity.HasScope(scope) {
+		return nil
+	}
+	return s.decide(ctx, Action(scope), resource, entity.ErrForbidden)
+}
+
+// manage checks that caller has permission, unlike can it lets caller
+// authenticated with API key act if key has write scope
+func (s *AdvertService) manage(ctx context.Context, action Action, permission,
+	resource string) error {
+	identity, ok := ctx.Value(entity.KeyIdentity).(entity.Identity)
+	var err error
+	switch {
+	case !ok:
+		err = entity.ErrUnauthenticated
+	case !identity.HasScope(entity.ScopeAdvertsWrite) || !identity.Can(permission):
+		err = entity.ErrForbidden
+	}
+	return s.decide(ctx, action, resource, err)
+}
+
+// decide records decision on caller's action in audit trail and returns it,
+// action is denied if decision could not be recorded
+func (s *AdvertService) decide(ctx context.Context, action Action, resource string,
+	decision error) error {
+	identity, _ := ctx.Value(entity.KeyIdentity).(entity.Identity)
+	entry := entity.AuditEntry{
+		UserId:    identity.UserId,
+		ApiKeyId:  identity.ApiKeyId,
+		Action:    string(action),
+		Resource:  resource,
+		Allowed:   decision == nil,
+		CreatedAt: getTime(),
+	}
+	if err := s.audit.Store(ctx, &entry); err != nil {
+		return fmt.Errorf("decide - %w", err)
+	}
+	return decision
+}
+
+// sessionCaller returns caller authenticated with session token
+func sessionCaller(ctx context.Context) (entity.Identity, error) {
+	identity, ok := ctx.Value(entity.KeyIdentity).(entity.Identity)
+	if !ok {
+		return entity.Identity{}, entity.ErrUnauthenticated
+	}
+	if identity.ApiKeyId != 0 {
+		return entity.Identity{}, entity.ErrForbidden
+	}
+	return identity, nil
+}
+
+func advertResource(id int64) string {
+	return fmt.Sprintf("adverts/%v", id)
+}
+
+func categoryResource(id int64) string {
+	return fmt.Sprintf("categories/%v", id)
+}
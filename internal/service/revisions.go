@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// GetRevisions returns history of advert, it is seen only by
+// owner of advert and moderators
+func (s *AdvertService) GetRevisions(ctx context.Context, id int64) ([]entity.Revision, error) {
+	err := s.authorizeRevisions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := s.repo.GetRevisions(ctx, id)
+	if err != nil {
+		return revisions, fmt.Errorf("AdvertService - GetRevisions: %w", err)
+	}
+	if len(revisions) == 0 {
+		return revisions, entity.ErrItemNotExists
+	}
+	return revisions, nil
+}
+
+func (s *AdvertService) GetRevision(ctx context.Context, id, rev int64) (entity.Revision, error) {
+	err := s.authorizeRevisions(ctx, id)
+	if err != nil {
+		return entity.Revision{}, err
+	}
+	return s.getRevision(ctx, id, rev)
+}
+
+func (s *AdvertService) getRevision(ctx context.Context, id, rev int64) (entity.Revision, error) {
+	revision, err := s.repo.GetRevision(ctx, id, rev)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return revision, entity.ErrItemNotExists
+		}
+		return revision, fmt.Errorf("AdvertService - GetRevision: %w", err)
+	}
+	return revision, nil
+}
+
+// DiffRevisions returns advert's fields which differ between two revisions
+func (s *AdvertService) DiffRevisions(ctx context.Context, id, from,
+	to int64) (entity.RevisionsDiff, error) {
+	diff := entity.RevisionsDiff{From: from, To: to, Changes: []entity.FieldChange{}}
+
+	err := s.authorizeRevisions(ctx, id)
+	if err != nil {
+		return diff, err
+	}
+
+	first, err := s.getRevision(ctx, id, from)
+	if err != nil {
+		return diff, fmt.Errorf("AdvertService - DiffRevisions - %w", err)
+	}
+	second, err := s.getRevision(ctx, id, to)
+	if err != nil {
+		return diff, fmt.Errorf("AdvertService - DiffRevisions - %w", err)
+	}
+
+	fields := []struct {
+		name     string
+		from, to interface{}
+	}{
+		{"name", first.Advert.Name, second.Advert.Name},
+		{"description", first.Advert.Description, second.Advert.Description},
+		{"price", first.Advert.Price, second.Advert.Price},
+		{"main_photo_url", first.Advert.MainPhotoUrl, second.Advert.MainPhotoUrl},
+		{"photo_urls", first.Advert.PhotosUrls, second.Advert.PhotosUrls},
+		{"category_id", first.Advert.CategoryId, second.Advert.CategoryId},
+		{"attributes", decodeAttributes(first.Advert.Attributes),
+			decodeAttributes(second.Advert.Attributes)},
+	}
+	for _, field := range fields {
+		if !reflect.DeepEqual(field.from, field.to) {
+			diff.Changes = append(diff.Changes, entity.FieldChange{
+				Field: field.name,
+				From:  field.from,
+				To:    field.to,
+			})
+		}
+	}
+
+	return diff, nil
+}
+
+// authorizeRevisions checks that caller may see history of advert,
+// advert in trash keeps its history and owner
+func (s *AdvertService) authorizeRevisions(ctx context.Context, id int64) error {
+	adv, err := s.repo.GetById(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		adv, err = s.repo.GetDeletedById(ctx, id)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.ErrItemNotExists
+		}
+		return fmt.Errorf("authorizeRevisions - %w", err)
+	}
+
+	return s.decide(ctx, ActionReadRevisions, advertResource(id),
+		s.policy.Authorize(ctx, ActionReadRevisions, adv))
+}
+
+// decodeAttributes makes attributes comparable regardless of their formatting
+func decodeAttributes(attributes json.RawMessage) interface{} {
+	var decoded interface{}
+	if err := json.Unmarshal(attributes, &decoded); err != nil {
+		return nil
+	}
+	return decoded
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+)
+
+// HideAdvert hides advert from lists and from users who may not hide
+// adverts, or shows it again
+func (s *AdvertService) HideAdvert(ctx context.Context, id int64, hidden bool) error {
+	action := ActionHideAdvert
+	if !hidden {
+		action = ActionUnhideAdvert
+	}
+	err := s.authorize(ctx, action, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.Hide(ctx, id, hidden)
+	if err != nil {
+		if errors.Is(err, entity.ErrItemNotExists) {
+			return entity.ErrItemNotExists
+		}
+		return fmt.Errorf("AdvertService - HideAdvert: %w", err)
+	}
+	return nil
+}
+
+// GetUser returns user with names of its roles
+func (s *AdvertService) GetUser(ctx context.Context, id int64) (entity.User, error) {
+	err := s.can(ctx, ActionGetUser, entity.PermissionManageUsers, fmt.Sprintf("users/%v", id))
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	user, err := s.users.GetById(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.User{}, entity.ErrItemNotExists
+		}
+		return entity.User{}, fmt.Errorf("AdvertService - GetUser: %w", err)
+	}
+
+	roles, err := s.roles.GetByUser(ctx, id)
+	if err != nil {
+		return entity.User{}, fmt.Errorf("AdvertService - GetUser: %w", err)
+	}
+	for _, role := range roles {
+		user.Roles = append(user.Roles, role.Name)
+	}
+	return user, nil
+}
+
+func (s *AdvertService) GetRoles(ctx context.Context) ([]entity.Role, error) {
+	err := s.can(ctx, ActionGetRoles, entity.PermissionManageUsers, "roles")
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.roles.Fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("AdvertService - GetRoles: %w", err)
+	}
+	return roles, nil
+}
+
+// AssignRole grants role to user, assigning role user has already is no-op
+func (s *AdvertService) AssignRole(ctx context.Context, userId int64, role string) error {
+	err := s.can(ctx, ActionAssignRole, entity.PermissionManageUsers,
+		fmt.Sprintf("users/%v/roles/%v", userId, role))
+	if err != nil {
+		return err
+	}
+	if err = s.checkUser(ctx, userId); err != nil {
+		return err
+	}
+
+	err = s.roles.Assign(ctx, userId, role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.ErrWrongRole
+		}
+		return fmt.Errorf("AdvertService - AssignRole: %w", err)
+	}
+	return nil
+}
+
+// UnassignRole takes role from user, taking role user has not is no-op
+func (s *AdvertService) UnassignRole(ctx context.Context, userId int64, role string) error {
+	err := s.can(ctx, ActionUnassignRole, entity.PermissionManageUsers,
+		fmt.Sprintf("users/%v/roles/%v", userId, role))
+	if err != nil {
+		return err
+	}
+	if err = s.checkUser(ctx, userId); err != nil {
+		return err
+	}
+
+	err = s.roles.Unassign(ctx, userId, role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.ErrWrongRole
+		}
+		return fmt.Errorf("AdvertService - UnassignRole: %w", err)
+	}
+	return nil
+}
+
+// GetUserApiKeys returns keys of any user, revoked ones included
+func (s *AdvertService) GetUserApiKeys(ctx context.Context, userId int64) ([]entity.ApiKey, error) {
+	err := s.can(ctx, ActionGetUserApiKeys, entity.PermissionManageApiKeys,
+		fmt.Sprintf("users/%v/api_keys", userId))
+	if err != nil {
+		return nil, err
+	}
+	if err = s.checkUser(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	keys, err := s.keys.GetByUser(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("AdvertService - GetUserApiKeys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeUserApiKey revokes key of any user
+func (s *AdvertService) RevokeUserApiKey(ctx context.Context, userId, id int64) error {
+	err := s.can(ctx, ActionRevokeUserApiKey, entity.PermissionManageApiKeys,
+		fmt.Sprintf("api_keys/%v", id))
+	if err != nil {
+		return err
+	}
+
+	key, err := s.keys.GetById(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.ErrItemNotExists
+		}
+		return fmt.Errorf("AdvertService - RevokeUserApiKey: %w", err)
+	}
+	if key.UserId != userId || key.RevokedAt != "" {
+		return entity.ErrItemNotExists
+	}
+
+	err = s.keys.Revoke(ctx, id, getTime())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.ErrItemNotExists
+		}
+		return fmt.Errorf("AdvertService - RevokeUserApiKey: %w", err)
+	}
+	return nil
+}
+
+// GetAudit returns page of audit trail newest first
+func (s *AdvertService) GetAudit(ctx context.Context) ([]entity.AuditEntry, error) {
+	err := s.can(ctx, ActionGetAudit, entity.PermissionReadAudit, "audit")
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.audit.Fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("AdvertService - GetAudit: %w", err)
+	}
+	return entries, nil
+}
+
+// identity returns identity of user with names of its roles
+// and permissions they grant
+func (s *AdvertService) identity(ctx context.Context, user entity.User) (entity.Identity, error) {
+	identity := entity.Identity{UserId: user.Id, Email: user.Email}
+	roles, err := s.roles.GetByUser(ctx, user.Id)
+	if err != nil {
+		return identity, fmt.Errorf("identity: %w", err)
+	}
+
+	granted := make(map[string]bool)
+	for _, role := range roles {
+		identity.Roles = append(identity.Roles, role.Name)
+		for _, permission := range role.Permissions {
+			if !granted[permission] {
+				granted[permission] = true
+				identity.Permissions = append(identity.Permissions, permission)
+			}
+		}
+	}
+	return identity, nil
+}
+
+func (s *AdvertService) checkUser(ctx context.Context, id int64) error {
+	_, err := s.users.GetById(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.ErrItemNotExists
+		}
+		return fmt.Errorf("checkUser - %w", err)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/mrsubudei/adv-store-service/internal/entity"
 )
@@ -9,7 +10,43 @@ import (
 type Service interface {
 	Create(ctx context.Context, adv entity.Advert) (int64, error)
 	GetById(ctx context.Context, id int64) (entity.Advert, error)
-	GetAll(ctx context.Context) ([]entity.Advert, error)
+	GetByIds(ctx context.Context, ids []int64) ([]entity.BatchAdvert, error)
+	GetAll(ctx context.Context) (entity.AdvertsPage, error)
 	Update(ctx context.Context, adv entity.Advert) error
-	Delete(ctx context.Context, id int64) error
+	Delete(ctx context.Context, id, version int64) error
+	Batch(ctx context.Context, ops []entity.BatchOperation, atomic bool) error
+	GetTrash(ctx context.Context) (entity.AdvertsPage, error)
+	Restore(ctx context.Context, id int64) error
+	PurgeTrash(ctx context.Context, retention time.Duration) (int64, error)
+	GetRevisions(ctx context.Context, id int64) ([]entity.Revision, error)
+	GetRevision(ctx context.Context, id, rev int64) (entity.Revision, error)
+	DiffRevisions(ctx context.Context, id, from, to int64) (entity.RevisionsDiff, error)
+	CreateCategory(ctx context.Context, cat entity.Category) (int64, error)
+	GetCategories(ctx context.Context) ([]entity.Category, error)
+	GetCategory(ctx context.Context, id int64) (entity.Category, error)
+	UpdateCategory(ctx context.Context, cat entity.Category) error
+	DeleteCategory(ctx context.Context, id int64) error
+	GetCategoryAdverts(ctx context.Context, id int64) (entity.AdvertsPage, error)
+	Register(ctx context.Context, email, password string) (int64, error)
+	Login(ctx context.Context, email, password string, ttl time.Duration) (entity.Session, error)
+	Logout(ctx context.Context, token string) error
+	Authenticate(ctx context.Context, token string) (entity.Identity, error)
+	CreateApiKey(ctx context.Context, name string, scopes []string) (entity.ApiKey, error)
+	GetApiKeys(ctx context.Context) ([]entity.ApiKey, error)
+	RotateApiKey(ctx context.Context, id int64) (entity.ApiKey, error)
+	RevokeApiKey(ctx context.Context, id int64) error
+	HideAdvert(ctx context.Context, id int64, hidden bool) error
+	GetUser(ctx context.Context, id int64) (entity.User, error)
+	GetRoles(ctx context.Context) ([]entity.Role, error)
+	AssignRole(ctx context.Context, userId int64, role string) error
+	UnassignRole(ctx context.Context, userId int64, role string) error
+	GetUserApiKeys(ctx context.Context, userId int64) ([]entity.ApiKey, error)
+	RevokeUserApiKey(ctx context.Context, userId, id int64) error
+	GetAudit(ctx context.Context) ([]entity.AuditEntry, error)
+	CheckScope(ctx context.Context, scope, resource string) error
+	ReserveIdempotencyKey(ctx context.Context, key, requestHash string,
+		ttl time.Duration) (entity.IdempotentResponse, error)
+	CompleteIdempotencyKey(ctx context.Context, key string, status int, body []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+	PurgeIdempotencyKeys(ctx context.Context) (int64, error)
 }
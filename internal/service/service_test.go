@@ -2,9 +2,12 @@ package service_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/mrsubudei/adv-store-service/internal/entity"
 	m "github.com/mrsubudei/adv-store-service/internal/repository/mock"
@@ -51,9 +54,24 @@ var (
 	}
 )
 
+// ownerContext returns context of request made by user
+func ownerContext(userId int64) context.Context {
+	return context.WithValue(context.Background(), entity.KeyIdentity,
+		entity.Identity{UserId: userId})
+}
+
+// adminContext returns context of request made by user with admin role
+func adminContext(userId int64) context.Context {
+	return context.WithValue(context.Background(), entity.KeyIdentity,
+		entity.Identity{UserId: userId, Roles: []string{entity.RoleAdmin},
+			Permissions: []string{entity.PermissionManageCategories}})
+}
+
 func TestCreate(t *testing.T) {
 	mockRepo := m.NewMockRepo()
-	service := service.NewAdvertService(mockRepo)
+	service := service.NewAdvertService(mockRepo, m.NewMockCategoryRepo(), m.NewMockUserRepo(),
+		m.NewMockApiKeyRepo(), m.NewMockRoleRepo(), m.NewMockAuditRepo(),
+		m.NewMockIdempotencyRepo())
 	ctx := context.Background()
 
 	t.Run("OK", func(t *testing.T) {
@@ -75,7 +93,9 @@ func TestCreate(t *testing.T) {
 
 func TestGetById(t *testing.T) {
 	mockRepo := m.NewMockRepo()
-	service := service.NewAdvertService(mockRepo)
+	service := service.NewAdvertService(mockRepo, m.NewMockCategoryRepo(), m.NewMockUserRepo(),
+		m.NewMockApiKeyRepo(), m.NewMockRoleRepo(), m.NewMockAuditRepo(),
+		m.NewMockIdempotencyRepo())
 	ctx := context.Background()
 
 	t.Run("OK", func(t *testing.T) {
@@ -90,6 +110,7 @@ func TestGetById(t *testing.T) {
 		}
 
 		advert1.CreatedAt = found.CreatedAt
+		advert1.Version = 1
 
 		if !reflect.DeepEqual(advert1, found) {
 			t.Fatalf("mismatch: %#v != %#v", advert1, found)
@@ -105,9 +126,62 @@ func TestGetById(t *testing.T) {
 	})
 }
 
+func TestGetByIds(t *testing.T) {
+	mockRepo := m.NewMockRepo()
+	service := service.NewAdvertService(mockRepo, m.NewMockCategoryRepo(), m.NewMockUserRepo(),
+		m.NewMockApiKeyRepo(), m.NewMockRoleRepo(), m.NewMockAuditRepo(),
+		m.NewMockIdempotencyRepo())
+
+	hidden, err := service.Create(ownerContext(1), advert1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shown, err := service.Create(ownerContext(1), advert2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mockRepo.Hide(context.Background(), hidden, true); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want []error
+	}{
+		{"Hidden from others", ownerContext(2), []error{nil, entity.ErrItemNotExists,
+			entity.ErrItemNotExists}},
+		{"Hidden shown to owner", ownerContext(1), []error{nil, entity.ErrItemNotExists, nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := []int64{shown, 89, hidden}
+			results, err := service.GetByIds(tt.ctx, ids)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != len(ids) {
+				t.Fatalf("want %v results, got: %v", len(ids), len(results))
+			}
+			for i, res := range results {
+				if res.Id != ids[i] || !errors.Is(res.Err, tt.want[i]) {
+					t.Fatalf("want id %v with error %v, got: %v with %v", ids[i],
+						tt.want[i], res.Id, res.Err)
+				}
+				if res.Err == nil && res.Advert.Id != ids[i] {
+					t.Fatalf("want advert %v, got: %+v", ids[i], res.Advert)
+				}
+			}
+		})
+	}
+}
+
 func TestGetAll(t *testing.T) {
 	mockRepo := m.NewMockRepo()
-	service := service.NewAdvertService(mockRepo)
+	service := service.NewAdvertService(mockRepo, m.NewMockCategoryRepo(), m.NewMockUserRepo(),
+		m.NewMockApiKeyRepo(), m.NewMockRoleRepo(), m.NewMockAuditRepo(),
+		m.NewMockIdempotencyRepo())
 	ctx := context.Background()
 
 	t.Run("Error no items", func(t *testing.T) {
@@ -133,16 +207,18 @@ func TestGetAll(t *testing.T) {
 
 		if found, err := service.GetAll(ctx); err != nil {
 			t.Fatal(err)
-		} else if len(found) != 3 {
-			t.Fatalf("want: %d, got: %d", 3, len(found))
+		} else if len(found.Adverts) != 3 {
+			t.Fatalf("want: %d, got: %d", 3, len(found.Adverts))
 		}
 	})
 }
 
 func TestUpdate(t *testing.T) {
 	mockRepo := m.NewMockRepo()
-	service := service.NewAdvertService(mockRepo)
-	ctx := context.Background()
+	service := service.NewAdvertService(mockRepo, m.NewMockCategoryRepo(), m.NewMockUserRepo(),
+		m.NewMockApiKeyRepo(), m.NewMockRoleRepo(), m.NewMockAuditRepo(),
+		m.NewMockIdempotencyRepo())
+	ctx := ownerContext(1)
 
 	t.Run("OK", func(t *testing.T) {
 		var id int64
@@ -174,12 +250,57 @@ func TestUpdate(t *testing.T) {
 		}
 
 		updated.CreatedAt = found.CreatedAt
+		updated.OwnerId = 1
+		updated.Version = 2
+
+		if !reflect.DeepEqual(updated, found) {
+			t.Fatalf("mismatch: %#v != %#v", updated, found)
+		}
+	})
+
+	t.Run("OK zero values replace fields", func(t *testing.T) {
+		updated := entity.Advert{
+			Id:           1,
+			Name:         "updated name",
+			MainPhotoUrl: "updated url 2",
+			PhotosUrls: []string{
+				"updated url 2",
+			},
+		}
+
+		if err := service.Update(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+
+		found, err := service.GetById(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		updated.CreatedAt = found.CreatedAt
+		updated.OwnerId = 1
+		updated.Version = 3
 
 		if !reflect.DeepEqual(updated, found) {
 			t.Fatalf("mismatch: %#v != %#v", updated, found)
 		}
 	})
 
+	t.Run("Err version mismatch", func(t *testing.T) {
+		updated := entity.Advert{
+			Id:         1,
+			Name:       "outdated name",
+			PhotosUrls: []string{"updated url 1"},
+			Version:    1,
+		}
+
+		if err := service.Update(ctx, updated); err == nil {
+			t.Fatal("Error expected")
+		} else if !errors.Is(err, entity.ErrVersionMismatch) {
+			t.Fatalf("want: %v, got: %v", entity.ErrVersionMismatch, err)
+		}
+	})
+
 	t.Run("Err item not found", func(t *testing.T) {
 		updated := entity.Advert{
 			Id:           987,
@@ -204,8 +325,10 @@ func TestUpdate(t *testing.T) {
 
 func TestDelete(t *testing.T) {
 	mockRepo := m.NewMockRepo()
-	service := service.NewAdvertService(mockRepo)
-	ctx := context.Background()
+	service := service.NewAdvertService(mockRepo, m.NewMockCategoryRepo(), m.NewMockUserRepo(),
+		m.NewMockApiKeyRepo(), m.NewMockRoleRepo(), m.NewMockAuditRepo(),
+		m.NewMockIdempotencyRepo())
+	ctx := ownerContext(1)
 
 	t.Run("OK", func(t *testing.T) {
 		var id int64
@@ -214,7 +337,7 @@ func TestDelete(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		if err := service.Delete(ctx, id); err != nil {
+		if err := service.Delete(ctx, id, 0); err != nil {
 			t.Fatal(err)
 		}
 
@@ -227,10 +350,677 @@ func TestDelete(t *testing.T) {
 
 	t.Run("Err item not found", func(t *testing.T) {
 
-		if err := service.Delete(ctx, 1); err == nil {
+		if err := service.Delete(ctx, 1, 0); err == nil {
 			t.Fatal("Error expected")
 		} else if !errors.Is(err, entity.ErrItemNotExists) {
 			t.Fatalf("want: %v, got: %v", entity.ErrItemNotExists, err)
 		}
 	})
+
+	t.Run("OK trash of owner", func(t *testing.T) {
+		if page, err := service.GetTrash(ctx); err != nil || len(page.Adverts) != 1 {
+			t.Fatalf("want 1 advert, got: %v, %v", page.Adverts, err)
+		}
+		if _, err := service.GetTrash(ownerContext(2)); !errors.Is(err, entity.ErrNoItems) {
+			t.Fatalf("want: %v, got: %v", entity.ErrNoItems, err)
+		}
+		if _, err := service.GetTrash(context.Background()); !errors.Is(err,
+			entity.ErrUnauthenticated) {
+			t.Fatalf("want: %v, got: %v", entity.ErrUnauthenticated, err)
+		}
+	})
+
+	t.Run("OK restore by owner", func(t *testing.T) {
+		if err := service.Restore(ownerContext(2), 1); !errors.Is(err, entity.ErrForbidden) {
+			t.Fatalf("want: %v, got: %v", entity.ErrForbidden, err)
+		}
+		if err := service.Restore(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := service.Restore(ctx, 1); !errors.Is(err, entity.ErrItemNotExists) {
+			t.Fatalf("want: %v, got: %v", entity.ErrItemNotExists, err)
+		}
+	})
+}
+
+func TestBatch(t *testing.T) {
+	mockRepo := m.NewMockRepo()
+	service := service.NewAdvertService(mockRepo, m.NewMockCategoryRepo(), m.NewMockUserRepo(),
+		m.NewMockApiKeyRepo(), m.NewMockRoleRepo(), m.NewMockAuditRepo(),
+		m.NewMockIdempotencyRepo())
+	for _, adv := range []entity.Advert{advert1, advert2} {
+		if _, err := service.Create(ownerContext(1), adv); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Best effort", func(t *testing.T) {
+		ops := []entity.BatchOperation{
+			{Action: entity.ActionCreate, Advert: advert3},
+			{Action: entity.ActionUpdate, Advert: advert1},
+			{Action: entity.ActionDelete, Advert: entity.Advert{Id: 89}},
+		}
+		if err := service.Batch(ownerContext(2), ops, false); err != nil {
+			t.Fatal(err)
+		}
+		want := []error{nil, entity.ErrForbidden, entity.ErrItemNotExists}
+		for i, op := range ops {
+			if !errors.Is(op.Err, want[i]) {
+				t.Fatalf("operation %v: want: %v, got: %v", i, want[i], op.Err)
+			}
+		}
+		if len(mockRepo.Adverts) != 3 || mockRepo.Adverts[2].OwnerId != 2 {
+			t.Fatalf("want advert created by caller, got: %+v", mockRepo.Adverts)
+		}
+	})
+
+	t.Run("Atomic", func(t *testing.T) {
+		wrong := advert1
+		wrong.CategoryId = 99
+		ops := []entity.BatchOperation{
+			{Action: entity.ActionDelete, Advert: entity.Advert{Id: advert2.Id}},
+			{Action: entity.ActionUpdate, Advert: wrong},
+		}
+		if err := service.Batch(ownerContext(1), ops, true); err != nil {
+			t.Fatal(err)
+		}
+		want := []error{entity.ErrBatchAborted, entity.ErrWrongCategory}
+		for i, op := range ops {
+			if !errors.Is(op.Err, want[i]) {
+				t.Fatalf("operation %v: want: %v, got: %v", i, want[i], op.Err)
+			}
+		}
+		if len(mockRepo.Adverts) != 3 || len(mockRepo.Trash) != 0 {
+			t.Fatalf("want no changes, got: %+v", mockRepo.Adverts)
+		}
+	})
+}
+
+func TestDiffRevisions(t *testing.T) {
+	mockRepo := m.NewMockRepo()
+	service := service.NewAdvertService(mockRepo, m.NewMockCategoryRepo(), m.NewMockUserRepo(),
+		m.NewMockApiKeyRepo(), m.NewMockRoleRepo(), m.NewMockAuditRepo(),
+		m.NewMockIdempotencyRepo())
+	ctx := ownerContext(1)
+
+	if _, err := service.Create(ctx, advert1); err != nil {
+		t.Fatal(err)
+	}
+	updated := advert1
+	updated.Price = 300
+	updated.Description = "changed"
+	if err := service.Update(ctx, updated); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		diff, err := service.DiffRevisions(ctx, advert1.Id, 1, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []entity.FieldChange{
+			{Field: "description", From: advert1.Description, To: updated.Description},
+			{Field: "price", From: advert1.Price, To: updated.Price},
+		}
+		if !reflect.DeepEqual(want, diff.Changes) {
+			t.Fatalf("want: %v, got: %v", want, diff.Changes)
+		}
+	})
+
+	t.Run("OK no changes", func(t *testing.T) {
+		diff, err := service.DiffRevisions(ctx, advert1.Id, 2, 2)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(diff.Changes) != 0 {
+			t.Fatalf("want no changes, got: %v", diff.Changes)
+		}
+	})
+
+	t.Run("Err revision not found", func(t *testing.T) {
+		if _, err := service.DiffRevisions(ctx, advert1.Id, 1, 7); !errors.Is(err,
+			entity.ErrItemNotExists) {
+			t.Fatalf("want: %v, got: %v", entity.ErrItemNotExists, err)
+		}
+	})
+
+	t.Run("OK author recorded", func(t *testing.T) {
+		revisions, err := service.GetRevisions(ctx, advert1.Id)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(revisions) != 2 || revisions[1].UserId != 1 {
+			t.Fatalf("want revisions made by user 1, got: %+v", revisions)
+		}
+	})
+
+	t.Run("OK moderator", func(t *testing.T) {
+		moderator := context.WithValue(context.Background(), entity.KeyIdentity,
+			entity.Identity{UserId: 2, Permissions: []string{entity.PermissionHideAdvert}})
+		if _, err := service.GetRevision(moderator, advert1.Id, 1); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Err other user", func(t *testing.T) {
+		if _, err := service.DiffRevisions(ownerContext(2), advert1.Id, 1, 2); !errors.Is(err,
+			entity.ErrForbidden) {
+			t.Fatalf("want: %v, got: %v", entity.ErrForbidden, err)
+		}
+		if _, err := service.GetRevisions(context.Background(), advert1.Id); !errors.Is(err,
+			entity.ErrUnauthenticated) {
+			t.Fatalf("want: %v, got: %v", entity.ErrUnauthenticated, err)
+		}
+	})
+}
+
+func TestCategories(t *testing.T) {
+	mockCategories := m.NewMockCategoryRepo()
+	mockCategories.Categories = []entity.Category{
+		{Id: 1, Name: "vehicles", AdvertsCount: 3},
+		{Id: 2, Name: "cars", ParentId: 1, AdvertsCount: 2},
+		{Id: 3, Name: "toys"},
+		{Id: 4, Name: "trucks", ParentId: 1, AdvertsCount: 1},
+	}
+	service := service.NewAdvertService(m.NewMockRepo(), mockCategories, m.NewMockUserRepo(),
+		m.NewMockApiKeyRepo(), m.NewMockRoleRepo(), m.NewMockAuditRepo(),
+		m.NewMockIdempotencyRepo())
+	ctx := context.Background()
+
+	t.Run("OK tree", func(t *testing.T) {
+		tree, err := service.GetCategories(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []entity.Category{
+			{Id: 1, Name: "vehicles", AdvertsCount: 3, Children: []entity.Category{
+				{Id: 2, Name: "cars", ParentId: 1, AdvertsCount: 2},
+				{Id: 4, Name: "trucks", ParentId: 1, AdvertsCount: 1},
+			}},
+			{Id: 3, Name: "toys"},
+		}
+		if !reflect.DeepEqual(want, tree) {
+			t.Fatalf("want: %v, got: %v", want, tree)
+		}
+	})
+
+	t.Run("OK subtree", func(t *testing.T) {
+		cat, err := service.GetCategory(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(cat.Children) != 2 {
+			t.Fatalf("want 2 children, got: %v", cat.Children)
+		}
+	})
+
+	t.Run("Err category not found", func(t *testing.T) {
+		if _, err := service.GetCategory(ctx, 7); !errors.Is(err, entity.ErrItemNotExists) {
+			t.Fatalf("want: %v, got: %v", entity.ErrItemNotExists, err)
+		}
+		if _, err := service.GetCategoryAdverts(ctx, 7); !errors.Is(err, entity.ErrItemNotExists) {
+			t.Fatalf("want: %v, got: %v", entity.ErrItemNotExists, err)
+		}
+	})
+
+	t.Run("Err category not empty", func(t *testing.T) {
+		if err := service.DeleteCategory(adminContext(1), 1); !errors.Is(err,
+			entity.ErrCategoryNotEmpty) {
+			t.Fatalf("want: %v, got: %v", entity.ErrCategoryNotEmpty, err)
+		}
+	})
+
+	t.Run("Err change without admin", func(t *testing.T) {
+		cat := entity.Category{Id: 3, Name: "games"}
+		if _, err := service.CreateCategory(ctx, cat); !errors.Is(err,
+			entity.ErrUnauthenticated) {
+			t.Fatalf("want: %v, got: %v", entity.ErrUnauthenticated, err)
+		}
+		if err := service.UpdateCategory(ownerContext(1), cat); !errors.Is(err,
+			entity.ErrForbidden) {
+			t.Fatalf("want: %v, got: %v", entity.ErrForbidden, err)
+		}
+		if err := service.DeleteCategory(ownerContext(1), 3); !errors.Is(err,
+			entity.ErrForbidden) {
+			t.Fatalf("want: %v, got: %v", entity.ErrForbidden, err)
+		}
+	})
+
+	t.Run("Err advert's category not exists", func(t *testing.T) {
+		adv := advert1
+		adv.CategoryId = 7
+		if _, err := service.Create(ctx, adv); !errors.Is(err, entity.ErrWrongCategory) {
+			t.Fatalf("want: %v, got: %v", entity.ErrWrongCategory, err)
+		}
+		adv.CategoryId = 2
+		if _, err := service.Create(ctx, adv); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestAttributes(t *testing.T) {
+	mockCategories := m.NewMockCategoryRepo()
+	mockCategories.Categories = []entity.Category{
+		{Id: 1, Name: "cars", Schema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"year": {"type": "integer", "minimum": 1900},
+				"mileage": {"type": "number", "minimum": 0}
+			},
+			"required": ["year"],
+			"additionalProperties": false
+		}`)},
+		{Id: 2, Name: "toys"},
+	}
+	service := service.NewAdvertService(m.NewMockRepo(), mockCategories, m.NewMockUserRepo(),
+		m.NewMockApiKeyRepo(), m.NewMockRoleRepo(), m.NewMockAuditRepo(),
+		m.NewMockIdempotencyRepo())
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		categoryId int64
+		attributes string
+		violations []string
+	}{
+		{
+			name:       "OK",
+			categoryId: 1,
+			attributes: `{"year": 2015, "mileage": 12000.5}`,
+		},
+		{
+			name:       "OK category without schema",
+			categoryId: 2,
+			attributes: `{"color": "red"}`,
+		},
+		{
+			name:       "Err missing attributes",
+			categoryId: 1,
+			violations: []string{`/: property "year" is required`},
+		},
+		{
+			name:       "Err wrong attributes",
+			categoryId: 1,
+			attributes: `{"year": 1850.5, "color": "red"}`,
+			violations: []string{
+				`/: property "color" is not allowed`,
+				`/year: should be integer`,
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adv := advert1
+			adv.Id = int64(i + 1)
+			adv.CategoryId = tt.categoryId
+			if tt.attributes != "" {
+				adv.Attributes = json.RawMessage(tt.attributes)
+			}
+
+			_, err := service.Create(ctx, adv)
+			var attrErr *entity.AttributesError
+			if tt.violations == nil && err != nil {
+				t.Fatal(err)
+			} else if tt.violations != nil && (!errors.As(err, &attrErr) ||
+				!reflect.DeepEqual(attrErr.Violations, tt.violations)) {
+				t.Fatalf("want: %v, got: %v", tt.violations, err)
+			}
+		})
+	}
+
+	t.Run("Err wrong schema", func(t *testing.T) {
+		cat := entity.Category{Name: "flats", Schema: json.RawMessage(`{"type": "room"}`)}
+		if _, err := service.CreateCategory(adminContext(1), cat); !errors.Is(err,
+			entity.ErrWrongSchema) {
+			t.Fatalf("want: %v, got: %v", entity.ErrWrongSchema, err)
+		}
+	})
+}
+
+func TestUsers(t *testing.T) {
+	mockUsers := m.NewMockUserRepo()
+	service := service.NewAdvertService(m.NewMockRepo(), m.NewMockCategoryRepo(), mockUsers,
+		m.NewMockApiKeyRepo(), m.NewMockRoleRepo(), m.NewMockAuditRepo(),
+		m.NewMockIdempotencyRepo())
+	ctx := context.Background()
+
+	id, err := service.Register(ctx, " User@Example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mockUsers.Users[0].Email != "user@example.com" ||
+		mockUsers.Users[0].PasswordHash == "password" {
+		t.Fatalf("email should be normalized and password hashed: %+v", mockUsers.Users[0])
+	}
+
+	t.Run("Err email exists", func(t *testing.T) {
+		_, err := service.Register(ctx, "user@example.com", "password2")
+		if !errors.Is(err, entity.ErrEmailAlreadyExist) {
+			t.Fatalf("want: %v, got: %v", entity.ErrEmailAlreadyExist, err)
+		}
+	})
+
+	t.Run("Err wrong credentials", func(t *testing.T) {
+		for _, cred := range [][2]string{{"user@example.com", "wrong"}, {"other@example.com", "password"}} {
+			_, err := service.Login(ctx, cred[0], cred[1], time.Hour)
+			if !errors.Is(err, entity.ErrWrongCredentials) {
+				t.Fatalf("want: %v, got: %v", entity.ErrWrongCredentials, err)
+			}
+		}
+	})
+
+	t.Run("OK session", func(t *testing.T) {
+		session, err := service.Login(ctx, "USER@example.com", "password", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if session.Token == "" || mockUsers.Sessions[0].TokenHash == session.Token {
+			t.Fatalf("token should be stored hashed: %+v", mockUsers.Sessions[0])
+		}
+
+		identity, err := service.Authenticate(ctx, session.Token)
+		want := entity.Identity{UserId: id, Email: "user@example.com"}
+		if err != nil || !reflect.DeepEqual(identity, want) {
+			t.Fatalf("want: %v, got: %v, %v", want, identity, err)
+		}
+
+		if err = service.Logout(ctx, session.Token); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = service.Authenticate(ctx, session.Token); !errors.Is(err, entity.ErrUnauthenticated) {
+			t.Fatalf("want: %v, got: %v", entity.ErrUnauthenticated, err)
+		}
+	})
+
+	t.Run("Err expired session", func(t *testing.T) {
+		session, err := service.Login(ctx, "user@example.com", "password", -time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = service.Authenticate(ctx, session.Token); !errors.Is(err, entity.ErrUnauthenticated) {
+			t.Fatalf("want: %v, got: %v", entity.ErrUnauthenticated, err)
+		}
+		if len(mockUsers.Sessions) != 0 {
+			t.Fatalf("expired session should be removed: %+v", mockUsers.Sessions)
+		}
+	})
+}
+
+func TestPolicy(t *testing.T) {
+	mockRepo := m.NewMockRepo()
+	service := service.NewAdvertService(mockRepo, m.NewMockCategoryRepo(), m.NewMockUserRepo(),
+		m.NewMockApiKeyRepo(), m.NewMockRoleRepo(), m.NewMockAuditRepo(),
+		m.NewMockIdempotencyRepo())
+	admin := context.WithValue(context.Background(), entity.KeyIdentity,
+		entity.Identity{UserId: 3, Roles: []string{entity.RoleAdmin}, Permissions: []string{
+			entity.PermissionUpdateAnyAdvert, entity.PermissionDeleteAnyAdvert}})
+
+	owned := advert1
+	if _, err := service.Create(ownerContext(1), owned); err != nil {
+		t.Fatal(err)
+	}
+	anonymous := advert2
+	if _, err := service.Create(context.Background(), anonymous); err != nil {
+		t.Fatal(err)
+	}
+	if mockRepo.Adverts[0].OwnerId != 1 || mockRepo.Adverts[1].OwnerId != 0 {
+		t.Fatalf("want owners 1 and 0, got: %v and %v", mockRepo.Adverts[0].OwnerId,
+			mockRepo.Adverts[1].OwnerId)
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		id   int64
+		want error
+	}{
+		{"Err anonymous", context.Background(), owned.Id, entity.ErrUnauthenticated},
+		{"Err not owner", ownerContext(2), owned.Id, entity.ErrForbidden},
+		{"Err no owner", ownerContext(1), anonymous.Id, entity.ErrForbidden},
+		{"Err not found", ownerContext(1), 987, entity.ErrItemNotExists},
+		{"OK owner", ownerContext(1), owned.Id, nil},
+		{"OK admin", admin, anonymous.Id, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adv := advert3
+			adv.Id = tt.id
+			adv.Name = tt.name
+			if err := service.Update(tt.ctx, adv); !errors.Is(err, tt.want) {
+				t.Fatalf("update: want: %v, got: %v", tt.want, err)
+			}
+			if err := service.Delete(tt.ctx, tt.id, 0); !errors.Is(err, tt.want) {
+				t.Fatalf("delete: want: %v, got: %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestApiKeys(t *testing.T) {
+	mockRepo := m.NewMockRepo()
+	mockKeys := m.NewMockApiKeyRepo()
+	service := service.NewAdvertService(mockRepo, m.NewMockCategoryRepo(), m.NewMockUserRepo(),
+		mockKeys, m.NewMockRoleRepo(), m.NewMockAuditRepo(),
+		m.NewMockIdempotencyRepo())
+	ctx := context.Background()
+
+	id, err := service.Register(ctx, "user@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := ownerContext(id)
+
+	if _, err = service.CreateApiKey(ctx, "bot", []string{entity.ScopeAdvertsRead}); !errors.Is(err,
+		entity.ErrUnauthenticated) {
+		t.Fatalf("want: %v, got: %v", entity.ErrUnauthenticated, err)
+	}
+	if _, err = service.CreateApiKey(user, "bot", []string{"adverts:all"}); !errors.Is(err,
+		entity.ErrWrongScope) {
+		t.Fatalf("want: %v, got: %v", entity.ErrWrongScope, err)
+	}
+
+	key, err := service.CreateApiKey(user, "bot", []string{entity.ScopeAdvertsRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Key == "" || mockKeys.Keys[0].KeyHash == key.Key {
+		t.Fatalf("key should be stored hashed: %+v", mockKeys.Keys[0])
+	}
+
+	t.Run("OK authenticate", func(t *testing.T) {
+		identity, err := service.Authenticate(ctx, key.Key)
+		want := entity.Identity{UserId: id, Email: "user@example.com", ApiKeyId: key.Id,
+			Scopes: []string{entity.ScopeAdvertsRead}}
+		if err != nil || !reflect.DeepEqual(identity, want) {
+			t.Fatalf("want: %v, got: %v, %v", want, identity, err)
+		}
+		if mockKeys.Keys[0].LastUsedAt == "" {
+			t.Fatal("last use of key should be recorded")
+		}
+	})
+
+	t.Run("Error scope and managing keys with key", func(t *testing.T) {
+		identity, err := service.Authenticate(ctx, key.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyCtx := context.WithValue(ctx, entity.KeyIdentity, identity)
+		if _, err = service.Create(keyCtx, advert1); !errors.Is(err, entity.ErrForbidden) {
+			t.Fatalf("want: %v, got: %v", entity.ErrForbidden, err)
+		}
+		if _, err = service.GetApiKeys(keyCtx); !errors.Is(err, entity.ErrForbidden) {
+			t.Fatalf("want: %v, got: %v", entity.ErrForbidden, err)
+		}
+		if err = service.CheckScope(keyCtx, entity.ScopeAdvertsRead, "adverts"); err != nil {
+			t.Fatal(err)
+		}
+		if err = service.CheckScope(keyCtx, entity.ScopeAdvertsWrite,
+			"adverts"); !errors.Is(err, entity.ErrForbidden) {
+			t.Fatalf("want: %v, got: %v", entity.ErrForbidden, err)
+		}
+	})
+
+	t.Run("Error key of other user", func(t *testing.T) {
+		if _, err := service.RotateApiKey(ownerContext(id+1), key.Id); !errors.Is(err,
+			entity.ErrItemNotExists) {
+			t.Fatalf("want: %v, got: %v", entity.ErrItemNotExists, err)
+		}
+	})
+
+	t.Run("OK rotate and revoke", func(t *testing.T) {
+		rotated, err := service.RotateApiKey(user, key.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = service.Authenticate(ctx, key.Key); !errors.Is(err, entity.ErrUnauthenticated) {
+			t.Fatalf("want: %v, got: %v", entity.ErrUnauthenticated, err)
+		}
+		if _, err = service.Authenticate(ctx, rotated.Key); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = service.RevokeApiKey(user, key.Id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = service.Authenticate(ctx, rotated.Key); !errors.Is(err,
+			entity.ErrUnauthenticated) {
+			t.Fatalf("want: %v, got: %v", entity.ErrUnauthenticated, err)
+		}
+		if err = service.RevokeApiKey(user, key.Id); !errors.Is(err, entity.ErrItemNotExists) {
+			t.Fatalf("want: %v, got: %v", entity.ErrItemNotExists, err)
+		}
+	})
+}
+
+func TestRoles(t *testing.T) {
+	mockRepo := m.NewMockRepo()
+	mockRoles := m.NewMockRoleRepo()
+	mockAudit := m.NewMockAuditRepo()
+	hide := string(service.ActionHideAdvert)
+	service := service.NewAdvertService(mockRepo, m.NewMockCategoryRepo(), m.NewMockUserRepo(),
+		m.NewMockApiKeyRepo(), mockRoles, mockAudit, m.NewMockIdempotencyRepo())
+	ctx := context.Background()
+
+	login := func(email string) (context.Context, int64) {
+		id, err := service.Register(ctx, email, "password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		session, err := service.Login(ctx, email, "password", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		identity, err := service.Authenticate(ctx, session.Token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return context.WithValue(ctx, entity.KeyIdentity, identity), id
+	}
+	owner, ownerId := login("owner@example.com")
+	mockRoles.UserRoles[2] = []string{entity.RoleModerator}
+	moderator, _ := login("moderator@example.com")
+	mockRoles.UserRoles[3] = []string{entity.RoleAdmin}
+	admin, _ := login("admin@example.com")
+
+	identity := moderator.Value(entity.KeyIdentity).(entity.Identity)
+	if !reflect.DeepEqual(identity.Permissions, []string{entity.PermissionHideAdvert}) {
+		t.Fatalf("want permissions of moderator, got: %v", identity.Permissions)
+	}
+
+	id, err := service.Create(owner, advert1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("OK moderator hides advert", func(t *testing.T) {
+		if err := service.HideAdvert(owner, id, true); !errors.Is(err, entity.ErrForbidden) {
+			t.Fatalf("want: %v, got: %v", entity.ErrForbidden, err)
+		}
+		if err := service.HideAdvert(moderator, id, true); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := service.GetById(ctx, id); !errors.Is(err, entity.ErrItemNotExists) {
+			t.Fatalf("want: %v, got: %v", entity.ErrItemNotExists, err)
+		}
+		if _, err := service.GetById(owner, id); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("OK decisions are audited", func(t *testing.T) {
+		want := entity.AuditEntry{UserId: ownerId, Action: hide,
+			Resource: fmt.Sprintf("adverts/%v", id)}
+		for _, entry := range mockAudit.Entries {
+			entry.Id, entry.CreatedAt = 0, ""
+			if entry == want {
+				return
+			}
+		}
+		t.Fatalf("want %+v in audit trail: %+v", want, mockAudit.Entries)
+	})
+
+	t.Run("OK admin assigns role", func(t *testing.T) {
+		err := service.AssignRole(moderator, ownerId, entity.RoleModerator)
+		if !errors.Is(err, entity.ErrForbidden) {
+			t.Fatalf("want: %v, got: %v", entity.ErrForbidden, err)
+		}
+		if err = service.AssignRole(admin, ownerId, "superuser"); !errors.Is(err,
+			entity.ErrWrongRole) {
+			t.Fatalf("want: %v, got: %v", entity.ErrWrongRole, err)
+		}
+		if err = service.AssignRole(admin, 987, entity.RoleModerator); !errors.Is(err,
+			entity.ErrItemNotExists) {
+			t.Fatalf("want: %v, got: %v", entity.ErrItemNotExists, err)
+		}
+		if err = service.AssignRole(admin, ownerId, entity.RoleModerator); err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := service.GetUser(admin, ownerId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(user.Roles, []string{entity.RoleModerator}) {
+			t.Fatalf("want roles: %v, got: %v", entity.RoleModerator, user.Roles)
+		}
+		if _, err = service.GetAudit(moderator); !errors.Is(err, entity.ErrForbidden) {
+			t.Fatalf("want: %v, got: %v", entity.ErrForbidden, err)
+		}
+	})
+}
+
+func TestIdempotency(t *testing.T) {
+	service := service.NewAdvertService(m.NewMockRepo(), m.NewMockCategoryRepo(),
+		m.NewMockUserRepo(), m.NewMockApiKeyRepo(), m.NewMockRoleRepo(), m.NewMockAuditRepo(),
+		m.NewMockIdempotencyRepo())
+	ctx := context.Background()
+
+	resp, err := service.ReserveIdempotencyKey(ctx, "user:1 key", "hash", time.Hour)
+	if err != nil || resp.Status != 0 {
+		t.Fatalf("key should be reserved: %+v, %v", resp, err)
+	}
+	if _, err = service.ReserveIdempotencyKey(ctx, "user:1 key", "hash",
+		time.Hour); !errors.Is(err, entity.ErrKeyInProgress) {
+		t.Fatalf("want: %v, got: %v", entity.ErrKeyInProgress, err)
+	}
+
+	if err = service.CompleteIdempotencyKey(ctx, "user:1 key", 201, []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = service.ReserveIdempotencyKey(ctx, "user:1 key", "hash", time.Hour)
+	if err != nil || resp.Status != 201 || string(resp.Body) != `{}` {
+		t.Fatalf("want stored response, got: %+v, %v", resp, err)
+	}
+	if _, err = service.ReserveIdempotencyKey(ctx, "user:1 key", "other hash",
+		time.Hour); !errors.Is(err, entity.ErrKeyReused) {
+		t.Fatalf("want: %v, got: %v", entity.ErrKeyReused, err)
+	}
+
+	if err = service.ReleaseIdempotencyKey(ctx, "user:1 key"); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err = service.ReserveIdempotencyKey(ctx, "user:1 key", "other hash",
+		time.Hour); err != nil || resp.Status != 0 {
+		t.Fatalf("released key should be reserved again: %+v, %v", resp, err)
+	}
 }
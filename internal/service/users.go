@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mrsubudei/adv-store-service/internal/entity"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// tokenLength is number of random bytes in session token
+const tokenLength = 32
+
+// dummyHash is compared with password of unknown user, so that
+// login takes the same time whether email is registered or not
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// Register creates user with given email and password, only hash of password is stored
+func (s *AdvertService) Register(ctx context.Context, email, password string) (int64, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, fmt.Errorf("AdvertService - Register - GenerateFromPassword: %w", err)
+	}
+
+	user := entity.User{
+		Email:        normalizeEmail(email),
+		PasswordHash: string(hash),
+		CreatedAt:    getTime(),
+	}
+	err = s.users.Store(ctx, &user)
+	if err != nil {
+		if errors.Is(err, entity.ErrEmailAlreadyExist) {
+			return 0, entity.ErrEmailAlreadyExist
+		}
+		return 0, fmt.Errorf("AdvertService - Register: %w", err)
+	}
+
+	return user.Id, nil
+}
+
+// Login checks password of user and starts session living for ttl,
+// token of session is returned only here
+func (s *AdvertService) Login(ctx context.Context, email, password string,
+	ttl time.Duration) (entity.Session, error) {
+	user, err := s.users.GetByEmail(ctx, normalizeEmail(email))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return entity.Session{}, fmt.Errorf("AdvertService - Login: %w", err)
+	}
+	hash := []byte(user.PasswordHash)
+	if err != nil {
+		hash = dummyHash
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || user.Id == 0 {
+		return entity.Session{}, entity.ErrWrongCredentials
+	}
+
+	token := make([]byte, tokenLength)
+	if _, err = rand.Read(token); err != nil {
+		return entity.Session{}, fmt.Errorf("AdvertService - Login - Read: %w", err)
+	}
+	now := time.Now()
+	session := entity.Session{
+		Token:     base64.RawURLEncoding.EncodeToString(token),
+		UserId:    user.Id,
+		CreatedAt: now.Format(DateFormat),
+		ExpiresAt: now.Add(ttl).Format(DateFormat),
+	}
+	session.TokenHash = hashToken(session.Token)
+
+	err = s.users.StoreSession(ctx, session)
+	if err != nil {
+		return entity.Session{}, fmt.Errorf("AdvertService - Login: %w", err)
+	}
+
+	return session, nil
+}
+
+// Logout ends session of given token
+func (s *AdvertService) Logout(ctx context.Context, token string) error {
+	err := s.users.DeleteSession(ctx, hashToken(token))
+	if err != nil {
+		return fmt.Errorf("AdvertService - Logout: %w", err)
+	}
+	return nil
+}
+
+// Authenticate returns identity of user whose session token or API key
+// is given, expired sessions are removed
+func (s *AdvertService) Authenticate(ctx context.Context, token string) (entity.Identity, error) {
+	if isApiKey(token) {
+		identity, err := s.authenticateKey(ctx, token)
+		if err != nil && !errors.Is(err, entity.ErrUnauthenticated) {
+			return identity, fmt.Errorf("AdvertService - Authenticate - %w", err)
+		}
+		return identity, err
+	}
+
+	tokenHash := hashToken(token)
+	session, err := s.users.GetSession(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.Identity{}, entity.ErrUnauthenticated
+		}
+		return entity.Identity{}, fmt.Errorf("AdvertService - Authenticate: %w", err)
+	}
+
+	expiresAt, err := time.ParseInLocation(DateFormat, session.ExpiresAt, time.Local)
+	if err != nil {
+		return entity.Identity{}, fmt.Errorf("AdvertService - Authenticate - Parse: %w", err)
+	}
+	if !time.Now().Before(expiresAt) {
+		err = s.users.DeleteSession(ctx, tokenHash)
+		if err != nil {
+			return entity.Identity{}, fmt.Errorf("AdvertService - Authenticate: %w", err)
+		}
+		return entity.Identity{}, entity.ErrUnauthenticated
+	}
+
+	user, err := s.users.GetById(ctx, session.UserId)
+	if err != nil {
+		return entity.Identity{}, fmt.Errorf("AdvertService - Authenticate: %w", err)
+	}
+
+	identity, err := s.identity(ctx, user)
+	if err != nil {
+		return entity.Identity{}, fmt.Errorf("AdvertService - Authenticate - %w", err)
+	}
+	return identity, nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// hashToken returns hash session is stored under, token itself
+// is random enough to not need salt or slow hashing
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
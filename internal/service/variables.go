@@ -1,8 +1,7 @@
 package service
 
 const (
-	UniqueNameConstraint = "UNIQUE constraint failed: adverts.name"
-	DateFormat           = "2006-01-02 15:04:05"
+	DateFormat = "2006-01-02 15:04:05"
 )
 
 type ContextKey string
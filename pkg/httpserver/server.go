@@ -2,6 +2,7 @@ package httpserver
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"time"
 
@@ -11,11 +12,14 @@ import (
 type Server struct {
 	httpServer *http.Server
 	h          *v1.Handler
+	// cancel cancels contexts of requests in flight on shutdown
+	cancel context.CancelFunc
 }
 
 const DefaultTime = int(time.Second)
 
 func NewServer(handler *v1.Handler) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Server{
 		httpServer: &http.Server{
 			Addr: handler.Cfg.Server.Port,
@@ -24,8 +28,12 @@ func NewServer(handler *v1.Handler) *Server {
 			WriteTimeout: time.Duration(handler.Cfg.Server.WriteTimeout *
 				DefaultTime),
 			Handler: handler.Mux,
+			BaseContext: func(net.Listener) context.Context {
+				return ctx
+			},
 		},
-		h: handler,
+		h:      handler,
+		cancel: cancel,
 	}
 }
 
@@ -38,5 +46,6 @@ func (s *Server) Shutdown() error {
 	ctx, cancel := context.WithTimeout(context.Background(),
 		time.Duration(s.h.Cfg.Server.ShutDownTimeout*DefaultTime))
 	defer cancel()
+	defer s.cancel()
 	return s.httpServer.Shutdown(ctx)
 }
@@ -0,0 +1,291 @@
+// Package jsonschema validates JSON documents against subset of JSON Schema.
+//
+// Supported keywords are type, enum, properties, required,
+// additionalProperties, minimum, maximum, minLength, maxLength, pattern,
+// items, minItems and maxItems, other keywords are ignored.
+// Violations are reported with JSON pointer to the wrong value.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+)
+
+var ErrNotValid = errors.New("schema is not valid")
+
+var types = map[string]bool{
+	"object":  true,
+	"array":   true,
+	"string":  true,
+	"number":  true,
+	"integer": true,
+	"boolean": true,
+	"null":    true,
+}
+
+type Schema struct {
+	types      []string
+	enum       []interface{}
+	properties map[string]*Schema
+	required   []string
+	// additional is nil if additional properties are allowed
+	// without restriction
+	additional *Schema
+	forbidden  bool
+	minimum    *float64
+	maximum    *float64
+	minLength  *int
+	maxLength  *int
+	pattern    *regexp.Regexp
+	items      *Schema
+	minItems   *int
+	maxItems   *int
+}
+
+type document struct {
+	Type                 json.RawMessage            `json:"type"`
+	Enum                 []interface{}              `json:"enum"`
+	Properties           map[string]json.RawMessage `json:"properties"`
+	Required             []string                   `json:"required"`
+	AdditionalProperties json.RawMessage            `json:"additionalProperties"`
+	Minimum              *float64                   `json:"minimum"`
+	Maximum              *float64                   `json:"maximum"`
+	MinLength            *int                       `json:"minLength"`
+	MaxLength            *int                       `json:"maxLength"`
+	Pattern              *string                    `json:"pattern"`
+	Items                json.RawMessage            `json:"items"`
+	MinItems             *int                       `json:"minItems"`
+	MaxItems             *int                       `json:"maxItems"`
+}
+
+// Compile parses schema, errors wrap ErrNotValid
+func Compile(data []byte) (*Schema, error) {
+	s, err := compile(data, "")
+	if err != nil {
+		return nil, fmt.Errorf("jsonschema - Compile: %w", err)
+	}
+	return s, nil
+}
+
+func compile(data []byte, path string) (*Schema, error) {
+	if string(bytes.TrimSpace(data)) == "true" {
+		return &Schema{}, nil
+	}
+
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v: %v", ErrNotValid, pointer(path), err)
+	}
+
+	s := &Schema{
+		enum:      doc.Enum,
+		required:  doc.Required,
+		minimum:   doc.Minimum,
+		maximum:   doc.Maximum,
+		minLength: doc.MinLength,
+		maxLength: doc.MaxLength,
+		minItems:  doc.MinItems,
+		maxItems:  doc.MaxItems,
+	}
+
+	if doc.Type != nil {
+		var one string
+		if err := json.Unmarshal(doc.Type, &one); err == nil {
+			s.types = []string{one}
+		} else if err = json.Unmarshal(doc.Type, &s.types); err != nil {
+			return nil, fmt.Errorf("%w: %v: type should be string or array of strings",
+				ErrNotValid, pointer(path))
+		}
+		for _, t := range s.types {
+			if !types[t] {
+				return nil, fmt.Errorf("%w: %v: unknown type %q", ErrNotValid, pointer(path), t)
+			}
+		}
+	}
+
+	if doc.Pattern != nil {
+		re, err := regexp.Compile(*doc.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v: %v", ErrNotValid, pointer(path), err)
+		}
+		s.pattern = re
+	}
+
+	if len(doc.Properties) != 0 {
+		s.properties = map[string]*Schema{}
+	}
+	for name, raw := range doc.Properties {
+		prop, err := compile(raw, path+"/properties/"+name)
+		if err != nil {
+			return nil, err
+		}
+		s.properties[name] = prop
+	}
+
+	switch string(bytes.TrimSpace(doc.AdditionalProperties)) {
+	case "", "true":
+	case "false":
+		s.forbidden = true
+	default:
+		additional, err := compile(doc.AdditionalProperties, path+"/additionalProperties")
+		if err != nil {
+			return nil, err
+		}
+		s.additional = additional
+	}
+
+	if doc.Items != nil {
+		items, err := compile(doc.Items, path+"/items")
+		if err != nil {
+			return nil, err
+		}
+		s.items = items
+	}
+
+	return s, nil
+}
+
+// Validate checks JSON document and returns violations of schema,
+// nil means document is valid
+func (s *Schema) Validate(data []byte) ([]string, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("jsonschema - Validate - Unmarshal: %w", err)
+	}
+
+	var violations []string
+	s.validate(v, "", &violations)
+	return violations, nil
+}
+
+func (s *Schema) validate(v interface{}, path string, violations *[]string) {
+	report := func(format string, args ...interface{}) {
+		*violations = append(*violations, pointer(path)+": "+fmt.Sprintf(format, args...))
+	}
+
+	if len(s.types) != 0 && !s.hasType(v) {
+		if len(s.types) == 1 {
+			report("should be %v", s.types[0])
+		} else {
+			report("should be one of types %v", s.types)
+		}
+		return
+	}
+
+	if s.enum != nil {
+		found := false
+		for _, e := range s.enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			report("should be one of %v", s.enum)
+		}
+	}
+
+	switch value := v.(type) {
+	case float64:
+		if s.minimum != nil && value < *s.minimum {
+			report("should be at least %v", *s.minimum)
+		}
+		if s.maximum != nil && value > *s.maximum {
+			report("should be at most %v", *s.maximum)
+		}
+	case string:
+		length := utf8.RuneCountInString(value)
+		if s.minLength != nil && length < *s.minLength {
+			report("should have at least %v characters", *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			report("should have at most %v characters", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(value) {
+			report("should match pattern %v", s.pattern)
+		}
+	case []interface{}:
+		if s.minItems != nil && len(value) < *s.minItems {
+			report("should have at least %v items", *s.minItems)
+		}
+		if s.maxItems != nil && len(value) > *s.maxItems {
+			report("should have at most %v items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range value {
+				s.items.validate(item, fmt.Sprintf("%v/%v", path, i), violations)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, ok := value[name]; !ok {
+				report("property %q is required", name)
+			}
+		}
+
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			prop, ok := s.properties[name]
+			switch {
+			case ok:
+				prop.validate(value[name], path+"/"+name, violations)
+			case s.forbidden:
+				report("property %q is not allowed", name)
+			case s.additional != nil:
+				s.additional.validate(value[name], path+"/"+name, violations)
+			}
+		}
+	}
+}
+
+func (s *Schema) hasType(v interface{}) bool {
+	for _, t := range s.types {
+		switch value := v.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case float64:
+			if t == "number" || t == "integer" && value == math.Trunc(value) {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pointer shows empty path as root
+func pointer(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
@@ -0,0 +1,311 @@
+// Package migrate applies numbered up and down sql migrations and keeps
+// track of applied ones in schema_migrations table.
+//
+// Migrations are read from files named like 0001_create_adverts.up.sql
+// and 0001_create_adverts.down.sql, every migration is applied
+// in its own transaction.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownVersion = errors.New("database has migration unknown to application")
+	ErrPending        = errors.New("database has migrations which are not applied")
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status shows if migration is applied, AppliedAt is zero for pending ones
+type Status struct {
+	Migration
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New reads migrations from root of fsys
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	files, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("migrate - New - ReadDir: %w", err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, file := range files {
+		parts := fileName.FindStringSubmatch(file.Name())
+		if parts == nil {
+			continue
+		}
+		version, err := strconv.Atoi(parts[1])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migrate - New - %v: wrong version", file.Name())
+		}
+		data, err := fs.ReadFile(fsys, file.Name())
+		if err != nil {
+			return nil, fmt.Errorf("migrate - New - ReadFile: %w", err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = migration
+		} else if migration.Name != parts[2] {
+			return nil, fmt.Errorf("migrate - New - %v: version %v is used twice",
+				file.Name(), version)
+		}
+		if parts[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	m := &Migrator{db: db}
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migrate - New - %04d_%v: up migration is missing",
+				migration.Version, migration.Name)
+		}
+		m.migrations = append(m.migrations, *migration)
+	}
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+
+	return m, nil
+}
+
+// Latest returns version of the last known migration
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns version of the last applied migration
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("migrate - Version - %w", err)
+	}
+
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("migrate - Status - %w", err)
+	}
+
+	statuses := []Status{}
+	for _, migration := range m.migrations {
+		statuses = append(statuses, Status{
+			Migration: migration,
+			AppliedAt: applied[migration.Version],
+		})
+	}
+	return statuses, nil
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up(ctx context.Context) error {
+	if err := m.To(ctx, m.Latest()); err != nil {
+		return fmt.Errorf("migrate - Up - %w", err)
+	}
+	return nil
+}
+
+// Down reverts the last applied migration
+func (m *Migrator) Down(ctx context.Context) error {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate - Down - %w", err)
+	}
+	if version == 0 {
+		return nil
+	}
+
+	target := 0
+	for _, migration := range m.migrations {
+		if migration.Version < version {
+			target = migration.Version
+		}
+	}
+	if err = m.To(ctx, target); err != nil {
+		return fmt.Errorf("migrate - Down - %w", err)
+	}
+	return nil
+}
+
+// To applies or reverts migrations until database is at given version
+func (m *Migrator) To(ctx context.Context, version int) error {
+	if version < 0 || version > m.Latest() {
+		return fmt.Errorf("migrate - To - %v: no such version", version)
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate - To - %w", err)
+	}
+	if err = m.checkKnown(applied); err != nil {
+		return fmt.Errorf("migrate - To - %w", err)
+	}
+
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok && migration.Version <= version {
+			if err = m.apply(ctx, migration, true); err != nil {
+				return fmt.Errorf("migrate - To - %w", err)
+			}
+		}
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; ok && migration.Version > version {
+			if err = m.apply(ctx, migration, false); err != nil {
+				return fmt.Errorf("migrate - To - %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Check returns error listing migrations which are not applied to database or
+// versions applied to database which application does not know about
+func (m *Migrator) Check(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate - Check - %w", err)
+	}
+	if err = m.checkKnown(applied); err != nil {
+		return fmt.Errorf("migrate - Check - %w", err)
+	}
+
+	pending := []string{}
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, fmt.Sprintf("%04d_%v", migration.Version, migration.Name))
+		}
+	}
+	if len(pending) != 0 {
+		return fmt.Errorf("migrate - Check - %v: %w", strings.Join(pending, ", "), ErrPending)
+	}
+	return nil
+}
+
+func (m *Migrator) checkKnown(applied map[int]time.Time) error {
+	known := map[int]bool{}
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+	unknown := []string{}
+	for version := range applied {
+		if !known[version] {
+			unknown = append(unknown, fmt.Sprintf("%04d", version))
+		}
+	}
+	if len(unknown) != 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("checkKnown - %v: %w", strings.Join(unknown, ", "), ErrUnknownVersion)
+	}
+	return nil
+}
+
+// apply runs up or down part of migration and records it in the same transaction,
+// version and name are interpolated as they are checked by file name pattern
+func (m *Migrator) apply(ctx context.Context, migration Migration, up bool) error {
+	script := migration.Up
+	record := fmt.Sprintf(`INSERT INTO schema_migrations(version, name) VALUES (%d, '%s')`,
+		migration.Version, migration.Name)
+	if !up {
+		if migration.Down == "" {
+			return fmt.Errorf("apply - %04d_%v: down migration is missing",
+				migration.Version, migration.Name)
+		}
+		script = migration.Down
+		record = fmt.Sprintf(`DELETE FROM schema_migrations WHERE version = %d`,
+			migration.Version)
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("apply - BeginTx: %w", err)
+	}
+	defer func() {
+		err = tx.Rollback()
+	}()
+
+	if _, err = tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("apply - %04d_%v: %w", migration.Version, migration.Name, err)
+	}
+	if _, err = tx.ExecContext(ctx, record); err != nil {
+		return fmt.Errorf("apply - ExecContext: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("apply - Commit: %w", err)
+	}
+	return nil
+}
+
+// applied returns versions of applied migrations with time they were applied at
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return nil, fmt.Errorf("applied - ExecContext: %w", err)
+	}
+
+	rows, err := m.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("applied - QueryContext: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("applied - Scan: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("applied - Rows: %w", err)
+	}
+
+	return applied, nil
+}
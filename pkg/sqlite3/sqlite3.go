@@ -15,6 +15,12 @@ func New(path string) (*Sqlite, error) {
 	if err != nil {
 		return nil, err
 	}
+	// sqlite allows single writer, write transactions of concurrent connections
+	// fail with "database is locked" when full-text index makes them longer and
+	// otherwise wait for each other sleeping in busy handler. On one connection
+	// queries wait in database/sql until it is free or request deadline comes,
+	// so repositories must not query DB while their transaction holds it.
+	db.SetMaxOpenConns(1)
 	return &Sqlite{
 		DB: db,
 	}, nil